@@ -0,0 +1,5 @@
+// Package voterclient is generated from the voter API's OpenAPI document.
+// Run go generate after changing voter-api/api/openapi.json.
+package voterclient
+
+//go:generate go run ../../cmd/clientgen -spec ../../../voter-api/api/openapi.json -package voterclient -out client.gen.go
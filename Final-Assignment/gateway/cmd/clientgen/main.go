@@ -0,0 +1,511 @@
+// Command clientgen generates a Go client package from one of the
+// services' OpenAPI documents. It covers the subset of OpenAPI used by
+// this project: object schemas, $refs, path and query parameters, JSON
+// bodies and responses. It is run through go generate in client/.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+type schema struct {
+	Ref         string            `json:"$ref"`
+	Type        string            `json:"type"`
+	Format      string            `json:"format"`
+	Description string            `json:"description"`
+	Minimum     *float64          `json:"minimum"`
+	Items       *schema           `json:"items"`
+	Properties  orderedProperties `json:"properties"`
+	Required    []string          `json:"required"`
+	OneOf       []*schema         `json:"oneOf"`
+}
+
+// orderedProperties keeps the order properties are declared in so the
+// generated struct fields follow the document
+type orderedProperties struct {
+	Names   []string
+	Schemas map[string]*schema
+}
+
+func (p *orderedProperties) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return errors.New("properties must be an object")
+	}
+
+	p.Schemas = map[string]*schema{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		property := &schema{}
+		if err := decoder.Decode(property); err != nil {
+			return err
+		}
+		p.Names = append(p.Names, name)
+		p.Schemas[name] = property
+	}
+	return nil
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type document struct {
+	Info struct {
+		Title string `json:"title"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Template data
+
+type field struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+type structType struct {
+	Name        string
+	Description string
+	Fields      []field
+}
+
+type param struct {
+	Name   string
+	GoName string
+	Type   string
+	// Go expression that formats the value for the URL
+	Value string
+}
+
+type method struct {
+	Name            string
+	Summary         string
+	HTTPMethod      string
+	PathExpression  string
+	PathParams      []param
+	QueryParams     []param
+	BodyType        string
+	RawBody         bool
+	ResultType      string
+	HasQueryStruct  bool
+	QueryStructName string
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+func main() {
+	specFlag := flag.String("spec", "", "OpenAPI document to read")
+	packageFlag := flag.String("package", "", "Name of the generated package")
+	outFlag := flag.String("out", "client.gen.go", "File to write")
+	flag.Parse()
+
+	if *specFlag == "" || *packageFlag == "" {
+		log.Fatal("Error: -spec and -package are required")
+	}
+
+	source, err := os.ReadFile(*specFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var doc document
+	if err := json.Unmarshal(source, &doc); err != nil {
+		log.Fatal("Error parsing ", *specFlag, ": ", err)
+	}
+
+	code, err := generate(doc, *packageFlag, filepath.ToSlash(*specFlag))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*outFlag, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(doc document, packageName string, specName string) ([]byte, error) {
+	var types []structType
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		definition := doc.Components.Schemas[name]
+		if definition.Type != "object" || len(definition.Properties.Names) == 0 {
+			continue
+		}
+		types = append(types, buildStruct(name, definition))
+	}
+
+	var methods []method
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, httpMethod := range methodOrder {
+			rawOperation, ok := doc.Paths[path][httpMethod]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(rawOperation, &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", httpMethod, path, err)
+			}
+			methods = append(methods, buildMethod(path, httpMethod, op))
+		}
+	}
+
+	var out bytes.Buffer
+	err := clientTemplate.Execute(&out, map[string]interface{}{
+		"Package": packageName,
+		"Spec":    strings.TrimLeft(specName, "./"),
+		"Title":   doc.Info.Title,
+		"Types":   types,
+		"Methods": methods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return formatted, nil
+}
+
+func buildStruct(name string, definition *schema) structType {
+	required := map[string]bool{}
+	for _, property := range definition.Required {
+		required[property] = true
+	}
+
+	result := structType{Name: goName(name), Description: definition.Description}
+	for _, property := range definition.Properties.Names {
+		propertyType := goType(definition.Properties.Schemas[property])
+		tag := property
+		// Empty arrays are kept because a replacement needs to be able
+		// to clear a list
+		if !required[property] && !strings.HasPrefix(propertyType, "[]") {
+			tag += ",omitempty"
+		}
+		result.Fields = append(result.Fields, field{
+			Name: goName(property),
+			Type: propertyType,
+			Tag:  fmt.Sprintf("`json:%q`", tag),
+		})
+	}
+	return result
+}
+
+// formatValue is a Go expression formatting the parameter expression of
+// type goType the way the api parses it
+func formatValue(goType string, expression string) string {
+	if goType == "time.Time" {
+		if strings.HasPrefix(expression, "*") {
+			expression = "(" + expression + ")"
+		}
+		return expression + ".Format(time.RFC3339Nano)"
+	}
+	return "fmt.Sprint(" + expression + ")"
+}
+
+func buildMethod(path string, httpMethod string, op operation) method {
+	result := method{
+		Name:       goName(op.OperationID),
+		Summary:    op.Summary,
+		HTTPMethod: strings.ToUpper(httpMethod),
+	}
+
+	pathValues := map[string]string{}
+	for _, p := range op.Parameters {
+		converted := param{Name: lowerFirst(goName(p.Name)), GoName: goName(p.Name), Type: goType(p.Schema)}
+		switch p.In {
+		case "path":
+			pathValues[p.Name] = formatValue(converted.Type, converted.Name)
+			result.PathParams = append(result.PathParams, converted)
+		case "query":
+			converted.Value = formatValue(converted.Type, "*params."+converted.GoName)
+			converted.Name = p.Name
+			converted.Type = "*" + converted.Type
+			result.QueryParams = append(result.QueryParams, converted)
+		}
+	}
+	if len(result.QueryParams) > 0 {
+		result.HasQueryStruct = true
+		result.QueryStructName = result.Name + "Params"
+	}
+
+	// Build the path as a Go string expression
+	var expression []string
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.Trim(segment, "{}")
+			value, ok := pathValues[name]
+			if !ok {
+				value = formatValue("", lowerFirst(goName(name)))
+			}
+			expression = append(expression, `"/" + url.PathEscape(`+value+`)`)
+		} else {
+			expression = append(expression, fmt.Sprintf("%q", "/"+segment))
+		}
+	}
+	result.PathExpression = strings.Join(expression, " + ")
+
+	if op.RequestBody != nil {
+		if body, ok := op.RequestBody.Content["application/json"]; ok {
+			result.BodyType = goType(body.Schema)
+		} else {
+			result.RawBody = true
+		}
+	}
+
+	if response, ok := op.Responses["200"]; ok {
+		if content, ok := response.Content["application/json"]; ok {
+			result.ResultType = goType(content.Schema)
+		} else if len(response.Content) > 0 {
+			result.ResultType = "json.RawMessage"
+		}
+	}
+
+	return result
+}
+
+func goType(s *schema) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Ref != "" {
+		return goName(s.Ref[strings.LastIndex(s.Ref, "/")+1:])
+	}
+	if len(s.OneOf) > 0 {
+		return "json.RawMessage"
+	}
+
+	switch s.Type {
+	case "integer":
+		if s.Minimum != nil && *s.Minimum >= 0 {
+			return "uint"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time"
+		}
+		return "string"
+	case "array":
+		return "[]" + goType(s.Items)
+	case "object":
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+func goName(name string) string {
+	var out strings.Builder
+	upperNext := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by clientgen from {{.Spec}}. DO NOT EDIT.
+
+// Package {{.Package}} is a client for the {{.Title}}.
+package {{.Package}}
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var _ = time.Time{}
+{{range .Types}}
+{{if .Description}}// {{.Name}}: {{.Description}}
+{{end}}type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}
+{{- end}}
+}
+{{end}}
+// RequestEditorFn can change a request before it is sent, e.g. to add
+// credentials
+type RequestEditorFn func(req *http.Request) error
+
+// APIError is returned for any response that is not a 200
+type APIError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, string(e.Body))
+}
+
+type Client struct {
+	// Server is the base URL of the service, e.g. http://localhost:1080
+	Server         string
+	HTTPClient     *http.Client
+	RequestEditors []RequestEditorFn
+}
+
+func New(server string, editors ...RequestEditorFn) *Client {
+	return &Client{Server: server, HTTPClient: http.DefaultClient, RequestEditors: editors}
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, contentType string, body io.Reader, result interface{}, editors []RequestEditorFn) error {
+	target := c.Server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	for _, editor := range append(append([]RequestEditorFn{}, c.RequestEditors...), editors...) {
+		if err := editor(req); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode, Body: data}
+	}
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+func jsonBody(value interface{}) (io.Reader, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+{{range .Methods}}{{$method := .}}
+{{- if .HasQueryStruct}}
+// {{.QueryStructName}} holds the optional query parameters of {{.Name}}
+type {{.QueryStructName}} struct {
+{{- range .QueryParams}}
+	{{.GoName}} {{.Type}}
+{{- end}}
+}
+{{end}}
+// {{.Name}}: {{.Summary}}
+func (c *Client) {{.Name}}(ctx context.Context{{range .PathParams}}, {{.Name}} {{.Type}}{{end}}{{if .HasQueryStruct}}, params *{{.QueryStructName}}{{end}}{{if .BodyType}}, body {{.BodyType}}{{end}}{{if .RawBody}}, contentType string, body io.Reader{{end}}, editors ...RequestEditorFn) ({{if .ResultType}}{{.ResultType}}, {{end}}error) {
+	{{- if .ResultType}}
+	var result {{.ResultType}}
+	{{- end}}
+	query := url.Values{}
+	{{- if .HasQueryStruct}}
+	if params != nil {
+		{{- range .QueryParams}}
+		if params.{{.GoName}} != nil {
+			query.Set("{{.Name}}", {{.Value}})
+		}
+		{{- end}}
+	}
+	{{- end}}
+	{{- if .BodyType}}
+	reader, err := jsonBody(body)
+	if err != nil {
+		return {{if .ResultType}}result, {{end}}err
+	}
+	err = c.do(ctx, "{{.HTTPMethod}}", {{.PathExpression}}, query, "application/json", reader, {{if .ResultType}}&result{{else}}nil{{end}}, editors)
+	{{- else if .RawBody}}
+	err := c.do(ctx, "{{.HTTPMethod}}", {{.PathExpression}}, query, contentType, body, {{if .ResultType}}&result{{else}}nil{{end}}, editors)
+	{{- else}}
+	err := c.do(ctx, "{{.HTTPMethod}}", {{.PathExpression}}, query, "", nil, {{if .ResultType}}&result{{else}}nil{{end}}, editors)
+	{{- end}}
+	return {{if .ResultType}}result, {{end}}err
+}
+{{end}}`))
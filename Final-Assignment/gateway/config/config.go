@@ -0,0 +1,110 @@
+// Package config is the typed configuration of the gateway. Settings come
+// from the defaults below, then the YAML or TOML file named by -config or
+// CONFIG_FILE, then the environment, then command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+
+	"gateway/logging"
+	"shared/configloader"
+	"shared/httpserver"
+	"shared/telemetry"
+)
+
+const (
+	VotesDefaultLocation  = "0.0.0.0:1080"
+	VotersDefaultLocation = "0.0.0.0:1081"
+	PollsDefaultLocation  = "0.0.0.0:1082"
+)
+
+type Config struct {
+	Host      string `config:"host" env:"HOST" flag:"h" usage:"Listen address"`
+	Port      uint   `config:"port" env:"PORT" flag:"p" usage:"Port"`
+	LogLevel  string `config:"log_level" env:"LOG_LEVEL" usage:"debug, info, warn or error" reload:"true"`
+	LogFormat string `config:"log_format" env:"LOG_FORMAT" usage:"json or text"`
+	// The gateway has no auth of its own, so /debug/config is only served
+	// when asked for
+	DebugEndpoints bool `config:"debug_endpoints" env:"DEBUG_ENDPOINTS" usage:"Serve /debug/config"`
+
+	Services Services          `config:"services"`
+	HTTP     httpserver.Config `config:"http"`
+	Tracing  telemetry.Config  `config:"tracing"`
+}
+
+// Services holds the host:port of each api the gateway resolves against
+type Services struct {
+	VotesURL  string `config:"votes_url" env:"VOTES_URL" usage:"host:port of the vote api"`
+	VotersURL string `config:"voters_url" env:"VOTERS_URL" usage:"host:port of the voter api"`
+	PollsURL  string `config:"polls_url" env:"POLLS_URL" usage:"host:port of the poll api"`
+}
+
+func Defaults() Config {
+	return Config{
+		Host:      "0.0.0.0",
+		Port:      1083,
+		LogLevel:  "info",
+		LogFormat: logging.FormatJSON,
+		Services: Services{
+			VotesURL:  VotesDefaultLocation,
+			VotersURL: VotersDefaultLocation,
+			PollsURL:  PollsDefaultLocation,
+		},
+		HTTP:    httpserver.Defaults(),
+		Tracing: telemetry.Defaults(),
+	}
+}
+
+// Parse registers the flags on flags, parses args and loads the config
+func Parse(flags *flag.FlagSet, args []string) (Config, *configloader.Sources, error) {
+	config := Defaults()
+	sources := configloader.RegisterFlags(flags, &config)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	config, err := Reload(sources)
+	return config, sources, err
+}
+
+// Reload loads the config again from the same sources
+func Reload(sources *configloader.Sources) (Config, error) {
+	config := Defaults()
+	if err := sources.Load(&config); err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Live is the running config, reloaded from the sources it was parsed from
+type Live = configloader.Live[Config]
+
+func NewLive(current Config, sources *configloader.Sources) *Live {
+	return configloader.NewLive(current, func() (Config, error) {
+		return Reload(sources)
+	})
+}
+
+// Validate reports every invalid setting at once
+func (config Config) Validate() error {
+	var errs []error
+	if config.Port == 0 {
+		errs = append(errs, errors.New("Error: port must be set"))
+	}
+	if _, err := logging.ParseLevel(config.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if err := logging.ValidateFormat(config.LogFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if config.Services.VotesURL == "" || config.Services.VotersURL == "" || config.Services.PollsURL == "" {
+		errs = append(errs, errors.New("Error: VOTES_URL, VOTERS_URL and POLLS_URL must be set"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Credentials passed on to the REST apis with every downstream call
+var forwardedHeaders = []string{"Authorization", "X-API-Key"}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler executes GraphQL requests sent as JSON in a POST body. Errors
+// from the REST apis are reported in the response's errors list.
+func Handler(schema *graphql.Schema, services *Services) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var params request
+		if err := c.ShouldBindJSON(&params); err != nil {
+			slog.WarnContext(c.Request.Context(), "Error decoding GraphQL request", "error", err)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		header := http.Header{}
+		for _, name := range forwardedHeaders {
+			if value := c.GetHeader(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+
+		ctx := withLoaders(c.Request.Context(), newLoaders(services, header))
+		response := schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+		c.JSON(http.StatusOK, response)
+	}
+}
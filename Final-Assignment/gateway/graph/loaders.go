@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gateway/client/pollclient"
+	"gateway/client/voteclient"
+	"gateway/client/voterclient"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+const (
+	// How long a loader waits for more keys before sending a batch
+	batchWait = 2 * time.Millisecond
+	// Batches larger than this are served from one list call instead of
+	// one request per record
+	listThreshold = 5
+)
+
+// Services are the REST apis the gateway resolves against
+type Services struct {
+	Voters *voterclient.Client
+	Polls  *pollclient.Client
+	Votes  *voteclient.Client
+}
+
+// loaders batch and cache the lookups of a single GraphQL request. They
+// are created per request so nothing is shared between callers and every
+// downstream call carries the caller's own credentials.
+type loaders struct {
+	services *Services
+	header   http.Header
+	voters   *dataloader.Loader[uint, voterclient.Voter]
+	polls    *dataloader.Loader[uint, pollclient.Poll]
+	results  *dataloader.Loader[uint, voteclient.ResultsReport]
+
+	votesOnce  sync.Once
+	votes      []voteclient.Vote
+	votesError error
+}
+
+type loadersKey struct{}
+
+func newLoaders(services *Services, header http.Header) *loaders {
+	l := &loaders{services: services, header: header}
+	l.voters = dataloader.NewBatchedLoader(l.loadVoters, dataloader.WithWait[uint, voterclient.Voter](batchWait))
+	l.polls = dataloader.NewBatchedLoader(l.loadPolls, dataloader.WithWait[uint, pollclient.Poll](batchWait))
+	l.results = dataloader.NewBatchedLoader(l.loadResults, dataloader.WithWait[uint, voteclient.ResultsReport](batchWait))
+	return l
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// forward copies the caller's credentials onto a downstream request
+func (l *loaders) forward(req *http.Request) error {
+	for name, values := range l.header {
+		req.Header[name] = values
+	}
+	return nil
+}
+
+func (l *loaders) voter(ctx context.Context, voterID uint) (voterclient.Voter, error) {
+	return l.voters.Load(ctx, voterID)()
+}
+
+func (l *loaders) poll(ctx context.Context, pollID uint) (pollclient.Poll, error) {
+	return l.polls.Load(ctx, pollID)()
+}
+
+func (l *loaders) pollResults(ctx context.Context, pollID uint) (voteclient.ResultsReport, error) {
+	return l.results.Load(ctx, pollID)()
+}
+
+// allVotes lists the votes once per request; the vote api cannot filter
+// them by voter or poll
+func (l *loaders) allVotes(ctx context.Context) ([]voteclient.Vote, error) {
+	l.votesOnce.Do(func() {
+		l.votes, l.votesError = l.services.Votes.ListVotes(ctx, l.forward)
+	})
+	return l.votes, l.votesError
+}
+
+func (l *loaders) loadVoters(ctx context.Context, voterIDs []uint) []*dataloader.Result[voterclient.Voter] {
+	if len(voterIDs) > listThreshold {
+		voters, err := l.services.Voters.ListVoters(ctx, l.forward)
+		byID := map[uint]voterclient.Voter{}
+		for _, voter := range voters {
+			byID[voter.VoterID] = voter
+		}
+		return fromList(voterIDs, byID, err, "voter")
+	}
+
+	return loadEach(ctx, voterIDs, func(ctx context.Context, voterID uint) (voterclient.Voter, error) {
+		return l.services.Voters.GetVoter(ctx, voterID, l.forward)
+	})
+}
+
+func (l *loaders) loadPolls(ctx context.Context, pollIDs []uint) []*dataloader.Result[pollclient.Poll] {
+	if len(pollIDs) > listThreshold {
+		polls, err := l.services.Polls.ListPolls(ctx, l.forward)
+		byID := map[uint]pollclient.Poll{}
+		for _, poll := range polls {
+			byID[poll.PollID] = poll
+		}
+		return fromList(pollIDs, byID, err, "poll")
+	}
+
+	return loadEach(ctx, pollIDs, func(ctx context.Context, pollID uint) (pollclient.Poll, error) {
+		return l.services.Polls.GetPoll(ctx, pollID, l.forward)
+	})
+}
+
+// loadResults asks the vote api for each poll's results, which it counts
+// itself; there is no route for the results of several polls at once
+func (l *loaders) loadResults(ctx context.Context, pollIDs []uint) []*dataloader.Result[voteclient.ResultsReport] {
+	return loadEach(ctx, pollIDs, func(ctx context.Context, pollID uint) (voteclient.ResultsReport, error) {
+		return l.services.Votes.GetPollResults(ctx, pollID, nil, l.forward)
+	})
+}
+
+// loadEach fetches the keys of a batch concurrently
+func loadEach[V any](ctx context.Context, keys []uint, get func(context.Context, uint) (V, error)) []*dataloader.Result[V] {
+	results := make([]*dataloader.Result[V], len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key uint) {
+			defer wg.Done()
+			value, err := get(ctx, key)
+			results[i] = &dataloader.Result[V]{Data: value, Error: err}
+		}(i, key)
+	}
+	wg.Wait()
+	return results
+}
+
+func fromList[V any](keys []uint, byID map[uint]V, err error, kind string) []*dataloader.Result[V] {
+	results := make([]*dataloader.Result[V], len(keys))
+	for i, key := range keys {
+		value, ok := byID[key]
+		switch {
+		case err != nil:
+			results[i] = &dataloader.Result[V]{Error: err}
+		case !ok:
+			results[i] = &dataloader.Result[V]{Error: fmt.Errorf("%s %d not found", kind, key)}
+		default:
+			results[i] = &dataloader.Result[V]{Data: value}
+		}
+	}
+	return results
+}
@@ -0,0 +1,485 @@
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"gateway/client/pollclient"
+	"gateway/client/voteclient"
+	"gateway/client/voterclient"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+// Resolver is the root of the schema. Queries and mutations are resolved
+// by calling the REST apis; relationships go through the request's
+// loaders.
+type Resolver struct {
+	services *Services
+}
+
+func NewSchema(services *Services) (*graphql.Schema, error) {
+	return graphql.ParseSchema(Schema, &Resolver{services: services})
+}
+
+// Queries
+
+func (r *Resolver) Poll(ctx context.Context, args struct{ ID graphql.ID }) (*pollResolver, error) {
+	pollID, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	poll, err := loadersFrom(ctx).poll(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	return &pollResolver{poll: poll}, nil
+}
+
+func (r *Resolver) Polls(ctx context.Context) ([]*pollResolver, error) {
+	l := loadersFrom(ctx)
+	polls, err := r.services.Polls.ListPolls(ctx, l.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*pollResolver, 0, len(polls))
+	for _, poll := range polls {
+		l.polls.Prime(ctx, poll.PollID, poll)
+		resolvers = append(resolvers, &pollResolver{poll: poll})
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) Voter(ctx context.Context, args struct{ ID graphql.ID }) (*voterResolver, error) {
+	voterID, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	voter, err := loadersFrom(ctx).voter(ctx, voterID)
+	if err != nil {
+		return nil, err
+	}
+	return &voterResolver{voter: voter}, nil
+}
+
+func (r *Resolver) Voters(ctx context.Context) ([]*voterResolver, error) {
+	l := loadersFrom(ctx)
+	voters, err := r.services.Voters.ListVoters(ctx, l.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*voterResolver, 0, len(voters))
+	for _, voter := range voters {
+		l.voters.Prime(ctx, voter.VoterID, voter)
+		resolvers = append(resolvers, &voterResolver{voter: voter})
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) Vote(ctx context.Context, args struct{ ID graphql.ID }) (*voteResolver, error) {
+	voteID, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := r.services.Votes.GetVote(ctx, voteID, nil, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	var vote voteclient.Vote
+	if err := json.Unmarshal(raw, &vote); err != nil {
+		return nil, err
+	}
+	return newVoteResolver(vote)
+}
+
+func (r *Resolver) Votes(ctx context.Context) ([]*voteResolver, error) {
+	votes, err := loadersFrom(ctx).allVotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return voteResolvers(votes, func(*voteResolver) bool { return true })
+}
+
+func (r *Resolver) Results(ctx context.Context, args struct{ PollID graphql.ID }) (*resultsResolver, error) {
+	pollID, err := parseID(args.PollID)
+	if err != nil {
+		return nil, err
+	}
+	poll, err := loadersFrom(ctx).poll(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	return tally(ctx, poll)
+}
+
+// Mutations
+
+type pollInput struct {
+	ID       graphql.ID
+	Title    string
+	Question *string
+}
+
+type pollOptionInput struct {
+	ID   graphql.ID
+	Text string
+}
+
+type voterInput struct {
+	ID        graphql.ID
+	FirstName string
+	LastName  *string
+}
+
+type voteInput struct {
+	ID       graphql.ID
+	VoterID  graphql.ID
+	PollID   graphql.ID
+	OptionID graphql.ID
+}
+
+func (r *Resolver) CreatePoll(ctx context.Context, args struct{ Input pollInput }) (*pollResolver, error) {
+	pollID, err := parseID(args.Input.ID)
+	if err != nil {
+		return nil, err
+	}
+	poll := pollclient.Poll{PollID: pollID, PollTitle: args.Input.Title, PollOptions: []pollclient.PollOption{}}
+	if args.Input.Question != nil {
+		poll.PollQuestion = *args.Input.Question
+	}
+
+	created, err := r.services.Polls.CreatePoll(ctx, pollID, poll, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	return &pollResolver{poll: created}, nil
+}
+
+func (r *Resolver) AddPollOption(ctx context.Context, args struct {
+	PollID graphql.ID
+	Input  pollOptionInput
+}) (*pollOptionResolver, error) {
+	pollID, err := parseID(args.PollID)
+	if err != nil {
+		return nil, err
+	}
+	pollOptionID, err := parseID(args.Input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pollOption := pollclient.PollOption{PollOptionID: pollOptionID, PollOptionText: args.Input.Text}
+	created, err := r.services.Polls.CreatePollOption(ctx, pollID, pollOptionID, pollOption, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	return &pollOptionResolver{pollID: pollID, option: created}, nil
+}
+
+func (r *Resolver) CreateVoter(ctx context.Context, args struct{ Input voterInput }) (*voterResolver, error) {
+	voterID, err := parseID(args.Input.ID)
+	if err != nil {
+		return nil, err
+	}
+	voter := voterclient.Voter{VoterID: voterID, FirstName: args.Input.FirstName}
+	if args.Input.LastName != nil {
+		voter.LastName = *args.Input.LastName
+	}
+
+	created, err := r.services.Voters.CreateVoter(ctx, voterID, voter, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	return &voterResolver{voter: created}, nil
+}
+
+func (r *Resolver) CastVote(ctx context.Context, args struct{ Input voteInput }) (*voteResolver, error) {
+	voteKeys, err := args.Input.voteKeys()
+	if err != nil {
+		return nil, err
+	}
+	vote, err := r.services.Votes.CastVote(ctx, voteKeys.VoteID, voteKeys, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	return newVoteResolver(vote)
+}
+
+func (r *Resolver) ChangeVote(ctx context.Context, args struct{ Input voteInput }) (*voteResolver, error) {
+	voteKeys, err := args.Input.voteKeys()
+	if err != nil {
+		return nil, err
+	}
+	vote, err := r.services.Votes.ReplaceVote(ctx, voteKeys.VoteID, voteKeys, loadersFrom(ctx).forward)
+	if err != nil {
+		return nil, err
+	}
+	return newVoteResolver(vote)
+}
+
+func (r *Resolver) DeleteVote(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	voteID, err := parseID(args.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.services.Votes.DeleteVote(ctx, voteID, loadersFrom(ctx).forward); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (input voteInput) voteKeys() (voteclient.VoteKeys, error) {
+	var keys voteclient.VoteKeys
+	var err error
+	if keys.VoteID, err = parseID(input.ID); err != nil {
+		return keys, err
+	}
+	if keys.VoterID, err = parseID(input.VoterID); err != nil {
+		return keys, err
+	}
+	if keys.PollID, err = parseID(input.PollID); err != nil {
+		return keys, err
+	}
+	keys.PollOptionID, err = parseID(input.OptionID)
+	return keys, err
+}
+
+// Types
+
+type pollResolver struct {
+	poll pollclient.Poll
+}
+
+func (p *pollResolver) ID() graphql.ID {
+	return formatID(p.poll.PollID)
+}
+
+func (p *pollResolver) Title() string {
+	return p.poll.PollTitle
+}
+
+func (p *pollResolver) Question() string {
+	return p.poll.PollQuestion
+}
+
+func (p *pollResolver) Options() []*pollOptionResolver {
+	resolvers := make([]*pollOptionResolver, 0, len(p.poll.PollOptions))
+	for _, pollOption := range p.poll.PollOptions {
+		resolvers = append(resolvers, &pollOptionResolver{pollID: p.poll.PollID, option: pollOption})
+	}
+	return resolvers
+}
+
+func (p *pollResolver) Votes(ctx context.Context) ([]*voteResolver, error) {
+	votes, err := loadersFrom(ctx).allVotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return voteResolvers(votes, func(v *voteResolver) bool { return v.pollID == p.poll.PollID })
+}
+
+func (p *pollResolver) Results(ctx context.Context) (*resultsResolver, error) {
+	return tally(ctx, p.poll)
+}
+
+type pollOptionResolver struct {
+	pollID uint
+	option pollclient.PollOption
+}
+
+func (o *pollOptionResolver) ID() graphql.ID {
+	return formatID(o.option.PollOptionID)
+}
+
+func (o *pollOptionResolver) Text() string {
+	return o.option.PollOptionText
+}
+
+func (o *pollOptionResolver) Poll(ctx context.Context) (*pollResolver, error) {
+	poll, err := loadersFrom(ctx).poll(ctx, o.pollID)
+	if err != nil {
+		return nil, err
+	}
+	return &pollResolver{poll: poll}, nil
+}
+
+type voterResolver struct {
+	voter voterclient.Voter
+}
+
+func (v *voterResolver) ID() graphql.ID {
+	return formatID(v.voter.VoterID)
+}
+
+func (v *voterResolver) FirstName() string {
+	return v.voter.FirstName
+}
+
+func (v *voterResolver) LastName() string {
+	return v.voter.LastName
+}
+
+func (v *voterResolver) Votes(ctx context.Context) ([]*voteResolver, error) {
+	votes, err := loadersFrom(ctx).allVotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return voteResolvers(votes, func(vote *voteResolver) bool { return vote.voterID == v.voter.VoterID })
+}
+
+// voteResolver holds the ids a vote links to, read from the end of its
+// Voter, Poll and PollOption links
+type voteResolver struct {
+	voteID       uint
+	voterID      uint
+	pollID       uint
+	pollOptionID uint
+	date         time.Time
+}
+
+func newVoteResolver(vote voteclient.Vote) (*voteResolver, error) {
+	resolver := &voteResolver{voteID: vote.VoteID, date: vote.VoteDate}
+	var err error
+	if resolver.voterID, err = lastPathID(vote.Voter); err != nil {
+		return nil, err
+	}
+	if resolver.pollID, err = lastPathID(vote.Poll); err != nil {
+		return nil, err
+	}
+	if resolver.pollOptionID, err = lastPathID(vote.PollOption); err != nil {
+		return nil, err
+	}
+	return resolver, nil
+}
+
+func voteResolvers(votes []voteclient.Vote, keep func(*voteResolver) bool) ([]*voteResolver, error) {
+	resolvers := []*voteResolver{}
+	for _, vote := range votes {
+		resolver, err := newVoteResolver(vote)
+		if err != nil {
+			return nil, err
+		}
+		if keep(resolver) {
+			resolvers = append(resolvers, resolver)
+		}
+	}
+	return resolvers, nil
+}
+
+func (v *voteResolver) ID() graphql.ID {
+	return formatID(v.voteID)
+}
+
+func (v *voteResolver) Voter(ctx context.Context) (*voterResolver, error) {
+	voter, err := loadersFrom(ctx).voter(ctx, v.voterID)
+	if err != nil {
+		return nil, err
+	}
+	return &voterResolver{voter: voter}, nil
+}
+
+func (v *voteResolver) Poll(ctx context.Context) (*pollResolver, error) {
+	poll, err := loadersFrom(ctx).poll(ctx, v.pollID)
+	if err != nil {
+		return nil, err
+	}
+	return &pollResolver{poll: poll}, nil
+}
+
+func (v *voteResolver) Option(ctx context.Context) (*pollOptionResolver, error) {
+	poll, err := loadersFrom(ctx).poll(ctx, v.pollID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pollOption := range poll.PollOptions {
+		if pollOption.PollOptionID == v.pollOptionID {
+			return &pollOptionResolver{pollID: v.pollID, option: pollOption}, nil
+		}
+	}
+	return nil, fmt.Errorf("option %d of poll %d not found", v.pollOptionID, v.pollID)
+}
+
+func (v *voteResolver) Date() graphql.Time {
+	return graphql.Time{Time: v.date}
+}
+
+type resultsResolver struct {
+	poll       pollclient.Poll
+	totalVotes int32
+	options    []*optionResultResolver
+}
+
+type optionResultResolver struct {
+	option *pollOptionResolver
+	votes  int32
+}
+
+// tally reads the poll's results from the vote api. Every option of the
+// poll is listed, so options nobody voted for show 0.
+func tally(ctx context.Context, poll pollclient.Poll) (*resultsResolver, error) {
+	report, err := loadersFrom(ctx).pollResults(ctx, poll.PollID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &resultsResolver{poll: poll, totalVotes: int32(report.TotalVotes)}
+	for _, optionResult := range report.Options {
+		pollOption := pollclient.PollOption{PollOptionID: optionResult.PollOptionID, PollOptionText: optionResult.PollOptionText}
+		results.options = append(results.options, &optionResultResolver{
+			option: &pollOptionResolver{pollID: poll.PollID, option: pollOption},
+			votes:  int32(optionResult.Votes),
+		})
+	}
+	return results, nil
+}
+
+func (r *resultsResolver) Poll() *pollResolver {
+	return &pollResolver{poll: r.poll}
+}
+
+func (r *resultsResolver) TotalVotes() int32 {
+	return r.totalVotes
+}
+
+func (r *resultsResolver) Options() []*optionResultResolver {
+	return r.options
+}
+
+func (o *optionResultResolver) Option() *pollOptionResolver {
+	return o.option
+}
+
+func (o *optionResultResolver) Votes() int32 {
+	return o.votes
+}
+
+func parseID(id graphql.ID) (uint, error) {
+	value, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid id " + strconv.Quote(string(id)))
+	}
+	return uint(value), nil
+}
+
+func formatID(id uint) graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(id), 10))
+}
+
+func lastPathID(link string) (uint, error) {
+	id, err := strconv.ParseUint(path.Base(path.Clean(link)), 10, 64)
+	if err != nil {
+		return 0, errors.New("link does not end in an id: " + link)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,310 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/client/pollclient"
+	"gateway/client/voteclient"
+	"gateway/client/voterclient"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backend stands in for one of the REST apis. It answers GET requests
+// from routes, keyed by "METHOD /path", and records every request.
+type backend struct {
+	routes map[string]interface{}
+
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mutex.Lock()
+	b.requests = append(b.requests, r)
+	b.mutex.Unlock()
+
+	body, ok := b.routes[r.Method+" "+r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// paths lists the requested paths in order, so a test can check what was
+// fetched and how often
+func (b *backend) paths() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	paths := make([]string, 0, len(b.requests))
+	for _, r := range b.requests {
+		paths = append(paths, r.URL.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (b *backend) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests = nil
+}
+
+type testBackends struct {
+	voters, polls, votes *backend
+	services             *Services
+}
+
+func (b testBackends) all() []*backend {
+	return []*backend{b.voters, b.polls, b.votes}
+}
+
+// newTestGateway serves the GraphQL handler over fake voter, poll and vote
+// apis. votes lists who voted for what as {voter, poll, option} ids.
+func newTestGateway(t *testing.T, votes [][3]uint) (*gin.Engine, testBackends) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	backends := testBackends{
+		voters: &backend{routes: map[string]interface{}{}},
+		polls:  &backend{routes: map[string]interface{}{}},
+		votes:  &backend{routes: map[string]interface{}{}},
+	}
+
+	var voters []voterclient.Voter
+	for voterID := uint(1); voterID <= 9; voterID++ {
+		voter := voterclient.Voter{VoterID: voterID, FirstName: fmt.Sprint("Voter ", voterID)}
+		voters = append(voters, voter)
+		backends.voters.routes[fmt.Sprintf("GET /voters/%d", voterID)] = voter
+	}
+	backends.voters.routes["GET /voters"] = voters
+
+	polls := []pollclient.Poll{
+		{PollID: 1, PollTitle: "Pets", PollOptions: []pollclient.PollOption{{PollOptionID: 1, PollOptionText: "Cat"}, {PollOptionID: 2, PollOptionText: "Dog"}}},
+		{PollID: 2, PollTitle: "Colors", PollOptions: []pollclient.PollOption{{PollOptionID: 1, PollOptionText: "Red"}}},
+	}
+	for _, poll := range polls {
+		backends.polls.routes[fmt.Sprintf("GET /polls/%d", poll.PollID)] = poll
+	}
+	backends.polls.routes["GET /polls/"] = polls
+
+	var voteList []voteclient.Vote
+	for i, ids := range votes {
+		voteList = append(voteList, voteclient.Vote{
+			VoteID:     uint(i + 1),
+			Voter:      fmt.Sprintf("http://voters.test/voters/%d", ids[0]),
+			Poll:       fmt.Sprintf("http://polls.test/polls/%d", ids[1]),
+			PollOption: fmt.Sprintf("http://polls.test/polls/%d/polloption/%d", ids[1], ids[2]),
+			VoteDate:   time.Date(2024, 5, 1, 12, i, 0, 0, time.UTC),
+		})
+	}
+	backends.votes.routes["GET /votes"] = voteList
+	backends.votes.routes["GET /polls/1/results"] = voteclient.ResultsReport{
+		PollID:    1,
+		PollTitle: "Pets",
+		Options: []voteclient.OptionResult{
+			{PollOptionID: 1, PollOptionText: "Cat", Votes: 7, Share: 0.7},
+			{PollOptionID: 2, PollOptionText: "Dog", Votes: 3, Share: 0.3},
+		},
+		TotalVotes: 10,
+	}
+
+	servers := make([]*httptest.Server, 0, 3)
+	for _, b := range backends.all() {
+		server := httptest.NewServer(b)
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+	}
+	services := &Services{
+		Voters: voterclient.New(servers[0].URL),
+		Polls:  pollclient.New(servers[1].URL),
+		Votes:  voteclient.New(servers[2].URL),
+	}
+	backends.services = services
+
+	schema, err := NewSchema(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/graphql", Handler(schema, services))
+	return r, backends
+}
+
+// query runs a GraphQL query with header and decodes its data into target
+func query(t *testing.T, r *gin.Engine, header http.Header, graphQuery string, target interface{}) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": graphQuery})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	var response struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) > 0 {
+		t.Fatalf("query failed: %+v", response.Errors)
+	}
+	if err := json.Unmarshal(response.Data, target); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const votesQuery = `{ votes { id voter { firstName } poll { title } option { text } } }`
+
+type votesData struct {
+	Votes []struct {
+		ID     string
+		Voter  struct{ FirstName string }
+		Poll   struct{ Title string }
+		Option struct{ Text string }
+	}
+}
+
+// Every voter and poll is fetched once per request however many votes
+// link to it, and the next request fetches them again
+func TestLoadersBatchPerRequest(t *testing.T) {
+	r, backends := newTestGateway(t, [][3]uint{{1, 1, 1}, {2, 1, 2}, {1, 2, 1}})
+
+	for request := 1; request <= 2; request++ {
+		for _, b := range backends.all() {
+			b.reset()
+		}
+
+		var data votesData
+		query(t, r, nil, votesQuery, &data)
+		if len(data.Votes) != 3 || data.Votes[2].Voter.FirstName != "Voter 1" ||
+			data.Votes[2].Poll.Title != "Colors" || data.Votes[1].Option.Text != "Dog" {
+			t.Errorf("request %d: unexpected votes %+v", request, data.Votes)
+		}
+
+		if paths, want := backends.voters.paths(), []string{"/voters/1", "/voters/2"}; !reflect.DeepEqual(paths, want) {
+			t.Errorf("request %d: voter api got %v, want %v", request, paths, want)
+		}
+		if paths, want := backends.polls.paths(), []string{"/polls/1", "/polls/2"}; !reflect.DeepEqual(paths, want) {
+			t.Errorf("request %d: poll api got %v, want %v", request, paths, want)
+		}
+		if paths, want := backends.votes.paths(), []string{"/votes"}; !reflect.DeepEqual(paths, want) {
+			t.Errorf("request %d: vote api got %v, want %v", request, paths, want)
+		}
+	}
+}
+
+// Lookups queued before any is waited on go out as one batch. A batch of
+// more than listThreshold voters is read from the list route, a smaller one
+// record by record, and records already loaded are not fetched again.
+func TestLoadersBatchLookups(t *testing.T) {
+	_, backends := newTestGateway(t, nil)
+	l := newLoaders(backends.services, http.Header{"X-Api-Key": {"reader-key"}})
+	ctx := context.Background()
+
+	load := func(voterIDs ...uint) {
+		t.Helper()
+		thunks := make([]func() (voterclient.Voter, error), 0, len(voterIDs))
+		for _, voterID := range voterIDs {
+			thunks = append(thunks, l.voters.Load(ctx, voterID))
+		}
+		for i, thunk := range thunks {
+			voter, err := thunk()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if voter.VoterID != voterIDs[i] {
+				t.Errorf("asked for voter %d, got %+v", voterIDs[i], voter)
+			}
+		}
+	}
+
+	load(1, 2, 3, 4, 5, 6)
+	if paths, want := backends.voters.paths(), []string{"/voters"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("voter api got %v, want %v", paths, want)
+	}
+
+	backends.voters.reset()
+	load(1, 7, 8)
+	if paths, want := backends.voters.paths(), []string{"/voters/7", "/voters/8"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("voter api got %v, want %v", paths, want)
+	}
+}
+
+// The caller's credentials reach every api, and nothing else is passed on
+func TestCredentialsForwarded(t *testing.T) {
+	r, backends := newTestGateway(t, [][3]uint{{1, 1, 1}})
+
+	header := http.Header{
+		"X-Api-Key":     {"reader-key"},
+		"Authorization": {"Bearer token"},
+		"Cookie":        {"session=secret"},
+	}
+	var data votesData
+	query(t, r, header, votesQuery, &data)
+	query(t, r, header, `{ results(pollId: "1") { totalVotes } }`, &struct{}{})
+
+	for _, b := range backends.all() {
+		if len(b.requests) == 0 {
+			t.Fatal("expected every api to be called")
+		}
+		for _, req := range b.requests {
+			if req.Header.Get("X-Api-Key") != "reader-key" || req.Header.Get("Authorization") != "Bearer token" {
+				t.Errorf("%s was sent without the caller's credentials: %v", req.URL.Path, req.Header)
+			}
+			if req.Header.Get("Cookie") != "" {
+				t.Errorf("%s was sent a header that is not a credential", req.URL.Path)
+			}
+		}
+	}
+}
+
+// Results are counted by the vote api, and asked for once per poll and
+// request
+func TestResultsFromVoteAPI(t *testing.T) {
+	r, backends := newTestGateway(t, nil)
+
+	var data struct {
+		Results struct {
+			TotalVotes int
+			Options    []struct {
+				Option struct{ Text string }
+				Votes  int
+			}
+		}
+		Poll struct {
+			Results struct{ TotalVotes int }
+		}
+	}
+	query(t, r, nil, `{
+		results(pollId: "1") { totalVotes options { option { text } votes } }
+		poll(id: "1") { results { totalVotes } }
+	}`, &data)
+
+	if data.Results.TotalVotes != 10 || data.Poll.Results.TotalVotes != 10 || len(data.Results.Options) != 2 ||
+		data.Results.Options[0].Option.Text != "Cat" || data.Results.Options[0].Votes != 7 || data.Results.Options[1].Votes != 3 {
+		t.Errorf("unexpected results %+v", data)
+	}
+	if paths, want := backends.votes.paths(), []string{"/polls/1/results"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("vote api got %v, want %v", paths, want)
+	}
+}
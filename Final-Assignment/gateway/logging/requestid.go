@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying requestID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID of ctx, or "" if it has none
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID returns a random 128 bit ID in hex
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID accepts the IDs of callers that are short and printable,
+// so they can be logged and sent on as they are
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDOrNew keeps a valid incoming ID and replaces any other with a
+// new one
+func requestIDOrNew(requestID string) string {
+	if validRequestID(requestID) {
+		return requestID
+	}
+	return newRequestID()
+}
+
+// RequestID takes the request ID from the X-Request-ID header, or makes
+// one up, returns it in the response and puts it in the request context
+// for the logs and downstream calls
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := requestIDOrNew(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := c.Request.Context()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+		c.Request = c.Request.WithContext(WithRequestID(ctx, requestID))
+		c.Next()
+	}
+}
+
+// Transport sends the request ID of each request's context on in the
+// X-Request-ID header
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return requestIDTransport{base: base}
+}
+
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFrom(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not change the request they are given
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return t.base.RoundTrip(req)
+}
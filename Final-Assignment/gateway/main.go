@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"gateway/client/pollclient"
+	"gateway/client/voteclient"
+	"gateway/client/voterclient"
+	"gateway/config"
+	"gateway/graph"
+	"gateway/logging"
+	"shared/configloader"
+	"shared/httpserver"
+	"shared/telemetry"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// serviceName names the gateway in traces
+const serviceName = "gateway"
+
+// Global variables to hold the loaded configuration and where it came
+// from, so that it can be reloaded
+var (
+	settings      config.Config
+	configSources *configloader.Sources
+)
+
+type HealthCheckData struct {
+	UpTime string
+}
+
+func processCmdLineFlags() error {
+
+	var err error
+	settings, configSources, err = config.Parse(flag.CommandLine, os.Args[1:])
+	return err
+}
+
+func main() {
+	if err := processCmdLineFlags(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(settings.LogLevel, settings.LogFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), settings.Tracing, serviceName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	services := &graph.Services{
+		Votes:  voteclient.New("http://" + settings.Services.VotesURL),
+		Voters: voterclient.New("http://" + settings.Services.VotersURL),
+		Polls:  pollclient.New("http://" + settings.Services.PollsURL),
+	}
+	// Calls to the apis are traced and carry the request ID
+	httpClient := &http.Client{Transport: otelhttp.NewTransport(logging.Transport(http.DefaultTransport))}
+	services.Votes.HTTPClient = httpClient
+	services.Voters.HTTPClient = httpClient
+	services.Polls.HTTPClient = httpClient
+
+	schema, err := graph.NewSchema(services)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// The log level follows the config when it is reloaded
+	liveConfig := config.NewLive(settings, configSources)
+	liveConfig.OnReload(func(settings config.Config) {
+		logging.SetLevel(settings.LogLevel)
+	})
+
+	r := setupRouter(schema, services)
+	if settings.DebugEndpoints {
+		r.GET("/debug/config", liveConfig.Handler)
+	}
+
+	ctx, stop := httpserver.SignalContext()
+	defer stop()
+	liveConfig.WatchSignals(ctx)
+
+	serverPath := fmt.Sprintf("%s:%d", settings.Host, settings.Port)
+	err = settings.HTTP.Run(ctx, serverPath, r)
+	shutdownTracing()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func setupRouter(schema *graphql.Schema, services *graph.Services) *gin.Engine {
+	r := gin.New()
+	r.Use(otelgin.Middleware(serviceName), logging.RequestID(), logging.AccessLog(), gin.Recovery())
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders("Authorization", "X-API-Key", logging.RequestIDHeader)
+	corsConfig.AddExposeHeaders(logging.RequestIDHeader)
+	r.Use(cors.New(corsConfig))
+
+	r.POST("/graphql", graph.Handler(schema, services))
+
+	bootTime := time.Now()
+	r.GET("/graphql/health", func(c *gin.Context) {
+		c.IndentedJSON(http.StatusOK, HealthCheckData{UpTime: time.Now().Sub(bootTime).String()})
+	})
+
+	return r
+}
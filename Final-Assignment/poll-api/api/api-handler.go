@@ -2,18 +2,25 @@ package api
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"poll-api/db"
+	"shared/hal"
+	"shared/validation"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
 )
 
 type PollAPI struct {
-	db *db.PollData
+	db db.PollStore
+	publicBaseURL string
 	bootTime time.Time
 	totalCalls int
 	totalErrors int
@@ -25,21 +32,29 @@ type HealthCheckData struct {
 	TotalErrors int
 }
 
-func New() (*PollAPI, error) {
-	dbHandler, err := db.New()
+func New(storage db.Config, redisClient redis.UniversalClient, publicBaseURL string) (*PollAPI, error) {
+	dbHandler, err := db.New(storage, redisClient)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewWithData(dbHandler, publicBaseURL), nil
+}
+
+// NewWithData creates the handlers on top of an existing PollStore, so it can
+// be shared with the gRPC server. Links are built from publicBaseURL, or
+// from the request when it is empty.
+func NewWithData(dbHandler db.PollStore, publicBaseURL string) *PollAPI {
 	return &PollAPI{   db: dbHandler, 
+						publicBaseURL: publicBaseURL,
 						bootTime: time.Now(),
 						totalCalls: 0,
-						totalErrors: 0,}, nil
+						totalErrors: 0,}
 }
 
 func (pollAPI *PollAPI) ListAllPolls(c *gin.Context) {
 	pollAPI.totalCalls++
-	pollList, err := pollAPI.db.GetAllPolls()
+	pollList, err := pollAPI.db.GetAllPolls(c.Request.Context())
 	if err != nil {
 		pollAPI.handleInternalServerError(c, "Error Getting All Polls: ", err)
 		return
@@ -49,7 +64,11 @@ func (pollAPI *PollAPI) ListAllPolls(c *gin.Context) {
 		pollList = make([]db.Poll, 0)
 	}
 
-	c.JSON(http.StatusOK, pollList)
+	hal.Collection(c, http.StatusOK, "polls", pollList, func(poll db.Poll) interface{} {
+		return presentPoll(c, poll)
+	}, func(poll db.Poll) hal.Links {
+		return pollAPI.pollLinks(c, poll)
+	}, pollAPI.pollsLinks(c))
 }
 
 func (pollAPI *PollAPI) GetPoll(c *gin.Context) {
@@ -60,13 +79,13 @@ func (pollAPI *PollAPI) GetPoll(c *gin.Context) {
 		return
 	}
 
-	poll, err := pollAPI.db.GetPoll(id)
+	poll, err := pollAPI.db.GetPoll(c.Request.Context(), id)
 	if err != nil {
 		pollAPI.handleBadRequestError(c, "Poll not found: ", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, poll)
+	hal.JSON(c, http.StatusOK, presentPoll(c, poll), pollAPI.pollLinks(c, poll))
 }
 
 func (pollAPI *PollAPI) AddPoll(c *gin.Context) {
@@ -80,7 +99,7 @@ func (pollAPI *PollAPI) AddPoll(c *gin.Context) {
 
 	var poll db.Poll
 	if err := c.ShouldBindJSON(&poll); err != nil {
-		pollAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		pollAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -89,12 +108,12 @@ func (pollAPI *PollAPI) AddPoll(c *gin.Context) {
 		return
 	}
 
-	if err := pollAPI.db.AddPoll(poll); err != nil {
+	if err := pollAPI.db.AddPoll(c.Request.Context(), poll); err != nil {
 		pollAPI.handleInternalServerError(c, "Error adding poll: ", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, poll)
+	hal.JSON(c, http.StatusOK, presentPoll(c, poll), pollAPI.pollLinks(c, poll))
 }
 
 func (pollAPI *PollAPI) UpdatePoll(c *gin.Context) {
@@ -105,9 +124,16 @@ func (pollAPI *PollAPI) UpdatePoll(c *gin.Context) {
 		return
 	}
 
+	// PUT replaces the whole poll, PATCH is used to change single fields
 	var poll db.Poll
 	if err := c.ShouldBindJSON(&poll); err != nil {
-		pollAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		pollAPI.handleValidationError(c, err)
+		return
+	}
+
+	if poll.PollOptions == nil {
+		pollAPI.handleFieldErrors(c, []validation.FieldError{{Field: "PollOptions", Rule: "required",
+			Message: "PollOptions is required when replacing a poll, send [] to remove every option or use PATCH"}})
 		return
 	}
 
@@ -116,12 +142,39 @@ func (pollAPI *PollAPI) UpdatePoll(c *gin.Context) {
 		return
 	}
 
-	err = pollAPI.db.UpdatePoll(poll.PollID, poll)
+	err = pollAPI.db.UpdatePoll(c.Request.Context(), poll.PollID, poll)
+	if errors.Is(err, db.ErrInUse) {
+		pollAPI.handleInUseError(c, "Error updating poll: ", err)
+		return
+	}
 	if err != nil {
 		pollAPI.handleBadRequestError(c, "Poll does not exist", err)
 		return
 	}
-	c.JSON(http.StatusOK, poll)
+	hal.JSON(c, http.StatusOK, presentPoll(c, poll), pollAPI.pollLinks(c, poll))
+}
+
+// PatchPoll accepts either a JSON Merge Patch or a JSON Patch document,
+// chosen by the Content-Type header
+func (pollAPI *PollAPI) PatchPoll(c *gin.Context) {
+	pollAPI.totalCalls++
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		pollAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	patch, ok := pollAPI.patchFromRequest(c)
+	if !ok {
+		return
+	}
+
+	poll, err := pollAPI.db.PatchPoll(c.Request.Context(), id, patch, binding.Validator.ValidateStruct)
+	if err != nil {
+		pollAPI.handlePatchError(c, err)
+		return
+	}
+	hal.JSON(c, http.StatusOK, presentPoll(c, poll), pollAPI.pollLinks(c, poll))
 }
 
 func (pollAPI *PollAPI) DeletePoll(c *gin.Context) {
@@ -132,7 +185,11 @@ func (pollAPI *PollAPI) DeletePoll(c *gin.Context) {
 		return
 	}
 
-	err = pollAPI.db.DeletePoll(id)
+	err = pollAPI.db.DeletePoll(c.Request.Context(), id)
+	if errors.Is(err, db.ErrInUse) {
+		pollAPI.handleInUseError(c, "Error deleting poll: ", err)
+		return
+	}
 	if err != nil {
 		pollAPI.handleBadRequestError(c, "Poll does not exist", err)
 		return
@@ -150,13 +207,17 @@ func (pollAPI *PollAPI) GetPollOptions(c *gin.Context) {
 		return
 	}
 
-	pollOptions, err := pollAPI.db.GetPollOptions(pollID)
+	pollOptions, err := pollAPI.db.GetPollOptions(c.Request.Context(), pollID)
 	if err != nil {
 		pollAPI.handleBadRequestError(c, "Poll does not exist", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, pollOptions)
+	hal.Collection(c, http.StatusOK, "options", pollOptions, func(pollOption db.PollOption) interface{} {
+		return presentPollOption(c, pollOption)
+	}, func(pollOption db.PollOption) hal.Links {
+		return pollAPI.pollOptionLinks(c, pollID, pollOption)
+	}, hal.Links{"self": {Href: fmt.Sprintf("%s/polls/%d", pollAPI.baseURL(c), pollID)}})
 }
 
 func (pollAPI *PollAPI) GetPollOption(c *gin.Context) {
@@ -173,13 +234,13 @@ func (pollAPI *PollAPI) GetPollOption(c *gin.Context) {
 		return
 	}
 
-	poll, err := pollAPI.db.GetPollOption(pollID, optionID)
+	poll, err := pollAPI.db.GetPollOption(c.Request.Context(), pollID, optionID)
 	if err != nil {
 		pollAPI.handleBadRequestError(c, "Poll does not have this option", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, poll)
+	hal.JSON(c, http.StatusOK, presentPollOption(c, poll), pollAPI.pollOptionLinks(c, pollID, poll))
 }
 
 func (pollAPI *PollAPI) AddPollOption(c *gin.Context) {
@@ -198,7 +259,7 @@ func (pollAPI *PollAPI) AddPollOption(c *gin.Context) {
 
 	var pollOption db.PollOption
 	if err := c.ShouldBindJSON(&pollOption); err != nil {
-		pollAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		pollAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -207,18 +268,23 @@ func (pollAPI *PollAPI) AddPollOption(c *gin.Context) {
 		return
 	}
 
-	pollExists := pollAPI.db.DoesPollOptionExist(pollID, optionID)
+	pollExists := pollAPI.db.DoesPollOptionExist(c.Request.Context(), pollID, optionID)
 	if pollExists {
 		pollAPI.handleBadRequestError(c, "ERROR: Poll Option ID already exists in Poll ", err)
 		return
 	}
 
-	if err := pollAPI.db.AddPollOption(pollID, pollOption); err != nil {
+	err = pollAPI.db.AddPollOption(c.Request.Context(), pollID, pollOption)
+	if errors.Is(err, db.ErrTooManyPollOptions) {
+		pollAPI.handleFieldErrors(c, []validation.FieldError{{Field: "PollOptions", Rule: "max", Message: err.Error()}})
+		return
+	}
+	if err != nil {
 		pollAPI.handleInternalServerError(c, "Error adding poll option: ", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, pollOption)
+	hal.JSON(c, http.StatusOK, presentPollOption(c, pollOption), pollAPI.pollOptionLinks(c, pollID, pollOption))
 }
 
 func (pollAPI *PollAPI) UpdatePollOption(c *gin.Context) {
@@ -235,7 +301,7 @@ func (pollAPI *PollAPI) UpdatePollOption(c *gin.Context) {
 		return
 	}
 
-	pollExists := pollAPI.db.DoesPollOptionExist(pollID, optionID)
+	pollExists := pollAPI.db.DoesPollOptionExist(c.Request.Context(), pollID, optionID)
 	if pollExists == false {
 		pollAPI.handleBadRequestError(c, "No option exists for this poll option ID in this poll", errors.New("Poll option does not exist"))
 		return
@@ -243,7 +309,7 @@ func (pollAPI *PollAPI) UpdatePollOption(c *gin.Context) {
 
 	var pollOption db.PollOption
 	if err := c.ShouldBindJSON(&pollOption); err != nil {
-		pollAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		pollAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -252,8 +318,11 @@ func (pollAPI *PollAPI) UpdatePollOption(c *gin.Context) {
 		return
 	}
 
-	pollAPI.db.UpdatePollOption(pollID, optionID, pollOption)
-	c.JSON(http.StatusOK, pollOption)
+	if err := pollAPI.db.UpdatePollOption(c.Request.Context(), pollID, optionID, pollOption); err != nil {
+		pollAPI.handleInternalServerError(c, "Error updating poll option: ", err)
+		return
+	}
+	hal.JSON(c, http.StatusOK, presentPollOption(c, pollOption), pollAPI.pollOptionLinks(c, pollID, pollOption))
 }
 
 func (pollAPI *PollAPI) DeletePollOption(c *gin.Context) {
@@ -270,16 +339,59 @@ func (pollAPI *PollAPI) DeletePollOption(c *gin.Context) {
 		return
 	}
 
-	pollOptionExists := pollAPI.db.DoesPollOptionExist(pollID, optionID)
-	if pollOptionExists == false {
-		pollAPI.handleBadRequestError(c, "No poll option data exists for this poll option in this poll", errors.New("Pollpoll does not exist"))
+	err = pollAPI.db.DeletePollOption(c.Request.Context(), pollID, optionID)
+	if errors.Is(err, db.ErrNotFound) {
+		pollAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "No poll option data exists for this poll option in this poll", "error", err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, db.ErrInUse) {
+		pollAPI.handleInUseError(c, "Error deleting poll option: ", err)
+		return
+	}
+	if err != nil {
+		pollAPI.handleInternalServerError(c, "Error deleting poll option: ", err)
 		return
 	}
-
-	pollAPI.db.DeletePollOption(pollID, optionID)
 	c.Status(http.StatusOK)
 }
 
+func (pollAPI *PollAPI) patchFromRequest(c *gin.Context) (db.Patch, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		pollAPI.handleBadRequestError(c, "Error reading patch: ", err)
+		return nil, false
+	}
+
+	patch := db.PatchFromContentType(c.ContentType(), body)
+	if patch == nil {
+		pollAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Unsupported patch content type", "content_type", c.ContentType())
+		c.Header("Accept-Patch", db.MergePatchContentType+", "+db.JSONPatchContentType)
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	return patch, true
+}
+
+func (pollAPI *PollAPI) handlePatchError(c *gin.Context, err error) {
+	switch {
+	case validation.IsValidationError(err):
+		pollAPI.handleValidationError(c, err)
+	case errors.Is(err, db.ErrPatchConflict):
+		pollAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error patching poll", "error", err)
+		c.AbortWithStatus(http.StatusConflict)
+	case errors.Is(err, db.ErrInUse):
+		pollAPI.handleInUseError(c, "Error patching poll: ", err)
+	case errors.Is(err, db.ErrNotFound), errors.Is(err, db.ErrInvalidPatch), errors.Is(err, db.ErrIDChanged):
+		pollAPI.handleBadRequestError(c, "Error patching poll: ", err)
+	default:
+		pollAPI.handleInternalServerError(c, "Error patching poll: ", err)
+	}
+}
+
 func getParameterUint(c *gin.Context, name string) (uint, error) {
 	paramS := c.Param(name)
 	param64, err := strconv.ParseUint(paramS, 10, 64)
@@ -291,16 +403,34 @@ func getParameterUint(c *gin.Context, name string) (uint, error) {
 
 func (pollAPI *PollAPI) handleBadRequestError(c *gin.Context, errorMessage string, err error) {
 	pollAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.WarnContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusBadRequest)
 }
 
+func (pollAPI *PollAPI) handleValidationError(c *gin.Context, err error) {
+	pollAPI.handleFieldErrors(c, validation.Errors(err))
+}
+
+func (pollAPI *PollAPI) handleFieldErrors(c *gin.Context, fieldErrors []validation.FieldError) {
+	pollAPI.totalErrors++
+	slog.WarnContext(c.Request.Context(), "Error validating request body", "errors", fieldErrors)
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": fieldErrors})
+}
+
 func (pollAPI *PollAPI) handleInternalServerError(c *gin.Context, errorMessage string, err error) {
 	pollAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.ErrorContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusInternalServerError)
 }
 
+// handleInUseError answers writes the store refused because they would
+// orphan votes
+func (pollAPI *PollAPI) handleInUseError(c *gin.Context, errorMessage string, err error) {
+	pollAPI.totalErrors++
+	slog.WarnContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
+	c.AbortWithStatus(http.StatusConflict)
+}
+
 func (pollAPI *PollAPI) HealthCheck(c *gin.Context) {
 	healthData := HealthCheckData{UpTime: time.Now().Sub(pollAPI.bootTime).String(), 
 									TotalCalls: pollAPI.totalCalls,
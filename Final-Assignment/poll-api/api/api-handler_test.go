@@ -0,0 +1,402 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"poll-api/db"
+	"shared/auth"
+	"shared/hal"
+	"shared/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter serves the handlers from a memory store behind API key
+// auth, as the service does, without rate limits or idempotency
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := validation.RegisterMax("maxpolloptions", db.MaxPollOptions); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{APIKeys: "manager-key:poll-manager,admin-key:admin,reader-key:reader,voter-key:voter:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pollAPI := NewWithData(db.NewMemory(), "http://polls.test")
+	r := gin.New()
+	pollAPI.Routes(r.Group("/", auth.Middleware(authenticator)), RouteMiddleware{})
+	r.GET("/polls/health", pollAPI.HealthCheck)
+	return r
+}
+
+func serve(r *gin.Engine, method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, "manager-key")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), target); err != nil {
+		t.Fatalf("response %q is not JSON: %v", recorder.Body.String(), err)
+	}
+}
+
+func addFavoriteColor(t *testing.T, r *gin.Engine) {
+	t.Helper()
+	steps := []struct{ path, body string }{
+		{"/polls/1", `{"PollID": 1, "PollTitle": "Favorite Color", "PollQuestion": "What is your favorite color?"}`},
+		{"/polls/1/polloption/1", `{"PollOptionID": 1, "PollOptionText": "Blue"}`},
+		{"/polls/1/polloption/2", `{"PollOptionID": 2, "PollOptionText": "Brown"}`},
+	}
+	for _, step := range steps {
+		if recorder := serve(r, http.MethodPost, step.path, step.body, nil); recorder.Code != http.StatusOK {
+			t.Fatalf("POST %s: expected 200, got %d %s", step.path, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestAddAndGetPoll(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	recorder := serve(r, http.MethodGet, "/polls/1", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	var poll PollV1
+	decode(t, recorder, &poll)
+	if poll.PollID != 1 || poll.PollTitle != "Favorite Color" || len(poll.PollOptions) != 2 {
+		t.Errorf("unexpected poll %+v", poll)
+	}
+
+	recorder = serve(r, http.MethodGet, "/polls/1/polloption/2", "", nil)
+	var pollOption PollOptionV1
+	decode(t, recorder, &pollOption)
+	if pollOption != (PollOptionV1{PollOptionID: 2, PollOptionText: "Brown"}) {
+		t.Errorf("unexpected poll option %+v", pollOption)
+	}
+
+	recorder = serve(r, http.MethodGet, "/polls/", "", nil)
+	var polls []PollV1
+	decode(t, recorder, &polls)
+	if len(polls) != 1 {
+		t.Errorf("expected 1 poll, got %d", len(polls))
+	}
+}
+
+func TestPollRequestErrors(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown poll", http.MethodGet, "/polls/9", "", http.StatusBadRequest},
+		{"id is not a number", http.MethodGet, "/polls/one", "", http.StatusBadRequest},
+		{"unknown option", http.MethodGet, "/polls/1/polloption/9", "", http.StatusBadRequest},
+		{"ids differ", http.MethodPost, "/polls/2", `{"PollID": 3, "PollTitle": "Pets"}`, http.StatusBadRequest},
+		{"missing title", http.MethodPost, "/polls/2", `{"PollID": 2}`, http.StatusBadRequest},
+		{"option exists", http.MethodPost, "/polls/1/polloption/1", `{"PollOptionID": 1, "PollOptionText": "Red"}`, http.StatusBadRequest},
+		{"update unknown option", http.MethodPut, "/polls/1/polloption/9", `{"PollOptionID": 9, "PollOptionText": "Red"}`, http.StatusBadRequest},
+		{"delete unknown poll", http.MethodDelete, "/polls/9", "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, test.method, test.path, test.body, nil)
+			if recorder.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsListFields(t *testing.T) {
+	r := newTestRouter(t)
+
+	recorder := serve(r, http.MethodPost, "/polls/1", `{"PollID": 1}`, nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", recorder.Code)
+	}
+	var body struct {
+		Errors []validation.FieldError `json:"errors"`
+	}
+	decode(t, recorder, &body)
+	if len(body.Errors) != 1 || body.Errors[0].Field != "PollTitle" {
+		t.Errorf("expected an error on PollTitle, got %+v", body.Errors)
+	}
+}
+
+func TestUpdateAndPatchPoll(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	recorder := serve(r, http.MethodPatch, "/polls/1", `{"PollTitle": "Favourite Colour"}`,
+		http.Header{"Content-Type": {db.MergePatchContentType}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("merge patch: expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var poll PollV1
+	decode(t, recorder, &poll)
+	if poll.PollTitle != "Favourite Colour" || len(poll.PollOptions) != 2 {
+		t.Errorf("merge patch changed more than the title: %+v", poll)
+	}
+
+	recorder = serve(r, http.MethodPatch, "/polls/1", `{"PollTitle": "x"}`, http.Header{"Content-Type": {"text/plain"}})
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a plain text patch, got %d", recorder.Code)
+	}
+
+	recorder = serve(r, http.MethodPut, "/polls/1/polloption/1", `{"PollOptionID": 1, "PollOptionText": "Green"}`, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("update option: expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	recorder = serve(r, http.MethodGet, "/polls/1/polloption/1", "", nil)
+	var pollOption PollOptionV1
+	decode(t, recorder, &pollOption)
+	if pollOption.PollOptionText != "Green" {
+		t.Errorf("expected the updated option, got %+v", pollOption)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	tooManyOptions := make([]string, db.MaxPollOptions+1)
+	for i := range tooManyOptions {
+		tooManyOptions[i] = fmt.Sprintf(`{"PollOptionID": %d, "PollOptionText": "Option"}`, i+1)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header http.Header
+		errors []validation.FieldError
+	}{
+		{"several fields", http.MethodPost, "/polls/2", `{"PollTitle": "<script>", "PollQuestion": "` + strings.Repeat("?", 501) + `"}`, nil,
+			[]validation.FieldError{
+				{Field: "PollID", Rule: "required", Message: "PollID is required"},
+				{Field: "PollTitle", Rule: "safetext", Message: "PollTitle contains characters that are not allowed"},
+				{Field: "PollQuestion", Rule: "max", Message: "PollQuestion must be at most 500 characters long"},
+			}},
+		{"option field", http.MethodPost, "/polls/2", `{"PollID": 2, "PollTitle": "Pets", "PollOptions": [{"PollOptionID": 1}]}`, nil,
+			[]validation.FieldError{{Field: "PollOptions[0].PollOptionText", Rule: "required", Message: "PollOptionText is required"}}},
+		{"too many options", http.MethodPost, "/polls/2", `{"PollID": 2, "PollTitle": "Pets", "PollOptions": [` + strings.Join(tooManyOptions, ",") + `]}`, nil,
+			[]validation.FieldError{{Field: "PollOptions", Rule: "max", Message: fmt.Sprintf("PollOptions must have at most %d items", db.MaxPollOptions)}}},
+		{"wrong type", http.MethodPost, "/polls/2", `{"PollID": "two", "PollTitle": "Pets"}`, nil,
+			[]validation.FieldError{{Field: "PollID", Rule: "type", Message: "PollID must be a non-negative integer"}}},
+		{"put without options", http.MethodPut, "/polls/1", `{"PollID": 1, "PollTitle": "Pets"}`, nil,
+			[]validation.FieldError{{Field: "PollOptions", Rule: "required",
+				Message: "PollOptions is required when replacing a poll, send [] to remove every option or use PATCH"}}},
+		{"repeated option id", http.MethodPut, "/polls/1", `{"PollID": 1, "PollTitle": "Pets", "PollOptions": [{"PollOptionID": 1, "PollOptionText": "Cat"}, {"PollOptionID": 1, "PollOptionText": "Dog"}]}`, nil,
+			[]validation.FieldError{{Field: "PollOptions", Rule: "unique", Message: "PollOptions may not have two items with the same PollOptionID"}}},
+		{"repeated option id in a patch", http.MethodPatch, "/polls/1", `[{"op": "replace", "path": "/PollOptions/1/PollOptionID", "value": 1}]`,
+			http.Header{"Content-Type": {db.JSONPatchContentType}},
+			[]validation.FieldError{{Field: "PollOptions", Rule: "unique", Message: "PollOptions may not have two items with the same PollOptionID"}}},
+		{"patched into an invalid poll", http.MethodPatch, "/polls/1", `{"PollTitle": ""}`, http.Header{"Content-Type": {db.MergePatchContentType}},
+			[]validation.FieldError{{Field: "PollTitle", Rule: "required", Message: "PollTitle is required"}}},
+		{"option field in a patch", http.MethodPatch, "/polls/1", `[{"op": "replace", "path": "/PollOptions/1/PollOptionText", "value": "<b>"}]`,
+			http.Header{"Content-Type": {db.JSONPatchContentType}},
+			[]validation.FieldError{{Field: "PollOptions[1].PollOptionText", Rule: "safetext", Message: "PollOptionText contains characters that are not allowed"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, test.method, test.path, test.body, test.header)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d %s", recorder.Code, recorder.Body)
+			}
+			var body struct {
+				Errors []validation.FieldError `json:"errors"`
+			}
+			decode(t, recorder, &body)
+			if !reflect.DeepEqual(body.Errors, test.errors) {
+				t.Errorf("expected %+v, got %+v", test.errors, body.Errors)
+			}
+		})
+	}
+
+	var poll PollV1
+	decode(t, serve(r, http.MethodGet, "/polls/1", "", nil), &poll)
+	if poll.PollTitle != "Favorite Color" || poll.PollOptions[1].PollOptionText != "Brown" {
+		t.Errorf("rejected patches should leave the poll alone, got %+v", poll)
+	}
+}
+
+func TestPatchFormats(t *testing.T) {
+	mergePatch := http.Header{"Content-Type": {db.MergePatchContentType}}
+	jsonPatch := http.Header{"Content-Type": {db.JSONPatchContentType}}
+
+	tests := []struct {
+		name     string
+		body     string
+		header   http.Header
+		status   int
+		title    string
+		question string
+		options  int
+	}{
+		{"merge patch sets a field", `{"PollTitle": "Colours"}`, mergePatch, http.StatusOK, "Colours", "What is your favorite color?", 2},
+		{"merge patch null removes a field", `{"PollQuestion": null}`, mergePatch, http.StatusOK, "Favorite Color", "", 2},
+		{"merge patch replaces arrays whole", `{"PollOptions": [{"PollOptionID": 3, "PollOptionText": "Red"}]}`, mergePatch, http.StatusOK, "Favorite Color", "What is your favorite color?", 1},
+		{"json patch replaces", `[{"op": "replace", "path": "/PollTitle", "value": "Colours"}]`, jsonPatch, http.StatusOK, "Colours", "What is your favorite color?", 2},
+		{"json patch adds to an array", `[{"op": "add", "path": "/PollOptions/-", "value": {"PollOptionID": 3, "PollOptionText": "Red"}}]`, jsonPatch, http.StatusOK, "Favorite Color", "What is your favorite color?", 3},
+		{"json patch removes from an array", `[{"op": "remove", "path": "/PollOptions/0"}]`, jsonPatch, http.StatusOK, "Favorite Color", "What is your favorite color?", 1},
+		{"json patch failed test", `[{"op": "test", "path": "/PollTitle", "value": "Pets"}, {"op": "replace", "path": "/PollTitle", "value": "Colours"}]`, jsonPatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"json patch unknown path", `[{"op": "replace", "path": "/PollOptions/9/PollOptionText", "value": "Red"}]`, jsonPatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"merge patch body to json patch", `{"PollTitle": "Colours"}`, jsonPatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"merge patch changes the id", `{"PollID": 2}`, mergePatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"json patch changes the id", `[{"op": "replace", "path": "/PollID", "value": 2}]`, jsonPatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"json patch removes the id", `[{"op": "remove", "path": "/PollID"}]`, jsonPatch, http.StatusBadRequest, "Favorite Color", "What is your favorite color?", 2},
+		{"plain json", `{"PollTitle": "Colours"}`, http.Header{"Content-Type": {"application/json"}}, http.StatusUnsupportedMediaType, "Favorite Color", "What is your favorite color?", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestRouter(t)
+			addFavoriteColor(t, r)
+
+			recorder := serve(r, http.MethodPatch, "/polls/1", test.body, test.header)
+			if recorder.Code != test.status {
+				t.Fatalf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+			if test.status == http.StatusUnsupportedMediaType && recorder.Header().Get("Accept-Patch") == "" {
+				t.Error("expected an Accept-Patch header naming the supported formats")
+			}
+
+			var poll PollV1
+			decode(t, serve(r, http.MethodGet, "/polls/1", "", nil), &poll)
+			if poll.PollID != 1 || poll.PollTitle != test.title || poll.PollQuestion != test.question || len(poll.PollOptions) != test.options {
+				t.Errorf("unexpected poll %+v", poll)
+			}
+			if recorder := serve(r, http.MethodGet, "/polls/2", "", nil); recorder.Code != http.StatusBadRequest {
+				t.Errorf("a patch should never create another poll, got %d for poll 2", recorder.Code)
+			}
+		})
+	}
+}
+
+func TestPatchUnknownPoll(t *testing.T) {
+	r := newTestRouter(t)
+
+	recorder := serve(r, http.MethodPatch, "/polls/9", `{"PollTitle": "Pets"}`, http.Header{"Content-Type": {db.MergePatchContentType}})
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", recorder.Code)
+	}
+}
+
+func TestDeletePoll(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	if recorder := serve(r, http.MethodDelete, "/polls/1/polloption/2", "", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("delete option: expected 200, got %d", recorder.Code)
+	}
+	if recorder := serve(r, http.MethodGet, "/polls/1/polloption/2", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("deleted option: expected 400, got %d", recorder.Code)
+	}
+	if recorder := serve(r, http.MethodDelete, "/polls/1/polloption/2", "", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing option: expected 404, got %d", recorder.Code)
+	}
+	if recorder := serve(r, http.MethodDelete, "/polls/1", "", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("delete poll: expected 200, got %d", recorder.Code)
+	}
+	if recorder := serve(r, http.MethodGet, "/polls/1", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("deleted poll: expected 400, got %d", recorder.Code)
+	}
+}
+
+func TestHALLinks(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	recorder := serve(r, http.MethodGet, "/polls/1", "", http.Header{"Accept": {hal.MediaType}})
+	if contentType := recorder.Header().Get("Content-Type"); contentType != hal.MediaType {
+		t.Fatalf("expected %s, got %s", hal.MediaType, contentType)
+	}
+	var document struct {
+		Links hal.Links `json:"_links"`
+	}
+	decode(t, recorder, &document)
+	if self := document.Links["self"].Href; self != "http://polls.test/polls/1" {
+		t.Errorf("unexpected self link %q", self)
+	}
+}
+
+func TestRequestsNeedCredentials(t *testing.T) {
+	r := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/polls/", nil)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", recorder.Code)
+	}
+}
+
+// TestRoutesRequireRoles checks that poll managers and admins can manage
+// polls and everyone else can only read them
+func TestRoutesRequireRoles(t *testing.T) {
+	r := newTestRouter(t)
+	addFavoriteColor(t, r)
+
+	tests := []struct {
+		apiKey string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"admin-key", http.MethodPost, "/polls/2", `{"PollID": 2, "PollTitle": "Lunch", "PollQuestion": "What is for lunch?"}`, http.StatusOK},
+		{"admin-key", http.MethodPost, "/polls/2/polloption/1", `{"PollOptionID": 1, "PollOptionText": "Soup"}`, http.StatusOK},
+		{"admin-key", http.MethodPatch, "/polls/2", `{"PollTitle": "Dinner"}`, http.StatusOK},
+		{"reader-key", http.MethodGet, "/polls/2", "", http.StatusOK},
+		{"reader-key", http.MethodPost, "/polls/3", `{"PollID": 3, "PollTitle": "Lunch", "PollQuestion": "What is for lunch?"}`, http.StatusForbidden},
+		{"voter-key", http.MethodPut, "/polls/1/polloption/1", `{"PollOptionID": 1, "PollOptionText": "Red"}`, http.StatusForbidden},
+		{"voter-key", http.MethodDelete, "/polls/1", "", http.StatusForbidden},
+		{"admin-key", http.MethodDelete, "/polls/2", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set(auth.APIKeyHeader, test.apiKey)
+		if test.method == http.MethodPatch {
+			header.Set("Content-Type", "application/merge-patch+json")
+		}
+		if recorder := serve(r, test.method, test.path, test.body, header); recorder.Code != test.status {
+			t.Errorf("%s %s as %s: expected %d, got %d %s", test.method, test.path, test.apiKey, test.status, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestHealthCheckCountsCalls(t *testing.T) {
+	r := newTestRouter(t)
+	serve(r, http.MethodGet, "/polls/1", "", nil)
+
+	recorder := serve(r, http.MethodGet, "/polls/health", "", nil)
+	var health HealthCheckData
+	decode(t, recorder, &health)
+	if health.TotalCalls != 1 || health.TotalErrors != 1 {
+		t.Errorf("expected 1 call and 1 error, got %+v", health)
+	}
+}
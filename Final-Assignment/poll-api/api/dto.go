@@ -0,0 +1,83 @@
+package api
+
+import (
+	"poll-api/db"
+	"shared/versioning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response bodies, one set per API version. They are kept apart from the
+// structs in db so that the storage format and each version's wire format
+// can change independently.
+
+// PollV1 is a poll as v1 has always returned it
+type PollV1 struct {
+	PollID       uint
+	PollTitle    string
+	PollQuestion string
+	PollOptions  []PollOptionV1
+}
+
+type PollOptionV1 struct {
+	PollOptionID   uint
+	PollOptionText string
+}
+
+// PollV2 always lists PollOptions, as [] for a poll without options
+type PollV2 struct {
+	PollID       uint
+	PollTitle    string
+	PollQuestion string
+	PollOptions  []PollOptionV2
+}
+
+type PollOptionV2 struct {
+	PollOptionID   uint
+	PollOptionText string
+}
+
+func presentPoll(c *gin.Context, poll db.Poll) interface{} {
+	if versioning.From(c) == versioning.V2 {
+		pollOptions := make([]PollOptionV2, 0, len(poll.PollOptions))
+		for _, pollOption := range poll.PollOptions {
+			pollOptions = append(pollOptions, pollOptionV2(pollOption))
+		}
+		return PollV2{
+			PollID:       poll.PollID,
+			PollTitle:    poll.PollTitle,
+			PollQuestion: poll.PollQuestion,
+			PollOptions:  pollOptions,
+		}
+	}
+
+	// v1 passes a missing option list through as null
+	var pollOptions []PollOptionV1
+	if poll.PollOptions != nil {
+		pollOptions = make([]PollOptionV1, 0, len(poll.PollOptions))
+	}
+	for _, pollOption := range poll.PollOptions {
+		pollOptions = append(pollOptions, pollOptionV1(pollOption))
+	}
+	return PollV1{
+		PollID:       poll.PollID,
+		PollTitle:    poll.PollTitle,
+		PollQuestion: poll.PollQuestion,
+		PollOptions:  pollOptions,
+	}
+}
+
+func presentPollOption(c *gin.Context, pollOption db.PollOption) interface{} {
+	if versioning.From(c) == versioning.V2 {
+		return pollOptionV2(pollOption)
+	}
+	return pollOptionV1(pollOption)
+}
+
+func pollOptionV1(pollOption db.PollOption) PollOptionV1 {
+	return PollOptionV1{PollOptionID: pollOption.PollOptionID, PollOptionText: pollOption.PollOptionText}
+}
+
+func pollOptionV2(pollOption db.PollOption) PollOptionV2 {
+	return PollOptionV2{PollOptionID: pollOption.PollOptionID, PollOptionText: pollOption.PollOptionText}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"poll-api/db"
+	"shared/auth"
+	"shared/hal"
+	"shared/versioning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Links are built from the service's public base URL so that they work
+// for clients outside the docker network. Action links are only listed
+// when the caller is allowed to perform them.
+
+// baseURL includes the version prefix of the request's route group
+func (pollAPI *PollAPI) baseURL(c *gin.Context) string {
+	return hal.BaseURL(c, pollAPI.publicBaseURL) + versioning.Prefix(c)
+}
+
+func canManagePolls(c *gin.Context) bool {
+	principal := auth.PrincipalFrom(c)
+	return principal != nil && principal.HasRole(auth.RolePollManager, auth.RoleAdmin)
+}
+
+func (pollAPI *PollAPI) pollsLinks(c *gin.Context) hal.Links {
+	base := pollAPI.baseURL(c)
+	links := hal.Links{
+		"self": {Href: base + "/polls/"},
+		"poll": {Href: base + "/polls/{id}", Templated: true},
+	}
+	if canManagePolls(c) {
+		links.Action("create", http.MethodPost, base+"/polls/{id}")
+	}
+	return links
+}
+
+func (pollAPI *PollAPI) pollLinks(c *gin.Context, poll db.Poll) hal.Links {
+	base := pollAPI.baseURL(c)
+	self := fmt.Sprintf("%s/polls/%d", base, poll.PollID)
+	links := hal.Links{
+		"self":       {Href: self},
+		"collection": {Href: base + "/polls/"},
+		"option":     {Href: self + "/polloption/{optionid}", Templated: true},
+	}
+	if canManagePolls(c) {
+		links.Action("update", http.MethodPut, self)
+		links.Action("patch", http.MethodPatch, self)
+		links.Action("delete", http.MethodDelete, self)
+		links.Action("add-option", http.MethodPost, self+"/polloption/{optionid}")
+	}
+	return links
+}
+
+func (pollAPI *PollAPI) pollOptionLinks(c *gin.Context, pollID uint, pollOption db.PollOption) hal.Links {
+	base := pollAPI.baseURL(c)
+	poll := fmt.Sprintf("%s/polls/%d", base, pollID)
+	self := fmt.Sprintf("%s/polloption/%d", poll, pollOption.PollOptionID)
+	links := hal.Links{
+		"self": {Href: self},
+		"poll": {Href: poll},
+	}
+	if canManagePolls(c) {
+		links.Action("update", http.MethodPut, self)
+		links.Action("delete", http.MethodDelete, self)
+	}
+	return links
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteMiddleware is what the routes run besides authorization. Idempotent
+// guards the create routes and LimitWrites every write. Nil fields are
+// skipped.
+type RouteMiddleware struct {
+	Idempotent  gin.HandlerFunc
+	LimitWrites gin.HandlerFunc
+}
+
+// Routes registers the poll routes on group, which authenticates its
+// requests, each behind the roles it requires. The service registers them
+// once for each API version.
+func (pollAPI *PollAPI) Routes(group *gin.RouterGroup, middleware RouteMiddleware) {
+	anyRole := auth.RequireRole()
+	pollManager := auth.RequireRole(auth.RolePollManager, auth.RoleAdmin)
+	idempotent := orNext(middleware.Idempotent)
+	limitWrites := orNext(middleware.LimitWrites)
+
+	group.GET("polls/", anyRole, pollAPI.ListAllPolls)
+
+	group.GET("polls/:id", anyRole, pollAPI.GetPoll)
+	group.POST("polls/:id", pollManager, idempotent, limitWrites, pollAPI.AddPoll)
+	group.PUT("polls/:id", pollManager, limitWrites, pollAPI.UpdatePoll)
+	group.PATCH("polls/:id", pollManager, limitWrites, pollAPI.PatchPoll)
+	group.DELETE("polls/:id", pollManager, limitWrites, pollAPI.DeletePoll)
+
+	group.GET("polls/:id/polloption/:optionid", anyRole, pollAPI.GetPollOption)
+	group.POST("polls/:id/polloption/:optionid", pollManager, idempotent, limitWrites, pollAPI.AddPollOption)
+	group.PUT("polls/:id/polloption/:optionid", pollManager, limitWrites, pollAPI.UpdatePollOption)
+	group.DELETE("polls/:id/polloption/:optionid", pollManager, limitWrites, pollAPI.DeletePollOption)
+}
+
+// orNext is handler, or one that passes the request on when it is nil
+func orNext(handler gin.HandlerFunc) gin.HandlerFunc {
+	if handler == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return handler
+}
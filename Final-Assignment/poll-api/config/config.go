@@ -0,0 +1,132 @@
+// Package config is the typed configuration of the poll service. Settings
+// come from the defaults below, then the YAML or TOML file named by
+// -config or CONFIG_FILE, then the environment, then command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"poll-api/db"
+	"poll-api/logging"
+	"shared/auth"
+	"shared/configloader"
+	"shared/httpserver"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"shared/redisclient"
+	"shared/telemetry"
+	"shared/versioning"
+)
+
+type Config struct {
+	Host      string `config:"host" env:"HOST" flag:"h" usage:"Listen address"`
+	Port      uint   `config:"port" env:"PORT" flag:"p" usage:"REST port"`
+	GRPCPort  uint   `config:"grpc_port" env:"GRPC_PORT" flag:"g" usage:"gRPC port"`
+	LogLevel  string `config:"log_level" env:"LOG_LEVEL" usage:"debug, info, warn or error" reload:"true"`
+	LogFormat string `config:"log_format" env:"LOG_FORMAT" usage:"json or text"`
+	// Base of the links in responses, taken from each request when empty
+	PublicBaseURL string `config:"public_base_url" env:"PUBLIC_BASE_URL" usage:"Base URL of links in responses"`
+
+	Storage        db.Config          `config:"storage"`
+	Redis          redisclient.Config `config:"redis"`
+	HTTP           httpserver.Config  `config:"http"`
+	Tracing        telemetry.Config   `config:"tracing"`
+	Auth           auth.Config        `config:"auth"`
+	RateLimits     RateLimits         `config:"rate_limits"`
+	IdempotencyTTL time.Duration      `config:"idempotency_ttl" env:"IDEMPOTENCY_TTL" usage:"How long Idempotency-Key responses are kept"`
+	APIV1          versioning.Config  `config:"api_v1"`
+}
+
+// RateLimits holds one ratelimit.ParseRule spec per limited route
+type RateLimits struct {
+	Write string `config:"write" env:"RATE_LIMIT_WRITE" usage:"Limit on writes, <limit>/<window>[@ip|apikey|voter] or off" reload:"true"`
+	Auth  string `config:"auth" env:"RATE_LIMIT_AUTH" usage:"Limit on failed authentications per client IP, <limit>/<window> or off" reload:"true"`
+}
+
+func Defaults() Config {
+	return Config{
+		Host:           "0.0.0.0",
+		Port:           1082,
+		GRPCPort:       2082,
+		LogLevel:       "info",
+		LogFormat:      logging.FormatJSON,
+		Storage:        db.Defaults(),
+		Redis:          redisclient.Defaults(),
+		HTTP:           httpserver.Defaults(),
+		Tracing:        telemetry.Defaults(),
+		RateLimits:     RateLimits{Write: "60/1m@apikey", Auth: "20/1m"},
+		IdempotencyTTL: idempotency.DefaultTTL,
+		APIV1:          versioning.Defaults(),
+	}
+}
+
+// Parse registers the flags on flags, parses args and loads the config
+func Parse(flags *flag.FlagSet, args []string) (Config, *configloader.Sources, error) {
+	config := Defaults()
+	sources := configloader.RegisterFlags(flags, &config)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	config, err := Reload(sources)
+	return config, sources, err
+}
+
+// Reload loads the config again from the same sources
+func Reload(sources *configloader.Sources) (Config, error) {
+	config := Defaults()
+	if err := sources.Load(&config); err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Live is the running config, reloaded from the sources it was parsed from
+type Live = configloader.Live[Config]
+
+func NewLive(current Config, sources *configloader.Sources) *Live {
+	return configloader.NewLive(current, func() (Config, error) {
+		return Reload(sources)
+	})
+}
+
+// Validate reports every invalid setting at once
+func (config Config) Validate() error {
+	var errs []error
+	if config.Port == 0 || config.GRPCPort == 0 {
+		errs = append(errs, errors.New("Error: port and grpc_port must be set"))
+	}
+	if _, err := logging.ParseLevel(config.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if err := logging.ValidateFormat(config.LogFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.Storage.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := config.Redis.Options(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ratelimit.ParseRule(config.RateLimits.Write); err != nil {
+		errs = append(errs, fmt.Errorf("%w (RATE_LIMIT_WRITE)", err))
+	}
+	if _, err := ratelimit.ParseRule(config.RateLimits.Auth); err != nil {
+		errs = append(errs, fmt.Errorf("%w (RATE_LIMIT_AUTH)", err))
+	}
+	if config.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("Error: IDEMPOTENCY_TTL must be positive"))
+	}
+	if _, err := config.APIV1.V1Deprecation(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryPollData keeps polls in a map. Nothing survives a restart, so it is
+// meant for tests and local development.
+type MemoryPollData struct {
+	pollOptions
+	mutex sync.RWMutex
+	polls map[uint]Poll
+}
+
+func NewMemory() *MemoryPollData {
+	polls := &MemoryPollData{polls: map[uint]Poll{}}
+	polls.pollOptions = pollOptions{polls}
+	return polls
+}
+
+// copyPoll keeps callers from sharing the stored option slice
+func copyPoll(poll Poll) Poll {
+	poll.PollOptions = append(make([]PollOption, 0, len(poll.PollOptions)), poll.PollOptions...)
+	return poll
+}
+
+func (p *MemoryPollData) GetAllPolls(ctx context.Context) ([]Poll, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var polls []Poll
+	for _, poll := range p.polls {
+		polls = append(polls, copyPoll(poll))
+	}
+	sort.Slice(polls, func(i, j int) bool { return polls[i].PollID < polls[j].PollID })
+	return polls, nil
+}
+
+func (p *MemoryPollData) GetPoll(ctx context.Context, pollID uint) (Poll, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	poll, ok := p.polls[pollID]
+	if !ok {
+		return Poll{}, ErrNotFound
+	}
+	return copyPoll(poll), nil
+}
+
+func (p *MemoryPollData) AddPoll(ctx context.Context, poll Poll) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.polls[poll.PollID]; ok {
+		return errors.New("item already exists")
+	}
+
+	newPoll, _ := NewPoll(poll.PollID, poll.PollTitle, poll.PollQuestion)
+	p.polls[poll.PollID] = *newPoll
+	return nil
+}
+
+func (p *MemoryPollData) UpdatePoll(ctx context.Context, pollID uint, updateData Poll) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.polls[pollID]; !ok {
+		return errors.New("Item does not exist")
+	}
+
+	p.polls[pollID] = copyPoll(updateData)
+	return nil
+}
+
+func (p *MemoryPollData) modifyPoll(ctx context.Context, pollID uint, modify func(poll *Poll) error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stored, ok := p.polls[pollID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	poll := copyPoll(stored)
+	if err := modify(&poll); err != nil {
+		return err
+	}
+	p.polls[pollID] = poll
+	return nil
+}
+
+// PatchPoll holds the write lock while the patch is applied, so concurrent
+// writes are never lost
+func (p *MemoryPollData) PatchPoll(ctx context.Context, pollID uint, patch Patch, validate func(interface{}) error) (Poll, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	poll, ok := p.polls[pollID]
+	if !ok {
+		return Poll{}, ErrNotFound
+	}
+	document, err := json.Marshal(poll)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	patchedDocument, err := patch.Apply(document)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	var patchedPoll Poll
+	if err := json.Unmarshal(patchedDocument, &patchedPoll); err != nil {
+		return Poll{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	if patchedPoll.PollID != pollID {
+		return Poll{}, ErrIDChanged
+	}
+	if patchedPoll.PollOptions == nil {
+		patchedPoll.PollOptions = make([]PollOption, 0)
+	}
+	if err := validate(&patchedPoll); err != nil {
+		return Poll{}, err
+	}
+
+	p.polls[pollID] = copyPoll(patchedPoll)
+	return patchedPoll, nil
+}
+
+func (p *MemoryPollData) DeletePoll(ctx context.Context, pollID uint) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.polls[pollID]; !ok {
+		return errors.New("Attempted to delete a non-existent poll")
+	}
+	delete(p.polls, pollID)
+	return nil
+}
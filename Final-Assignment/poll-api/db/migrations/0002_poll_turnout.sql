@@ -0,0 +1,36 @@
+-- Votes per poll and UTC hour for the vote api's turnout analytics, kept
+-- up to date by a trigger on votes. A voter votes once per poll, so every
+-- vote is also a new voter of its hour.
+
+CREATE TABLE poll_turnout_hourly (
+    poll_id BIGINT NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    votes BIGINT NOT NULL,
+    PRIMARY KEY (poll_id, hour)
+);
+
+CREATE FUNCTION count_poll_turnout() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE poll_turnout_hourly SET votes = votes - 1
+        WHERE poll_id = OLD.poll_id
+            AND hour = date_trunc('hour', OLD.vote_date AT TIME ZONE 'UTC') AT TIME ZONE 'UTC';
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO poll_turnout_hourly (poll_id, hour, votes)
+        VALUES (NEW.poll_id, date_trunc('hour', NEW.vote_date AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', 1)
+        ON CONFLICT (poll_id, hour) DO UPDATE SET votes = poll_turnout_hourly.votes + 1;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER votes_count_poll_turnout
+    AFTER INSERT OR UPDATE OR DELETE ON votes
+    FOR EACH ROW EXECUTE FUNCTION count_poll_turnout();
+
+-- Votes cast before this migration
+INSERT INTO poll_turnout_hourly (poll_id, hour, votes)
+SELECT poll_id, date_trunc('hour', vote_date AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', count(*)
+FROM votes
+GROUP BY 1, 2;
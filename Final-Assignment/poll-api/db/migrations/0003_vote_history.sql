@@ -0,0 +1,23 @@
+-- Every choice a vote held, written by the vote api alongside the vote,
+-- and the per-poll policies on changing votes. Votes cast before this
+-- migration get one revision for their current choice.
+
+CREATE TABLE vote_revisions (
+    vote_id BIGINT NOT NULL REFERENCES votes (vote_id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    poll_id BIGINT NOT NULL,
+    poll_option_id BIGINT NOT NULL,
+    revised_at TIMESTAMPTZ NOT NULL,
+    source TEXT NOT NULL,
+    PRIMARY KEY (vote_id, revision)
+);
+
+INSERT INTO vote_revisions (vote_id, revision, poll_id, poll_option_id, revised_at, source)
+SELECT vote_id, 1, poll_id, poll_option_id, vote_date, 'cast' FROM votes;
+
+CREATE TABLE revote_policies (
+    poll_id BIGINT PRIMARY KEY REFERENCES polls (poll_id) ON DELETE CASCADE,
+    mode TEXT NOT NULL,
+    closes_at TIMESTAMPTZ,
+    window_minutes BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// pollDocuments is the part of a store the option methods are built on.
+// modifyPoll runs modify on the stored poll and saves the result, without
+// letting a concurrent write to the poll slip in between. It returns
+// ErrNotFound if the poll does not exist.
+type pollDocuments interface {
+	GetPoll(ctx context.Context, pollID uint) (Poll, error)
+	modifyPoll(ctx context.Context, pollID uint, modify func(poll *Poll) error) error
+}
+
+// pollOptions implements the poll option methods by rewriting the whole
+// poll, for stores that keep a poll as one document
+type pollOptions struct {
+	polls pollDocuments
+}
+
+func (p pollOptions) GetPollOptions(ctx context.Context, pollID uint) ([]PollOption, error) {
+	poll, err := p.polls.GetPoll(ctx, pollID)
+	if err != nil {
+		return make([]PollOption, 0), errors.New("Poll ID does not exist")
+	}
+
+	return poll.PollOptions, nil
+}
+
+func (p pollOptions) GetPollOption(ctx context.Context, pollID uint, pollOptionID uint) (PollOption, error) {
+	poll, err := p.polls.GetPoll(ctx, pollID)
+	if err != nil {
+		return PollOption{}, errors.New("Poll ID does not exist")
+	}
+
+	for _, pollOption := range poll.PollOptions {
+		if pollOption.PollOptionID == pollOptionID {
+			return pollOption, nil
+		}
+	}
+	slog.DebugContext(ctx, "Poll option ID does not exist for this poll", "poll_id", pollID, "poll_option_id", pollOptionID)
+	return PollOption{}, errors.New("Poll option ID does not exist for this poll")
+}
+
+func (p pollOptions) DoesPollOptionExist(ctx context.Context, pollID uint, pollOptionID uint) bool {
+	_, err := p.GetPollOption(ctx, pollID, pollOptionID)
+	return err == nil
+}
+
+func (p pollOptions) AddPollOption(ctx context.Context, pollID uint, newPollOption PollOption) error {
+	err := p.polls.modifyPoll(ctx, pollID, func(poll *Poll) error {
+		for _, pollOption := range poll.PollOptions {
+			if newPollOption.PollOptionID == pollOption.PollOptionID {
+				return errors.New("Poll Option ID already exists for this poll")
+			}
+		}
+
+		if len(poll.PollOptions) >= MaxPollOptions {
+			return ErrTooManyPollOptions
+		}
+
+		poll.PollOptions = append(poll.PollOptions, newPollOption)
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return errors.New("Poll ID does not exist")
+	}
+	return err
+}
+
+func (p pollOptions) UpdatePollOption(ctx context.Context, pollID uint, pollOptionID uint, updateData PollOption) error {
+	err := p.polls.modifyPoll(ctx, pollID, func(poll *Poll) error {
+		for index, pollOption := range poll.PollOptions {
+			if pollOption.PollOptionID == pollOptionID {
+				poll.PollOptions[index] = updateData
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if errors.Is(err, ErrNotFound) {
+		return errors.New("Error: Poll option does not exist")
+	}
+	return err
+}
+
+// DeletePollOption returns ErrNotFound if the poll or the option does not
+// exist
+func (p pollOptions) DeletePollOption(ctx context.Context, pollID uint, pollOptionID uint) error {
+	return p.polls.modifyPoll(ctx, pollID, func(poll *Poll) error {
+		for index, pollOption := range poll.PollOptions {
+			if pollOption.PollOptionID == pollOptionID {
+				poll.PollOptions = append(poll.PollOptions[:index], poll.PollOptions[index+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+
+	"shared/redisclient"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
@@ -14,71 +14,55 @@ import (
 
 const (
 	RedisNilError = "redis: nil"
-	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisPollKeyPrefix = "poll:"
+	// Checked by the maxpolloptions rule on Poll.PollOptions and by the stores
+	MaxPollOptions = 20
 )
 
+var ErrTooManyPollOptions = fmt.Errorf("a poll may have at most %d options", MaxPollOptions)
+
 type cache struct {
-	cacheClient *redis.Client
-	jsonHelper *rejson.Handler
-	context context.Context
+	cacheClient redis.UniversalClient
+}
+
+// jsonHandler runs RedisJSON commands under the caller's ctx
+func (c cache) jsonHandler(ctx context.Context) *rejson.Handler {
+	jsonHelper := rejson.NewReJSONHandler()
+	jsonHelper.SetGoRedisClientWithContext(ctx, c.cacheClient)
+	return jsonHelper
 }
 
 type Poll struct {
-	PollID uint
-	PollTitle string
-	PollQuestion string
-	PollOptions []PollOption
+	PollID uint `binding:"required"`
+	PollTitle string `binding:"required,max=100,safetext"`
+	PollQuestion string `binding:"max=500,safetext"`
+	PollOptions []PollOption `binding:"maxpolloptions,unique=PollOptionID,dive"`
 }
 
 type PollOption struct {
-	PollOptionID uint
-	PollOptionText string
+	PollOptionID uint `binding:"required"`
+	PollOptionText string `binding:"required,max=100,safetext"`
 }
 
 
 
+// PollData keeps each poll as a RedisJSON document
 type PollData struct {
 	cache
+	pollOptions
 }
 
-// Creat New Voter Data Handler 
-func New() (*PollData, error){
-
-	redisUrl := os.Getenv("REDIS_URL")
-
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-
-	return NewWithCacheInstance(redisUrl)
-
-}
-
-func NewWithCacheInstance(location string) (*PollData, error) {
-	
-	client := redis.NewClient(&redis.Options{
-		Addr:location,
-	})
-
-	ctx := context.Background()
-
-	err := client.Ping(ctx).Err()
-	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
-		return nil, err
-	}
-
-	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
+// NewWithClient keeps polls in redis through client, which is shared
+// with the rate limiter and the idempotency store
+func NewWithClient(client redis.UniversalClient) *PollData {
 
-	return &PollData{
+	polls := &PollData{
 		cache: cache{
 			cacheClient: client,
-			jsonHelper: jsonHelper,
-			context: ctx,
 		},
-	}, nil
+	}
+	polls.pollOptions = pollOptions{polls}
+	return polls
 }
 
 func isRedisNilError(err error) bool {
@@ -90,8 +74,8 @@ func redisPollKeyFromId(id int) string {
 	return fmt.Sprintf("%s%d", RedisPollKeyPrefix, id)
 }
 
-func (p *PollData) getPollFromRedis(key string, poll *Poll) error {
-	pollObject, err := p.jsonHelper.JSONGet(key, ".")
+func (p *PollData) getPollFromRedis(ctx context.Context, key string, poll *Poll) error {
+	pollObject, err := p.jsonHandler(ctx).JSONGet(key, ".")
 	if err != nil {
 		return err
 	}
@@ -124,14 +108,22 @@ func NewPollOption(	pollOptionID uint, pollOptionText string) (*PollOption, erro
 }
 
 
-func (p *PollData) GetAllPolls() ([]Poll, error){
+func (p *PollData) GetAllPolls(ctx context.Context) ([]Poll, error){
 	var polls []Poll
 	var poll Poll
 
 	pattern := RedisPollKeyPrefix + "*"
-	ks, _ := p.cacheClient.Keys(p.context, pattern).Result()
+	var ks []string
+	err := redisclient.ForEachNode(ctx, p.cacheClient, func(ctx context.Context, node redis.Cmdable) error {
+		nodeKeys, err := node.Keys(ctx, pattern).Result()
+		ks = append(ks, nodeKeys...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 	for _,key := range ks {
-		err := p.getPollFromRedis(key, &poll)
+		err := p.getPollFromRedis(ctx, key, &poll)
 		if err != nil {
 			return nil, err
 		}
@@ -140,11 +132,11 @@ func (p *PollData) GetAllPolls() ([]Poll, error){
 	return polls, nil
 } 
 
-func (p *PollData) GetPoll(pollID uint) (Poll, error){
+func (p *PollData) GetPoll(ctx context.Context, pollID uint) (Poll, error){
 	
 	var poll Poll
 	pattern := redisPollKeyFromId(int(pollID))
-	err := p.getPollFromRedis(pattern, &poll)
+	err := p.getPollFromRedis(ctx, pattern, &poll)
 	if err != nil {
 		return Poll{}, err
 	}
@@ -152,154 +144,140 @@ func (p *PollData) GetPoll(pollID uint) (Poll, error){
 	return poll, nil
 } 
 
-func (p *PollData) AddPoll(poll Poll) error {
+func (p *PollData) AddPoll(ctx context.Context, poll Poll) error {
 
 	redisKey := redisPollKeyFromId(int(poll.PollID))
 	var existingItem Poll
-	if err := p.getPollFromRedis(redisKey, &existingItem); err == nil {
+	if err := p.getPollFromRedis(ctx, redisKey, &existingItem); err == nil {
 		return errors.New("item already exists")
 	}
 
 	newPoll, _ := NewPoll(poll.PollID, poll.PollTitle, poll.PollQuestion)
 
-	if _, err := p.jsonHelper.JSONSet(redisKey, ".", newPoll); err != nil {
+	if _, err := p.jsonHandler(ctx).JSONSet(redisKey, ".", newPoll); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (p *PollData) UpdatePoll(pollID uint, updateData Poll) error {
-
-	redisKey := redisPollKeyFromId(int(pollID))
-	var existingPoll Poll
-	if err := p.getPollFromRedis(redisKey, &existingPoll); err != nil {
+// UpdatePoll replaces the stored poll inside a redis transaction, so it
+// never recreates a poll deleted while it runs
+func (p *PollData) UpdatePoll(ctx context.Context, pollID uint, updateData Poll) error {
+	_, err := p.watchPoll(ctx, pollID, func(document []byte) (Poll, error) {
+		return updateData, nil
+	})
+	if errors.Is(err, ErrNotFound) {
 		return errors.New("Item does not exist")
 	}
-
-	updatedPoll:= removeZeroValuesFromUpdateData(existingPoll, updateData)
-
-	if _, err := p.jsonHelper.JSONSet(redisKey, ".", updatedPoll); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func removeZeroValuesFromUpdateData(oldData Poll, updateData Poll) Poll {
-	if updateData.PollTitle == "" {
-		updateData.PollTitle = oldData.PollTitle
-	}
-	if updateData.PollQuestion == "" {
-		updateData.PollQuestion = oldData.PollQuestion
-	}
-	if updateData.PollOptions == nil {
-		updateData.PollOptions = oldData.PollOptions
-	}
-
-	return updateData
+	return err
 }
 
-func (p *PollData) DeletePoll(pollID uint) error {
-	pattern := redisPollKeyFromId(int(pollID))
-	numDeleted, err := p.cacheClient.Del(p.context, pattern).Result()
-	if err != nil {
-		return err
-	}
-	if numDeleted == 0 {
-		return errors.New("Attempted to delete a non-existent poll")
-	}
-
-	return nil
-}
+// PatchPoll applies patch to the stored poll inside a redis transaction so
+// that concurrent writes are never lost. validate is run on the patched
+// poll before it is saved.
+func (p *PollData) PatchPoll(ctx context.Context, pollID uint, patch Patch, validate func(interface{}) error) (Poll, error) {
+	return p.watchPoll(ctx, pollID, func(document []byte) (Poll, error) {
+		patchedDocument, err := patch.Apply(document)
+		if err != nil {
+			return Poll{}, err
+		}
 
-func (p *PollData) GetPollOptions(pollID uint) ([]PollOption, error){
-	poll, err := p.GetPoll(pollID)
-	if err != nil {
-		return make([]PollOption, 0) , errors.New("Poll ID does not exist")
-	}
-	
-	return poll.PollOptions, nil
+		var patchedPoll Poll
+		if err := json.Unmarshal(patchedDocument, &patchedPoll); err != nil {
+			return Poll{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		if patchedPoll.PollID != pollID {
+			return Poll{}, ErrIDChanged
+		}
+		if patchedPoll.PollOptions == nil {
+			patchedPoll.PollOptions = make([]PollOption, 0)
+		}
+		if err := validate(&patchedPoll); err != nil {
+			return Poll{}, err
+		}
+		return patchedPoll, nil
+	})
 }
 
-func (p *PollData) GetPollOption(pollID uint, pollOptionID uint) (PollOption, error){
-	poll, err := p.GetPoll(pollID)
-	if err != nil {
-		return PollOption{} , errors.New("Poll ID does not exist")
-	} 
-
-	for _, pollOption := range poll.PollOptions{
-		if pollOption.PollOptionID == pollOptionID{
-			return pollOption, nil
+func (p *PollData) modifyPoll(ctx context.Context, pollID uint, modify func(poll *Poll) error) error {
+	_, err := p.watchPoll(ctx, pollID, func(document []byte) (Poll, error) {
+		var poll Poll
+		if err := json.Unmarshal(document, &poll); err != nil {
+			return Poll{}, err
 		}
-	}
-	log.Println("Error: Poll option ID does not exist for this poll")
-	return PollOption{}, errors.New("Poll option ID does not exist for this poll")
+		if err := modify(&poll); err != nil {
+			return Poll{}, err
+		}
+		return poll, nil
+	})
+	return err
 }
 
-func (p *PollData) DoesPollOptionExist(pollID uint, pollOptionID uint) bool {
-	_, err := p.GetPollOption(pollID, pollOptionID)
-	if err == nil { return true } 
-	return false 
-}
+// watchPoll saves the poll update makes from the stored document. The poll's
+// key is watched while update runs, and the whole read and write is retried
+// if another client changes the poll in the meantime.
+func (p *PollData) watchPoll(ctx context.Context, pollID uint, update func(document []byte) (Poll, error)) (Poll, error) {
+	redisKey := redisPollKeyFromId(int(pollID))
+	var savedPoll Poll
 
-func (p *PollData) AddPollOption(pollID uint, newPollOption PollOption) error{
-	poll, err := p.GetPoll(pollID)
-	if err != nil {
-		return errors.New("Poll ID does not exist")
-	} 
+	applyUpdate := func(tx *redis.Tx) error {
+		getCmd := redis.NewCmd(ctx, "JSON.GET", redisKey, ".")
+		_ = tx.Process(ctx, getCmd)
+		document, err := getCmd.Text()
+		if err != nil {
+			if isRedisNilError(err) {
+				return ErrNotFound
+			}
+			return err
+		}
 
-	for _, pollOption := range poll.PollOptions{
-		if newPollOption.PollOptionID == pollOption.PollOptionID{
-			return errors.New("Poll Option ID already exists for this poll")
+		poll, err := update([]byte(document))
+		if err != nil {
+			return err
+		}
+		if poll.PollOptions == nil {
+			poll.PollOptions = make([]PollOption, 0)
 		}
-	}
 
-	poll.PollOptions = append(poll.PollOptions, newPollOption)
-	p.UpdatePoll(pollID, poll)
-	return nil
-}
+		pollObject, err := json.Marshal(poll)
+		if err != nil {
+			return err
+		}
 
-func (p *PollData) UpdatePollOption(pollID uint, pollOptionID uint, updateData PollOption) error{
-	
-	oldData,err := p.GetPollOption(pollID, pollOptionID)
-	if err != nil {
-		return errors.New("Error: Poll option does not exist")
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", redisKey, ".", pollObject)
+			return nil
+		})
+		if err == nil {
+			savedPoll = poll
+		}
+		return err
 	}
 
-	updateData = removeZeroValuesFromPollOptionUpdateData(oldData, updateData)
-	poll,_ := p.GetPoll(pollID)
-	for index, pollOption := range poll.PollOptions{
-		if pollOption.PollOptionID == pollOptionID{
-			poll.PollOptions[index] = updateData
-			break
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		err := p.cacheClient.Watch(ctx, applyUpdate, redisKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
 		}
+		if err != nil {
+			return Poll{}, err
+		}
+		return savedPoll, nil
 	}
-	p.UpdatePoll(pollID, poll)
-	return nil
-}
 
-func removeZeroValuesFromPollOptionUpdateData(oldData PollOption, updateData PollOption) PollOption{
-	if updateData.PollOptionText == "" {
-		updateData.PollOptionText = oldData.PollOptionText
-	}
-	return updateData
+	return Poll{}, ErrPatchConflict
 }
 
-func (p *PollData) DeletePollOption(pollID uint, pollOptionID uint) error{
-	poll, err := p.GetPoll(pollID)
+func (p *PollData) DeletePoll(ctx context.Context, pollID uint) error {
+	pattern := redisPollKeyFromId(int(pollID))
+	numDeleted, err := p.cacheClient.Del(ctx, pattern).Result()
 	if err != nil {
-		return errors.New("Poll does not exists")
+		return err
 	}
-
-	for index,pollOption := range poll.PollOptions{
-		if pollOption.PollOptionID == pollOptionID{
-			poll.PollOptions = append(poll.PollOptions[:index], poll.PollOptions[index+1:]... )
-			break
-		}
+	if numDeleted == 0 {
+		return errors.New("Attempted to delete a non-existent poll")
 	}
 
-	p.UpdatePoll(pollID, poll)
 	return nil
 }
-
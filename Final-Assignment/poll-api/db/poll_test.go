@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// startRedis runs a throwaway redis-server for one test. RedisJSON is
+// loaded from REDISJSON_MODULE unless the server has it built in, as
+// redis-stack-server does. The test is skipped when neither is available.
+func startRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	binary := ""
+	for _, name := range []string{"redis-stack-server", "redis-server"} {
+		if path, err := exec.LookPath(name); err == nil {
+			binary = path
+			break
+		}
+	}
+	if binary == "" {
+		t.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	args := []string{"--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir()}
+	if module := os.Getenv("REDISJSON_MODULE"); module != "" {
+		args = append(args, "--loadmodule", module)
+	}
+	server := exec.Command(binary, args...)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	t.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(ctx).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := client.Do(ctx, "JSON.SET", "probe", ".", "{}").Err(); err != nil {
+		t.Skip("redis-server has no RedisJSON, set REDISJSON_MODULE to the module's path: ", err)
+	}
+	client.Del(ctx, "probe")
+	return client
+}
+
+// The document stores rewrite the whole poll for every option change, so
+// option writes running alongside patches must neither lose each other's
+// changes nor undo a patch
+func TestDocumentStoresKeepConcurrentWrites(t *testing.T) {
+	stores := map[string]func(t *testing.T) PollStore{
+		"memory": func(t *testing.T) PollStore { return NewMemory() },
+		"redis":  func(t *testing.T) PollStore { return NewWithClient(startRedis(t)) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			polls := newStore(t)
+			ctx := context.Background()
+			if err := polls.AddPoll(ctx, Poll{PollID: 1, PollTitle: "Busy"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var wait sync.WaitGroup
+			var mutex sync.Mutex
+			added, patched := 0, 0
+			for id := uint(1); id <= 10; id++ {
+				wait.Add(2)
+				go func(id uint) {
+					defer wait.Done()
+					err := polls.AddPollOption(ctx, 1, PollOption{id, fmt.Sprint("Option ", id)})
+					if err != nil && !errors.Is(err, ErrPatchConflict) {
+						t.Error(err)
+					}
+					mutex.Lock()
+					defer mutex.Unlock()
+					if err == nil {
+						added++
+					}
+				}(id)
+				go func() {
+					defer wait.Done()
+					_, err := polls.PatchPoll(ctx, 1, MergePatch(`{"PollQuestion": "Still busy?"}`), func(interface{}) error { return nil })
+					if err != nil && !errors.Is(err, ErrPatchConflict) {
+						t.Error(err)
+					}
+					mutex.Lock()
+					defer mutex.Unlock()
+					if err == nil {
+						patched++
+					}
+				}()
+			}
+			wait.Wait()
+
+			poll, err := polls.GetPoll(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(poll.PollOptions) != added {
+				t.Errorf("expected the %d added options, got %+v", added, poll.PollOptions)
+			}
+			if patched > 0 && poll.PollQuestion != "Still busy?" {
+				t.Errorf("an option write undid a patch: %+v", poll)
+			}
+
+			if err := polls.DeletePollOption(ctx, 1, 99); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting a missing option should fail with ErrNotFound, got %v", err)
+			}
+			if err := polls.DeletePollOption(ctx, 2, 1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting an option of a missing poll should fail with ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
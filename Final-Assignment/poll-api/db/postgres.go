@@ -0,0 +1,379 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostgresDefaultLocation = "postgres://postgres@0.0.0.0:5432/postgres?sslmode=disable"
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	postgresForeignKeyViolation = "23503"
+)
+
+// PostgresPollData keeps polls and their options in the polls and
+// poll_options tables. Votes reference both, so options with votes cannot
+// be removed.
+type PostgresPollData struct {
+	db *sql.DB
+}
+
+// queryer is a *sql.DB or a *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func NewPostgres(location string) (*PostgresPollData, error) {
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("Error connecting to postgres: %w", err)
+	}
+	if err := Migrate(database); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return &PostgresPollData{db: database}, nil
+}
+
+// inUse turns the error of a write that would orphan votes into ErrInUse
+func inUse(err error) error {
+	var postgresError *pq.Error
+	if errors.As(err, &postgresError) && postgresError.Code == postgresForeignKeyViolation {
+		return fmt.Errorf("%w: %s", ErrInUse, postgresError.Message)
+	}
+	return err
+}
+
+// readPoll loads a poll with its options. With lock the poll's row stays
+// locked until tx ends.
+func readPoll(ctx context.Context, q queryer, pollID uint, lock bool) (Poll, error) {
+	query := `SELECT poll_id, poll_title, poll_question FROM polls WHERE poll_id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var poll Poll
+	err := q.QueryRowContext(ctx, query, pollID).Scan(&poll.PollID, &poll.PollTitle, &poll.PollQuestion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Poll{}, ErrNotFound
+	}
+	if err != nil {
+		return Poll{}, err
+	}
+
+	poll.PollOptions, err = readPollOptions(ctx, q, pollID)
+	if err != nil {
+		return Poll{}, err
+	}
+	return poll, nil
+}
+
+func readPollOptions(ctx context.Context, q queryer, pollID uint) ([]PollOption, error) {
+	rows, err := q.QueryContext(ctx, `SELECT poll_option_id, poll_option_text FROM poll_options
+		WHERE poll_id = $1 ORDER BY position`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pollOptions := make([]PollOption, 0)
+	for rows.Next() {
+		var pollOption PollOption
+		if err := rows.Scan(&pollOption.PollOptionID, &pollOption.PollOptionText); err != nil {
+			return nil, err
+		}
+		pollOptions = append(pollOptions, pollOption)
+	}
+	return pollOptions, rows.Err()
+}
+
+// writePoll overwrites a stored poll and its option list inside tx.
+// Options missing from poll are deleted, which fails with ErrInUse if
+// they have votes.
+func writePoll(ctx context.Context, tx *sql.Tx, pollID uint, poll Poll) error {
+	result, err := tx.ExecContext(ctx, `UPDATE polls SET poll_title = $2, poll_question = $3 WHERE poll_id = $1`,
+		pollID, poll.PollTitle, poll.PollQuestion)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound
+	}
+
+	optionIDs := make(pq.Int64Array, len(poll.PollOptions))
+	for i, pollOption := range poll.PollOptions {
+		optionIDs[i] = int64(pollOption.PollOptionID)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM poll_options WHERE poll_id = $1 AND NOT (poll_option_id = ANY($2))`,
+		pollID, optionIDs)
+	if err != nil {
+		return inUse(err)
+	}
+
+	for position, pollOption := range poll.PollOptions {
+		_, err := tx.ExecContext(ctx, `INSERT INTO poll_options (poll_id, poll_option_id, poll_option_text, position)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (poll_id, poll_option_id)
+			DO UPDATE SET poll_option_text = EXCLUDED.poll_option_text, position = EXCLUDED.position`,
+			pollID, pollOption.PollOptionID, pollOption.PollOptionText, position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PostgresPollData) GetAllPolls(ctx context.Context) ([]Poll, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT poll_id, poll_title, poll_question FROM polls ORDER BY poll_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var polls []Poll
+	index := map[uint]int{}
+	for rows.Next() {
+		poll := Poll{PollOptions: make([]PollOption, 0)}
+		if err := rows.Scan(&poll.PollID, &poll.PollTitle, &poll.PollQuestion); err != nil {
+			return nil, err
+		}
+		index[poll.PollID] = len(polls)
+		polls = append(polls, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	optionRows, err := p.db.QueryContext(ctx, `SELECT poll_id, poll_option_id, poll_option_text FROM poll_options
+		ORDER BY poll_id, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var pollID uint
+		var pollOption PollOption
+		if err := optionRows.Scan(&pollID, &pollOption.PollOptionID, &pollOption.PollOptionText); err != nil {
+			return nil, err
+		}
+		// Skip options of polls added since the first query
+		if i, ok := index[pollID]; ok {
+			polls[i].PollOptions = append(polls[i].PollOptions, pollOption)
+		}
+	}
+	return polls, optionRows.Err()
+}
+
+func (p *PostgresPollData) GetPoll(ctx context.Context, pollID uint) (Poll, error) {
+	return readPoll(ctx, p.db, pollID, false)
+}
+
+func (p *PostgresPollData) AddPoll(ctx context.Context, poll Poll) error {
+	result, err := p.db.ExecContext(ctx, `INSERT INTO polls (poll_id, poll_title, poll_question) VALUES ($1, $2, $3)
+		ON CONFLICT (poll_id) DO NOTHING`, poll.PollID, poll.PollTitle, poll.PollQuestion)
+	if err != nil {
+		return err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("item already exists")
+	}
+	return nil
+}
+
+func (p *PostgresPollData) UpdatePoll(ctx context.Context, pollID uint, updateData Poll) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writePoll(ctx, tx, pollID, updateData); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return errors.New("Item does not exist")
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// PatchPoll locks the poll's row for the length of a transaction, so
+// concurrent writes are never lost
+func (p *PostgresPollData) PatchPoll(ctx context.Context, pollID uint, patch Patch, validate func(interface{}) error) (Poll, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Poll{}, err
+	}
+	defer tx.Rollback()
+
+	poll, err := readPoll(ctx, tx, pollID, true)
+	if err != nil {
+		return Poll{}, err
+	}
+	document, err := json.Marshal(poll)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	patchedDocument, err := patch.Apply(document)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	var patchedPoll Poll
+	if err := json.Unmarshal(patchedDocument, &patchedPoll); err != nil {
+		return Poll{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	if patchedPoll.PollID != pollID {
+		return Poll{}, ErrIDChanged
+	}
+	if patchedPoll.PollOptions == nil {
+		patchedPoll.PollOptions = make([]PollOption, 0)
+	}
+	if err := validate(&patchedPoll); err != nil {
+		return Poll{}, err
+	}
+
+	if err := writePoll(ctx, tx, pollID, patchedPoll); err != nil {
+		return Poll{}, err
+	}
+	return patchedPoll, tx.Commit()
+}
+
+// DeletePoll removes the poll's options with it, and fails with ErrInUse
+// if anyone voted in the poll
+func (p *PostgresPollData) DeletePoll(ctx context.Context, pollID uint) error {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM polls WHERE poll_id = $1`, pollID)
+	if err != nil {
+		return inUse(err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("Attempted to delete a non-existent poll")
+	}
+	return nil
+}
+
+func (p *PostgresPollData) GetPollOptions(ctx context.Context, pollID uint) ([]PollOption, error) {
+	poll, err := readPoll(ctx, p.db, pollID, false)
+	if err != nil {
+		return make([]PollOption, 0), errors.New("Poll ID does not exist")
+	}
+	return poll.PollOptions, nil
+}
+
+func (p *PostgresPollData) GetPollOption(ctx context.Context, pollID uint, pollOptionID uint) (PollOption, error) {
+	pollOption := PollOption{PollOptionID: pollOptionID}
+	err := p.db.QueryRowContext(ctx, `SELECT poll_option_text FROM poll_options WHERE poll_id = $1 AND poll_option_id = $2`,
+		pollID, pollOptionID).Scan(&pollOption.PollOptionText)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PollOption{}, errors.New("Poll option ID does not exist for this poll")
+	}
+	if err != nil {
+		return PollOption{}, err
+	}
+	return pollOption, nil
+}
+
+func (p *PostgresPollData) DoesPollOptionExist(ctx context.Context, pollID uint, pollOptionID uint) bool {
+	_, err := p.GetPollOption(ctx, pollID, pollOptionID)
+	return err == nil
+}
+
+// AddPollOption locks the poll's row while it counts the options, so
+// concurrent adds can never take a poll past MaxPollOptions
+func (p *PostgresPollData) AddPollOption(ctx context.Context, pollID uint, newPollOption PollOption) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `SELECT poll_id FROM polls WHERE poll_id = $1 FOR UPDATE`, pollID).Scan(&pollID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("Poll ID does not exist")
+	}
+	if err != nil {
+		return err
+	}
+
+	var options, exists, nextPosition int
+	err = tx.QueryRowContext(ctx, `SELECT count(*), count(*) FILTER (WHERE poll_option_id = $2), COALESCE(max(position) + 1, 0)
+		FROM poll_options WHERE poll_id = $1`, pollID, newPollOption.PollOptionID).Scan(&options, &exists, &nextPosition)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return errors.New("Poll Option ID already exists for this poll")
+	}
+	if options >= MaxPollOptions {
+		return ErrTooManyPollOptions
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO poll_options (poll_id, poll_option_id, poll_option_text, position)
+		VALUES ($1, $2, $3, $4)`, pollID, newPollOption.PollOptionID, newPollOption.PollOptionText, nextPosition)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePollOption rewrites one option in place. Votes follow the option
+// if its id changes.
+func (p *PostgresPollData) UpdatePollOption(ctx context.Context, pollID uint, pollOptionID uint, updateData PollOption) error {
+	result, err := p.db.ExecContext(ctx, `UPDATE poll_options SET poll_option_id = $3, poll_option_text = $4
+		WHERE poll_id = $1 AND poll_option_id = $2`,
+		pollID, pollOptionID, updateData.PollOptionID, updateData.PollOptionText)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errors.New("Error: Poll option does not exist")
+	}
+	return nil
+}
+
+// DeletePollOption fails with ErrInUse if anyone voted for the option, and
+// with ErrNotFound if the poll has no such option
+func (p *PostgresPollData) DeletePollOption(ctx context.Context, pollID uint, pollOptionID uint) error {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM poll_options WHERE poll_id = $1 AND poll_option_id = $2`,
+		pollID, pollOptionID)
+	if err != nil {
+		return inUse(err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startPostgres runs a throwaway postgres cluster for one test and returns
+// its address. initdb and postgres are looked up in POSTGRES_BIN, for
+// example /usr/lib/postgresql/16/bin, and then on the PATH. The test is
+// skipped when they are not installed.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	binaries := map[string]string{}
+	for _, name := range []string{"initdb", "postgres"} {
+		if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+			binaries[name] = filepath.Join(dir, name)
+		} else if path, err := exec.LookPath(name); err == nil {
+			binaries[name] = path
+		} else {
+			t.Skip("postgres is not installed, set POSTGRES_BIN to the directory holding initdb and postgres")
+		}
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres refuses to run as root")
+	}
+
+	dataDir := t.TempDir()
+	initdb := exec.Command(binaries["initdb"], "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, output)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binaries["postgres"], "-D", dataDir, "-p", fmt.Sprint(port),
+		"-c", "listen_addresses=127.0.0.1", "-c", "unix_socket_directories="+dataDir, "-c", "fsync=off")
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Signal(os.Interrupt)
+		server.Wait()
+	})
+
+	location := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for database.Ping() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("postgres did not start")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return location
+}
+
+func newTestPostgres(t *testing.T) *PostgresPollData {
+	t.Helper()
+	polls, err := NewPostgres(startPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { polls.db.Close() })
+	return polls
+}
+
+// castVote adds a voter and their vote for an option, as the voter and
+// vote apis would
+func castVote(t *testing.T, polls *PostgresPollData, voteID uint, pollID uint, pollOptionID uint) {
+	t.Helper()
+	_, err := polls.db.Exec(`INSERT INTO voters (voter_id, first_name) VALUES ($1, 'Test')`, voteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = polls.db.Exec(`INSERT INTO votes (vote_id, voter_id, poll_id, poll_option_id, vote_date)
+		VALUES ($1, $1, $2, $3, now())`, voteID, pollID, pollOptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresMigrateTwice(t *testing.T) {
+	polls := newTestPostgres(t)
+	if err := Migrate(polls.db); err != nil {
+		t.Fatal("second migration run: ", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	if err := polls.db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(names) {
+		t.Errorf("expected %d applied migrations, got %d", len(names), applied)
+	}
+}
+
+func TestPostgresPollOptions(t *testing.T) {
+	polls := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := polls.AddPoll(ctx, Poll{PollID: 1, PollTitle: "Lunch", PollQuestion: "Where?"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := polls.AddPoll(ctx, Poll{PollID: 1, PollTitle: "Again"}); err == nil {
+		t.Error("adding a poll twice should fail")
+	}
+	for _, option := range []PollOption{{3, "Pizza"}, {1, "Tacos"}, {2, "Sushi"}} {
+		if err := polls.AddPollOption(ctx, 1, option); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := polls.AddPollOption(ctx, 1, PollOption{1, "Tacos again"}); err == nil {
+		t.Error("adding an option id twice should fail")
+	}
+
+	poll, err := polls.GetPoll(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Poll{PollID: 1, PollTitle: "Lunch", PollQuestion: "Where?",
+		PollOptions: []PollOption{{3, "Pizza"}, {1, "Tacos"}, {2, "Sushi"}}}
+	if !reflect.DeepEqual(poll, expected) {
+		t.Errorf("options should keep the order they were added in:\n got %+v\nwant %+v", poll, expected)
+	}
+
+	castVote(t, polls, 10, 1, 2)
+	if err := polls.UpdatePollOption(ctx, 1, 2, PollOption{4, "Ramen"}); err != nil {
+		t.Fatal(err)
+	}
+	var votedFor uint
+	if err := polls.db.QueryRow(`SELECT poll_option_id FROM votes WHERE vote_id = 10`).Scan(&votedFor); err != nil {
+		t.Fatal(err)
+	}
+	if votedFor != 4 {
+		t.Errorf("vote should follow its renumbered option, it is for option %d", votedFor)
+	}
+
+	if err := polls.DeletePollOption(ctx, 1, 4); !errors.Is(err, ErrInUse) {
+		t.Errorf("deleting an option with votes should fail with ErrInUse, got %v", err)
+	}
+	if err := polls.DeletePollOption(ctx, 1, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a missing option should fail with ErrNotFound, got %v", err)
+	}
+	err = polls.UpdatePoll(ctx, 1, Poll{PollID: 1, PollTitle: "Lunch", PollOptions: []PollOption{{1, "Tacos"}}})
+	if !errors.Is(err, ErrInUse) {
+		t.Errorf("dropping an option with votes in UpdatePoll should fail with ErrInUse, got %v", err)
+	}
+	if err := polls.DeletePoll(ctx, 1); !errors.Is(err, ErrInUse) {
+		t.Errorf("deleting a poll with votes should fail with ErrInUse, got %v", err)
+	}
+
+	err = polls.UpdatePoll(ctx, 1, Poll{PollID: 1, PollTitle: "Dinner", PollOptions: []PollOption{{4, "Ramen"}, {5, "Curry"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := polls.GetPollOptions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []PollOption{{4, "Ramen"}, {5, "Curry"}}; !reflect.DeepEqual(options, want) {
+		t.Errorf("UpdatePoll should replace the options: got %+v, want %+v", options, want)
+	}
+
+	if _, err := polls.db.Exec(`DELETE FROM votes`); err != nil {
+		t.Fatal(err)
+	}
+	if err := polls.DeletePoll(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := polls.GetPoll(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+// Like the document stores, which count and add options in one guarded
+// write, this one must hold MaxPollOptions when options are added at once
+func TestPostgresAddPollOptionConcurrently(t *testing.T) {
+	polls := newTestPostgres(t)
+	ctx := context.Background()
+	if err := polls.AddPoll(ctx, Poll{PollID: 1, PollTitle: "Busy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wait sync.WaitGroup
+	results := make(chan error, 2*MaxPollOptions)
+	for id := 1; id <= 2*MaxPollOptions; id++ {
+		wait.Add(1)
+		go func(id uint) {
+			defer wait.Done()
+			results <- polls.AddPollOption(ctx, 1, PollOption{id, fmt.Sprint("Option ", id)})
+		}(uint(id))
+	}
+	wait.Wait()
+	close(results)
+
+	added := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			added++
+		case !errors.Is(err, ErrTooManyPollOptions):
+			t.Error(err)
+		}
+	}
+	if added != MaxPollOptions {
+		t.Errorf("expected %d options to be added, got %d", MaxPollOptions, added)
+	}
+}
+
+func TestPostgresPatchPoll(t *testing.T) {
+	polls := newTestPostgres(t)
+	ctx := context.Background()
+	if err := polls.AddPoll(ctx, Poll{PollID: 1, PollTitle: "Lunch"}); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := polls.PatchPoll(ctx, 1, MergePatch(`{"PollTitle":"Dinner","PollOptions":[{"PollOptionID":1,"PollOptionText":"Soup"}]}`),
+		func(interface{}) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := polls.GetPoll(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(patched, stored) {
+		t.Errorf("stored poll %+v differs from the patched one %+v", stored, patched)
+	}
+
+	if _, err := polls.PatchPoll(ctx, 1, MergePatch(`{"PollID":2}`), func(interface{}) error { return nil }); !errors.Is(err, ErrIDChanged) {
+		t.Errorf("expected ErrIDChanged, got %v", err)
+	}
+	if _, err := polls.PatchPoll(ctx, 9, MergePatch(`{}`), func(interface{}) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The storage backends, picked with STORAGE_BACKEND or storage.backend
+const (
+	RedisBackend    = "redis"
+	MemoryBackend   = "memory"
+	PostgresBackend = "postgres"
+)
+
+// ErrInUse is returned by stores that keep votes alongside polls, when a
+// write would remove a poll or option that has votes
+var ErrInUse = errors.New("record is referenced by votes")
+
+// PollStore is everything the REST and gRPC handlers need from a storage
+// backend. PollData (RedisJSON), MemoryPollData and PostgresPollData
+// implement it.
+type PollStore interface {
+	GetAllPolls(ctx context.Context) ([]Poll, error)
+	GetPoll(ctx context.Context, pollID uint) (Poll, error)
+	AddPoll(ctx context.Context, poll Poll) error
+	UpdatePoll(ctx context.Context, pollID uint, updateData Poll) error
+	PatchPoll(ctx context.Context, pollID uint, patch Patch, validate func(interface{}) error) (Poll, error)
+	DeletePoll(ctx context.Context, pollID uint) error
+
+	GetPollOptions(ctx context.Context, pollID uint) ([]PollOption, error)
+	GetPollOption(ctx context.Context, pollID uint, pollOptionID uint) (PollOption, error)
+	DoesPollOptionExist(ctx context.Context, pollID uint, pollOptionID uint) bool
+	AddPollOption(ctx context.Context, pollID uint, newPollOption PollOption) error
+	UpdatePollOption(ctx context.Context, pollID uint, pollOptionID uint, updateData PollOption) error
+	DeletePollOption(ctx context.Context, pollID uint, pollOptionID uint) error
+}
+
+var (
+	_ PollStore = (*PollData)(nil)
+	_ PollStore = (*MemoryPollData)(nil)
+	_ PollStore = (*PostgresPollData)(nil)
+)
+
+// Config picks the storage backend
+type Config struct {
+	Backend     string `config:"backend" env:"STORAGE_BACKEND" usage:"Storage backend: redis, memory or postgres"`
+	DatabaseURL string `config:"database_url" env:"DATABASE_URL" usage:"Postgres connection URL" secret:"url"`
+}
+
+func Defaults() Config {
+	return Config{Backend: RedisBackend, DatabaseURL: PostgresDefaultLocation}
+}
+
+// Validate checks the backend name
+func (config Config) Validate() error {
+	switch config.Backend {
+	case RedisBackend, MemoryBackend, PostgresBackend:
+		return nil
+	}
+	return fmt.Errorf("Error: STORAGE_BACKEND must be redis, memory or postgres, got %s", config.Backend)
+}
+
+// New opens the store config names, "redis" (the default), "memory" or
+// "postgres". The redis store uses redisClient, which the other backends
+// ignore.
+func New(config Config, redisClient redis.UniversalClient) (PollStore, error) {
+	switch config.Backend {
+	case "", RedisBackend:
+		return NewWithClient(redisClient), nil
+
+	case MemoryBackend:
+		return NewMemory(), nil
+
+	case PostgresBackend:
+		databaseUrl := config.DatabaseURL
+		if databaseUrl == "" {
+			databaseUrl = PostgresDefaultLocation
+		}
+		polls, err := NewPostgres(databaseUrl)
+		if err != nil {
+			return nil, err
+		}
+		return polls, nil
+	}
+	return nil, config.Validate()
+}
@@ -0,0 +1,100 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+
+	"poll-api/db"
+	"poll-api/logging"
+	pollv1 "poll-api/pb/poll/v1"
+	"shared/auth"
+	"shared/ratelimit"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PollServer serves the poll service over gRPC from the same PollStore as
+// the REST handlers
+type PollServer struct {
+	pollv1.UnimplementedPollServiceServer
+	db db.PollStore
+}
+
+func NewPollServer(dbHandler db.PollStore) *PollServer {
+	return &PollServer{db: dbHandler}
+}
+
+// NewServer creates a gRPC server with the poll service registered. Every
+// call must carry credentials accepted by authenticator, and failed
+// authentications count against the REST api's auth limit.
+func NewServer(dbHandler db.PollStore, authenticator auth.Authenticator, limiter *ratelimit.Limiter) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			limiter.FailureUnaryInterceptor("auth"),
+			auth.UnaryInterceptor(authenticator, nil),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			limiter.FailureStreamInterceptor("auth"),
+			auth.StreamInterceptor(authenticator, nil),
+		),
+	)
+	pollv1.RegisterPollServiceServer(server, NewPollServer(dbHandler))
+	return server
+}
+
+func (s *PollServer) GetPoll(ctx context.Context, req *pollv1.GetPollRequest) (*pollv1.Poll, error) {
+	poll, err := s.db.GetPoll(ctx, uint(req.GetPollId()))
+	if err != nil {
+		slog.WarnContext(ctx, "Poll not found", "error", err)
+		return nil, status.Errorf(codes.NotFound, "poll %d not found", req.GetPollId())
+	}
+	return toProtoPoll(poll), nil
+}
+
+func (s *PollServer) ListPolls(ctx context.Context, req *pollv1.ListPollsRequest) (*pollv1.ListPollsResponse, error) {
+	polls, err := s.db.GetAllPolls(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting all polls", "error", err)
+		return nil, status.Error(codes.Internal, "could not list polls")
+	}
+
+	response := &pollv1.ListPollsResponse{}
+	for _, poll := range polls {
+		response.Polls = append(response.Polls, toProtoPoll(poll))
+	}
+	return response, nil
+}
+
+func (s *PollServer) GetPollOption(ctx context.Context, req *pollv1.GetPollOptionRequest) (*pollv1.PollOption, error) {
+	pollOption, err := s.db.GetPollOption(ctx, uint(req.GetPollId()), uint(req.GetPollOptionId()))
+	if err != nil {
+		slog.WarnContext(ctx, "Poll option not found", "error", err)
+		return nil, status.Errorf(codes.NotFound, "option %d of poll %d not found", req.GetPollOptionId(), req.GetPollId())
+	}
+	return toProtoPollOption(pollOption), nil
+}
+
+func toProtoPoll(poll db.Poll) *pollv1.Poll {
+	message := &pollv1.Poll{
+		PollId:       uint64(poll.PollID),
+		PollTitle:    poll.PollTitle,
+		PollQuestion: poll.PollQuestion,
+	}
+	for _, pollOption := range poll.PollOptions {
+		message.PollOptions = append(message.PollOptions, toProtoPollOption(pollOption))
+	}
+	return message
+}
+
+func toProtoPollOption(pollOption db.PollOption) *pollv1.PollOption {
+	return &pollv1.PollOption{
+		PollOptionId:   uint64(pollOption.PollOptionID),
+		PollOptionText: pollOption.PollOptionText,
+	}
+}
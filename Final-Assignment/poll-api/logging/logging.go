@@ -0,0 +1,118 @@
+// Package logging sends the service's logs, and the standard logger's,
+// through log/slog at a level that can be changed while the service runs.
+// Records logged with a request context carry its request and trace IDs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup installs the slog handler and sets the level, one of debug, info,
+// warn or error. format is json or text.
+func Setup(name string, format string) error {
+	if err := SetLevel(name); err != nil {
+		return err
+	}
+	if err := ValidateFormat(format); err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stderr, options)
+	if format == FormatText {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+	handler = contextHandler{Handler: handler}
+	slog.SetDefault(slog.New(handler))
+
+	// What libraries still print with the log package is reported as
+	// errors
+	log.SetOutput(slog.NewLogLogger(handler, slog.LevelError).Writer())
+	log.SetFlags(0)
+	return nil
+}
+
+// SetLevel changes the level of every logger set up by Setup
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return parsed, fmt.Errorf("Error: log level must be debug, info, warn or error, got %s", name)
+	}
+	return parsed, nil
+}
+
+func ValidateFormat(format string) error {
+	if format != FormatJSON && format != FormatText {
+		return fmt.Errorf("Error: log format must be json or text, got %s", format)
+	}
+	return nil
+}
+
+// contextHandler adds the request ID and the trace and span IDs found in
+// the context of each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFrom(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// AccessLog logs every request at the info level once it is answered
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		slog.LogAttrs(c.Request.Context(), slog.LevelInfo, "Request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"poll-api/api"
+	"poll-api/config"
+	"poll-api/db"
+	"shared/auth"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"shared/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+var httpMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+var openAPIParam = regexp.MustCompile(`\{(\w+)\}`)
+
+type openAPIOperation struct {
+	Parameters []struct {
+		Name string
+		In   string
+	}
+}
+
+func documentedOperations(t *testing.T, source []byte) map[string]openAPIOperation {
+	var document struct {
+		OpenAPI string
+		Paths   map[string]map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(source, &document); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Fatalf("expected an OpenAPI 3.1.0 document, got %q", document.OpenAPI)
+	}
+
+	operations := map[string]openAPIOperation{}
+	for path, pathItem := range document.Paths {
+		for method, rawOperation := range pathItem {
+			if !httpMethods[method] {
+				continue
+			}
+			var operation openAPIOperation
+			if err := json.Unmarshal(rawOperation, &operation); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			operations[strings.ToUpper(method)+" "+path] = operation
+		}
+	}
+	return operations
+}
+
+// routesByVersion groups the registered routes by version prefix, with
+// the prefix removed. Unprefixed routes are listed under "".
+func routesByVersion(r *gin.Engine) map[string]map[string]bool {
+	routes := map[string]map[string]bool{"": {}, "v1": {}, "v2": {}}
+	for _, route := range r.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		version := ""
+		for _, prefix := range []string{"v1", "v2"} {
+			if strings.HasPrefix(path, "/"+prefix+"/") {
+				version = prefix
+				path = strings.TrimPrefix(path, "/"+prefix)
+			}
+		}
+		routes[version][route.Method+" "+path] = true
+	}
+	return routes
+}
+
+func TestOpenAPIDocumentsMatchRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := setupRouter(&api.PollAPI{}, auth.Chain{}, &ratelimit.Limiter{}, &idempotency.Store{}, nil, &config.Live{})
+	routes := routesByVersion(r)
+
+	v1 := documentedOperations(t, api.OpenAPIDocument)
+	checkDocumented(t, "openapi.json", v1, routes[""])
+	for route := range routes["v1"] {
+		if _, ok := v1[route]; !ok {
+			t.Errorf("route /v1%s is missing from openapi.json", route)
+		}
+	}
+
+	v2 := documentedOperations(t, api.OpenAPIDocumentV2)
+	checkDocumented(t, "openapi-v2.json", v2, routes["v2"])
+}
+
+// checkDocumented compares the routes of one version with its document
+// in both directions and checks that path parameters are declared
+func checkDocumented(t *testing.T, name string, documented map[string]openAPIOperation, routed map[string]bool) {
+	for route := range routed {
+		if _, ok := documented[route]; !ok {
+			t.Errorf("route %s is missing from %s", route, name)
+		}
+	}
+
+	for route, operation := range documented {
+		if !routed[route] {
+			t.Errorf("%s documents %s which is not routed", name, route)
+		}
+
+		declared := map[string]bool{}
+		for _, parameter := range operation.Parameters {
+			if parameter.In == "path" {
+				declared[parameter.Name] = true
+			}
+		}
+		for _, match := range openAPIParam.FindAllStringSubmatch(route, -1) {
+			if !declared[match[1]] {
+				t.Errorf("%s: %s does not declare path parameter %s", name, route, match[1])
+			}
+			delete(declared, match[1])
+		}
+		for name := range declared {
+			t.Errorf("%s declares path parameter %s which is not in its path", route, name)
+		}
+	}
+}
+
+// TestPublishedSchemas checks that the schemas served to clients carry
+// the rules enforced on request bodies
+func TestPublishedSchemas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := validation.RegisterMax("maxpolloptions", db.MaxPollOptions); err != nil {
+		t.Fatal(err)
+	}
+	r := setupRouter(&api.PollAPI{}, auth.Chain{}, &ratelimit.Limiter{}, &idempotency.Store{}, nil, &config.Live{})
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/schemas/poll.json", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/schema+json" {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	var schema struct {
+		ID         string `json:"$id"`
+		Required   []string
+		Properties map[string]struct {
+			MaxItems  int `json:"maxItems"`
+			MaxLength int `json:"maxLength"`
+			Items     struct{ Required []string }
+		}
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.ID != "/schemas/poll.json" || len(schema.Required) != 2 || schema.Properties["PollTitle"].MaxLength != 100 {
+		t.Errorf("unexpected poll schema %s", recorder.Body)
+	}
+	if options := schema.Properties["PollOptions"]; options.MaxItems != db.MaxPollOptions || len(options.Items.Required) != 2 {
+		t.Errorf("expected PollOptions to allow %d options, got %+v", db.MaxPollOptions, options)
+	}
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/schemas/polloption.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("poll option schema: expected 200, got %d", recorder.Code)
+	}
+}
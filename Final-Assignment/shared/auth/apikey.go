@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIKeyAuthenticator authenticates static keys sent in the X-API-Key
+// header. Keys are held as hashes so lookups do not leak key contents.
+type APIKeyAuthenticator struct {
+	principals map[[sha256.Size]byte]*Principal
+}
+
+// ParseAPIKeys reads a comma separated list of key:role[|role...][:voterID]
+// entries, e.g. "k1:admin,k2:voter:7". Roles must be known and keys with
+// the voter role must name their voter.
+func ParseAPIKeys(spec string) (*APIKeyAuthenticator, error) {
+	authenticator := &APIKeyAuthenticator{principals: map[[sha256.Size]byte]*Principal{}}
+
+	for index, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+			return nil, errors.New("Error: malformed API key entry " + strconv.Itoa(index+1))
+		}
+
+		principal := &Principal{Subject: "apikey-" + strconv.Itoa(index+1)}
+		for _, role := range strings.Split(fields[1], "|") {
+			if !Role(role).Known() {
+				return nil, errors.New("Error: unknown role " + strconv.Quote(role) + " in API key entry " + strconv.Itoa(index+1))
+			}
+			principal.Roles = append(principal.Roles, Role(role))
+		}
+
+		if len(fields) == 3 {
+			voterID, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				return nil, errors.New("Error: malformed voter id in API key entry " + strconv.Itoa(index+1))
+			}
+			principal.VoterID = uint(voterID)
+			principal.Subject = "voter-" + fields[2]
+		}
+		if principal.HasRole(RoleVoter) && principal.VoterID == 0 {
+			return nil, errors.New("Error: missing voter id in API key entry " + strconv.Itoa(index+1))
+		}
+
+		authenticator.principals[sha256.Sum256([]byte(fields[0]))] = principal
+	}
+
+	return authenticator, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Role string
+
+const (
+	RoleAdmin       Role = "admin"
+	RolePollManager Role = "poll-manager"
+	RoleVoter       Role = "voter"
+	RoleReader      Role = "reader"
+)
+
+// Known reports whether role is one of the roles above
+func (role Role) Known() bool {
+	switch role {
+	case RoleAdmin, RolePollManager, RoleVoter, RoleReader:
+		return true
+	}
+	return false
+}
+
+const (
+	APIKeyHeader        = "X-API-Key"
+	AuthorizationHeader = "Authorization"
+	principalContextKey = "auth.principal"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials supplied")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller of a request. VoterID is only
+// meaningful for principals holding the voter role.
+type Principal struct {
+	Subject string
+	Roles   []Role
+	VoterID uint
+}
+
+func (p *Principal) HasRole(roles ...Role) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authenticator resolves the caller of a request. Implementations return
+// ErrNoCredentials when the request carries none of the credentials they
+// understand so that they can be chained.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator in turn until one finds credentials
+type Chain []Authenticator
+
+func (chain Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range chain {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Config holds the credentials the services accept
+type Config struct {
+	APIKeys               string `config:"api_keys" env:"API_KEYS" usage:"Comma separated key:role[|role...][:voterID] entries" secret:"true"`
+	JWTIssuer             string `config:"jwt_issuer" env:"JWT_ISSUER" usage:"Required iss claim of JWTs"`
+	JWTAudience           string `config:"jwt_audience" env:"JWT_AUDIENCE" usage:"Required aud claim of JWTs"`
+	JWTHS256Secret        string `config:"jwt_hs256_secret" env:"JWT_HS256_SECRET" usage:"Shared secret of HS256 JWTs" secret:"true"`
+	JWTRS256PublicKeyFile string `config:"jwt_rs256_public_key_file" env:"JWT_RS256_PUBLIC_KEY_FILE" usage:"PEM public key of RS256 JWTs"`
+}
+
+// New builds the authenticator chain from the API keys and the JWT keys
+// in config
+func New(config Config) (Authenticator, error) {
+	var chain Chain
+
+	if config.APIKeys != "" {
+		apiKeyAuthenticator, err := ParseAPIKeys(config.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, apiKeyAuthenticator)
+	}
+
+	jwtAuthenticator := &JWTAuthenticator{
+		Issuer:   config.JWTIssuer,
+		Audience: config.JWTAudience,
+	}
+	if config.JWTHS256Secret != "" {
+		jwtAuthenticator.HMACSecret = []byte(config.JWTHS256Secret)
+	}
+	if config.JWTRS256PublicKeyFile != "" {
+		publicKey, err := LoadRSAPublicKey(config.JWTRS256PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		jwtAuthenticator.RSAPublicKey = publicKey
+	}
+	if jwtAuthenticator.HMACSecret != nil || jwtAuthenticator.RSAPublicKey != nil {
+		chain = append(chain, jwtAuthenticator)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("Error: no authentication configured, set API_KEYS, JWT_HS256_SECRET or JWT_RS256_PUBLIC_KEY_FILE")
+	}
+	return chain, nil
+}
+
+// Middleware authenticates every request and stores the principal on the
+// gin context for RequireRole and the handlers
+func Middleware(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Error authenticating request", "error", err)
+			c.Header("WWW-Authenticate", `Bearer realm="polling"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+// RequireRole rejects principals holding none of the given roles. With no
+// roles any authenticated principal is allowed.
+func RequireRole(roles ...Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if principal == nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if len(roles) > 0 && !principal.HasRole(roles...) {
+			slog.WarnContext(c.Request.Context(), "Principal lacks required role", "subject", principal.Subject, "roles", roles)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+func PrincipalFrom(c *gin.Context) *Principal {
+	value, ok := c.Get(principalContextKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
+
+// ForwardHeaders returns the credential headers of an incoming request so
+// they can be passed on to downstream services
+func ForwardHeaders(r *http.Request) http.Header {
+	header := http.Header{}
+	for _, name := range []string{AuthorizationHeader, APIKeyHeader} {
+		if value := r.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	return header
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var hmacSecret = []byte("test-secret")
+
+func signedToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func validClaims(roles ...Role) Claims {
+	return Claims{
+		Roles: roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "someone",
+			Issuer:    "polling-test",
+			Audience:  jwt.ClaimStrings{"polling"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func requestWith(header string, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		valid bool
+	}{
+		{"single key", "k1:admin", true},
+		{"several roles", "k1:admin|reader", true},
+		{"voter id", "k1:voter:7", true},
+		{"blank entries", " k1:admin , ,k2:reader", true},
+		{"missing role", "k1", false},
+		{"missing key", ":admin", false},
+		{"too many fields", "k1:voter:7:8", false},
+		{"bad voter id", "k1:voter:seven", false},
+		{"unknown role", "k1:superuser", false},
+		{"unknown role among known ones", "k1:admin|root", false},
+		{"voter without voter id", "k1:voter", false},
+		{"voter id 0", "k1:voter:0", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseAPIKeys(test.spec)
+			if test.valid && err != nil {
+				t.Errorf("expected %q to parse, got %v", test.spec, err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected %q to be rejected", test.spec)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	authenticator, err := ParseAPIKeys("admin-key:admin|reader,voter-key:voter:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		err     error
+		roles   []Role
+		voterID uint
+	}{
+		{"admin", "admin-key", nil, []Role{RoleAdmin, RoleReader}, 0},
+		{"voter", "voter-key", nil, []Role{RoleVoter}, 7},
+		{"unknown key", "other-key", ErrInvalidCredentials, nil, 0},
+		{"no key", "", ErrNoCredentials, nil, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(requestWith(APIKeyHeader, test.key))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if test.err != nil {
+				return
+			}
+			if !principal.HasRole(test.roles...) || len(principal.Roles) != len(test.roles) || principal.VoterID != test.voterID {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacOnly := &JWTAuthenticator{HMACSecret: hmacSecret, Issuer: "polling-test", Audience: "polling"}
+	rsaOnly := &JWTAuthenticator{RSAPublicKey: &rsaKey.PublicKey}
+
+	expired := validClaims(RoleAdmin)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims(RoleAdmin)
+	noExpiry.ExpiresAt = nil
+	wrongIssuer := validClaims(RoleAdmin)
+	wrongIssuer.Issuer = "elsewhere"
+	wrongAudience := validClaims(RoleAdmin)
+	wrongAudience.Audience = jwt.ClaimStrings{"elsewhere"}
+	voter := validClaims(RoleVoter)
+	voter.VoterID = 7
+	voterWithoutID := validClaims(RoleVoter)
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims(RoleAdmin)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authenticator *JWTAuthenticator
+		header        string
+		err           error
+	}{
+		{"valid HS256", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, voter), nil},
+		{"valid RS256", rsaOnly, "Bearer " + signedToken(t, jwt.SigningMethodRS256, rsaKey, validClaims(RoleAdmin)), nil},
+		{"bad HS256 signature", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, []byte("other-secret"), validClaims(RoleAdmin)), ErrInvalidCredentials},
+		{"bad RS256 signature", rsaOnly, "Bearer " + signedToken(t, jwt.SigningMethodRS256, otherKey, validClaims(RoleAdmin)), ErrInvalidCredentials},
+		{"expired", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, expired), ErrInvalidCredentials},
+		{"no expiry", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, noExpiry), ErrInvalidCredentials},
+		{"wrong issuer", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, wrongIssuer), ErrInvalidCredentials},
+		{"wrong audience", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, wrongAudience), ErrInvalidCredentials},
+		{"HS256 when only RS256 is configured", rsaOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, validClaims(RoleAdmin)), ErrInvalidCredentials},
+		{"RS256 when only HS256 is configured", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodRS256, rsaKey, validClaims(RoleAdmin)), ErrInvalidCredentials},
+		{"voter without voter_id", hmacOnly, "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, voterWithoutID), ErrInvalidCredentials},
+		{"alg none", hmacOnly, "Bearer " + unsigned, ErrInvalidCredentials},
+		{"malformed token", hmacOnly, "Bearer not-a-token", ErrInvalidCredentials},
+		{"not a bearer token", hmacOnly, "Basic dXNlcjpwYXNz", ErrNoCredentials},
+		{"no header", hmacOnly, "", ErrNoCredentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := test.authenticator.Authenticate(requestWith(AuthorizationHeader, test.header))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if test.err == nil && (principal.Subject != "someone" || len(principal.Roles) != 1) {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+
+	principal, err := hmacOnly.Authenticate(requestWith(AuthorizationHeader, "Bearer "+signedToken(t, jwt.SigningMethodHS256, hmacSecret, voter)))
+	if err != nil || principal.VoterID != 7 || !principal.HasRole(RoleVoter) {
+		t.Errorf("expected voter 7, got %+v %v", principal, err)
+	}
+}
+
+func TestNewNeedsCredentials(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("expected an error without API keys or JWT keys")
+	}
+	if _, err := New(Config{APIKeys: "k1"}); err == nil {
+		t.Error("expected a malformed API key entry to be rejected")
+	}
+}
+
+// TestRequireRole serves routes behind Middleware and RequireRole the way
+// the services do, with API keys and HS256 tokens chained
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := New(Config{
+		APIKeys:        "admin-key:admin,reader-key:reader,voter-key:voter:7",
+		JWTIssuer:      "polling-test",
+		JWTAudience:    "polling",
+		JWTHS256Secret: string(hmacSecret),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	authenticated := r.Group("/", Middleware(authenticator))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	authenticated.GET("/any", RequireRole(), ok)
+	authenticated.GET("/admin", RequireRole(RoleAdmin), ok)
+	authenticated.GET("/voter", RequireRole(RoleVoter, RoleAdmin), ok)
+	r.GET("/unauthenticated", RequireRole(), ok)
+
+	managerToken := "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, validClaims(RolePollManager))
+	badToken := "Bearer " + signedToken(t, jwt.SigningMethodHS256, []byte("other-secret"), validClaims(RoleAdmin))
+	voterWithoutIDToken := "Bearer " + signedToken(t, jwt.SigningMethodHS256, hmacSecret, validClaims(RoleVoter))
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		status int
+	}{
+		{"no credentials", "/any", "", "", http.StatusUnauthorized},
+		{"unknown API key", "/any", APIKeyHeader, "other-key", http.StatusUnauthorized},
+		{"bad token", "/any", AuthorizationHeader, badToken, http.StatusUnauthorized},
+		{"any role", "/any", APIKeyHeader, "reader-key", http.StatusOK},
+		{"any role by token", "/any", AuthorizationHeader, managerToken, http.StatusOK},
+		{"admin", "/admin", APIKeyHeader, "admin-key", http.StatusOK},
+		{"reader on an admin route", "/admin", APIKeyHeader, "reader-key", http.StatusForbidden},
+		{"token without the role", "/admin", AuthorizationHeader, managerToken, http.StatusForbidden},
+		{"voter", "/voter", APIKeyHeader, "voter-key", http.StatusOK},
+		{"admin on a voter route", "/voter", APIKeyHeader, "admin-key", http.StatusOK},
+		{"reader on a voter route", "/voter", APIKeyHeader, "reader-key", http.StatusForbidden},
+		{"voter token without voter_id", "/voter", AuthorizationHeader, voterWithoutIDToken, http.StatusUnauthorized},
+		{"no principal", "/unauthenticated", APIKeyHeader, "admin-key", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			if recorder.Code != test.status {
+				t.Errorf("expected %d, got %d", test.status, recorder.Code)
+			}
+			if test.status == http.StatusUnauthorized && test.path != "/unauthenticated" && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestForwardHeaders(t *testing.T) {
+	r := requestWith(APIKeyHeader, "admin-key")
+	r.Header.Set(AuthorizationHeader, "Bearer token")
+	r.Header.Set("Cookie", "session=1")
+	header := ForwardHeaders(r)
+	if header.Get(APIKeyHeader) != "admin-key" || header.Get(AuthorizationHeader) != "Bearer token" || header.Get("Cookie") != "" {
+		t.Errorf("unexpected forwarded headers %v", header)
+	}
+}
@@ -0,0 +1,95 @@
+package configloader
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Live is the running config, of the struct type C. Reload swaps in the
+// settings marked reload:"true" and hands the result to the OnReload
+// funcs, which apply them.
+type Live[C any] struct {
+	lock     sync.RWMutex
+	current  C
+	reload   func() (C, error)
+	onReload []func(C)
+}
+
+// NewLive starts from current. reload loads and validates the config
+// again from the sources current came from.
+func NewLive[C any](current C, reload func() (C, error)) *Live[C] {
+	return &Live[C]{current: current, reload: reload}
+}
+
+func (l *Live[C]) Current() C {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.current
+}
+
+// OnReload registers apply to be called with every reloaded config
+func (l *Live[C]) OnReload(apply func(C)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.onReload = append(l.onReload, apply)
+}
+
+// Reload loads the config again from its sources. An invalid config is
+// rejected whole and the running one kept.
+func (l *Live[C]) Reload() error {
+	next, err := l.reload()
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	updated := l.current
+	applied, restart := Apply(&updated, &next)
+	l.current = updated
+	onReload := l.onReload
+	l.lock.Unlock()
+
+	for _, key := range restart {
+		slog.Warn("Config setting changed, restart to apply it", "setting", key)
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+	slog.Info("Config reloaded", "settings", applied)
+	for _, apply := range onReload {
+		apply(updated)
+	}
+	return nil
+}
+
+// WatchSignals reloads the config on every SIGHUP until ctx is done
+func (l *Live[C]) WatchSignals(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangups)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangups:
+				if err := l.Reload(); err != nil {
+					slog.Error("Error reloading config, keeping the running one", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Handler serves the running config with its secrets redacted
+func (l *Live[C]) Handler(c *gin.Context) {
+	current := l.Current()
+	c.JSON(http.StatusOK, Redact(&current))
+}
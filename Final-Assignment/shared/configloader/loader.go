@@ -0,0 +1,355 @@
+// Package configloader fills a service's config struct from defaults, a
+// YAML or TOML file, the environment and command line flags, and keeps
+// the running config up to date when it is reloaded.
+package configloader
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The loader reads any struct whose fields carry these tags:
+//
+//	config:"name"   key in the config file; nested structs become sections
+//	env:"NAME"      environment variable
+//	flag:"name"     command line flag, by default the dotted key with
+//	                dashes, e.g. -rate-limits-write
+//	usage:"text"    flag help
+//	secret:"true"   redacted at /debug/config; secret:"url" only hides the
+//	                password of a URL
+//	reload:"true"   applied again when the config is reloaded
+//
+// Fields may be strings, bools, ints, uints, time.Durations, string slices
+// (comma separated in the environment and on the command line) or structs
+// of those.
+
+// FileEnv names the config file when -config is not given
+const FileEnv = "CONFIG_FILE"
+
+// Redacted replaces secrets at /debug/config
+const Redacted = "REDACTED"
+
+// Sources remembers where a config comes from, so it can be loaded again
+// on reload with the same precedence: defaults, then the file, then the
+// environment, then flags.
+type Sources struct {
+	file  string
+	flags map[string]string
+}
+
+type field struct {
+	key   string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// fields lists the leaf fields of the struct config points to, by dotted
+// key
+func fields(config interface{}) []field {
+	var leaves []field
+	var walk func(prefix string, value reflect.Value)
+	walk = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			name, ok := structField.Tag.Lookup("config")
+			if !ok {
+				continue
+			}
+			key := prefix + name
+			if structField.Type.Kind() == reflect.Struct && structField.Type != reflect.TypeOf(time.Time{}) {
+				walk(key+".", value.Field(i))
+				continue
+			}
+			leaves = append(leaves, field{key: key, value: value.Field(i), tag: structField.Tag})
+		}
+	}
+	walk("", reflect.ValueOf(config).Elem())
+	return leaves
+}
+
+func (f field) flagName() string {
+	if name := f.tag.Get("flag"); name != "" {
+		return name
+	}
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+// flagValue records a flag until Load applies it over the file and the
+// environment
+type flagValue struct {
+	name   string
+	isBool bool
+	flags  map[string]string
+	shown  string
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.shown
+}
+
+func (v *flagValue) Set(value string) error {
+	v.flags[v.name] = value
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool { return v.isBool }
+
+// RegisterFlags adds -config and a flag for every field of config, showing
+// the values config holds as defaults
+func RegisterFlags(flags *flag.FlagSet, config interface{}) *Sources {
+	sources := &Sources{flags: map[string]string{}}
+	flags.StringVar(&sources.file, "config", os.Getenv(FileEnv), "YAML or TOML config file ("+FileEnv+")")
+
+	for _, f := range fields(config) {
+		usage := f.tag.Get("usage")
+		if env := f.tag.Get("env"); env != "" {
+			usage += " (" + env + ")"
+		}
+		shown := ""
+		if f.tag.Get("secret") == "" {
+			shown = formatValue(f.value)
+		}
+		flags.Var(&flagValue{
+			name:   f.flagName(),
+			isBool: f.value.Kind() == reflect.Bool,
+			flags:  sources.flags,
+			shown:  shown,
+		}, f.flagName(), strings.TrimSpace(usage))
+	}
+	return sources
+}
+
+// File is the config file in use, if any
+func (s *Sources) File() string {
+	return s.file
+}
+
+// Load fills config, which holds the defaults, from the file, the
+// environment and the flags, in that order. Unknown keys in the file are
+// errors.
+func (s *Sources) Load(config interface{}) error {
+	leaves := fields(config)
+	var errs []error
+
+	if s.file != "" {
+		values, err := readFile(s.file)
+		if err != nil {
+			return err
+		}
+		for _, f := range leaves {
+			raw, ok := values[f.key]
+			if !ok {
+				continue
+			}
+			delete(values, f.key)
+			if err := setValue(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("Error: invalid %s in %s: %w", f.key, s.file, err))
+			}
+		}
+		var unknown []string
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			errs = append(errs, fmt.Errorf("Error: unknown setting %s in %s", key, s.file))
+		}
+	}
+
+	for _, f := range leaves {
+		env := f.tag.Get("env")
+		if env == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if err := setValue(f.value, value); err != nil {
+				errs = append(errs, fmt.Errorf("Error: invalid %s: %w", env, err))
+			}
+		}
+	}
+
+	for _, f := range leaves {
+		if value, ok := s.flags[f.flagName()]; ok {
+			if err := setValue(f.value, value); err != nil {
+				errs = append(errs, fmt.Errorf("Error: invalid -%s: %w", f.flagName(), err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// readFile flattens a YAML or TOML file into dotted keys
+func readFile(path string) (map[string]interface{}, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config file: %w", err)
+	}
+
+	document := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &document)
+	case ".toml":
+		err = toml.Unmarshal(contents, &document)
+	default:
+		return nil, fmt.Errorf("Error: config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+	}
+
+	values := map[string]interface{}{}
+	var flatten func(prefix string, section map[string]interface{})
+	flatten = func(prefix string, section map[string]interface{}) {
+		for key, value := range section {
+			if nested, ok := value.(map[string]interface{}); ok {
+				flatten(prefix+key+".", nested)
+				continue
+			}
+			values[prefix+key] = value
+		}
+	}
+	flatten("", document)
+	return values, nil
+}
+
+func setValue(value reflect.Value, raw interface{}) error {
+	if value.Kind() == reflect.Slice {
+		var items []string
+		switch raw := raw.(type) {
+		case []interface{}:
+			for _, item := range raw {
+				items = append(items, fmt.Sprint(item))
+			}
+		default:
+			for _, item := range strings.Split(fmt.Sprint(raw), ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	text := strings.TrimSpace(fmt.Sprint(raw))
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+	case value.Kind() == reflect.String:
+		value.SetString(fmt.Sprint(raw))
+	case value.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case value.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+	case value.Kind() == reflect.Uint:
+		parsed, err := strconv.ParseUint(text, 10, 0)
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	return nil
+}
+
+func formatValue(value reflect.Value) string {
+	if value.Kind() == reflect.Slice {
+		return strings.Join(value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// Redact lists every setting by dotted key, hiding secrets
+func Redact(config interface{}) map[string]interface{} {
+	redacted := map[string]interface{}{}
+	for _, f := range fields(config) {
+		value := f.value.Interface()
+		if duration, ok := value.(time.Duration); ok {
+			value = duration.String()
+		}
+		switch f.tag.Get("secret") {
+		case "":
+		case "url":
+			value = redactURLs(f.value)
+		default:
+			if !f.value.IsZero() {
+				value = Redacted
+			}
+		}
+		redacted[f.key] = value
+	}
+	return redacted
+}
+
+// redactURLs hides the passwords of a URL or a list of them. Values that
+// do not parse are hidden whole.
+func redactURLs(value reflect.Value) interface{} {
+	redact := func(text string) string {
+		if !strings.Contains(text, "://") {
+			return text
+		}
+		parsed, err := url.Parse(text)
+		if err != nil {
+			return Redacted
+		}
+		return parsed.Redacted()
+	}
+	if value.Kind() == reflect.Slice {
+		var redacted []string
+		for _, text := range value.Interface().([]string) {
+			redacted = append(redacted, redact(text))
+		}
+		return redacted
+	}
+	return redact(value.String())
+}
+
+// Apply copies the settings marked reload:"true" that differ from next
+// into current. The others that differ are only listed, as they take
+// effect on restart.
+func Apply(current interface{}, next interface{}) (applied []string, restart []string) {
+	nextFields := fields(next)
+	for i, f := range fields(current) {
+		value := nextFields[i].value
+		if reflect.DeepEqual(f.value.Interface(), value.Interface()) {
+			continue
+		}
+		if f.tag.Get("reload") == "true" {
+			f.value.Set(value)
+			applied = append(applied, f.key)
+		} else {
+			restart = append(restart, f.key)
+		}
+	}
+	return applied, restart
+}
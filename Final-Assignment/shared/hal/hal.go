@@ -0,0 +1,129 @@
+package hal
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const MediaType = "application/hal+json"
+
+// Link is a HAL link. Method is not part of HAL; it tells clients which
+// HTTP method an action link expects.
+type Link struct {
+	Href      string `json:"href"`
+	Method    string `json:"method,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+type Links map[string]Link
+
+// Action adds a link for an operation the caller may perform
+func (links Links) Action(rel string, method string, href string) {
+	links[rel] = Link{Href: href, Method: method, Templated: strings.Contains(href, "{")}
+}
+
+// Wanted reports whether the client prefers HAL to plain JSON. Clients that
+// do not name application/hal+json in their Accept header, including those
+// sending */*, get plain JSON.
+func Wanted(c *gin.Context) bool {
+	best := ""
+	bestQuality := 0.0
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || (mediaType != MediaType && mediaType != gin.MIMEJSON) {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if err := json.Unmarshal([]byte(q), &quality); err != nil {
+				continue
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = mediaType, quality
+		}
+	}
+	return best == MediaType
+}
+
+// BaseURL is the public address of the service that links are built
+// from: configured when set, otherwise the scheme and host the request
+// was sent to
+func BaseURL(c *gin.Context, configured string) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// JSON writes resource with its links as HAL when the client asked for it
+// and as plain JSON otherwise
+func JSON(c *gin.Context, status int, resource interface{}, links Links) {
+	c.Header("Vary", "Accept")
+	if !Wanted(c) {
+		c.JSON(status, resource)
+		return
+	}
+
+	document, err := withLinks(resource, links)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Content-Type", MediaType)
+	c.JSON(status, document)
+}
+
+// Collection writes a list of resources, each turned into its response
+// body by present. As HAL the items are embedded under name, each with its
+// own links.
+func Collection[T any](c *gin.Context, status int, name string, items []T, present func(T) interface{}, itemLinks func(T) Links, links Links) {
+	c.Header("Vary", "Accept")
+	if !Wanted(c) {
+		bodies := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			bodies = append(bodies, present(item))
+		}
+		c.JSON(status, bodies)
+		return
+	}
+
+	embedded := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		document, err := withLinks(present(item), itemLinks(item))
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		embedded = append(embedded, document)
+	}
+
+	c.Header("Content-Type", MediaType)
+	c.JSON(status, map[string]interface{}{
+		"_links":    links,
+		"_embedded": map[string]interface{}{name: embedded},
+		"count":     len(items),
+	})
+}
+
+func withLinks(resource interface{}, links Links) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	document := map[string]interface{}{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	document["_links"] = links
+	return document, nil
+}
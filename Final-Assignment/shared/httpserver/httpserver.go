@@ -0,0 +1,118 @@
+// Package httpserver runs the REST api with read, write and idle timeouts
+// and shuts it down gracefully on SIGINT or SIGTERM, so requests in flight
+// when docker stops the container still finish.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultReadTimeout     = time.Minute
+	DefaultWriteTimeout    = 2 * time.Minute
+	DefaultIdleTimeout     = 2 * time.Minute
+	DefaultShutdownTimeout = 10 * time.Second
+	// Slow clients get this long to send their headers whatever the read
+	// timeout
+	readHeaderTimeout = 10 * time.Second
+)
+
+type Config struct {
+	// Zero disables a timeout
+	ReadTimeout  time.Duration `config:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"read-timeout" usage:"Time to read a whole request, 0 for none"`
+	WriteTimeout time.Duration `config:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"write-timeout" usage:"Time to write a whole response, 0 for none"`
+	IdleTimeout  time.Duration `config:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"idle-timeout" usage:"How long idle keep-alive connections stay open"`
+
+	// How long requests in flight get to finish after a signal
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"How long requests in flight get to finish on shutdown"`
+}
+
+func Defaults() Config {
+	return Config{
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		IdleTimeout:     DefaultIdleTimeout,
+		ShutdownTimeout: DefaultShutdownTimeout,
+	}
+}
+
+// SignalContext is done once the process gets SIGINT or SIGTERM
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// GracefulStopper is a *grpc.Server, or anything else with work in flight
+// to finish on shutdown
+type GracefulStopper interface {
+	GracefulStop()
+	Stop()
+}
+
+// Run serves handler on address until ctx is done. It then stops accepting
+// connections and waits up to ShutdownTimeout for the requests in flight,
+// stopping others alongside.
+func (config Config) Run(ctx context.Context, address string, handler http.Handler, others ...GracefulStopper) error {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		slog.Info("Shutting down, waiting for requests in flight", "timeout", config.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	var wait sync.WaitGroup
+	for _, other := range others {
+		wait.Add(1)
+		go func(other GracefulStopper) {
+			defer wait.Done()
+			stopGracefully(shutdownCtx, other)
+		}(other)
+	}
+	if err == nil {
+		if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = fmt.Errorf("Error shutting down: %w", shutdownErr)
+		}
+	}
+	wait.Wait()
+	return err
+}
+
+// stopGracefully lets the calls in flight on server finish, cutting them
+// off once ctx is done
+func stopGracefully(ctx context.Context, server GracefulStopper) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
@@ -0,0 +1,178 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"shared/auth"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// IdempotencyKeyMetadata carries the key of a gRPC call
+const IdempotencyKeyMetadata = "idempotency-key"
+
+// Stored gRPC responses are an Any holding the response message, or the
+// error's status for calls that failed for good
+const grpcContentType = "application/grpc+proto"
+
+// UnaryInterceptor makes the methods listed safe to retry, like Middleware
+// does for routes. The first outcome of a call with an idempotency-key is
+// stored and replayed for retries of the same request; reusing a key with
+// another request or sending one without a subject fails with
+// InvalidArgument, and a retry while the first call is still running with
+// Aborted. It goes after auth.UnaryInterceptor.
+func (s *Store) UnaryInterceptor(methods ...string) grpc.UnaryServerInterceptor {
+	idempotent := map[string]bool{}
+	for _, method := range methods {
+		idempotent[method] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(IdempotencyKeyMetadata)
+		if !idempotent[info.FullMethod] || len(keys) == 0 || keys[0] == "" {
+			return handler(ctx, req)
+		}
+		key := keys[0]
+		if len(key) > maxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "%s may be at most %d characters", IdempotencyKeyMetadata, maxKeyLength)
+		}
+
+		message, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		callerScope, ok := scope(auth.PrincipalFromContext(ctx))
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "%s needs a caller with a subject", IdempotencyKeyMetadata)
+		}
+		requestHash := hashCall(info.FullMethod, body)
+		redisKey := RedisIdempotencyKeyPrefix + callerScope + ":" + key
+
+		pending, claimed, err := s.claim(ctx, redisKey, requestHash)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking idempotency key, processing call", "error", err)
+			return handler(ctx, req)
+		}
+		if !claimed {
+			return s.replayCall(ctx, redisKey, requestHash)
+		}
+
+		finished := false
+		defer func() {
+			if !finished {
+				// The handler panicked, let the client retry
+				s.release(ctx, redisKey, pending, nil)
+			}
+		}()
+
+		response, err := handler(ctx, req)
+		finished = true
+
+		stored, storable := storedCall(requestHash, response, err)
+		if !storable {
+			// Let the client retry calls that did not complete
+			s.release(ctx, redisKey, pending, nil)
+			return response, err
+		}
+		s.release(ctx, redisKey, pending, stored)
+		return response, err
+	}
+}
+
+// storedCall is what is kept for a call that returned response and err.
+// Calls that may succeed when retried are not stored.
+func storedCall(requestHash string, response interface{}, err error) (*storedResponse, bool) {
+	var outcome proto.Message
+	switch status.Code(err) {
+	case codes.OK:
+		message, ok := response.(proto.Message)
+		if !ok {
+			return nil, false
+		}
+		outcome = message
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.ResourceExhausted,
+		codes.DeadlineExceeded, codes.Canceled, codes.Aborted:
+		return nil, false
+	default:
+		outcome = status.Convert(err).Proto()
+	}
+
+	wrapped, marshalErr := anypb.New(outcome)
+	if marshalErr != nil {
+		return nil, false
+	}
+	body, marshalErr := proto.Marshal(wrapped)
+	if marshalErr != nil {
+		return nil, false
+	}
+	return &storedResponse{
+		RequestHash: requestHash,
+		Status:      int(status.Code(err)),
+		Header:      http.Header{"Content-Type": {grpcContentType}},
+		Body:        body,
+	}, true
+}
+
+func (s *Store) replayCall(ctx context.Context, redisKey string, requestHash string) (interface{}, error) {
+	data, err := s.client.Get(ctx, redisKey).Bytes()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading idempotent response", "error", err)
+		return nil, status.Error(codes.Internal, "could not read the stored response")
+	}
+
+	var stored storedResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		slog.ErrorContext(ctx, "Error decoding idempotent response", "error", err)
+		return nil, status.Error(codes.Internal, "could not read the stored response")
+	}
+
+	if stored.RequestHash != requestHash {
+		slog.WarnContext(ctx, "Idempotency key reused with a different call")
+		return nil, status.Errorf(codes.InvalidArgument, "%s was used for a different call", IdempotencyKeyMetadata)
+	}
+	if stored.Pending {
+		slog.InfoContext(ctx, "Call with this idempotency key is still in progress")
+		return nil, status.Errorf(codes.Aborted, "a call with this %s is still in progress", IdempotencyKeyMetadata)
+	}
+
+	wrapped := &anypb.Any{}
+	if err := proto.Unmarshal(stored.Body, wrapped); err != nil {
+		slog.ErrorContext(ctx, "Error decoding idempotent response", "error", err)
+		return nil, status.Error(codes.Internal, "could not read the stored response")
+	}
+	outcome, err := wrapped.UnmarshalNew()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error decoding idempotent response", "error", err)
+		return nil, status.Error(codes.Internal, "could not read the stored response")
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(ReplayedHeader, "true"))
+	if statusProto, ok := outcome.(*spb.Status); ok && codes.Code(stored.Status) != codes.OK {
+		return nil, status.ErrorProto(statusProto)
+	}
+	return outcome, nil
+}
+
+func hashCall(method string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte("grpc " + method + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
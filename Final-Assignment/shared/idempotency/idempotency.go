@@ -0,0 +1,270 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	RedisIdempotencyKeyPrefix = "idempotency:"
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	ReplayedHeader            = "Idempotent-Replayed"
+	DefaultTTL                = 24 * time.Hour
+	// PendingLease is how long a request in progress holds its key. A
+	// request that dies without releasing it blocks retries this long.
+	PendingLease = time.Minute
+	maxKeyLength = 255
+)
+
+// releaseScript replaces the pending entry in KEYS[1] with ARGV[2] for
+// ARGV[3] milliseconds, or deletes it when ARGV[2] is empty, provided the
+// entry is still ARGV[1]. A request whose lease ran out cannot touch a
+// later request's claim.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '' then
+	return redis.call('DEL', KEYS[1])
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// storedResponse is what is kept in redis for each key. A pending entry
+// marks a request that is still being processed; Claim tells apart the
+// requests that held the key.
+type storedResponse struct {
+	RequestHash string
+	Pending     bool
+	Claim       string `json:",omitempty"`
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+type Store struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// Create New Idempotency Store, keeping responses for ttl
+func New(client redis.UniversalClient, ttl time.Duration) *Store {
+	return &Store{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// recordingWriter copies everything written to the client so the response
+// can be stored once the handler finishes. headers holds the headers set
+// before the handler ran, which the middleware ahead of this one sets
+// again on a replay.
+type recordingWriter struct {
+	gin.ResponseWriter
+	headers http.Header
+	body    bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// handlerHeaders is the headers the handler set, such as Location
+func (w *recordingWriter) handlerHeaders() http.Header {
+	set := http.Header{}
+	for name, values := range w.Header() {
+		if !slices.Equal(values, w.headers[name]) {
+			set[name] = values
+		}
+	}
+	return set
+}
+
+// Middleware makes a route safe to retry. The first response for an
+// Idempotency-Key is stored with its headers and replayed for retries with
+// the same body; reusing a key with a different body is rejected with 422.
+// Requests without the header are passed straight through, and keys from
+// callers without a subject are rejected with 400.
+func (s *Store) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			slog.WarnContext(c.Request.Context(), "Idempotency-Key too long", "max_length", maxKeyLength)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Error reading request body", "error", err)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		callerScope, ok := scope(auth.PrincipalFrom(c))
+		if !ok {
+			slog.WarnContext(c.Request.Context(), "Idempotency-Key sent by a caller without a subject")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+		redisKey := RedisIdempotencyKeyPrefix + callerScope + ":" + key
+
+		pending, claimed, err := s.claim(c.Request.Context(), redisKey, requestHash)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking idempotency key, processing request", "error", err)
+			c.Next()
+			return
+		}
+
+		if !claimed {
+			s.replay(c, redisKey, requestHash)
+			return
+		}
+
+		finished := false
+		defer func() {
+			if !finished {
+				// The handler panicked, let the client retry
+				s.release(c.Request.Context(), redisKey, pending, nil)
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: c.Writer, headers: c.Writer.Header().Clone()}
+		c.Writer = writer
+		c.Next()
+		finished = true
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			// Let the client retry requests that did not complete
+			s.release(c.Request.Context(), redisKey, pending, nil)
+			return
+		}
+
+		s.release(c.Request.Context(), redisKey, pending, &storedResponse{
+			RequestHash: requestHash,
+			Status:      status,
+			Header:      writer.handlerHeaders(),
+			Body:        writer.body.Bytes(),
+		})
+	}
+}
+
+// claim marks the key pending for PendingLease unless another request
+// holds it, returning the entry it set
+func (s *Store) claim(ctx context.Context, redisKey string, requestHash string) ([]byte, bool, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, false, err
+	}
+	pending, err := json.Marshal(storedResponse{RequestHash: requestHash, Pending: true, Claim: hex.EncodeToString(token)})
+	if err != nil {
+		return nil, false, err
+	}
+	claimed, err := s.client.SetNX(ctx, redisKey, pending, PendingLease).Result()
+	return pending, claimed, err
+}
+
+// release swaps the pending entry for response, kept for the store's ttl,
+// or drops it when response is nil so the request can be retried. The
+// request is over by then, so ctx being cancelled because the client hung
+// up does not keep the outcome from being stored.
+func (s *Store) release(ctx context.Context, redisKey string, pending []byte, response *storedResponse) {
+	ctx = context.WithoutCancel(ctx)
+	data := []byte{}
+	if response != nil {
+		var err error
+		if data, err = json.Marshal(response); err != nil {
+			slog.ErrorContext(ctx, "Error encoding idempotent response", "error", err)
+			data = []byte{}
+		}
+	}
+	err := releaseScript.Run(ctx, s.client, []string{redisKey}, pending, data, s.ttl.Milliseconds()).Err()
+	if err != nil && err != redis.Nil {
+		slog.ErrorContext(ctx, "Error storing idempotent response", "error", err)
+	}
+}
+
+func (s *Store) replay(c *gin.Context, redisKey string, requestHash string) {
+	data, err := s.client.Get(c.Request.Context(), redisKey).Bytes()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error reading idempotent response", "error", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var stored storedResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error decoding idempotent response", "error", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		slog.WarnContext(c.Request.Context(), "Idempotency-Key reused with a different request")
+		c.AbortWithStatus(http.StatusUnprocessableEntity)
+		return
+	}
+
+	if stored.Pending {
+		slog.InfoContext(c.Request.Context(), "Request with this Idempotency-Key is still in progress")
+		c.Header("Retry-After", "1")
+		c.AbortWithStatus(http.StatusConflict)
+		return
+	}
+
+	for name, values := range stored.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(ReplayedHeader, "true")
+	if len(stored.Body) == 0 {
+		c.AbortWithStatus(stored.Status)
+		return
+	}
+	c.Data(stored.Status, stored.Header.Get("Content-Type"), stored.Body)
+	c.Abort()
+}
+
+func hashRequest(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Keys are scoped to the caller so clients cannot replay each other's
+// responses. Callers without a subject would all share one scope, so
+// their keys are refused.
+func scope(principal *auth.Principal) (string, bool) {
+	if principal == nil || principal.Subject == "" {
+		return "", false
+	}
+	return principal.Subject, true
+}
@@ -0,0 +1,260 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// startRedis runs a throwaway redis-server for one test, skipping the
+// test when none is installed
+func startRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	binary, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binary, "--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir())
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	t.Cleanup(func() { client.Close() })
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(context.Background()).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return client
+}
+
+// testServer serves POST / behind the middleware, answering with handle
+// and counting the calls that reach it
+type testServer struct {
+	router *gin.Engine
+	calls  atomic.Int32
+	handle func(c *gin.Context)
+}
+
+func newTestServer(t *testing.T, client *redis.Client) *testServer {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := auth.ParseAPIKeys("key-1:admin,key-2:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServerWith(authenticator, client)
+}
+
+// newTestServerWith is newTestServer with another authenticator. A
+// middleware ahead of the idempotency one numbers the requests in
+// X-Request-Number.
+func newTestServerWith(authenticator auth.Authenticator, client *redis.Client) *testServer {
+	server := &testServer{
+		router: gin.New(),
+		handle: func(c *gin.Context) {
+			c.Header("Location", "/items/1")
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		},
+	}
+	var requests atomic.Int32
+	server.router.Use(gin.RecoveryWithWriter(io.Discard), func(c *gin.Context) {
+		c.Header("X-Request-Number", fmt.Sprint(requests.Add(1)))
+	})
+	server.router.POST("/", auth.Middleware(authenticator), New(client, DefaultTTL).Middleware(), func(c *gin.Context) {
+		server.calls.Add(1)
+		server.handle(c)
+	})
+	return server
+}
+
+func (s *testServer) post(apiKey string, idempotencyKey string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, apiKey)
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestReplay(t *testing.T) {
+	server := newTestServer(t, startRedis(t))
+
+	first := server.post("key-1", "k", `{"a":1}`)
+	if first.Code != http.StatusCreated || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first request: unexpected %d %v", first.Code, first.Header())
+	}
+	retry := server.post("key-1", "k", `{"a":1}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "true" ||
+		retry.Body.String() != first.Body.String() || retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") ||
+		retry.Header().Get("Location") != "/items/1" {
+		t.Errorf("retry: expected the stored response, got %d %v %s", retry.Code, retry.Header(), retry.Body)
+	}
+	if retry.Header().Get("X-Request-Number") != "2" {
+		t.Errorf("retry: expected the headers of the middleware ahead to be its own, got %v", retry.Header())
+	}
+	if calls := server.calls.Load(); calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+
+	if recorder := server.post("key-2", "k", `{"a":1}`); recorder.Header().Get(ReplayedHeader) != "" {
+		t.Error("keys should be scoped to the caller")
+	}
+	server.post("key-1", "", `{"a":1}`)
+	server.post("key-1", "", `{"a":1}`)
+	if calls := server.calls.Load(); calls != 4 {
+		t.Errorf("requests without a key should always be handled, handler ran %d times", calls)
+	}
+}
+
+// principalWithoutSubject authenticates every request as an admin
+// without a subject, like a JWT without a sub claim
+type principalWithoutSubject struct{}
+
+func (principalWithoutSubject) Authenticate(r *http.Request) (*auth.Principal, error) {
+	return &auth.Principal{Roles: []auth.Role{auth.RoleAdmin}}, nil
+}
+
+func TestCallerWithoutSubject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := newTestServerWith(principalWithoutSubject{}, startRedis(t))
+
+	if recorder := server.post("", "k", `{"a":1}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a key from a caller without a subject, got %d", recorder.Code)
+	}
+	if recorder := server.post("", "", `{"a":1}`); recorder.Code != http.StatusCreated {
+		t.Errorf("expected requests without a key to be handled, got %d", recorder.Code)
+	}
+	if calls := server.calls.Load(); calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestMismatchedBody(t *testing.T) {
+	server := newTestServer(t, startRedis(t))
+
+	server.post("key-1", "k", `{"a":1}`)
+	if recorder := server.post("key-1", "k", `{"a":2}`); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a key reused with another body, got %d", recorder.Code)
+	}
+	if calls := server.calls.Load(); calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+// A client that hangs up once its request is handled still gets the stored
+// response when it retries
+func TestClientHangsUp(t *testing.T) {
+	server := newTestServer(t, startRedis(t))
+	ctx, hangUp := context.WithCancel(context.Background())
+	server.handle = func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+		hangUp()
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`)).WithContext(ctx)
+	req.Header.Set(auth.APIKeyHeader, "key-1")
+	req.Header.Set(IdempotencyKeyHeader, "k")
+	server.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if retry := server.post("key-1", "k", `{"a":1}`); retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry: expected the stored response, got %d %v", retry.Code, retry.Header())
+	}
+	if calls := server.calls.Load(); calls != 1 {
+		t.Errorf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestConcurrentRequest(t *testing.T) {
+	client := startRedis(t)
+	server := newTestServer(t, client)
+	entered, proceed := make(chan struct{}), make(chan struct{})
+	server.handle = func(c *gin.Context) {
+		close(entered)
+		<-proceed
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- server.post("key-1", "k", `{"a":1}`) }()
+	<-entered
+
+	ttl, err := client.PTTL(context.Background(), RedisIdempotencyKeyPrefix+"apikey-1:k").Result()
+	if err != nil || ttl <= 0 || ttl > PendingLease {
+		t.Errorf("expected the pending entry to be leased for at most %s, got %s %v", PendingLease, ttl, err)
+	}
+	recorder := server.post("key-1", "k", `{"a":1}`)
+	if recorder.Code != http.StatusConflict || recorder.Header().Get("Retry-After") != "1" {
+		t.Errorf("request in progress: expected 409 with Retry-After, got %d %v", recorder.Code, recorder.Header())
+	}
+
+	close(proceed)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Fatalf("first request: expected 201, got %d", first.Code)
+	}
+	ttl, err = client.PTTL(context.Background(), RedisIdempotencyKeyPrefix+"apikey-1:k").Result()
+	if err != nil || ttl <= PendingLease {
+		t.Errorf("expected the response to be kept for the store's ttl, got %s %v", ttl, err)
+	}
+	if recorder := server.post("key-1", "k", `{"a":1}`); recorder.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("after the first request finished: expected a replay, got %d", recorder.Code)
+	}
+}
+
+func TestFailedHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(c *gin.Context)
+	}{
+		{"server error", func(c *gin.Context) { c.Status(http.StatusInternalServerError) }},
+		{"rate limited", func(c *gin.Context) { c.Status(http.StatusTooManyRequests) }},
+		{"panic", func(c *gin.Context) { panic("handler failed") }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestServer(t, startRedis(t))
+			server.handle = test.handle
+			if recorder := server.post("key-1", "k", `{"a":1}`); recorder.Code < http.StatusTooManyRequests {
+				t.Fatalf("expected the request to fail, got %d", recorder.Code)
+			}
+
+			server.handle = func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"id": 1}) }
+			recorder := server.post("key-1", "k", `{"a":1}`)
+			if recorder.Code != http.StatusCreated || recorder.Header().Get(ReplayedHeader) != "" {
+				t.Errorf("retry: expected the handler to run again, got %d %v", recorder.Code, recorder.Header())
+			}
+			if calls := server.calls.Load(); calls != 2 {
+				t.Errorf("expected the handler to run twice, ran %d times", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"shared/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCKeyFunc picks the bucket a gRPC call is counted against, like
+// KeyFunc does for requests
+type GRPCKeyFunc func(ctx context.Context) string
+
+func GRPCByClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// GRPCByAPIKey counts calls per credential, falling back to the client IP
+// for calls without one
+func GRPCByAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	credential := ""
+	for _, name := range []string{auth.APIKeyHeader, auth.AuthorizationHeader} {
+		if values := md.Get(strings.ToLower(name)); len(values) > 0 && credential == "" {
+			credential = values[0]
+		}
+	}
+	if credential == "" {
+		return GRPCByClientIP(ctx)
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// GRPCByVoterID counts calls per authenticated voter, falling back to the
+// caller's credential
+func GRPCByVoterID(ctx context.Context) string {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal != nil && principal.VoterID != 0 {
+		return "voter:" + strconv.FormatUint(uint64(principal.VoterID), 10)
+	}
+	return GRPCByAPIKey(ctx)
+}
+
+var grpcKeyFuncs = map[string]GRPCKeyFunc{
+	"ip":     GRPCByClientIP,
+	"apikey": GRPCByAPIKey,
+	"voter":  GRPCByVoterID,
+}
+
+// UnaryInterceptor enforces the current rule of the route each method in
+// routes is counted under, so a call and the matching REST request share
+// one limit. Calls are let through when redis cannot be reached.
+func (l *Limiter) UnaryInterceptor(routes map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		route, ok := routes[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		rule := l.rule(route)
+		if rule.Limit == 0 {
+			return handler(ctx, req)
+		}
+
+		result, err := l.Allow(ctx, route+":"+rule.grpcKey()(ctx), rule)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking rate limit, allowing call", "error", err)
+			return handler(ctx, req)
+		}
+		if !result.Allowed {
+			return nil, rejectCall(ctx, route, result)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// FailureUnaryInterceptor limits failed authentications per client IP
+// like FailureMiddleware. It goes in front of auth.UnaryInterceptor.
+func (l *Limiter) FailureUnaryInterceptor(route string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var response interface{}
+		err := l.limitFailures(ctx, route, func() error {
+			var err error
+			response, err = handler(ctx, req)
+			return err
+		})
+		return response, err
+	}
+}
+
+// FailureStreamInterceptor is FailureUnaryInterceptor for streams
+func (l *Limiter) FailureStreamInterceptor(route string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return l.limitFailures(stream.Context(), route, func() error {
+			return handler(srv, stream)
+		})
+	}
+}
+
+func (l *Limiter) limitFailures(ctx context.Context, route string, call func() error) error {
+	rule := l.rule(route)
+	if rule.Limit == 0 {
+		return call()
+	}
+
+	bucket := route + ":" + GRPCByClientIP(ctx)
+	result, err := l.Peek(ctx, bucket, rule)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking rate limit, allowing call", "error", err)
+		return call()
+	}
+	if !result.Allowed {
+		return rejectCall(ctx, route, result)
+	}
+
+	err = call()
+	if status.Code(err) == codes.Unauthenticated {
+		if _, err := l.Allow(context.WithoutCancel(ctx), bucket, rule); err != nil {
+			slog.ErrorContext(ctx, "Error counting failed authentication", "error", err)
+		}
+	}
+	return err
+}
+
+func (rule Rule) grpcKey() GRPCKeyFunc {
+	if rule.GRPCKey == nil {
+		return GRPCByClientIP
+	}
+	return rule.GRPCKey
+}
+
+// rejectCall answers ResourceExhausted, with the wait in the retry-after
+// header
+func rejectCall(ctx context.Context, route string, result Result) error {
+	slog.InfoContext(ctx, "Rate limit exceeded", "route", route)
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfterSeconds(result)))
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %ss", retryAfterSeconds(result))
+}
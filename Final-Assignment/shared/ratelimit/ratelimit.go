@@ -0,0 +1,309 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	RedisRateLimitKeyPrefix = "ratelimit:"
+)
+
+// slidingWindowScript keeps one sorted set entry per accepted request and
+// admits a new one while fewer than limit entries fall inside the window.
+// Returns {allowed, remaining, milliseconds until the oldest entry expires}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// peekScript answers like slidingWindowScript without counting a request
+var peekScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	allowed = 1
+end
+
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// KeyFunc picks the bucket a request is counted against
+type KeyFunc func(c *gin.Context) string
+
+func ByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByAPIKey counts requests per credential, falling back to the client IP
+// for requests without one
+func ByAPIKey(c *gin.Context) string {
+	credential := c.GetHeader(auth.APIKeyHeader)
+	if credential == "" {
+		credential = c.GetHeader(auth.AuthorizationHeader)
+	}
+	if credential == "" {
+		return ByClientIP(c)
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// ByVoterID counts requests per authenticated voter, falling back to the
+// caller's credential
+func ByVoterID(c *gin.Context) string {
+	principal := auth.PrincipalFrom(c)
+	if principal != nil && principal.VoterID != 0 {
+		return "voter:" + strconv.FormatUint(uint64(principal.VoterID), 10)
+	}
+	return ByAPIKey(c)
+}
+
+var keyFuncs = map[string]KeyFunc{
+	"ip":     ByClientIP,
+	"apikey": ByAPIKey,
+	"voter":  ByVoterID,
+}
+
+// Rule allows Limit requests per Window for each key. A zero Limit
+// disables limiting. GRPCKey picks the same key for gRPC calls.
+type Rule struct {
+	Limit   int
+	Window  time.Duration
+	Key     KeyFunc
+	GRPCKey GRPCKeyFunc
+}
+
+// ParseRule reads "<limit>/<window>[@ip|apikey|voter]", e.g. "10/1m@voter",
+// or "off"
+func ParseRule(spec string) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "off" {
+		return Rule{}, nil
+	}
+
+	rule := Rule{Key: ByClientIP, GRPCKey: GRPCByClientIP}
+	if before, keyName, found := strings.Cut(spec, "@"); found {
+		keyFunc, ok := keyFuncs[keyName]
+		if !ok {
+			return Rule{}, errors.New("Error: unknown rate limit key " + keyName)
+		}
+		rule.Key = keyFunc
+		rule.GRPCKey = grpcKeyFuncs[keyName]
+		spec = before
+	}
+
+	limit, window, found := strings.Cut(spec, "/")
+	if !found {
+		return Rule{}, errors.New("Error: rate limit must look like <limit>/<window>: " + spec)
+	}
+
+	var err error
+	if rule.Limit, err = strconv.Atoi(limit); err != nil || rule.Limit < 0 {
+		return Rule{}, errors.New("Error: invalid rate limit count: " + limit)
+	}
+	if rule.Window, err = time.ParseDuration(window); err != nil || rule.Window <= 0 {
+		return Rule{}, errors.New("Error: invalid rate limit window: " + window)
+	}
+
+	return rule, nil
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	client redis.UniversalClient
+	now    func() time.Time
+
+	// The rule of each route, replaced when the config is reloaded
+	lock  sync.RWMutex
+	rules map[string]Rule
+}
+
+// Create New Rate Limiter, keeping its windows in redis through the
+// shared client
+func New(client redis.UniversalClient) *Limiter {
+	return &Limiter{
+		client: client,
+		now:    time.Now,
+		rules:  map[string]Rule{},
+	}
+}
+
+// SetRule sets the rule enforced on route from now on
+func (l *Limiter) SetRule(route string, rule Rule) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.rules[route] = rule
+}
+
+func (l *Limiter) rule(route string) Rule {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.rules[route]
+}
+
+// Allow counts a request against bucket if the rule lets it through. The
+// redis call is made under ctx, the context of the request being counted.
+func (l *Limiter) Allow(ctx context.Context, bucket string, rule Rule) (Result, error) {
+	now := l.now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+
+	values, err := slidingWindowScript.Run(ctx, l.client,
+		[]string{RedisRateLimitKeyPrefix + bucket},
+		now, rule.Window.Milliseconds(), rule.Limit, member).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return resultOf(values, rule), nil
+}
+
+// Peek tells whether the rule would let a request through bucket now,
+// without counting one
+func (l *Limiter) Peek(ctx context.Context, bucket string, rule Rule) (Result, error) {
+	values, err := peekScript.Run(ctx, l.client,
+		[]string{RedisRateLimitKeyPrefix + bucket},
+		l.now().UnixMilli(), rule.Window.Milliseconds(), rule.Limit).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return resultOf(values, rule), nil
+}
+
+func resultOf(values []int64, rule Rule) Result {
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      rule.Limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+}
+
+// Middleware enforces the current rule of the route named route. Requests
+// are let through when redis cannot be reached.
+func (l *Limiter) Middleware(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := l.rule(route)
+		if rule.Limit == 0 {
+			c.Next()
+			return
+		}
+
+		result, err := l.Allow(c.Request.Context(), route+":"+rule.Key(c), rule)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking rate limit, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		writeHeaders(c, rule, result)
+		if !result.Allowed {
+			reject(c, route, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+// FailureMiddleware limits how often a client IP may fail to authenticate,
+// under the current rule of the route named route whatever its key. It
+// goes in front of auth.Middleware: an IP over the limit is turned away
+// before its credentials are checked, and only requests answered 401 count.
+func (l *Limiter) FailureMiddleware(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := l.rule(route)
+		if rule.Limit == 0 {
+			c.Next()
+			return
+		}
+
+		bucket := route + ":" + ByClientIP(c)
+		result, err := l.Peek(c.Request.Context(), bucket, rule)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking rate limit, allowing request", "error", err)
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			writeHeaders(c, rule, result)
+			reject(c, route, result)
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			// Counted even if the client has hung up by now
+			if _, err := l.Allow(context.WithoutCancel(c.Request.Context()), bucket, rule); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Error counting failed authentication", "error", err)
+			}
+		}
+	}
+}
+
+func writeHeaders(c *gin.Context, rule Rule, result Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", retryAfterSeconds(result))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, int(rule.Window.Seconds())))
+}
+
+func reject(c *gin.Context, route string, result Result) {
+	slog.InfoContext(c.Request.Context(), "Rate limit exceeded", "route", route)
+	c.Header("Retry-After", retryAfterSeconds(result))
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
+
+func retryAfterSeconds(result Result) string {
+	return strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+}
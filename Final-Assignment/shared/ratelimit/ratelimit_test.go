@@ -0,0 +1,279 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// startRedis runs a throwaway redis-server for one test, skipping the
+// test when none is installed
+func startRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	binary, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binary, "--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir())
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	t.Cleanup(func() { client.Close() })
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(context.Background()).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return client
+}
+
+// newTestLimiter returns a limiter whose clock only moves when the test
+// advances it
+func newTestLimiter(t *testing.T) (*Limiter, func(time.Duration)) {
+	limiter := New(startRedis(t))
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func mustParseRule(t *testing.T, spec string) Rule {
+	t.Helper()
+	rule, err := ParseRule(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec   string
+		limit  int
+		window time.Duration
+		valid  bool
+	}{
+		{"10/1m", 10, time.Minute, true},
+		{" 5/30s@voter ", 5, 30 * time.Second, true},
+		{"60/1m@apikey", 60, time.Minute, true},
+		{"off", 0, 0, true},
+		{"10", 0, 0, false},
+		{"ten/1m", 0, 0, false},
+		{"-1/1m", 0, 0, false},
+		{"10/0s", 0, 0, false},
+		{"10/soon", 0, 0, false},
+		{"10/1m@cookie", 0, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			rule, err := ParseRule(test.spec)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("expected %q to be rejected", test.spec)
+				}
+				return
+			}
+			if err != nil || rule.Limit != test.limit || rule.Window != test.window {
+				t.Errorf("expected %d/%s, got %+v %v", test.limit, test.window, rule, err)
+			}
+		})
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticator, err := auth.ParseAPIKeys("voter-key:voter:7,admin-key:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]string{}
+	r := gin.New()
+	r.GET("/open", func(c *gin.Context) {
+		keys["ip"], keys["apikey"], keys["voter"] = ByClientIP(c), ByAPIKey(c), ByVoterID(c)
+	})
+	r.GET("/authenticated", auth.Middleware(authenticator), func(c *gin.Context) {
+		keys["ip"], keys["apikey"], keys["voter"] = ByClientIP(c), ByAPIKey(c), ByVoterID(c)
+	})
+
+	keyOf := func(path string, header string, value string) map[string]string {
+		keys = map[string]string{}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return keys
+	}
+
+	anonymous := keyOf("/open", "", "")
+	if anonymous["ip"] != "ip:192.0.2.1" || anonymous["apikey"] != "ip:192.0.2.1" || anonymous["voter"] != "ip:192.0.2.1" {
+		t.Errorf("requests without credentials should fall back to the client IP, got %v", anonymous)
+	}
+	token := keyOf("/open", auth.AuthorizationHeader, "Bearer token")
+	if token["apikey"] == "ip:192.0.2.1" || token["apikey"] == keyOf("/open", auth.AuthorizationHeader, "Bearer other")["apikey"] {
+		t.Errorf("tokens should get buckets of their own, got %v", token)
+	}
+	voter := keyOf("/authenticated", auth.APIKeyHeader, "voter-key")
+	if voter["voter"] != "voter:7" || voter["apikey"] == voter["voter"] {
+		t.Errorf("voters should be counted by voter id, got %v", voter)
+	}
+	admin := keyOf("/authenticated", auth.APIKeyHeader, "admin-key")
+	if admin["voter"] != admin["apikey"] || admin["apikey"] == voter["apikey"] {
+		t.Errorf("principals without a voter id should be counted by key, got %v", admin)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter, advance := newTestLimiter(t)
+	rule := mustParseRule(t, "2/1m")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if result, err := limiter.Allow(ctx, "bucket", rule); err != nil || !result.Allowed || result.Remaining != 1-i {
+			t.Fatalf("request %d: expected to be allowed, got %+v %v", i+1, result, err)
+		}
+		advance(20 * time.Second)
+	}
+	result, err := limiter.Allow(ctx, "bucket", rule)
+	if err != nil || result.Allowed || result.RetryAfter != 20*time.Second {
+		t.Fatalf("third request: expected a refusal until the first leaves the window in 20s, got %+v %v", result, err)
+	}
+	if result, _ := limiter.Allow(ctx, "other-bucket", rule); !result.Allowed {
+		t.Error("buckets should be counted apart")
+	}
+
+	advance(20 * time.Second)
+	if result, err := limiter.Allow(ctx, "bucket", rule); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("once the first request left the window: expected to be allowed, got %+v %v", result, err)
+	}
+	if result, _ := limiter.Peek(ctx, "bucket", rule); result.Allowed {
+		t.Errorf("peeking at a full window should refuse, got %+v", result)
+	}
+	if result, _ := limiter.Allow(ctx, "bucket", rule); result.Allowed {
+		t.Errorf("the window is full again, got %+v", result)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(t)
+	limiter.SetRule("write", mustParseRule(t, "2/1m@apikey"))
+	r := gin.New()
+	r.POST("/", limiter.Middleware("write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/open", limiter.Middleware("unlimited"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	post := func(path string, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		recorder := post("/", "key-1")
+		if recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Limit") != "2" ||
+			recorder.Header().Get("RateLimit-Remaining") != remaining || recorder.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("request %d: unexpected %d %v", i+1, recorder.Code, recorder.Header())
+		}
+	}
+	recorder := post("/", "key-1")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "60" || recorder.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("over the limit: unexpected %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder := post("/", "key-2"); recorder.Code != http.StatusOK {
+		t.Errorf("another key: expected 200, got %d", recorder.Code)
+	}
+	if recorder := post("/open", "key-1"); recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("a route without a rule should not be limited, got %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestFailureMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, advance := newTestLimiter(t)
+	limiter.SetRule("auth", mustParseRule(t, "2/1m"))
+	authenticator, err := auth.ParseAPIKeys("good-key:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/", limiter.FailureMiddleware("auth"), auth.Middleware(authenticator), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(ip string, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set(auth.APIKeyHeader, key)
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		if status := get("192.0.2.1", "good-key"); status != http.StatusOK {
+			t.Fatalf("good request %d: expected 200, got %d", i+1, status)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if status := get("192.0.2.1", "guess"); status != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d: expected 401, got %d", i+1, status)
+		}
+	}
+	if status := get("192.0.2.1", "guess"); status != http.StatusTooManyRequests {
+		t.Errorf("after two failures: expected 429, got %d", status)
+	}
+	if status := get("192.0.2.1", "good-key"); status != http.StatusTooManyRequests {
+		t.Errorf("the IP is turned away before its credentials are checked, got %d", status)
+	}
+	if status := get("192.0.2.2", "guess"); status != http.StatusUnauthorized {
+		t.Errorf("another IP: expected 401, got %d", status)
+	}
+	advance(time.Minute + time.Second)
+	if status := get("192.0.2.1", "good-key"); status != http.StatusOK {
+		t.Errorf("after the window: expected 200, got %d", status)
+	}
+}
+
+func TestUnreachableRedisAllows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	limiter := New(client)
+	limiter.SetRule("write", Rule{Limit: 1, Window: time.Minute, Key: ByClientIP})
+	r := gin.New()
+	r.GET("/", limiter.Middleware("write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("request %d: expected 200 without redis, got %d", i+1, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package redisclient configures the one connection to redis that the
+// store, the rate limiter and the idempotency store share.
+//
+// REDIS_URL holds one address, several comma separated ones for Sentinel
+// or Cluster, or a redis:// or rediss:// URL that may carry the username,
+// password and database too.
+package redisclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultLocation       = "0.0.0.0:6379"
+	DefaultConnectTimeout = 30 * time.Second
+	// Bounds of the wait between Pings while redis is not up yet
+	firstRetryDelay = 100 * time.Millisecond
+	maxRetryDelay   = 5 * time.Second
+)
+
+type Config struct {
+	// The redis server, or the sentinels when MasterName is set, or the
+	// cluster nodes to start from
+	Addrs    []string `config:"addrs" env:"REDIS_URL" flag:"redis" usage:"Redis address, comma separated addresses or redis:// URL" secret:"url"`
+	Username string   `config:"username" env:"REDIS_USERNAME" usage:"Redis ACL username"`
+	Password string   `config:"password" env:"REDIS_PASSWORD" usage:"Redis password, prefer REDIS_PASSWORD so it stays out of the process list" secret:"true"`
+	DB       int      `config:"db" env:"REDIS_DB" usage:"Redis database index"`
+
+	// Sentinel: the name of the monitored master, and the sentinels' own
+	// password if they have one
+	MasterName       string `config:"sentinel_master" env:"REDIS_SENTINEL_MASTER" usage:"Sentinel master name, -redis then lists the sentinels"`
+	SentinelPassword string `config:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD" usage:"Password of the sentinels themselves" secret:"true"`
+
+	// Cluster mode, which is also used whenever Addrs holds more than one
+	// address and no MasterName is set
+	Cluster bool `config:"cluster" env:"REDIS_CLUSTER" usage:"Connect to a Redis Cluster"`
+
+	TLS bool `config:"tls" env:"REDIS_TLS" usage:"Connect with TLS"`
+	// PEM file with the CAs to trust instead of the system pool
+	TLSCAFile     string `config:"tls_ca_file" env:"REDIS_TLS_CA_FILE" flag:"redis-tls-ca" usage:"PEM file of CAs to trust, implies -redis-tls"`
+	TLSServerName string `config:"tls_server_name" env:"REDIS_TLS_SERVER_NAME" usage:"Server name to verify, implies -redis-tls"`
+
+	// Zero leaves go-redis' defaults
+	PoolSize     int           `config:"pool_size" env:"REDIS_POOL_SIZE" usage:"Connections per node, 0 for the go-redis default"`
+	DialTimeout  time.Duration `config:"dial_timeout" env:"REDIS_DIAL_TIMEOUT" usage:"Redis dial timeout"`
+	ReadTimeout  time.Duration `config:"read_timeout" env:"REDIS_READ_TIMEOUT" usage:"Redis read timeout"`
+	WriteTimeout time.Duration `config:"write_timeout" env:"REDIS_WRITE_TIMEOUT" usage:"Redis write timeout"`
+
+	// How long startup keeps retrying the first Ping
+	ConnectTimeout time.Duration `config:"connect_timeout" env:"REDIS_CONNECT_TIMEOUT" usage:"How long startup waits for Redis"`
+}
+
+// Defaults connects to a local redis without TLS
+func Defaults() Config {
+	return Config{
+		Addrs:          []string{DefaultLocation},
+		ConnectTimeout: DefaultConnectTimeout,
+	}
+}
+
+// Options turns config into go-redis options, reading the CA file
+func (config Config) Options() (*redis.UniversalOptions, error) {
+	options := &redis.UniversalOptions{
+		Addrs:            config.Addrs,
+		Username:         config.Username,
+		Password:         config.Password,
+		DB:               config.DB,
+		MasterName:       config.MasterName,
+		SentinelPassword: config.SentinelPassword,
+		PoolSize:         config.PoolSize,
+		DialTimeout:      config.DialTimeout,
+		ReadTimeout:      config.ReadTimeout,
+		WriteTimeout:     config.WriteTimeout,
+	}
+	if len(options.Addrs) == 0 {
+		options.Addrs = []string{DefaultLocation}
+	}
+	useTLS := config.TLS || config.TLSCAFile != "" || config.TLSServerName != ""
+
+	// A URL fills in whatever the other settings leave empty
+	if len(options.Addrs) == 1 && strings.Contains(options.Addrs[0], "://") {
+		parsed, err := redis.ParseURL(options.Addrs[0])
+		if err != nil {
+			return nil, fmt.Errorf("Error: invalid REDIS_URL: %w", err)
+		}
+		options.Addrs = []string{parsed.Addr}
+		if options.Username == "" {
+			options.Username = parsed.Username
+		}
+		if options.Password == "" {
+			options.Password = parsed.Password
+		}
+		if options.DB == 0 {
+			options.DB = parsed.DB
+		}
+		useTLS = useTLS || parsed.TLSConfig != nil
+	}
+
+	if useTLS {
+		options.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: config.TLSServerName,
+		}
+		if config.TLSCAFile != "" {
+			pem, err := os.ReadFile(config.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading REDIS_TLS_CA_FILE: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("Error: REDIS_TLS_CA_FILE holds no PEM certificates")
+			}
+			options.TLSConfig.RootCAs = pool
+		}
+	}
+
+	if config.Cluster && config.MasterName != "" {
+		return nil, errors.New("Error: REDIS_CLUSTER and REDIS_SENTINEL_MASTER cannot both be set")
+	}
+	if (config.Cluster || len(options.Addrs) > 1) && options.DB != 0 {
+		return nil, errors.New("Error: REDIS_DB must be 0 with Redis Cluster")
+	}
+	return options, nil
+}
+
+// Connect opens a client for config: a Sentinel failover client when a
+// master name is set, a cluster client in cluster mode or for several
+// addresses, and a plain client otherwise. It retries the first Ping with
+// growing delays until ConnectTimeout, so the services can start before
+// redis does.
+func Connect(config Config) (redis.UniversalClient, error) {
+	options, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	if config.Cluster {
+		client = redis.NewClusterClient(options.Cluster())
+	} else {
+		client = redis.NewUniversalClient(options)
+	}
+
+	ctx := context.Background()
+	deadline := time.Now().Add(config.ConnectTimeout)
+	delay := firstRetryDelay
+	for {
+		err := client.Ping(ctx).Err()
+		if err == nil {
+			return client, nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			client.Close()
+			return nil, fmt.Errorf("Error connecting to redis at %s: %w", strings.Join(options.Addrs, ","), err)
+		}
+		slog.Warn("Error connecting to redis, retrying", "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// ForEachNode calls fn with every node that holds keys: each master of a
+// cluster, or the client itself otherwise. KEYS and SCAN only see the keys
+// of the node they run on. fn is never called concurrently.
+func ForEachNode(ctx context.Context, client redis.UniversalClient, fn func(ctx context.Context, node redis.Cmdable) error) error {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return fn(ctx, client)
+	}
+
+	var mutex sync.Mutex
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return fn(ctx, node)
+	})
+}
@@ -0,0 +1,91 @@
+// Package telemetry sets up OpenTelemetry tracing. Spans are always
+// recorded, so logs carry trace IDs, and exported only when an exporter is
+// configured. Trace context travels between services in the W3C
+// traceparent header.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	DefaultOTLPEndpoint = "http://localhost:4318"
+	shutdownTimeout     = 5 * time.Second
+)
+
+type Config struct {
+	Exporter     string `config:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"Where to send traces: none, stdout or otlp"`
+	OTLPEndpoint string `config:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector URL"`
+}
+
+func Defaults() Config {
+	return Config{
+		Exporter:     ExporterNone,
+		OTLPEndpoint: DefaultOTLPEndpoint,
+	}
+}
+
+func (config Config) Validate() error {
+	switch config.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+		return nil
+	}
+	return fmt.Errorf("Error: OTEL_TRACES_EXPORTER must be none, stdout or otlp, got %s", config.Exporter)
+}
+
+// Setup installs the global tracer provider and propagator for the service
+// called serviceName, which OTEL_SERVICE_NAME overrides. The returned
+// function flushes the spans not yet exported.
+func Setup(ctx context.Context, config Config, serviceName string) (func(), error) {
+	serviceResource, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(serviceResource)}
+	switch config.Exporter {
+	case ExporterStdout:
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		provider.Shutdown(ctx)
+	}, nil
+}
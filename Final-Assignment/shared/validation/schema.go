@@ -0,0 +1,149 @@
+package validation
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema builds a JSON Schema for model from the same binding tags that
+// are enforced on request bodies, so the published schema cannot drift
+// from the validation rules
+func Schema(id string, title string, model interface{}) map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(model))
+	schema["$schema"] = SchemaDialect
+	schema["$id"] = id
+	schema["title"] = title
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ","); jsonName == "-" {
+			continue
+		} else if jsonName != "" {
+			name = jsonName
+		}
+
+		property := typeSchema(field.Type)
+		if applyRules(property, field.Type, field.Tag.Get("binding")) {
+			required = append(required, name)
+		}
+		properties[name] = property
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyRules maps binding rules onto schema keywords and reports whether
+// the field is required. Rules after "dive" apply to slice elements, which
+// are already described by the element type's own tags.
+func applyRules(property map[string]interface{}, t reflect.Type, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+			switch t.Kind() {
+			case reflect.String:
+				property["minLength"] = 1
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				property["minimum"] = 1
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				property["not"] = map[string]interface{}{"const": 0}
+			}
+		case "min", "max":
+			bound, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			property[boundKeyword(name, t.Kind())] = bound
+		default:
+			if pattern, ok := patterns[name]; ok {
+				property["pattern"] = pattern
+			}
+			if max, ok := limits[name]; ok {
+				property[boundKeyword("max", t.Kind())] = max
+			}
+		}
+	}
+
+	return required
+}
+
+func boundKeyword(rule string, kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return rule + "Length"
+	case reflect.Slice, reflect.Array:
+		return rule + "Items"
+	}
+	if rule == "min" {
+		return "minimum"
+	}
+	return "maximum"
+}
+
+// SchemaHandler serves the given schemas by file name at /schemas/:name
+func SchemaHandler(schemas map[string]map[string]interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schema, ok := schemas[c.Param("name")]
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Header("Content-Type", "application/schema+json")
+		c.IndentedJSON(http.StatusOK, schema)
+	}
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom rules usable in binding tags. The patterns are also published in
+// the JSON Schemas so they must stay valid ECMA-262 expressions.
+var patterns = map[string]string{
+	// Anything printable except markup characters
+	"safetext": `^[^<>\x00-\x1F\x7F]*$`,
+	// Letters, combining marks, spaces, hyphens, apostrophes and periods
+	"personname": `^[\p{L}\p{M}' .-]*$`,
+}
+
+var messages = map[string]string{
+	"safetext":   "contains characters that are not allowed",
+	"personname": "may only contain letters, spaces, hyphens, apostrophes and periods",
+}
+
+// Upper bounds on the number of items in a slice, registered with
+// RegisterMax by the packages that own the limit
+var limits = map[string]int{}
+
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Register adds the custom rules to gin's validator. Call once at startup
+// before any request is bound.
+func Register() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("Error: unexpected validator engine")
+	}
+
+	for tag, pattern := range patterns {
+		expression := regexp.MustCompile(pattern)
+		err := engine.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return expression.MatchString(fl.Field().String())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterMax adds a rule named tag that allows at most max items, for
+// limits the stores enforce too so they are only written down once. Call
+// at startup after Register.
+func RegisterMax(tag string, max int) error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("Error: unexpected validator engine")
+	}
+
+	limits[tag] = max
+	return engine.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return fl.Field().Len() <= max
+	})
+}
+
+func IsValidationError(err error) bool {
+	var validationErrors validator.ValidationErrors
+	return errors.As(err, &validationErrors)
+}
+
+// Errors turns a binding error into one message per offending field
+func Errors(err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fieldErrors := make([]FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   fieldPath(fieldError.Namespace()),
+				Rule:    rule(fieldError.Tag()),
+				Message: message(fieldError),
+			})
+		}
+		return fieldErrors
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []FieldError{{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeError.Field, jsonTypeName(typeError.Type)),
+		}}
+	}
+
+	return []FieldError{{Rule: "json", Message: "request body is not valid JSON: " + err.Error()}}
+}
+
+// fieldPath drops the struct name from a validator namespace, turning
+// "Poll.PollOptions[0].PollOptionText" into "PollOptions[0].PollOptionText"
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
+}
+
+// rule reports registered limits as the max rule they stand for
+func rule(tag string) string {
+	if _, ok := limits[tag]; ok {
+		return "max"
+	}
+	return tag
+}
+
+func message(fieldError validator.FieldError) string {
+	field := fieldError.Field()
+	kind := fieldError.Kind()
+	param := fieldError.Param()
+	if max, ok := limits[fieldError.Tag()]; ok {
+		param = strconv.Itoa(max)
+	}
+
+	switch rule(fieldError.Tag()) {
+	case "required":
+		return field + " is required"
+	case "min", "max":
+		bound := "at least"
+		if rule(fieldError.Tag()) == "max" {
+			bound = "at most"
+		}
+		switch kind {
+		case reflect.String:
+			return fmt.Sprintf("%s must be %s %s characters long", field, bound, param)
+		case reflect.Slice, reflect.Array:
+			return fmt.Sprintf("%s must have %s %s items", field, bound, param)
+		}
+		return fmt.Sprintf("%s must be %s %s", field, bound, param)
+	}
+
+	if fieldError.Tag() == "unique" && param != "" {
+		return fmt.Sprintf("%s may not have two items with the same %s", field, param)
+	}
+
+	if text, ok := messages[fieldError.Tag()]; ok {
+		return field + " " + text
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fieldError.Tag())
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "non-negative integer"
+	}
+	return "integer"
+}
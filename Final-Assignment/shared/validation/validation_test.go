@@ -0,0 +1,163 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type testItem struct {
+	Name string `binding:"required,max=5,safetext"`
+}
+
+type testRecord struct {
+	ID       uint       `binding:"required"`
+	Title    string     `binding:"required,max=10,safetext"`
+	Person   string     `binding:"personname"`
+	Note     string     `binding:"min=2"`
+	Items    []testItem `binding:"maxtestitems,unique=Name,dive"`
+	Date     time.Time
+	Internal string `json:"-"`
+}
+
+func register(t *testing.T) {
+	t.Helper()
+	if err := Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterMax("maxtestitems", 2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bind decodes body into a testRecord and validates it the way gin binds
+// request bodies
+func bind(body string) error {
+	var record testRecord
+	return binding.JSON.BindBody([]byte(body), &record)
+}
+
+func TestErrors(t *testing.T) {
+	register(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		errors []FieldError
+	}{
+		{"valid", `{"ID": 1, "Title": "Pets", "Person": "Anne-Marie O'Neil", "Note": "ok"}`, nil},
+		{"missing fields", `{"Note": "ok"}`, []FieldError{
+			{"ID", "required", "ID is required"},
+			{"Title", "required", "Title is required"},
+		}},
+		{"too long", `{"ID": 1, "Title": "<b>Too long</b>", "Note": "ok"}`, []FieldError{
+			{"Title", "max", "Title must be at most 10 characters long"},
+		}},
+		{"unsafe text", `{"ID": 1, "Title": "<b>", "Note": "ok"}`, []FieldError{
+			{"Title", "safetext", "Title contains characters that are not allowed"},
+		}},
+		{"not a name", `{"ID": 1, "Title": "Pets", "Person": "R2-D2", "Note": "ok"}`, []FieldError{
+			{"Person", "personname", "Person may only contain letters, spaces, hyphens, apostrophes and periods"},
+		}},
+		{"too short", `{"ID": 1, "Title": "Pets", "Note": "x"}`, []FieldError{
+			{"Note", "min", "Note must be at least 2 characters long"},
+		}},
+		{"registered limit", `{"ID": 1, "Title": "Pets", "Note": "ok", "Items": [{"Name": "a"}, {"Name": "b"}, {"Name": "c"}]}`, []FieldError{
+			{"Items", "max", "Items must have at most 2 items"},
+		}},
+		{"repeated key", `{"ID": 1, "Title": "Pets", "Note": "ok", "Items": [{"Name": "a"}, {"Name": "a"}]}`, []FieldError{
+			{"Items", "unique", "Items may not have two items with the same Name"},
+		}},
+		{"nested field", `{"ID": 1, "Title": "Pets", "Note": "ok", "Items": [{"Name": "a"}, {"Name": "toolong"}]}`, []FieldError{
+			{"Items[1].Name", "max", "Name must be at most 5 characters long"},
+		}},
+		{"wrong type", `{"ID": "one", "Title": "Pets"}`, []FieldError{
+			{"ID", "type", "ID must be a non-negative integer"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := bind(test.body)
+			if test.errors == nil {
+				if err != nil {
+					t.Fatalf("expected no errors, got %v", err)
+				}
+				return
+			}
+			if fieldErrors := Errors(err); !reflect.DeepEqual(fieldErrors, test.errors) {
+				t.Errorf("expected %+v, got %+v", test.errors, fieldErrors)
+			}
+		})
+	}
+
+	fieldErrors := Errors(bind(`{"ID": 1,`))
+	if len(fieldErrors) != 1 || fieldErrors[0].Rule != "json" || fieldErrors[0].Field != "" {
+		t.Errorf("expected a single json error for a malformed body, got %+v", fieldErrors)
+	}
+	if IsValidationError(bind(`{"ID": 1,`)) || !IsValidationError(bind(`{}`)) {
+		t.Error("only rule violations should count as validation errors")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	register(t)
+	schema := Schema("/schemas/record.json", "Record", testRecord{})
+
+	// Compare through JSON so the expectation reads like the published schema
+	var got map[string]interface{}
+	data, _ := json.Marshal(schema)
+	json.Unmarshal(data, &got)
+
+	var want map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "/schemas/record.json",
+		"title": "Record",
+		"type": "object",
+		"required": ["ID", "Title"],
+		"properties": {
+			"ID": {"type": "integer", "minimum": 1},
+			"Title": {"type": "string", "minLength": 1, "maxLength": 10, "pattern": "^[^<>\\x00-\\x1F\\x7F]*$"},
+			"Person": {"type": "string", "pattern": "^[\\p{L}\\p{M}' .-]*$"},
+			"Note": {"type": "string", "minLength": 2},
+			"Items": {"type": "array", "maxItems": 2, "items": {
+				"type": "object",
+				"required": ["Name"],
+				"properties": {"Name": {"type": "string", "minLength": 1, "maxLength": 5, "pattern": "^[^<>\\x00-\\x1F\\x7F]*$"}}
+			}},
+			"Date": {"type": "string", "format": "date-time"}
+		}
+	}`), &want)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected schema %s", data)
+	}
+}
+
+func TestSchemaHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/schemas/:name", SchemaHandler(map[string]map[string]interface{}{
+		"item.json": Schema("/schemas/item.json", "Item", testItem{}),
+	}))
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/schemas/item.json", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/schema+json" ||
+		!strings.Contains(recorder.Body.String(), `"$id": "/schemas/item.json"`) {
+		t.Errorf("unexpected response %d %v %s", recorder.Code, recorder.Header(), recorder.Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/schemas/other.json", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown schema: expected 404, got %d", recorder.Code)
+	}
+}
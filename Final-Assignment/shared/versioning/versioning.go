@@ -0,0 +1,101 @@
+package versioning
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	V1                = "v1"
+	V2                = "v2"
+	versionContextKey = "versioning.version"
+	prefixContextKey  = "versioning.prefix"
+	dateLayout        = "2006-01-02"
+)
+
+// Defaults for when v1 was deprecated and when it will be removed
+const (
+	DefaultV1Deprecation = "2026-10-19"
+	DefaultV1Sunset      = "2027-04-30"
+)
+
+// Deprecation describes a version that clients should move off of. It is
+// announced with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+// and a Link to the successor version.
+type Deprecation struct {
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+}
+
+// Config dates the deprecation of v1, both as YYYY-MM-DD
+type Config struct {
+	DeprecationDate string `config:"deprecation_date" env:"API_V1_DEPRECATION_DATE" usage:"When v1 was deprecated, YYYY-MM-DD"`
+	SunsetDate      string `config:"sunset_date" env:"API_V1_SUNSET_DATE" usage:"When v1 will be removed, YYYY-MM-DD"`
+}
+
+func Defaults() Config {
+	return Config{DeprecationDate: DefaultV1Deprecation, SunsetDate: DefaultV1Sunset}
+}
+
+// V1Deprecation parses the dates in config
+func (config Config) V1Deprecation() (*Deprecation, error) {
+	since, err := parseDate("API_V1_DEPRECATION_DATE", config.DeprecationDate)
+	if err != nil {
+		return nil, err
+	}
+	sunset, err := parseDate("API_V1_SUNSET_DATE", config.SunsetDate)
+	if err != nil {
+		return nil, err
+	}
+	if sunset.Before(since) {
+		return nil, errors.New("Error: API_V1_SUNSET_DATE is before API_V1_DEPRECATION_DATE")
+	}
+	return &Deprecation{Since: since, Sunset: sunset, Successor: V2}, nil
+}
+
+func parseDate(name string, value string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, errors.New("Error: invalid " + name + ": " + err.Error())
+	}
+	return date, nil
+}
+
+// Middleware marks the requests of a route group with their API version.
+// prefix is the path the group is mounted at, "" for the unprefixed
+// aliases of v1. Routes of a deprecated version announce it on every
+// response.
+func Middleware(version string, prefix string, deprecation *Deprecation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionContextKey, version)
+		c.Set(prefixContextKey, prefix)
+
+		if deprecation != nil {
+			c.Header("Deprecation", "@"+strconv.FormatInt(deprecation.Since.Unix(), 10))
+			c.Header("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+			successor := "/" + deprecation.Successor + strings.TrimPrefix(c.Request.URL.Path, prefix)
+			c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		c.Next()
+	}
+}
+
+// From returns the API version a request was made against
+func From(c *gin.Context) string {
+	if version := c.GetString(versionContextKey); version != "" {
+		return version
+	}
+	return V1
+}
+
+// Prefix returns the path prefix of the request's route group, so that
+// links stay within the version the client is using
+func Prefix(c *gin.Context) string {
+	return c.GetString(prefixContextKey)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPollTurnout answers with how a poll's votes came in, bucketed by
+// ?interval=hour (the default) or day, and how many of the registered
+// voters had voted by the end of each bucket
+func (voteAPI *VoteAPI) GetPollTurnout(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	turnout, err := voteAPI.db.GetPollTurnout(c.Request.Context(), id, c.Query("interval"), auth.ForwardHeaders(c.Request))
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Poll turnout not available: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, turnout)
+}
+
+// GetVoterParticipation answers with the polls a voter voted in
+func (voteAPI *VoteAPI) GetVoterParticipation(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting voter id to int", err)
+		return
+	}
+
+	participation, err := voteAPI.db.GetVoterParticipation(c.Request.Context(), id)
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error getting voter participation: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, participation)
+}
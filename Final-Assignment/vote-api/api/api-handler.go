@@ -2,18 +2,27 @@ package api
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"shared/auth"
+	"shared/hal"
+	"shared/validation"
 	"votes-api/db"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
 )
 
 type VoteAPI struct {
 	db *db.VoteData
+	publicBaseURL string
+	votersPublicURL string
+	pollsPublicURL string
 	bootTime time.Time
 	totalCalls int
 	totalErrors int
@@ -25,21 +34,31 @@ type HealthCheckData struct {
 	TotalErrors int
 }
 
-func New() (*VoteAPI, error) {
-	dbHandler, err := db.New()
+func New(storage db.Config, services db.Services, redisClient redis.UniversalClient, publicBaseURL string) (*VoteAPI, error) {
+	dbHandler, err := db.New(storage, services, redisClient)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewWithData(dbHandler, publicBaseURL, services), nil
+}
+
+// NewWithData creates the handlers on top of an existing VoteData, so it can
+// be shared with the gRPC server. Links are built from publicBaseURL, or
+// from the request when it is empty, and from the addresses in services.
+func NewWithData(dbHandler *db.VoteData, publicBaseURL string, services db.Services) *VoteAPI {
 	return &VoteAPI{   db: dbHandler, 
+						publicBaseURL: publicBaseURL,
+						votersPublicURL: linkedServiceURL(services.VotersPublicURL, services.VotersURL),
+						pollsPublicURL: linkedServiceURL(services.PollsPublicURL, services.PollsURL),
 						bootTime: time.Now(),
 						totalCalls: 0,
-						totalErrors: 0,}, nil
+						totalErrors: 0,}
 }
 
 func (voteAPI *VoteAPI) ListAllVotes(c *gin.Context) {
 	voteAPI.totalCalls++
-	voterList, err := voteAPI.db.GetAllVotes()
+	voterList, err := voteAPI.db.GetAllVotes(c.Request.Context())
 	if err != nil {
 		voteAPI.handleInternalServerError(c, "Error Getting All Votes: ", err)
 		return
@@ -49,7 +68,13 @@ func (voteAPI *VoteAPI) ListAllVotes(c *gin.Context) {
 		voterList = make([]db.Vote, 0)
 	}
 
-	c.JSON(http.StatusOK, voterList)
+	hal.Collection(c, http.StatusOK, "votes", voterList, func(vote db.Vote) interface{} {
+		keys, _ := db.VoteKeysFromVote(vote)
+		return voteAPI.presentVote(c, vote, keys)
+	}, func(vote db.Vote) hal.Links {
+		keys, _ := db.VoteKeysFromVote(vote)
+		return voteAPI.voteLinks(c, keys)
+	}, voteAPI.votesLinks(c))
 }
 
 func (voteAPI *VoteAPI) GetVote(c *gin.Context) {
@@ -63,19 +88,24 @@ func (voteAPI *VoteAPI) GetVote(c *gin.Context) {
 
 	isDetail := c.Query("detail")
 	if isDetail == "true"{
-		vote, err := voteAPI.db.GetVoteDetails(id)
+		vote, err := voteAPI.db.GetVoteDetails(c.Request.Context(), id, auth.ForwardHeaders(c.Request))
 		if err != nil {
 			voteAPI.handleBadRequestError(c, "Vote details not found: ", err)
 			return
 		}
-		c.JSON(http.StatusOK, vote)
+		hal.JSON(c, http.StatusOK, presentVoteDetails(c, vote), voteAPI.voteLinks(c, db.VoteKeys{
+			VoteID: vote.VoteID,
+			VoterID: vote.Voter.VoterID,
+			PollID: vote.Poll.PollID,
+			PollOptionID: vote.PollOption.PollOptionID,
+		}))
 	} else {
-		vote, err := voteAPI.db.GetVote(id)
+		vote, err := voteAPI.db.GetVote(c.Request.Context(), id)
 		if err != nil {
 			voteAPI.handleBadRequestError(c, "Vote not found: ", err)
 			return
 		}
-		c.JSON(http.StatusOK, vote)
+		voteAPI.writeVote(c, vote)
 	}
 
 	
@@ -92,7 +122,7 @@ func (voteAPI *VoteAPI) AddVote(c *gin.Context) {
 
 	var voteKeys db.VoteKeys
 	if err := c.ShouldBindJSON(&voteKeys); err != nil {
-		voteAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		voteAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -101,14 +131,22 @@ func (voteAPI *VoteAPI) AddVote(c *gin.Context) {
 		return
 	}
 
-	if err := voteAPI.db.AddVote(voteKeys); err != nil {
+	if !canVoteAs(c, voteKeys.VoterID) {
+		voteAPI.handleForbiddenError(c, "ERROR: voters may only cast votes as themselves")
+		return
+	}
+
+	if err := voteAPI.db.AddVote(c.Request.Context(), voteKeys); err != nil {
+		if voteAPI.handleRejectedVote(c, err) {
+			return
+		}
 		voteAPI.handleInternalServerError(c, "Error adding voter: ", err)
 		return
 	}
 
-	vote,_ := voteAPI.db.GetVote(voteKeys.VoteID)
+	vote,_ := voteAPI.db.GetVote(c.Request.Context(), voteKeys.VoteID)
 
-	c.JSON(http.StatusOK, vote)
+	voteAPI.writeVote(c, vote)
 }
 
 
@@ -122,7 +160,7 @@ func (voteAPI *VoteAPI) UpdateVote(c *gin.Context) {
 
 	var voteKeys db.VoteKeys
 	if err := c.ShouldBindJSON(&voteKeys); err != nil {
-		voteAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		voteAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -131,14 +169,65 @@ func (voteAPI *VoteAPI) UpdateVote(c *gin.Context) {
 		return
 	}
 
-	err = voteAPI.db.UpdateVote(voteKeys.VoteID, voteKeys)
+	existingVote, err := voteAPI.db.GetVote(c.Request.Context(), id)
 	if err != nil {
 		voteAPI.handleBadRequestError(c, "Vote does not exist", err)
 		return
 	}
 
-	vote,_ := voteAPI.db.GetVote(voteKeys.VoteID)
-	c.JSON(http.StatusOK, vote)
+	existingKeys, err := db.VoteKeysFromVote(existingVote)
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error reading existing vote: ", err)
+		return
+	}
+
+	if !canVoteAs(c, voteKeys.VoterID) || !canVoteAs(c, existingKeys.VoterID) {
+		voteAPI.handleForbiddenError(c, "ERROR: "+errNotOwnVote.Error())
+		return
+	}
+
+	_, err = voteAPI.db.UpdateVote(c.Request.Context(), voteKeys.VoteID, voteKeys)
+	if voteAPI.handleRejectedVote(c, err) {
+		return
+	}
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Vote does not exist", err)
+		return
+	}
+
+	vote,_ := voteAPI.db.GetVote(c.Request.Context(), voteKeys.VoteID)
+	voteAPI.writeVote(c, vote)
+}
+
+// PatchVote accepts either a JSON Merge Patch or a JSON Patch document,
+// chosen by the Content-Type header, applied to the vote's VoteKeys
+func (voteAPI *VoteAPI) PatchVote(c *gin.Context) {
+	voteAPI.totalCalls++
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting vote id to int", err)
+		return
+	}
+
+	patch, ok := voteAPI.patchFromRequest(c)
+	if !ok {
+		return
+	}
+
+	vote, err := voteAPI.db.PatchVote(c.Request.Context(), id, patch, func(existing db.VoteKeys, patched *db.VoteKeys) error {
+		if err := binding.Validator.ValidateStruct(patched); err != nil {
+			return err
+		}
+		if !canVoteAs(c, existing.VoterID) || !canVoteAs(c, patched.VoterID) {
+			return errNotOwnVote
+		}
+		return nil
+	})
+	if err != nil {
+		voteAPI.handlePatchError(c, err)
+		return
+	}
+	voteAPI.writeVote(c, vote)
 }
 
 func (voteAPI *VoteAPI) DeleteVote(c *gin.Context) {
@@ -149,7 +238,7 @@ func (voteAPI *VoteAPI) DeleteVote(c *gin.Context) {
 		return
 	}
 
-	err = voteAPI.db.DeleteVote(id)
+	_, err = voteAPI.db.DeleteVote(c.Request.Context(), id)
 	if err != nil {
 		voteAPI.handleBadRequestError(c, "Voter does not exist", err)
 		return
@@ -159,6 +248,57 @@ func (voteAPI *VoteAPI) DeleteVote(c *gin.Context) {
 }
 
 
+var errNotOwnVote = errors.New("voters may only change their own votes")
+
+// Admins may act for any voter, everyone else only as the voter bound to
+// their credentials
+func canVoteAs(c *gin.Context, voterID uint) bool {
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		return false
+	}
+	if principal.HasRole(auth.RoleAdmin) {
+		return true
+	}
+	return principal.HasRole(auth.RoleVoter) && principal.VoterID == voterID
+}
+
+func (voteAPI *VoteAPI) patchFromRequest(c *gin.Context) (db.Patch, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error reading patch: ", err)
+		return nil, false
+	}
+
+	patch := db.PatchFromContentType(c.ContentType(), body)
+	if patch == nil {
+		voteAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Unsupported patch content type", "content_type", c.ContentType())
+		c.Header("Accept-Patch", db.MergePatchContentType+", "+db.JSONPatchContentType)
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	return patch, true
+}
+
+func (voteAPI *VoteAPI) handlePatchError(c *gin.Context, err error) {
+	switch {
+	case validation.IsValidationError(err):
+		voteAPI.handleValidationError(c, err)
+	case errors.Is(err, errNotOwnVote):
+		voteAPI.handleForbiddenError(c, "ERROR: "+err.Error())
+	case errors.Is(err, db.ErrPatchConflict):
+		voteAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error patching vote", "error", err)
+		c.AbortWithStatus(http.StatusConflict)
+	case voteAPI.handleRejectedVote(c, err):
+	case errors.Is(err, db.ErrNotFound), errors.Is(err, db.ErrInvalidPatch), errors.Is(err, db.ErrIDChanged):
+		voteAPI.handleBadRequestError(c, "Error patching vote: ", err)
+	default:
+		voteAPI.handleInternalServerError(c, "Error patching vote: ", err)
+	}
+}
+
 func getParameterUint(c *gin.Context, name string) (uint, error) {
 	paramS := c.Param(name)
 	param64, err := strconv.ParseUint(paramS, 10, 64)
@@ -170,16 +310,49 @@ func getParameterUint(c *gin.Context, name string) (uint, error) {
 
 func (voteAPI *VoteAPI) handleBadRequestError(c *gin.Context, errorMessage string, err error) {
 	voteAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.WarnContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusBadRequest)
 }
 
+func (voteAPI *VoteAPI) handleValidationError(c *gin.Context, err error) {
+	voteAPI.totalErrors++
+	fieldErrors := validation.Errors(err)
+	slog.WarnContext(c.Request.Context(), "Error validating request body", "errors", fieldErrors)
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": fieldErrors})
+}
+
+func (voteAPI *VoteAPI) handleForbiddenError(c *gin.Context, errorMessage string) {
+	voteAPI.totalErrors++
+	slog.WarnContext(c.Request.Context(), errorMessage)
+	c.AbortWithStatus(http.StatusForbidden)
+}
+
 func (voteAPI *VoteAPI) handleInternalServerError(c *gin.Context, errorMessage string, err error) {
 	voteAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.ErrorContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusInternalServerError)
 }
 
+// handleRejectedVote answers votes the store refused for naming a voter,
+// poll or option that does not exist, for taking a vote id already in use
+// or for voting twice in one poll, and changes the poll's revote policy
+// does not allow. It reports whether err was one of those.
+func (voteAPI *VoteAPI) handleRejectedVote(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, db.ErrAlreadyVoted), errors.Is(err, db.ErrVoteExists):
+		voteAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error saving vote", "error", err)
+		c.AbortWithStatus(http.StatusConflict)
+	case errors.Is(err, db.ErrReferenceNotFound):
+		voteAPI.handleBadRequestError(c, "Error saving vote: ", err)
+	case errors.Is(err, db.ErrRevoteNotAllowed):
+		voteAPI.handleForbiddenError(c, "ERROR: "+err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
 func (voteAPI * VoteAPI) HealthCheck(c *gin.Context) {
 	healthData := HealthCheckData{UpTime: time.Now().Sub(voteAPI.bootTime).String(), 
 									TotalCalls: voteAPI.totalCalls,
@@ -0,0 +1,471 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"shared/auth"
+	"shared/validation"
+	"votes-api/contracts"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startStub stands in for the voter or poll api, answering what the vote
+// api's contract with it lists
+func startStub(t *testing.T, provider string) (*contracts.Stub, string) {
+	t.Helper()
+	contract, err := contracts.Load(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := contracts.NewStub(contract)
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, strings.TrimPrefix(server.URL, "http://")
+}
+
+// newTestRouter serves the routes from a memory store behind API key auth
+// and the roles they require, as the service does, without rate limits or
+// idempotency. Details come from stubs of the voter and poll apis.
+func newTestRouter(t *testing.T) (*gin.Engine, *contracts.Stub) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{
+		APIKeys: "admin-key:admin,manager-key:poll-manager,reader-key:reader,voter1-key:voter:1,voter2-key:voter:2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voterStub, votersURL := startStub(t, "voter-api")
+	_, pollsURL := startStub(t, "poll-api")
+	services := db.DefaultServices()
+	services.VotersURL = votersURL
+	services.PollsURL = pollsURL
+	services.VotersPublicURL = "http://voters.test"
+	services.PollsPublicURL = "http://polls.test"
+
+	dbHandler, err := db.NewWithStore(db.NewMemory(services), services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voteAPI := NewWithData(dbHandler, "http://votes.test", services)
+
+	r := gin.New()
+	voteAPI.Routes(r.Group("/", auth.Middleware(authenticator)), RouteMiddleware{})
+	return r, voterStub
+}
+
+func serve(r *gin.Engine, apiKey string, method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, apiKey)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), target); err != nil {
+		t.Fatalf("response %q is not JSON: %v", recorder.Body.String(), err)
+	}
+}
+
+func castVote(t *testing.T, r *gin.Engine, apiKey string, body string) {
+	t.Helper()
+	var keys db.VoteKeys
+	if err := json.Unmarshal([]byte(body), &keys); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/votes/%d", keys.VoteID)
+	if recorder := serve(r, apiKey, http.MethodPost, path, body, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("POST %s: expected 200, got %d %s", path, recorder.Code, recorder.Body)
+	}
+}
+
+func TestCastAndGetVote(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/votes/1", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	var vote VoteV1
+	decode(t, recorder, &vote)
+	if vote.VoteID != 1 || vote.Voter != "http://voters.test/voters/1" ||
+		vote.PollOption != "http://polls.test/polls/1/polloption/1" || vote.VoteDate.IsZero() {
+		t.Errorf("unexpected vote %+v", vote)
+	}
+
+	recorder = serve(r, "reader-key", http.MethodGet, "/votes", "", nil)
+	var votes []VoteV1
+	decode(t, recorder, &votes)
+	if len(votes) != 1 {
+		t.Errorf("expected 1 vote, got %d", len(votes))
+	}
+}
+
+func TestCastTakenVoteID(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "voter2-key", http.MethodPost, "/votes/1", `{"VoteID": 1, "VoterID": 2, "PollID": 1, "PollOptionID": 2}`, nil)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a vote id that is taken, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	var vote VoteV1
+	decode(t, serve(r, "reader-key", http.MethodGet, "/votes/1", "", nil), &vote)
+	if vote.Voter != "http://voters.test/voters/1" {
+		t.Errorf("the refused vote replaced the stored one: %+v", vote)
+	}
+}
+
+func TestVotersVoteOnlyAsThemselves(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "admin-key", `{"VoteID": 2, "VoterID": 2, "PollID": 1, "PollOptionID": 2}`)
+
+	tests := []struct {
+		name   string
+		apiKey string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"cast as another voter", "voter1-key", http.MethodPost, "/votes/3", `{"VoteID": 3, "VoterID": 2, "PollID": 1, "PollOptionID": 1}`, http.StatusForbidden},
+		{"change another voter's vote", "voter1-key", http.MethodPut, "/votes/2", `{"VoteID": 2, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`, http.StatusForbidden},
+		{"readers cannot vote", "reader-key", http.MethodPost, "/votes/3", `{"VoteID": 3, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`, http.StatusForbidden},
+		{"change own vote", "voter2-key", http.MethodPut, "/votes/2", `{"VoteID": 2, "VoterID": 2, "PollID": 1, "PollOptionID": 1}`, http.StatusOK},
+		{"ids differ", "voter1-key", http.MethodPost, "/votes/4", `{"VoteID": 5, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`, http.StatusBadRequest},
+		{"missing poll", "voter1-key", http.MethodPost, "/votes/4", `{"VoteID": 4, "VoterID": 1, "PollOptionID": 1}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, test.apiKey, test.method, test.path, test.body, nil)
+			if recorder.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestGetVoteWithDetails(t *testing.T) {
+	r, voterStub := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/votes/1?detail=true", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var vote VoteDetailsV1
+	decode(t, recorder, &vote)
+	if vote.Voter.FirstName != "Michael" || vote.Poll.PollTitle != "Favorite Color" || vote.PollOption.PollOptionText != "Blue" {
+		t.Errorf("unexpected details %+v", vote)
+	}
+	if forwarded := voterStub.LastHeader().Get(auth.APIKeyHeader); forwarded != "reader-key" {
+		t.Errorf("expected the caller's API key to reach the voter api, got %q", forwarded)
+	}
+}
+
+func TestDetailsOfUnknownVoter(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "admin-key", `{"VoteID": 9, "VoterID": 9, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/votes/9?detail=true", "", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 when the voter api has no such voter, got %d", recorder.Code)
+	}
+}
+
+func TestPatchAndDeleteVote(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "voter1-key", http.MethodPatch, "/votes/1", `{"PollOptionID": 2}`,
+		http.Header{"Content-Type": {db.MergePatchContentType}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("patch: expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var vote VoteV1
+	decode(t, recorder, &vote)
+	if vote.PollOption != "http://polls.test/polls/1/polloption/2" {
+		t.Errorf("expected the vote to move to option 2, got %+v", vote)
+	}
+
+	if recorder := serve(r, "voter1-key", http.MethodDelete, "/votes/1", "", nil); recorder.Code != http.StatusForbidden {
+		t.Fatalf("delete by the voter: expected 403, got %d", recorder.Code)
+	}
+	if recorder := serve(r, "admin-key", http.MethodDelete, "/votes/1", "", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", recorder.Code)
+	}
+	if recorder := serve(r, "reader-key", http.MethodGet, "/votes/1", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("deleted vote: expected 400, got %d", recorder.Code)
+	}
+}
+
+// TestRoutesRequireRoles checks the roles of the routes the handler tests
+// do not already cover
+func TestRoutesRequireRoles(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+	tests := []struct {
+		apiKey string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"reader-key", http.MethodPost, "/votes/2", `{"VoteID": 2, "VoterID": 2, "PollID": 1, "PollOptionID": 1}`, http.StatusForbidden},
+		{"manager-key", http.MethodPut, "/votes/1", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 2}`, http.StatusForbidden},
+		{"voter1-key", http.MethodPut, "/polls/1/revote-policy", `{"Mode": "forbid"}`, http.StatusForbidden},
+		{"reader-key", http.MethodPut, "/polls/1/revote-policy", `{"Mode": "forbid"}`, http.StatusForbidden},
+		{"manager-key", http.MethodPut, "/polls/1/revote-policy", `{"Mode": "window", "WindowMinutes": 5}`, http.StatusOK},
+		{"admin-key", http.MethodPut, "/polls/1/revote-policy", `{"Mode": "until-close"}`, http.StatusOK},
+		{"manager-key", http.MethodDelete, "/votes/1", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if recorder := serve(r, test.apiKey, test.method, test.path, test.body, nil); recorder.Code != test.status {
+			t.Errorf("%s %s as %s: expected %d, got %d %s", test.method, test.path, test.apiKey, test.status, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestPatchFormats(t *testing.T) {
+	mergePatch := http.Header{"Content-Type": {db.MergePatchContentType}}
+	jsonPatch := http.Header{"Content-Type": {db.JSONPatchContentType}}
+
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		status int
+		option uint
+	}{
+		{"merge patch", `{"PollOptionID": 2}`, mergePatch, http.StatusOK, 2},
+		{"json patch", `[{"op": "replace", "path": "/PollOptionID", "value": 2}]`, jsonPatch, http.StatusOK, 2},
+		{"json patch failed test", `[{"op": "test", "path": "/PollOptionID", "value": 2}, {"op": "replace", "path": "/PollOptionID", "value": 2}]`, jsonPatch, http.StatusBadRequest, 1},
+		{"merge patch body to json patch", `{"PollOptionID": 2}`, jsonPatch, http.StatusBadRequest, 1},
+		{"merge patch changes the id", `{"VoteID": 2}`, mergePatch, http.StatusBadRequest, 1},
+		{"json patch changes the id", `[{"op": "replace", "path": "/VoteID", "value": 2}]`, jsonPatch, http.StatusBadRequest, 1},
+		{"patched into an invalid vote", `{"PollOptionID": null}`, mergePatch, http.StatusBadRequest, 1},
+		{"plain json", `{"PollOptionID": 2}`, http.Header{"Content-Type": {"application/json"}}, http.StatusUnsupportedMediaType, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := newTestRouter(t)
+			castVote(t, r, "admin-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+
+			recorder := serve(r, "admin-key", http.MethodPatch, "/votes/1", test.body, test.header)
+			if recorder.Code != test.status {
+				t.Fatalf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+
+			var vote VoteV1
+			decode(t, serve(r, "reader-key", http.MethodGet, "/votes/1", "", nil), &vote)
+			if want := fmt.Sprintf("http://polls.test/polls/1/polloption/%d", test.option); vote.PollOption != want {
+				t.Errorf("expected the vote for %s, got %+v", want, vote)
+			}
+			if recorder := serve(r, "reader-key", http.MethodGet, "/votes/2", "", nil); recorder.Code != http.StatusBadRequest {
+				t.Errorf("a patch should never create another vote, got %d for vote 2", recorder.Code)
+			}
+		})
+	}
+
+	r, _ := newTestRouter(t)
+	castVote(t, r, "admin-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+	recorder := serve(r, "admin-key", http.MethodPatch, "/votes/1", `{"PollOptionID": null}`, mergePatch)
+	var body struct {
+		Errors []validation.FieldError `json:"errors"`
+	}
+	decode(t, recorder, &body)
+	if len(body.Errors) != 1 || body.Errors[0] != (validation.FieldError{Field: "PollOptionID", Rule: "required", Message: "PollOptionID is required"}) {
+		t.Errorf("expected an error on PollOptionID, got %+v", body.Errors)
+	}
+}
+
+func TestPollResults(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "admin-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+	castVote(t, r, "admin-key", `{"VoteID": 2, "VoterID": 2, "PollID": 1, "PollOptionID": 2}`)
+	castVote(t, r, "admin-key", `{"VoteID": 3, "VoterID": 3, "PollID": 1, "PollOptionID": 1}`)
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/polls/1/results", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var report ResultsReport
+	decode(t, recorder, &report)
+	if report.PollTitle != "Favorite Color" || report.TotalVotes != 3 || report.RegisteredVoters != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	votes := map[string]uint{}
+	for _, option := range report.Options {
+		votes[option.PollOptionText] = option.Votes
+	}
+	if votes["Blue"] != 2 || votes["Brown"] != 1 {
+		t.Errorf("unexpected tallies %v", votes)
+	}
+
+	recorder = serve(r, "reader-key", http.MethodGet, "/polls/1/results?format=csv", "", nil)
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, CSVContentType) {
+		t.Errorf("expected CSV, got %s", contentType)
+	}
+	recorder = serve(r, "reader-key", http.MethodGet, "/polls/1/results?format=ndjson", "", nil)
+	if lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n"); len(lines) != 3 || strings.Contains(recorder.Body.String(), "VoterID") {
+		t.Errorf("expected three votes without voter IDs, got %s", recorder.Body)
+	}
+	recorder = serve(r, "reader-key", http.MethodGet, "/polls/1/results?format=xml", "", nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400, got %d", recorder.Code)
+	}
+}
+
+// Option texts come from poll managers, so the CSV export must not let a
+// spreadsheet run one as a formula
+func TestResultsCSVEscapesFormulas(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	report := ResultsReport{PollID: 1, Options: []OptionResult{
+		{PollOptionID: 1, PollOptionText: "=HYPERLINK(\"http://evil.test\")"},
+		{PollOptionID: 2, PollOptionText: "+1"},
+		{PollOptionID: 3, PollOptionText: "-1"},
+		{PollOptionID: 4, PollOptionText: "@SUM(A1)"},
+		{PollOptionID: 5, PollOptionText: "Blue = good"},
+	}}
+	(&VoteAPI{}).writeResultsCSV(c, report)
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, record := range records[1:] {
+		texts = append(texts, record[2])
+	}
+	want := []string{"'=HYPERLINK(\"http://evil.test\")", "'+1", "'-1", "'@SUM(A1)", "Blue = good"}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("got option texts %q, want %q", texts, want)
+	}
+}
+
+func TestTurnoutAndParticipation(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "admin-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+	castVote(t, r, "admin-key", `{"VoteID": 2, "VoterID": 2, "PollID": 1, "PollOptionID": 2}`)
+	if recorder := serve(r, "admin-key", http.MethodDelete, "/votes/2", "", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("DELETE /votes/2: expected 200, got %d", recorder.Code)
+	}
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/polls/1/turnout?interval=day", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var turnout db.PollTurnout
+	decode(t, recorder, &turnout)
+	if turnout.Interval != db.IntervalDay || turnout.TotalVotes != 1 || turnout.Voters != 1 || turnout.RegisteredVoters != 1 || turnout.Turnout != 1 {
+		t.Errorf("unexpected turnout %+v", turnout)
+	}
+	if len(turnout.Buckets) != 1 || turnout.Buckets[0].Votes != 1 || turnout.Buckets[0].NewVoters != 1 {
+		t.Errorf("expected one day with one vote, got %+v", turnout.Buckets)
+	}
+	if recorder := serve(r, "reader-key", http.MethodGet, "/polls/1/turnout?interval=week", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown interval: expected 400, got %d", recorder.Code)
+	}
+
+	recorder = serve(r, "reader-key", http.MethodGet, "/voters/1/participation", "", nil)
+	var participation db.VoterParticipation
+	decode(t, recorder, &participation)
+	if participation.TotalVotes != 1 || len(participation.Polls) != 1 || participation.Polls[0].PollID != 1 {
+		t.Errorf("unexpected participation %+v", participation)
+	}
+	recorder = serve(r, "reader-key", http.MethodGet, "/voters/2/participation", "", nil)
+	decode(t, recorder, &participation)
+	if participation.TotalVotes != 0 || len(participation.Polls) != 0 {
+		t.Errorf("voter 2's vote was deleted, got %+v", participation)
+	}
+}
+
+func TestVoteHistoryAndRevotePolicy(t *testing.T) {
+	r, _ := newTestRouter(t)
+	castVote(t, r, "voter1-key", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`)
+	if recorder := serve(r, "voter1-key", http.MethodPut, "/votes/1", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 2}`, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("PUT /votes/1: expected 200, got %d", recorder.Code)
+	}
+	if recorder := serve(r, "voter1-key", http.MethodPatch, "/votes/1", `{"PollOptionID": 3}`,
+		http.Header{"Content-Type": {db.MergePatchContentType}}); recorder.Code != http.StatusOK {
+		t.Fatalf("PATCH /votes/1: expected 200, got %d", recorder.Code)
+	}
+
+	recorder := serve(r, "reader-key", http.MethodGet, "/votes/1/history", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var history db.VoteHistory
+	decode(t, recorder, &history)
+	if len(history.Revisions) != 3 || !history.CastDate.Equal(history.Revisions[0].Date) {
+		t.Fatalf("expected 3 revisions from the cast date, got %+v", history)
+	}
+	for i, want := range []db.Revision{
+		{PollID: 1, PollOptionID: 1, Source: db.RevisionCast},
+		{PollID: 1, PollOptionID: 2, Source: db.RevisionUpdate},
+		{PollID: 1, PollOptionID: 3, Source: db.RevisionPatch},
+	} {
+		got := history.Revisions[i]
+		if got.PollID != want.PollID || got.PollOptionID != want.PollOptionID || got.Source != want.Source {
+			t.Errorf("revision %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if recorder := serve(r, "reader-key", http.MethodGet, "/votes/2/history", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("unknown vote: expected 400, got %d", recorder.Code)
+	}
+
+	for _, test := range []struct {
+		name   string
+		poll   string
+		body   string
+		status int
+	}{
+		{"window without minutes", "1", `{"Mode": "window"}`, http.StatusBadRequest},
+		{"unknown mode", "1", `{"Mode": "sometimes"}`, http.StatusBadRequest},
+		{"unknown poll", "2", `{"Mode": "forbid"}`, http.StatusBadRequest},
+		{"forbid", "1", `{"Mode": "forbid"}`, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, "admin-key", http.MethodPut, "/polls/"+test.poll+"/revote-policy", test.body, nil)
+			if recorder.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+		})
+	}
+
+	recorder = serve(r, "reader-key", http.MethodGet, "/polls/1/revote-policy", "", nil)
+	var policy db.RevotePolicy
+	decode(t, recorder, &policy)
+	if policy.Mode != db.RevoteForbid {
+		t.Errorf("expected the forbid policy, got %+v", policy)
+	}
+	if recorder := serve(r, "voter1-key", http.MethodPut, "/votes/1", `{"VoteID": 1, "VoterID": 1, "PollID": 1, "PollOptionID": 1}`, nil); recorder.Code != http.StatusForbidden {
+		t.Errorf("PUT under forbid: expected 403, got %d", recorder.Code)
+	}
+	if recorder := serve(r, "voter1-key", http.MethodPatch, "/votes/1", `{"PollOptionID": 1}`,
+		http.Header{"Content-Type": {db.MergePatchContentType}}); recorder.Code != http.StatusForbidden {
+		t.Errorf("PATCH under forbid: expected 403, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"shared/versioning"
+	"votes-api/client/pollclient"
+	"votes-api/client/voterclient"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response bodies, one set per API version. They are kept apart from the
+// structs in db so that the storage format and each version's wire format
+// can change independently.
+
+// VoteV1 links the voter, poll and option as plain URL strings
+type VoteV1 struct {
+	VoteID     uint
+	Voter      string
+	Poll       string
+	PollOption string
+	VoteDate   time.Time
+}
+
+// VoteV2 links the voter, poll and option as objects carrying both the
+// id and the URL, so clients no longer parse ids out of the links
+type VoteV2 struct {
+	VoteID     uint
+	Voter      VoterRefV2
+	Poll       PollRefV2
+	PollOption PollOptionRefV2
+	VoteDate   time.Time
+}
+
+type VoterRefV2 struct {
+	VoterID uint
+	Href    string `json:"href"`
+}
+
+type PollRefV2 struct {
+	PollID uint
+	Href   string `json:"href"`
+}
+
+type PollOptionRefV2 struct {
+	PollOptionID uint
+	Href         string `json:"href"`
+}
+
+// VoteDetailsV1 and VoteDetailsV2 embed the linked records as the voter
+// and poll apis return them
+type VoteDetailsV1 struct {
+	VoteID     uint
+	Voter      voterclient.Voter
+	Poll       pollclient.Poll
+	PollOption pollclient.PollOption
+	VoteDate   time.Time
+}
+
+type VoteDetailsV2 struct {
+	VoteID     uint
+	Voter      voterclient.Voter
+	Poll       pollclient.Poll
+	PollOption pollclient.PollOption
+	VoteDate   time.Time
+}
+
+// presentVote renders a vote for the request's version with links to the
+// public addresses of the voter and poll apis, under the same version
+func (voteAPI *VoteAPI) presentVote(c *gin.Context, vote db.Vote, keys db.VoteKeys) interface{} {
+	voter := fmt.Sprintf("%s/voters/%d", voteAPI.votersURL(c), keys.VoterID)
+	poll := fmt.Sprintf("%s/polls/%d", voteAPI.pollsURL(c), keys.PollID)
+	pollOption := fmt.Sprintf("%s/polloption/%d", poll, keys.PollOptionID)
+
+	if versioning.From(c) == versioning.V2 {
+		return VoteV2{
+			VoteID:     vote.VoteID,
+			Voter:      VoterRefV2{VoterID: keys.VoterID, Href: voter},
+			Poll:       PollRefV2{PollID: keys.PollID, Href: poll},
+			PollOption: PollOptionRefV2{PollOptionID: keys.PollOptionID, Href: pollOption},
+			VoteDate:   vote.VoteDate,
+		}
+	}
+	return VoteV1{
+		VoteID:     vote.VoteID,
+		Voter:      voter,
+		Poll:       poll,
+		PollOption: pollOption,
+		VoteDate:   vote.VoteDate,
+	}
+}
+
+func presentVoteDetails(c *gin.Context, vote db.VoteDetails) interface{} {
+	if versioning.From(c) == versioning.V2 {
+		return VoteDetailsV2{
+			VoteID:     vote.VoteID,
+			Voter:      vote.Voter,
+			Poll:       vote.Poll,
+			PollOption: vote.PollOption,
+			VoteDate:   vote.VoteDate,
+		}
+	}
+	return VoteDetailsV1{
+		VoteID:     vote.VoteID,
+		Voter:      vote.Voter,
+		Poll:       vote.Poll,
+		PollOption: vote.PollOption,
+		VoteDate:   vote.VoteDate,
+	}
+}
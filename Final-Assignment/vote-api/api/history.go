@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"shared/auth"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetVoteHistory answers with every choice a vote held, oldest first
+func (voteAPI *VoteAPI) GetVoteHistory(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting vote id to int", err)
+		return
+	}
+
+	history, err := voteAPI.db.GetVoteHistory(c.Request.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		voteAPI.handleBadRequestError(c, "Vote does not exist", err)
+		return
+	}
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error getting vote history: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetRevotePolicy answers with whether the votes in a poll can be changed
+func (voteAPI *VoteAPI) GetRevotePolicy(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	policy, err := voteAPI.db.GetRevotePolicy(c.Request.Context(), id)
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error getting revote policy: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// SetRevotePolicy replaces the policy of a poll. It applies to votes
+// changed from then on.
+func (voteAPI *VoteAPI) SetRevotePolicy(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	var policy db.RevotePolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		voteAPI.handleValidationError(c, err)
+		return
+	}
+
+	err = voteAPI.db.SetPollRevotePolicy(c.Request.Context(), id, policy, auth.ForwardHeaders(c.Request))
+	if errors.Is(err, db.ErrReferenceNotFound) {
+		voteAPI.handleBadRequestError(c, "Poll does not exist", err)
+		return
+	}
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error setting revote policy: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"shared/auth"
+	"shared/hal"
+	"shared/versioning"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Links are built from public base URLs so that they work for clients
+// outside the docker network. Action links are only listed when the
+// caller is allowed to perform them.
+
+// linkedServiceURL is the public address of the voter or poll api. It
+// falls back to the internal address the vote api itself uses.
+func linkedServiceURL(publicURL string, internalURL string) string {
+	if publicURL != "" {
+		return publicURL
+	}
+	return "http://" + internalURL
+}
+
+// baseURL includes the version prefix of the request's route group
+func (voteAPI *VoteAPI) baseURL(c *gin.Context) string {
+	return hal.BaseURL(c, voteAPI.publicBaseURL) + versioning.Prefix(c)
+}
+
+// votersURL and pollsURL are the public addresses of the voter and poll
+// apis under the same version as the request
+func (voteAPI *VoteAPI) votersURL(c *gin.Context) string {
+	return voteAPI.votersPublicURL + versioning.Prefix(c)
+}
+
+func (voteAPI *VoteAPI) pollsURL(c *gin.Context) string {
+	return voteAPI.pollsPublicURL + versioning.Prefix(c)
+}
+
+func (voteAPI *VoteAPI) votesLinks(c *gin.Context) hal.Links {
+	base := voteAPI.baseURL(c)
+	links := hal.Links{
+		"self": {Href: base + "/votes"},
+		"vote": {Href: base + "/votes/{id}", Templated: true},
+	}
+	if principal := auth.PrincipalFrom(c); principal != nil && principal.HasRole(auth.RoleVoter, auth.RoleAdmin) {
+		links.Action("create", http.MethodPost, base+"/votes/{id}")
+	}
+	return links
+}
+
+func (voteAPI *VoteAPI) voteLinks(c *gin.Context, keys db.VoteKeys) hal.Links {
+	base := voteAPI.baseURL(c)
+	self := fmt.Sprintf("%s/votes/%d", base, keys.VoteID)
+	poll := fmt.Sprintf("%s/polls/%d", voteAPI.pollsURL(c), keys.PollID)
+	links := hal.Links{
+		"self":       {Href: self},
+		"collection": {Href: base + "/votes"},
+		"details":    {Href: self + "?detail=true"},
+		"voter":      {Href: fmt.Sprintf("%s/voters/%d", voteAPI.votersURL(c), keys.VoterID)},
+		"poll":       {Href: poll},
+		"option":     {Href: fmt.Sprintf("%s/polloption/%d", poll, keys.PollOptionID)},
+	}
+	if canVoteAs(c, keys.VoterID) {
+		links.Action("update", http.MethodPut, self)
+		links.Action("patch", http.MethodPatch, self)
+	}
+	if principal := auth.PrincipalFrom(c); principal != nil && principal.HasRole(auth.RoleAdmin) {
+		links.Action("delete", http.MethodDelete, self)
+	}
+	return links
+}
+
+// writeVote responds with a vote in its public form
+func (voteAPI *VoteAPI) writeVote(c *gin.Context, vote db.Vote) {
+	keys, err := db.VoteKeysFromVote(vote)
+	if err != nil {
+		voteAPI.handleInternalServerError(c, "Error reading vote links: ", err)
+		return
+	}
+	hal.JSON(c, http.StatusOK, voteAPI.presentVote(c, vote, keys), voteAPI.voteLinks(c, keys))
+}
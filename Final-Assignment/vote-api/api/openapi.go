@@ -0,0 +1,27 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIDocument describes every v1 route of this service, which are
+// also served without a prefix. OpenAPIDocumentV2 describes the /v2
+// routes. main_test.go checks both against the registered gin routes.
+var (
+	//go:embed openapi.json
+	OpenAPIDocument []byte
+
+	//go:embed openapi-v2.json
+	OpenAPIDocumentV2 []byte
+)
+
+func ServeOpenAPIDocument(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", OpenAPIDocument)
+}
+
+func ServeOpenAPIDocumentV2(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", OpenAPIDocumentV2)
+}
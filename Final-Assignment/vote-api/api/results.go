@@ -0,0 +1,225 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/auth"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSVContentType    = "text/csv"
+	NDJSONContentType = "application/x-ndjson"
+	HTMLContentType   = "text/html"
+)
+
+//go:embed results.html
+var resultsHTML string
+
+var resultsTemplate = template.Must(template.New("results").Funcs(template.FuncMap{
+	"percent": func(share float64) string { return strconv.FormatFloat(share*100, 'f', 1, 64) + "%" },
+	"date":    func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 MST") },
+}).Parse(resultsHTML))
+
+// ResultsReport is a poll's results with every option of the poll, also
+// those nobody voted for
+type ResultsReport struct {
+	PollID           uint
+	PollTitle        string
+	PollQuestion     string
+	Options          []OptionResult
+	TotalVotes       uint
+	Voters           uint
+	RegisteredVoters uint
+	// Share of registered voters who voted, from 0 to 1
+	Turnout       float64
+	FirstVoteDate *time.Time `json:",omitempty"`
+	LastVoteDate  *time.Time `json:",omitempty"`
+	GeneratedAt   time.Time
+}
+
+type OptionResult struct {
+	PollOptionID   uint
+	PollOptionText string
+	Votes          uint
+	// Share of all votes in the poll, from 0 to 1
+	Share float64
+}
+
+// ResultVote is one line of the NDJSON form. Who cast a vote is left out,
+// so publishing the votes does not reveal how anyone voted.
+type ResultVote struct {
+	VoteID       uint
+	PollOptionID uint
+	VoteDate     time.Time
+}
+
+// GetPollResults answers with the poll's results as JSON (the default),
+// CSV, NDJSON of the individual votes or a printable HTML report, chosen
+// with ?format= or the Accept header
+func (voteAPI *VoteAPI) GetPollResults(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		switch c.NegotiateFormat(gin.MIMEJSON, CSVContentType, NDJSONContentType, HTMLContentType) {
+		case CSVContentType:
+			format = "csv"
+		case NDJSONContentType:
+			format = "ndjson"
+		case HTMLContentType:
+			format = "html"
+		}
+	}
+
+	pollReport, err := voteAPI.db.GetPollReport(c.Request.Context(), id, auth.ForwardHeaders(c.Request))
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Poll results not available: ", err)
+		return
+	}
+	report := newResultsReport(pollReport)
+
+	switch format {
+	case "", "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		voteAPI.writeResultsCSV(c, report)
+	case "ndjson":
+		voteAPI.writeResultVotes(c, pollReport.Votes)
+	case "html":
+		voteAPI.writeResultsHTML(c, report)
+	default:
+		voteAPI.handleBadRequestError(c, "Error getting poll results: ", fmt.Errorf("unknown format %q, expected json, csv, ndjson or html", format))
+	}
+}
+
+func newResultsReport(pollReport db.PollReport) ResultsReport {
+	results := pollReport.Results
+	report := ResultsReport{
+		PollID:           results.PollID,
+		PollTitle:        pollReport.Poll.PollTitle,
+		PollQuestion:     pollReport.Poll.PollQuestion,
+		Options:          []OptionResult{},
+		TotalVotes:       results.TotalVotes,
+		Voters:           results.Voters,
+		RegisteredVoters: pollReport.RegisteredVoters,
+		Turnout:          share(results.Voters, pollReport.RegisteredVoters),
+		GeneratedAt:      time.Now().UTC(),
+	}
+	if results.TotalVotes > 0 {
+		report.FirstVoteDate = &results.FirstVoteDate
+		report.LastVoteDate = &results.LastVoteDate
+	}
+
+	counts := map[uint]uint{}
+	for _, tally := range results.Tallies {
+		counts[tally.PollOptionID] = tally.Votes
+	}
+	for _, pollOption := range pollReport.Poll.PollOptions {
+		report.Options = append(report.Options, OptionResult{
+			PollOptionID:   pollOption.PollOptionID,
+			PollOptionText: pollOption.PollOptionText,
+			Votes:          counts[pollOption.PollOptionID],
+			Share:          share(counts[pollOption.PollOptionID], results.TotalVotes),
+		})
+		delete(counts, pollOption.PollOptionID)
+	}
+	// Votes for options that have since been removed from the poll still
+	// count, under their id
+	for _, tally := range results.Tallies {
+		if _, removed := counts[tally.PollOptionID]; removed {
+			report.Options = append(report.Options, OptionResult{
+				PollOptionID: tally.PollOptionID,
+				Votes:        tally.Votes,
+				Share:        share(tally.Votes, results.TotalVotes),
+			})
+		}
+	}
+	return report
+}
+
+func share(part uint, whole uint) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+func (voteAPI *VoteAPI) writeResultsCSV(c *gin.Context, report ResultsReport) {
+	c.Header("Content-Type", CSVContentType+"; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="poll-%d-results.csv"`, report.PollID))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"PollID", "PollOptionID", "PollOptionText", "Votes", "Share"})
+	for _, option := range report.Options {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(report.PollID), 10),
+			strconv.FormatUint(uint64(option.PollOptionID), 10),
+			csvText(option.PollOptionText),
+			strconv.FormatUint(uint64(option.Votes), 10),
+			strconv.FormatFloat(option.Share, 'f', 4, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		voteAPI.totalErrors++
+		c.Error(err)
+	}
+}
+
+// csvText keeps spreadsheets from running text that looks like a formula
+// by prefixing it with a quote, as OWASP recommends for CSV exports
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (voteAPI *VoteAPI) writeResultVotes(c *gin.Context, votes []db.Vote) {
+	c.Header("Content-Type", NDJSONContentType)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for _, vote := range votes {
+		keys, err := db.VoteKeysFromVote(vote)
+		if err == nil {
+			err = encoder.Encode(ResultVote{
+				VoteID:       keys.VoteID,
+				PollOptionID: keys.PollOptionID,
+				VoteDate:     vote.VoteDate,
+			})
+		}
+		if err != nil {
+			voteAPI.totalErrors++
+			c.Error(err)
+			return
+		}
+	}
+}
+
+func (voteAPI *VoteAPI) writeResultsHTML(c *gin.Context, report ResultsReport) {
+	c.Header("Content-Type", HTMLContentType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := resultsTemplate.Execute(c.Writer, report); err != nil {
+		voteAPI.totalErrors++
+		c.Error(err)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteMiddleware is what the routes run besides authorization. Idempotent
+// guards casting a vote, LimitCastVote limits it and LimitChangeVote
+// limits updates and patches. Nil fields are skipped.
+type RouteMiddleware struct {
+	Idempotent      gin.HandlerFunc
+	LimitCastVote   gin.HandlerFunc
+	LimitChangeVote gin.HandlerFunc
+}
+
+// Routes registers the vote routes on group, which authenticates its
+// requests, each behind the roles it requires. The service registers them
+// once for each API version.
+func (voteAPI *VoteAPI) Routes(group *gin.RouterGroup, middleware RouteMiddleware) {
+	anyRole := auth.RequireRole()
+	voter := auth.RequireRole(auth.RoleVoter, auth.RoleAdmin)
+	admin := auth.RequireRole(auth.RoleAdmin)
+	pollManager := auth.RequireRole(auth.RolePollManager, auth.RoleAdmin)
+	idempotent := orNext(middleware.Idempotent)
+	limitCastVote := orNext(middleware.LimitCastVote)
+	limitChangeVote := orNext(middleware.LimitChangeVote)
+
+	group.GET("/votes", anyRole, voteAPI.ListAllVotes)
+
+	group.GET("/votes/:id", anyRole, voteAPI.GetVote)
+	group.POST("/votes/:id", voter, idempotent, limitCastVote, voteAPI.AddVote)
+	group.PUT("/votes/:id", voter, limitChangeVote, voteAPI.UpdateVote)
+	group.PATCH("/votes/:id", voter, limitChangeVote, voteAPI.PatchVote)
+	group.DELETE("/votes/:id", admin, voteAPI.DeleteVote)
+	group.GET("/votes/:id/history", anyRole, voteAPI.GetVoteHistory)
+
+	group.GET("/polls/:id/results", anyRole, voteAPI.GetPollResults)
+	group.GET("/polls/:id/results/timeline", anyRole, voteAPI.GetPollResultsTimeline)
+	group.GET("/polls/:id/turnout", anyRole, voteAPI.GetPollTurnout)
+	group.GET("/polls/:id/revote-policy", anyRole, voteAPI.GetRevotePolicy)
+	group.PUT("/polls/:id/revote-policy", pollManager, voteAPI.SetRevotePolicy)
+	group.GET("/voters/:id/participation", anyRole, voteAPI.GetVoterParticipation)
+}
+
+// orNext is handler, or one that passes the request on when it is nil
+func orNext(handler gin.HandlerFunc) gin.HandlerFunc {
+	if handler == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return handler
+}
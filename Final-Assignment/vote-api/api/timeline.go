@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"shared/auth"
+	"votes-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Range of a timeline unless ?from=, ?to= and ?bucket= say otherwise
+const (
+	defaultTimelineSpan   = 24 * time.Hour
+	defaultTimelineBucket = time.Hour
+)
+
+// GetPollResultsTimeline answers with the votes each option of a poll had
+// at the end of each ?bucket= (a duration such as 15m) between ?from= and
+// ?to= (RFC 3339 times), by default hourly over the last day
+func (voteAPI *VoteAPI) GetPollResultsTimeline(c *gin.Context) {
+	voteAPI.totalCalls++
+
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Error converting poll id to int", err)
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			voteAPI.handleBadRequestError(c, "Error reading to: ", err)
+			return
+		}
+	}
+	from := to.Add(-defaultTimelineSpan)
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			voteAPI.handleBadRequestError(c, "Error reading from: ", err)
+			return
+		}
+	}
+	bucket := defaultTimelineBucket
+	if value := c.Query("bucket"); value != "" {
+		if bucket, err = time.ParseDuration(value); err != nil {
+			voteAPI.handleBadRequestError(c, "Error reading bucket: ", err)
+			return
+		}
+	}
+
+	timeline, err := voteAPI.db.GetPollTimeline(c.Request.Context(), id, from, to, bucket, auth.ForwardHeaders(c.Request))
+	if errors.Is(err, db.ErrTimelineUnavailable) {
+		voteAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Poll results timeline not available", "error", err)
+		c.AbortWithStatus(http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		voteAPI.handleBadRequestError(c, "Poll results timeline not available: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, timeline)
+}
@@ -0,0 +1,212 @@
+// Package backup dumps the polls, voters and votes of the shared Redis to
+// an archive and restores them into an empty Redis.
+//
+// An archive is gzip compressed NDJSON. The first line is a header with
+// the format version, then one line per key holding the key's JSON
+// document, then a trailer with the record counts and the SHA-256 of
+// every line before it.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	Format = "cnse-voting-backup"
+	// Bump when the archive layout changes; restore refuses newer versions
+	FormatVersion = 1
+)
+
+// The key prefixes of the records that are backed up, as written by the
+// poll, voter and vote apis
+var Prefixes = []string{"poll:", "voter:", "vote:", "revote-policy:"}
+
+var ErrChecksum = errors.New("archive checksum does not match its contents")
+
+type Header struct {
+	Format    string
+	Version   int
+	CreatedAt time.Time
+}
+
+type Record struct {
+	Key   string
+	Value json.RawMessage
+}
+
+type Trailer struct {
+	Records int
+	// Records per key prefix, without the colon
+	Counts map[string]int
+	SHA256 string
+}
+
+// Manifest describes a whole archive
+type Manifest struct {
+	Header
+	Trailer
+}
+
+// line is any line of an archive; which fields are set tells them apart
+type line struct {
+	Format    string          `json:",omitempty"`
+	Version   int             `json:",omitempty"`
+	CreatedAt *time.Time      `json:",omitempty"`
+	Key       string          `json:",omitempty"`
+	Value     json.RawMessage `json:",omitempty"`
+	Records   *int            `json:",omitempty"`
+	Counts    map[string]int  `json:",omitempty"`
+	SHA256    string          `json:",omitempty"`
+}
+
+// Writer writes one archive. Close must be called to write the trailer.
+type Writer struct {
+	gzip    *gzip.Writer
+	hash    hash.Hash
+	records int
+	counts  map[string]int
+}
+
+func NewWriter(w io.Writer, createdAt time.Time) (*Writer, error) {
+	archive := &Writer{gzip: gzip.NewWriter(w), hash: sha256.New(), counts: map[string]int{}}
+	createdAt = createdAt.UTC()
+	if err := archive.writeLine(line{Format: Format, Version: FormatVersion, CreatedAt: &createdAt}, true); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (archive *Writer) Add(key string, value json.RawMessage) error {
+	prefix, _, found := strings.Cut(key, ":")
+	if !found || key == "" {
+		return fmt.Errorf("key %q has no prefix", key)
+	}
+	if err := archive.writeLine(line{Key: key, Value: value}, true); err != nil {
+		return err
+	}
+	archive.records++
+	archive.counts[prefix]++
+	return nil
+}
+
+// Close writes the trailer and flushes the compressed stream. It does not
+// close the underlying writer.
+func (archive *Writer) Close() (Trailer, error) {
+	trailer := Trailer{
+		Records: archive.records,
+		Counts:  archive.counts,
+		SHA256:  hex.EncodeToString(archive.hash.Sum(nil)),
+	}
+	records := trailer.Records
+	if err := archive.writeLine(line{Records: &records, Counts: trailer.Counts, SHA256: trailer.SHA256}, false); err != nil {
+		return Trailer{}, err
+	}
+	return trailer, archive.gzip.Close()
+}
+
+func (archive *Writer) writeLine(l line, hashed bool) error {
+	encoded, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+	if hashed {
+		archive.hash.Write(encoded)
+	}
+	_, err = archive.gzip.Write(encoded)
+	return err
+}
+
+// ReadArchive checks an archive's version, record counts and checksum,
+// handing each record to fn on the way. fn may be nil to only verify.
+// Because the checksum is at the end, callers that must not act on a
+// corrupt archive should read it twice: once to verify, once to use.
+func ReadArchive(r io.Reader, fn func(Record) error) (Manifest, error) {
+	decompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer decompressed.Close()
+
+	reader := bufio.NewReader(decompressed)
+	digest := sha256.New()
+	var manifest Manifest
+	counts := map[string]int{}
+
+	for number := 1; ; number++ {
+		raw, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(raw) == 0 {
+			return Manifest{}, errors.New("archive ends without a trailer, it may be truncated")
+		}
+		if err != nil && err != io.EOF {
+			return Manifest{}, err
+		}
+
+		var l line
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return Manifest{}, fmt.Errorf("line %d of the archive: %w", number, err)
+		}
+
+		switch {
+		case number == 1:
+			if l.Format != Format {
+				return Manifest{}, fmt.Errorf("not a backup archive: format %q", l.Format)
+			}
+			if l.Version < 1 || l.Version > FormatVersion {
+				return Manifest{}, fmt.Errorf("archive version %d is not supported, this tool reads up to version %d", l.Version, FormatVersion)
+			}
+			manifest.Format = l.Format
+			manifest.Version = l.Version
+			if l.CreatedAt != nil {
+				manifest.CreatedAt = *l.CreatedAt
+			}
+			digest.Write(raw)
+
+		case l.Key != "":
+			prefix, _, _ := strings.Cut(l.Key, ":")
+			counts[prefix]++
+			manifest.Records++
+			digest.Write(raw)
+			if fn != nil {
+				if err := fn(Record{Key: l.Key, Value: l.Value}); err != nil {
+					return Manifest{}, err
+				}
+			}
+
+		case l.SHA256 != "":
+			if _, err := reader.ReadByte(); err != io.EOF {
+				return Manifest{}, errors.New("archive has data after its trailer")
+			}
+			if l.SHA256 != hex.EncodeToString(digest.Sum(nil)) {
+				return Manifest{}, ErrChecksum
+			}
+			if l.Records == nil {
+				return Manifest{}, errors.New("archive trailer has no record count")
+			}
+			if *l.Records != manifest.Records {
+				return Manifest{}, fmt.Errorf("archive trailer counts %d records but it holds %d", *l.Records, manifest.Records)
+			}
+			for prefix, count := range l.Counts {
+				if counts[prefix] != count {
+					return Manifest{}, fmt.Errorf("archive trailer counts %d %s records but it holds %d", count, prefix, counts[prefix])
+				}
+			}
+			manifest.Counts = l.Counts
+			manifest.SHA256 = l.SHA256
+			return manifest, nil
+
+		default:
+			return Manifest{}, fmt.Errorf("line %d of the archive is neither a record nor a trailer", number)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, records []Record) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	archive, err := NewWriter(&buffer, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := archive.Add(record.Key, record.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// rewrite decompresses an archive, edits its text and compresses it again
+func rewrite(t *testing.T, archive []byte, edit func(string) string) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Write([]byte(edit(string(text))))
+	writer.Close()
+	return buffer.Bytes()
+}
+
+var sampleRecords = []Record{
+	{Key: "poll:1", Value: json.RawMessage(`{"PollID":1,"PollTitle":"Pets","PollOptions":[{"PollOptionID":1,"PollOptionText":"Cat"}]}`)},
+	{Key: "voter:1", Value: json.RawMessage(`{"VoterID":1,"FirstName":"Ann","LastName":"Lee"}`)},
+	{Key: "vote:1", Value: json.RawMessage(`{"VoteID":1,"Voter":"http://voter-api:1081/voters/1","Poll":"http://poll-api:1082/polls/1","PollOption":"http://poll-api:1082/polls/1/polloption/1","VoteDate":"2026-10-19T12:00:00Z"}`)},
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	archive := writeArchive(t, sampleRecords)
+
+	var read []Record
+	manifest, err := ReadArchive(bytes.NewReader(archive), func(record Record) error {
+		read = append(read, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Version != FormatVersion || manifest.Records != 3 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+	for _, prefix := range []string{"poll", "voter", "vote"} {
+		if manifest.Counts[prefix] != 1 {
+			t.Errorf("expected one %s record, got %d", prefix, manifest.Counts[prefix])
+		}
+	}
+	if len(read) != len(sampleRecords) {
+		t.Fatalf("read %d records, wrote %d", len(read), len(sampleRecords))
+	}
+	for i, record := range read {
+		if record.Key != sampleRecords[i].Key || !bytes.Equal(record.Value, sampleRecords[i].Value) {
+			t.Errorf("record %d: got %s %s", i, record.Key, record.Value)
+		}
+	}
+}
+
+func TestArchiveRejectsTampering(t *testing.T) {
+	archive := writeArchive(t, sampleRecords)
+
+	tests := map[string]struct {
+		edit func(string) string
+		want string
+	}{
+		"changed record": {
+			edit: func(text string) string { return strings.Replace(text, `"Ann"`, `"Bob"`, 1) },
+			want: ErrChecksum.Error(),
+		},
+		"dropped record": {
+			edit: func(text string) string {
+				lines := strings.SplitAfter(text, "\n")
+				return strings.Join(append(lines[:2:2], lines[3:]...), "")
+			},
+			want: ErrChecksum.Error(),
+		},
+		"truncated": {
+			edit: func(text string) string {
+				lines := strings.SplitAfter(text, "\n")
+				return strings.Join(lines[:3], "")
+			},
+			want: "without a trailer",
+		},
+		"newer version": {
+			edit: func(text string) string { return strings.Replace(text, `"Version":1`, `"Version":99`, 1) },
+			want: "version 99 is not supported",
+		},
+		"data after trailer": {
+			edit: func(text string) string { return text + `{"Key":"vote:2"}` + "\n" },
+			want: "after its trailer",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadArchive(bytes.NewReader(rewrite(t, archive, test.edit)), nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestArchiveStopsOnCallbackError(t *testing.T) {
+	archive := writeArchive(t, sampleRecords)
+	stop := errors.New("stop")
+	_, err := ReadArchive(bytes.NewReader(archive), func(Record) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+}
+
+func TestLinkIDs(t *testing.T) {
+	ids, ok := linkIDs("http://poll-api:1082/polls/4/polloption/2", pollOptionPath)
+	if !ok || len(ids) != 2 || ids[0] != 4 || ids[1] != 2 {
+		t.Errorf("got %v %v", ids, ok)
+	}
+	if _, ok := linkIDs("http://poll-api:1082/polls/4", voterPath); ok {
+		t.Error("a poll link resolved as a voter link")
+	}
+	if _, ok := linkIDs("http://voter-api:1081/voters/x", voterPath); ok {
+		t.Error("a link without a numeric id resolved")
+	}
+}
@@ -0,0 +1,272 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"shared/redisclient"
+	"votes-api/db"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// How many keys go into one SCAN or pipeline
+const batchSize = 500
+
+var ErrNotEmpty = errors.New("target redis already holds polls, voters or votes")
+
+// Dump writes every poll, voter and vote key to w as an archive
+func Dump(ctx context.Context, client redis.UniversalClient, w io.Writer) (Manifest, error) {
+	createdAt := time.Now().UTC()
+	archive, err := NewWriter(w, createdAt)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	for _, prefix := range Prefixes {
+		err := scanDocuments(ctx, client, prefix, func(key string, document json.RawMessage) error {
+			return archive.Add(key, document)
+		})
+		if err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	trailer, err := archive.Close()
+	if err != nil {
+		return Manifest{}, err
+	}
+	return Manifest{Header: Header{Format: Format, Version: FormatVersion, CreatedAt: createdAt}, Trailer: trailer}, nil
+}
+
+// Restore verifies the archive in full and then writes its records into
+// client, which must not hold any polls, voters or votes yet
+func Restore(ctx context.Context, client redis.UniversalClient, archive io.ReadSeeker) (Manifest, error) {
+	for _, prefix := range Prefixes {
+		err := redisclient.ForEachNode(ctx, client, func(ctx context.Context, node redis.Cmdable) error {
+			// A SCAN page can come back empty before the cursor is done
+			var cursor uint64
+			for {
+				keys, next, err := node.Scan(ctx, cursor, prefix+"*", batchSize).Result()
+				if err != nil {
+					return err
+				}
+				if len(keys) > 0 {
+					return ErrNotEmpty
+				}
+				if next == 0 {
+					return nil
+				}
+				cursor = next
+			}
+		})
+		if err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	if _, err := ReadArchive(archive, nil); err != nil {
+		return Manifest{}, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return Manifest{}, err
+	}
+
+	var batch []Record
+	manifest, err := ReadArchive(archive, func(record Record) error {
+		batch = append(batch, record)
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := setDocuments(ctx, client, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := setDocuments(ctx, client, batch); err != nil {
+		return Manifest{}, err
+	}
+	// The vote api's analytics and timeline do not know the restored votes yet
+	if err := db.DropRedisAnalytics(ctx, client); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, db.ResetTimeline(ctx, client)
+}
+
+// Problem is a vote whose links do not resolve
+type Problem struct {
+	Key     string
+	Link    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s %s", p.Key, p.Link, p.Message)
+}
+
+var (
+	voterPath      = regexp.MustCompile(`^/voters/(\d+)$`)
+	pollPath       = regexp.MustCompile(`^/polls/(\d+)$`)
+	pollOptionPath = regexp.MustCompile(`^/polls/(\d+)/polloption/(\d+)$`)
+)
+
+// Check resolves the Voter, Poll and PollOption links of every vote
+// against the records in client, the way the vote api follows them for
+// ?detail=true. Hosts are not compared because they name the services,
+// not the data.
+func Check(ctx context.Context, client redis.UniversalClient) ([]Problem, error) {
+	voters := map[uint]bool{}
+	err := scanDocuments(ctx, client, "voter:", func(key string, document json.RawMessage) error {
+		var voter struct{ VoterID uint }
+		if err := json.Unmarshal(document, &voter); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		voters[voter.VoterID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pollOptions := map[uint]map[uint]bool{}
+	err = scanDocuments(ctx, client, "poll:", func(key string, document json.RawMessage) error {
+		var poll struct {
+			PollID      uint
+			PollOptions []struct{ PollOptionID uint }
+		}
+		if err := json.Unmarshal(document, &poll); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		pollOptions[poll.PollID] = map[uint]bool{}
+		for _, pollOption := range poll.PollOptions {
+			pollOptions[poll.PollID][pollOption.PollOptionID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []Problem{}
+	err = scanDocuments(ctx, client, "vote:", func(key string, document json.RawMessage) error {
+		var vote db.Vote
+		if err := json.Unmarshal(document, &vote); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if ids, ok := linkIDs(vote.Voter, voterPath); !ok {
+			problems = append(problems, Problem{key, vote.Voter, "is not a voter link"})
+		} else if !voters[ids[0]] {
+			problems = append(problems, Problem{key, vote.Voter, "names a voter that does not exist"})
+		}
+
+		pollIDs, pollOK := linkIDs(vote.Poll, pollPath)
+		if !pollOK {
+			problems = append(problems, Problem{key, vote.Poll, "is not a poll link"})
+		} else if pollOptions[pollIDs[0]] == nil {
+			problems = append(problems, Problem{key, vote.Poll, "names a poll that does not exist"})
+		}
+
+		ids, ok := linkIDs(vote.PollOption, pollOptionPath)
+		switch {
+		case !ok:
+			problems = append(problems, Problem{key, vote.PollOption, "is not a poll option link"})
+		case pollOK && ids[0] != pollIDs[0]:
+			problems = append(problems, Problem{key, vote.PollOption, "belongs to a different poll than the vote's Poll link"})
+		case pollOptions[ids[0]] == nil:
+			problems = append(problems, Problem{key, vote.PollOption, "names a poll that does not exist"})
+		case !pollOptions[ids[0]][ids[1]]:
+			problems = append(problems, Problem{key, vote.PollOption, "names an option the poll does not have"})
+		}
+		return nil
+	})
+	return problems, err
+}
+
+func linkIDs(link string, path *regexp.Regexp) ([]uint, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil, false
+	}
+	match := path.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return nil, false
+	}
+	ids := make([]uint, 0, len(match)-1)
+	for _, group := range match[1:] {
+		id, err := strconv.ParseUint(group, 10, 0)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true
+}
+
+// scanDocuments reads every JSON document under prefix with SCAN, on each
+// master of a cluster, and pipelined JSON.GETs
+func scanDocuments(ctx context.Context, client redis.UniversalClient, prefix string, fn func(string, json.RawMessage) error) error {
+	return redisclient.ForEachNode(ctx, client, func(ctx context.Context, node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, prefix+"*", batchSize).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				commands := make([]*redis.Cmd, len(keys))
+				_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+					for i, key := range keys {
+						commands[i] = pipe.Do(ctx, "JSON.GET", key, ".")
+					}
+					return nil
+				})
+				if err != nil && !errors.Is(err, redis.Nil) {
+					return err
+				}
+
+				for i, command := range commands {
+					document, err := command.Text()
+					if errors.Is(err, redis.Nil) {
+						// Deleted since the SCAN
+						continue
+					}
+					if err != nil {
+						return fmt.Errorf("%s: %w", keys[i], err)
+					}
+					if err := fn(keys[i], json.RawMessage(document)); err != nil {
+						return err
+					}
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	})
+}
+
+func setDocuments(ctx context.Context, client redis.UniversalClient, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, record := range records {
+			pipe.Do(ctx, "JSON.SET", record.Key, ".", string(record.Value))
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,214 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// startRedis runs a throwaway redis-server for one test. The RedisJSON
+// module is loaded from REDISJSON_MODULE unless the server has it built
+// in, as redis-stack-server does. The test is skipped when neither is
+// available.
+func startRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	binary := ""
+	for _, name := range []string{"redis-stack-server", "redis-server"} {
+		if path, err := exec.LookPath(name); err == nil {
+			binary = path
+			break
+		}
+	}
+	if binary == "" {
+		t.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	args := []string{"--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir()}
+	if module := os.Getenv("REDISJSON_MODULE"); module != "" {
+		args = append(args, "--loadmodule", module)
+	}
+	server := exec.Command(binary, args...)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	t.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(ctx).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := client.Do(ctx, "JSON.SET", "probe", ".", "{}").Err(); err != nil {
+		t.Skip("redis-server has no RedisJSON, set REDISJSON_MODULE to the module's path: ", err)
+	}
+	client.Del(ctx, "probe")
+	return client
+}
+
+func seed(t *testing.T, client *redis.Client, records []Record) {
+	t.Helper()
+	if err := setDocuments(context.Background(), client, records); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func documents(t *testing.T, client *redis.Client) map[string]string {
+	t.Helper()
+	found := map[string]string{}
+	for _, prefix := range Prefixes {
+		err := scanDocuments(context.Background(), client, prefix, func(key string, document json.RawMessage) error {
+			found[key] = string(document)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return found
+}
+
+func TestDumpAndRestoreRoundTrip(t *testing.T) {
+	source := startRedis(t)
+	target := startRedis(t)
+	ctx := context.Background()
+
+	// Enough records to span several SCAN batches and pipelines
+	records := append([]Record{}, sampleRecords...)
+	for i := 2; i <= 1200; i++ {
+		records = append(records,
+			Record{Key: fmt.Sprintf("voter:%d", i), Value: []byte(fmt.Sprintf(`{"VoterID":%d,"FirstName":"Voter","LastName":""}`, i))},
+			Record{Key: fmt.Sprintf("vote:%d", i), Value: []byte(fmt.Sprintf(
+				`{"VoteID":%d,"Voter":"http://voter-api:1081/voters/%d","Poll":"http://poll-api:1082/polls/1","PollOption":"http://poll-api:1082/polls/1/polloption/1","VoteDate":"2026-10-19T12:00:00Z"}`, i, i))})
+	}
+	seed(t, source, records)
+	// Keys of other kinds are not part of a backup
+	source.Set(ctx, "ratelimit:write:admin", 3, 0)
+
+	var archive bytes.Buffer
+	dumped, err := Dump(ctx, source, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dumped.Records != len(records) {
+		t.Errorf("dumped %d records, expected %d", dumped.Records, len(records))
+	}
+
+	restored, err := Restore(ctx, target, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.SHA256 != dumped.SHA256 || restored.Records != dumped.Records {
+		t.Errorf("restored manifest %+v differs from dumped %+v", restored.Trailer, dumped.Trailer)
+	}
+
+	want, got := documents(t, source), documents(t, target)
+	if len(got) != len(want) {
+		t.Errorf("restored %d documents, expected %d", len(got), len(want))
+	}
+	for key, document := range want {
+		if got[key] != document {
+			t.Errorf("%s: restored %s, expected %s", key, got[key], document)
+		}
+	}
+	if target.Exists(ctx, "ratelimit:write:admin").Val() != 0 {
+		t.Error("a rate limit key was restored")
+	}
+
+	problems, err := Check(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("expected every vote link to resolve, got %v", problems)
+	}
+}
+
+func TestRestoreRefusesNonEmptyRedis(t *testing.T) {
+	client := startRedis(t)
+	seed(t, client, sampleRecords[:1])
+
+	// Enough other keys that the first SCAN pages find no records
+	pipe := client.Pipeline()
+	for i := 0; i < 20*batchSize; i++ {
+		pipe.Set(context.Background(), fmt.Sprintf("ratelimit:write:%d", i), 1, 0)
+	}
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := writeArchive(t, sampleRecords)
+	_, err := Restore(context.Background(), client, bytes.NewReader(archive))
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+}
+
+func TestRestoreWritesNothingFromACorruptArchive(t *testing.T) {
+	client := startRedis(t)
+
+	archive := rewrite(t, writeArchive(t, sampleRecords), func(text string) string {
+		return strings.Replace(text, `"Ann"`, `"Bob"`, 1)
+	})
+	if _, err := Restore(context.Background(), client, bytes.NewReader(archive)); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+	if keys := client.Keys(context.Background(), "*").Val(); len(keys) > 0 {
+		t.Errorf("corrupt archive wrote %v", keys)
+	}
+}
+
+func TestCheckReportsDanglingLinks(t *testing.T) {
+	client := startRedis(t)
+	seed(t, client, append(sampleRecords[:2:2],
+		Record{Key: "vote:1", Value: []byte(`{"VoteID":1,"Voter":"http://voter-api:1081/voters/9","Poll":"http://poll-api:1082/polls/1","PollOption":"http://poll-api:1082/polls/1/polloption/1","VoteDate":"2026-10-19T12:00:00Z"}`)},
+		Record{Key: "vote:2", Value: []byte(`{"VoteID":2,"Voter":"http://voter-api:1081/voters/1","Poll":"http://poll-api:1082/polls/1","PollOption":"http://poll-api:1082/polls/1/polloption/7","VoteDate":"2026-10-19T12:00:00Z"}`)},
+		Record{Key: "vote:3", Value: []byte(`{"VoteID":3,"Voter":"http://voter-api:1081/voters/1","Poll":"http://poll-api:1082/polls/2","PollOption":"http://poll-api:1082/polls/1/polloption/1","VoteDate":"2026-10-19T12:00:00Z"}`)},
+	))
+
+	problems, err := Check(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.Key+" "+problem.Message)
+	}
+	sort.Strings(got)
+	want := []string{
+		"vote:1 names a voter that does not exist",
+		"vote:2 names an option the poll does not have",
+		"vote:3 belongs to a different poll than the vote's Poll link",
+		"vote:3 names a poll that does not exist",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
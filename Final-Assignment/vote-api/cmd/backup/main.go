@@ -0,0 +1,58 @@
+// Command backup dumps every poll, voter and vote in the shared Redis to a
+// versioned, checksummed archive. See the backup package for the format.
+//
+//	go run ./cmd/backup -redis localhost:6379 -o backup.ndjson.gz
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"shared/redisclient"
+	"votes-api/backup"
+	"votes-api/config"
+)
+
+func main() {
+	outputFlag := flag.String("o", "backup-"+time.Now().UTC().Format("20060102T150405Z")+".ndjson.gz", "Archive to write, - for stdout")
+	// Redis is found the same way as by the vote api, config file included
+	settings, _, err := config.Parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	client, err := redisclient.Connect(settings.Redis)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	var output io.Writer = os.Stdout
+	if *outputFlag != "-" {
+		file, err := os.Create(*outputFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	manifest, err := backup.Dump(context.Background(), client, output)
+	if err != nil {
+		fmt.Println(err)
+		if *outputFlag != "-" {
+			os.Remove(*outputFlag)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Backed up %d records (%d polls, %d voters, %d votes) to %s, sha256 %s\n",
+		manifest.Records, manifest.Counts["poll"], manifest.Counts["voter"], manifest.Counts["vote"], *outputFlag, manifest.SHA256)
+}
@@ -0,0 +1,213 @@
+// Command loadgen measures how many votes per second the vote api takes.
+// It creates a poll and one voter per vote, then casts the votes at a
+// fixed rate, or as fast as the workers can, and reports throughput,
+// errors and latency percentiles.
+//
+//	go run ./cmd/loadgen -n 10000 -c 50 -rate 500
+//
+// Each voter votes once, as the postgres backend requires. Give each run
+// against the same store a fresh -first-id, or -skip-setup to reuse the
+// poll and voters of an earlier run with the same -first-id and -n.
+// Rate limits of the vote api count toward the errors; run the services
+// with RATE_LIMIT_CAST_VOTE=off and RATE_LIMIT_WRITE=off to measure
+// without them.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyHeader      = "X-API-Key"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+type settings struct {
+	votesURL, votersURL, pollsURL string
+	adminKey, managerKey          string
+	pollID, firstID               uint
+	options                       uint
+	votes                         int
+	concurrency                   int
+	rate                          float64
+	timeout                       time.Duration
+	skipSetup                     bool
+}
+
+func main() {
+	var s settings
+	flag.StringVar(&s.votesURL, "votes", "http://localhost:1080", "Base URL of the vote api")
+	flag.StringVar(&s.votersURL, "voters", "http://localhost:1081", "Base URL of the voter api")
+	flag.StringVar(&s.pollsURL, "polls", "http://localhost:1082", "Base URL of the poll api")
+	flag.StringVar(&s.adminKey, "admin-key", "admin-key", "API key with the admin role, to create voters and cast their votes")
+	flag.StringVar(&s.managerKey, "manager-key", "manager-key", "API key with the poll-manager role, to create the poll")
+	flag.UintVar(&s.pollID, "poll", 1, "ID of the poll to vote on")
+	flag.UintVar(&s.options, "options", 4, "Options of the poll, votes are spread evenly across them")
+	flag.UintVar(&s.firstID, "first-id", 1, "ID of the first voter and vote, the rest follow")
+	flag.IntVar(&s.votes, "n", 1000, "Votes to cast")
+	flag.IntVar(&s.concurrency, "c", 10, "Votes in flight at once")
+	flag.Float64Var(&s.rate, "rate", 0, "Votes per second to start, 0 for as fast as the workers go")
+	flag.DurationVar(&s.timeout, "timeout", 10*time.Second, "Timeout of each request")
+	flag.BoolVar(&s.skipSetup, "skip-setup", false, "Do not create the poll and voters")
+	flag.Parse()
+
+	if s.votes < 1 || s.concurrency < 1 || s.options < 1 || s.rate < 0 {
+		fmt.Println("Error: -n, -c and -options must be positive and -rate not negative")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := &http.Client{
+		Timeout:   s.timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: s.concurrency},
+	}
+
+	if !s.skipSetup {
+		if err := setup(ctx, client, s); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created poll %d with %d options and voters %d to %d\n",
+			s.pollID, s.options, s.firstID, s.firstID+uint(s.votes)-1)
+	}
+
+	report := castVotes(ctx, client, s)
+	report.Print(os.Stdout)
+	if report.Failed() > 0 {
+		os.Exit(2)
+	}
+}
+
+// setup creates the poll with its options and imports a voter per vote
+func setup(ctx context.Context, client *http.Client, s settings) error {
+	poll := map[string]interface{}{
+		"PollID":       s.pollID,
+		"PollTitle":    "Load test",
+		"PollQuestion": "Which option?",
+	}
+	pollURL := fmt.Sprintf("%s/polls/%d", s.pollsURL, s.pollID)
+	if err := send(ctx, client, s.managerKey, pollURL, "application/json", poll); err != nil {
+		return fmt.Errorf("Error creating poll: %w", err)
+	}
+	for option := uint(1); option <= s.options; option++ {
+		pollOption := map[string]interface{}{
+			"PollOptionID":   option,
+			"PollOptionText": fmt.Sprintf("Option %d", option),
+		}
+		if err := send(ctx, client, s.managerKey, fmt.Sprintf("%s/polloption/%d", pollURL, option), "application/json", pollOption); err != nil {
+			return fmt.Errorf("Error creating poll option: %w", err)
+		}
+	}
+
+	var voters bytes.Buffer
+	encoder := json.NewEncoder(&voters)
+	for i := 0; i < s.votes; i++ {
+		id := s.firstID + uint(i)
+		encoder.Encode(map[string]interface{}{"VoterID": id, "FirstName": "Voter", "LastName": fmt.Sprint(id)})
+	}
+	importURL := s.votersURL + "/voters:import?mode=upsert&async=false"
+	if err := send(ctx, client, s.adminKey, importURL, ndjsonContentType, voters.Bytes()); err != nil {
+		return fmt.Errorf("Error importing voters: %w", err)
+	}
+	return nil
+}
+
+// send POSTs body, JSON encoded unless it is already bytes, and fails
+// unless the answer is 2xx
+func send(ctx context.Context, client *http.Client, apiKey string, url string, contentType string, body interface{}) error {
+	data, ok := body.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(apiKeyHeader, apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", url, resp.Status, bytes.TrimSpace(message))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// castVotes casts s.votes votes from s.concurrency workers. With a rate
+// votes start no faster than it; when every worker is busy the next vote
+// waits for one, so an api that cannot keep up shows as a lower rate.
+func castVotes(ctx context.Context, client *http.Client, s settings) *Report {
+	report := NewReport()
+	jobs := make(chan int)
+	var workers sync.WaitGroup
+	for w := 0; w < s.concurrency; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				id := s.firstID + uint(i)
+				vote := map[string]interface{}{
+					"VoteID":       id,
+					"VoterID":      id,
+					"PollID":       s.pollID,
+					"PollOptionID": uint(i)%s.options + 1,
+				}
+				start := time.Now()
+				err := send(ctx, client, s.adminKey, fmt.Sprintf("%s/votes/%d", s.votesURL, id), "application/json", vote)
+				report.Add(time.Since(start), err)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if s.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / s.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	report.Start()
+dispatch:
+	for i := 0; i < s.votes; i++ {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	workers.Wait()
+	report.Stop()
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		fmt.Println("Interrupted, reporting the votes cast so far")
+	}
+	return report
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report collects the outcome and latency of every vote
+type Report struct {
+	lock      sync.Mutex
+	latencies []time.Duration
+	errors    map[string]int
+	started   time.Time
+	elapsed   time.Duration
+}
+
+func NewReport() *Report {
+	return &Report{errors: map[string]int{}}
+}
+
+func (r *Report) Start() {
+	r.started = time.Now()
+}
+
+func (r *Report) Stop() {
+	r.elapsed = time.Since(r.started)
+}
+
+// Add records one vote. Failed votes count toward the latency too, since a
+// client waits for them just the same.
+func (r *Report) Add(latency time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.latencies = append(r.latencies, latency)
+	if err != nil {
+		r.errors[errorKind(err)]++
+	}
+}
+
+func (r *Report) Failed() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	failed := 0
+	for _, count := range r.errors {
+		failed += count
+	}
+	return failed
+}
+
+// errorKind groups errors by status, or by the error itself when there was
+// no answer
+func errorKind(err error) string {
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return urlError.Err.Error()
+	}
+	message := err.Error()
+	if _, status, ok := strings.Cut(message, " answered "); ok {
+		status, _, _ = strings.Cut(status, ":")
+		return status
+	}
+	return message
+}
+
+// percentile returns the latency that p percent of sorted are at or below
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(p/100*float64(len(sorted))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+func (r *Report) Print(w io.Writer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	total := len(r.latencies)
+	failed := 0
+	for _, count := range r.errors {
+		failed += count
+	}
+	seconds := r.elapsed.Seconds()
+	if seconds == 0 {
+		seconds = 1
+	}
+	fmt.Fprintf(w, "Sent %d votes in %s: %.1f votes/s\n", total, r.elapsed.Round(time.Millisecond), float64(total)/seconds)
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Succeeded %d (%.1f votes/s), failed %d (%.2f%%)\n",
+		total-failed, float64(total-failed)/seconds, failed, float64(failed)*100/float64(total))
+
+	kinds := make([]string, 0, len(r.errors))
+	for kind := range r.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return r.errors[kinds[i]] > r.errors[kinds[j]] })
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "  %6d  %s\n", r.errors[kind], kind)
+	}
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	fmt.Fprintf(w, "Latency min %s, p50 %s, p90 %s, p95 %s, p99 %s, max %s\n",
+		round(sorted[0]), round(percentile(sorted, 50)), round(percentile(sorted, 90)),
+		round(percentile(sorted, 95)), round(percentile(sorted, 99)), round(sorted[len(sorted)-1]))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
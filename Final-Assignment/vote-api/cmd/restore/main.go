@@ -0,0 +1,94 @@
+// Command restore loads an archive written by cmd/backup into a Redis
+// that holds no polls, voters or votes, then checks that every vote's
+// links resolve to the restored records.
+//
+//	go run ./cmd/restore -redis localhost:6379 -i backup.ndjson.gz
+//	go run ./cmd/restore -check-only -redis localhost:6379
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"shared/redisclient"
+	"votes-api/backup"
+	"votes-api/config"
+)
+
+func main() {
+	inputFlag := flag.String("i", "", "Archive to restore")
+	verifyOnlyFlag := flag.Bool("verify-only", false, "Only verify the archive's version and checksum")
+	checkOnlyFlag := flag.Bool("check-only", false, "Only check that the votes already in Redis resolve")
+	// Redis is found the same way as by the vote api, config file included
+	settings, _, err := config.Parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	if *verifyOnlyFlag {
+		manifest, err := verify(*inputFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Archive version %d from %s holds %d records, checksum ok\n",
+			manifest.Version, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), manifest.Records)
+		return
+	}
+
+	client, err := redisclient.Connect(settings.Redis)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	if !*checkOnlyFlag {
+		if *inputFlag == "" {
+			fmt.Println("Error: -i is required")
+			os.Exit(1)
+		}
+		file, err := os.Open(*inputFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		manifest, err := backup.Restore(ctx, client, file)
+		file.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %d records (%d polls, %d voters, %d votes) from a backup taken %s\n",
+			manifest.Records, manifest.Counts["poll"], manifest.Counts["voter"], manifest.Counts["vote"],
+			manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	problems, err := backup.Check(ctx, client)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d vote links do not resolve\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Println("Every vote link resolves")
+}
+
+func verify(path string) (backup.Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return backup.Manifest{}, err
+	}
+	defer file.Close()
+	return backup.ReadArchive(file, nil)
+}
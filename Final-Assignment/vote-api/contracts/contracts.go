@@ -0,0 +1,135 @@
+// Package contracts holds the consumer-driven contracts the vote api has
+// with the voter and poll apis: each request it sends them and the part of
+// the response it reads. The providers replay the requests against their
+// own routers in their contract tests, and Stub answers them in the vote
+// api's tests in place of the real services.
+//
+// A provider meets an interaction when it answers with the status and a
+// body holding every field of the contract's body with an equal value.
+// Fields the contract does not name are free to change; arrays must have
+// the same length.
+package contracts
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+//go:embed *.json
+var files embed.FS
+
+type Contract struct {
+	Consumer     string
+	Provider     string
+	Interactions []Interaction
+}
+
+type Interaction struct {
+	Description string
+	// State names the data the provider must hold before the request
+	State    string
+	Request  Request
+	Response Response
+}
+
+type Request struct {
+	Method  string
+	Path    string
+	Headers map[string]string
+}
+
+type Response struct {
+	Status int
+	Body   json.RawMessage
+}
+
+// Matches reports whether r is this request, with at least its headers
+func (request Request) Matches(r *http.Request) bool {
+	if request.Method != r.Method || request.Path != r.URL.Path {
+		return false
+	}
+	for name, value := range request.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads the contract with provider, "voter-api" or "poll-api"
+func Load(provider string) (Contract, error) {
+	data, err := files.ReadFile(provider + ".json")
+	if err != nil {
+		return Contract{}, fmt.Errorf("Error: no contract with %s: %w", provider, err)
+	}
+	var contract Contract
+	if err := json.Unmarshal(data, &contract); err != nil {
+		return Contract{}, fmt.Errorf("Error reading contract with %s: %w", provider, err)
+	}
+	return contract, nil
+}
+
+// Stub answers the requests of a contract with its responses, and 404 to
+// anything else, including a request without the headers it names. It
+// keeps the headers of the last request and which interactions were used.
+type Stub struct {
+	contract Contract
+	lock     sync.Mutex
+	used     map[string]bool
+	header   http.Header
+}
+
+func NewStub(contract Contract) *Stub {
+	return &Stub{contract: contract, used: map[string]bool{}}
+}
+
+func (s *Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.header = r.Header.Clone()
+	s.lock.Unlock()
+
+	for _, interaction := range s.contract.Interactions {
+		if !interaction.Request.Matches(r) {
+			continue
+		}
+		s.lock.Lock()
+		s.used[interaction.Description] = true
+		s.lock.Unlock()
+
+		if len(interaction.Response.Body) == 0 {
+			w.WriteHeader(interaction.Response.Status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(interaction.Response.Status)
+		w.Write(interaction.Response.Body)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// LastHeader returns the headers of the last request served
+func (s *Stub) LastHeader() http.Header {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.header
+}
+
+// Unused lists the interactions no request has matched
+func (s *Stub) Unused() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var unused []string
+	for _, interaction := range s.contract.Interactions {
+		if !s.used[interaction.Description] {
+			unused = append(unused, interaction.Description)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
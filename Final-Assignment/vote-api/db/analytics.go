@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Turnout intervals, picked with ?interval=
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// The stores keep turnout in hourly buckets, which the day interval adds up
+const bucketSize = time.Hour
+
+// TurnoutBucket counts the votes cast in one hour or day of a poll. A voter
+// is new in the bucket their first vote in the poll fell in.
+type TurnoutBucket struct {
+	Start     time.Time
+	Votes     uint
+	NewVoters uint
+	// Voters who had voted by the end of the bucket, and their share of
+	// the registered voters from 0 to 1
+	Voters  uint
+	Turnout float64
+}
+
+// PollTurnout is how a poll's votes came in over time
+type PollTurnout struct {
+	PollID           uint
+	Interval         string
+	Buckets          []TurnoutBucket
+	TotalVotes       uint
+	Voters           uint
+	RegisteredVoters uint
+	Turnout          float64
+	GeneratedAt      time.Time
+}
+
+// PollParticipation is one poll a voter voted in
+type PollParticipation struct {
+	PollID        uint
+	VoteIDs       []uint
+	FirstVoteDate time.Time
+	LastVoteDate  time.Time
+}
+
+// VoterParticipation lists the polls a voter voted in, in the order they
+// first voted in them
+type VoterParticipation struct {
+	VoterID     uint
+	Polls       []PollParticipation
+	TotalVotes  uint
+	GeneratedAt time.Time
+}
+
+// participationVote is a vote as the participation aggregates keep it
+type participationVote struct {
+	VoteID   uint
+	VoteDate time.Time
+}
+
+// voterPolls is the aggregate kept for each voter: their votes by poll
+type voterPolls map[uint][]participationVote
+
+// turnoutChange is what one vote write does to an hour of a poll
+type turnoutChange struct {
+	PollID    uint
+	Hour      time.Time
+	Votes     int
+	NewVoters int
+}
+
+func bucketStart(date time.Time) time.Time {
+	return date.UTC().Truncate(bucketSize)
+}
+
+// firstVote is when the voter first voted in the poll, zero if they did not
+func (polls voterPolls) firstVote(pollID uint) time.Time {
+	var first time.Time
+	for _, vote := range polls[pollID] {
+		if first.IsZero() || vote.VoteDate.Before(first) {
+			first = vote.VoteDate
+		}
+	}
+	return first
+}
+
+// add records a vote and returns what it changes in the poll's turnout
+func (polls voterPolls) add(pollID uint, vote participationVote) []turnoutChange {
+	firstBefore := polls.firstVote(pollID)
+	polls[pollID] = append(polls[pollID], vote)
+	changes := []turnoutChange{{PollID: pollID, Hour: bucketStart(vote.VoteDate), Votes: 1}}
+	return append(changes, newVoterChanges(pollID, firstBefore, polls.firstVote(pollID))...)
+}
+
+// remove forgets a vote and returns what it changes in the poll's turnout,
+// nothing if the vote was not recorded
+func (polls voterPolls) remove(pollID uint, voteID uint) []turnoutChange {
+	firstBefore := polls.firstVote(pollID)
+	votes := polls[pollID]
+	for i, vote := range votes {
+		if vote.VoteID != voteID {
+			continue
+		}
+		votes = append(votes[:i:i], votes[i+1:]...)
+		if len(votes) == 0 {
+			delete(polls, pollID)
+		} else {
+			polls[pollID] = votes
+		}
+		changes := []turnoutChange{{PollID: pollID, Hour: bucketStart(vote.VoteDate), Votes: -1}}
+		return append(changes, newVoterChanges(pollID, firstBefore, polls.firstVote(pollID))...)
+	}
+	return nil
+}
+
+// newVoterChanges moves the voter between the buckets of their first vote
+// before and after a write
+func newVoterChanges(pollID uint, before time.Time, after time.Time) []turnoutChange {
+	if !before.IsZero() && !after.IsZero() && bucketStart(before).Equal(bucketStart(after)) {
+		return nil
+	}
+	var changes []turnoutChange
+	if !before.IsZero() {
+		changes = append(changes, turnoutChange{PollID: pollID, Hour: bucketStart(before), NewVoters: -1})
+	}
+	if !after.IsZero() {
+		changes = append(changes, turnoutChange{PollID: pollID, Hour: bucketStart(after), NewVoters: 1})
+	}
+	return changes
+}
+
+// applyVoteWrite updates the participation of the voters of a vote going
+// from before to after, either of which is nil when the vote is added or
+// deleted. A vote already recorded as after is replaced, so applying a
+// write twice changes nothing. participation must hold those voters'
+// aggregates; missing ones are created and emptied ones deleted. It returns the net turnout changes
+// of the write, one per hour.
+func applyVoteWrite(participation map[uint]voterPolls, before *Vote, after *Vote) ([]turnoutChange, error) {
+	var changes []turnoutChange
+	for _, vote := range []*Vote{before, after} {
+		if vote == nil {
+			continue
+		}
+		keys, err := VoteKeysFromVote(*vote)
+		if err != nil {
+			return nil, err
+		}
+		if polls, ok := participation[keys.VoterID]; ok {
+			changes = append(changes, polls.remove(keys.PollID, keys.VoteID)...)
+			if len(polls) == 0 {
+				delete(participation, keys.VoterID)
+			}
+		}
+	}
+	if after != nil {
+		keys, err := VoteKeysFromVote(*after)
+		if err != nil {
+			return nil, err
+		}
+		if participation[keys.VoterID] == nil {
+			participation[keys.VoterID] = voterPolls{}
+		}
+		changes = append(changes, participation[keys.VoterID].add(keys.PollID,
+			participationVote{VoteID: keys.VoteID, VoteDate: after.VoteDate})...)
+	}
+	return netChanges(changes), nil
+}
+
+// netChanges adds up the changes to each hour and drops those that cancel
+// out, so that an hour is never seen emptier than it ends up
+func netChanges(changes []turnoutChange) []turnoutChange {
+	var net []turnoutChange
+	for _, change := range changes {
+		merged := false
+		for i := range net {
+			if net[i].PollID == change.PollID && net[i].Hour.Equal(change.Hour) {
+				net[i].Votes += change.Votes
+				net[i].NewVoters += change.NewVoters
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			net = append(net, change)
+		}
+	}
+
+	kept := net[:0]
+	for _, change := range net {
+		if change.Votes != 0 || change.NewVoters != 0 {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// votersOf are the voters whose aggregates a write from before to after
+// touches
+func votersOf(before *Vote, after *Vote) ([]uint, error) {
+	var voterIDs []uint
+	for _, vote := range []*Vote{before, after} {
+		if vote == nil {
+			continue
+		}
+		keys, err := VoteKeysFromVote(*vote)
+		if err != nil {
+			return nil, err
+		}
+		if len(voterIDs) == 0 || voterIDs[0] != keys.VoterID {
+			voterIDs = append(voterIDs, keys.VoterID)
+		}
+	}
+	return voterIDs, nil
+}
+
+func sortBuckets(buckets []TurnoutBucket) {
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+}
+
+// participationOf lists the polls in a voter's aggregate
+func participationOf(polls voterPolls) []PollParticipation {
+	participation := []PollParticipation{}
+	for pollID, votes := range polls {
+		poll := PollParticipation{PollID: pollID}
+		for _, vote := range votes {
+			poll.VoteIDs = append(poll.VoteIDs, vote.VoteID)
+			if poll.FirstVoteDate.IsZero() || vote.VoteDate.Before(poll.FirstVoteDate) {
+				poll.FirstVoteDate = vote.VoteDate
+			}
+			if vote.VoteDate.After(poll.LastVoteDate) {
+				poll.LastVoteDate = vote.VoteDate
+			}
+		}
+		sort.Slice(poll.VoteIDs, func(i, j int) bool { return poll.VoteIDs[i] < poll.VoteIDs[j] })
+		participation = append(participation, poll)
+	}
+	sort.Slice(participation, func(i, j int) bool {
+		if participation[i].FirstVoteDate.Equal(participation[j].FirstVoteDate) {
+			return participation[i].PollID < participation[j].PollID
+		}
+		return participation[i].FirstVoteDate.Before(participation[j].FirstVoteDate)
+	})
+	return participation
+}
+
+// GetPollTurnout buckets a poll's votes by interval and relates the voters
+// to the voter roll, which is counted with the caller's credentials,
+// forwarded in header
+func (v *VoteData) GetPollTurnout(ctx context.Context, pollID uint, interval string, header http.Header) (PollTurnout, error) {
+	if interval == "" {
+		interval = IntervalHour
+	}
+	if interval != IntervalHour && interval != IntervalDay {
+		return PollTurnout{}, errors.New("Error: interval must be hour or day, got " + interval)
+	}
+
+	if _, err := v.details.GetPoll(ctx, pollID, header); err != nil {
+		return PollTurnout{}, errors.New("Error: could not get poll details: " + err.Error())
+	}
+	registeredVoters, err := v.details.CountVoters(ctx, header)
+	if err != nil {
+		return PollTurnout{}, errors.New("Error: could not count voters: " + err.Error())
+	}
+
+	hours, err := v.GetTurnout(ctx, pollID)
+	if err != nil {
+		return PollTurnout{}, err
+	}
+
+	turnout := PollTurnout{
+		PollID:           pollID,
+		Interval:         interval,
+		Buckets:          []TurnoutBucket{},
+		RegisteredVoters: registeredVoters,
+		GeneratedAt:      time.Now().UTC(),
+	}
+	for _, hour := range hours {
+		start := hour.Start
+		if interval == IntervalDay {
+			start = start.Truncate(24 * time.Hour)
+		}
+		last := len(turnout.Buckets) - 1
+		if last < 0 || !turnout.Buckets[last].Start.Equal(start) {
+			turnout.Buckets = append(turnout.Buckets, TurnoutBucket{Start: start})
+			last++
+		}
+		turnout.Buckets[last].Votes += hour.Votes
+		turnout.Buckets[last].NewVoters += hour.NewVoters
+	}
+	for i := range turnout.Buckets {
+		bucket := &turnout.Buckets[i]
+		turnout.TotalVotes += bucket.Votes
+		turnout.Voters += bucket.NewVoters
+		bucket.Voters = turnout.Voters
+		bucket.Turnout = share(bucket.Voters, registeredVoters)
+	}
+	turnout.Turnout = share(turnout.Voters, registeredVoters)
+	return turnout, nil
+}
+
+// GetVoterParticipation summarizes the polls a voter voted in
+func (v *VoteData) GetVoterParticipation(ctx context.Context, voterID uint) (VoterParticipation, error) {
+	polls, err := v.GetParticipation(ctx, voterID)
+	if err != nil {
+		return VoterParticipation{}, err
+	}
+	participation := VoterParticipation{VoterID: voterID, Polls: polls, GeneratedAt: time.Now().UTC()}
+	for _, poll := range polls {
+		participation.TotalVotes += uint(len(poll.VoteIDs))
+	}
+	return participation, nil
+}
+
+func share(part uint, whole uint) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// turnoutOf applies changes to hourly counts, as the stores do
+func turnoutOf(changes ...[]turnoutChange) map[time.Time][2]int {
+	hours := map[time.Time][2]int{}
+	for _, batch := range changes {
+		for _, change := range batch {
+			counts := hours[change.Hour]
+			counts[0] += change.Votes
+			counts[1] += change.NewVoters
+			if counts == [2]int{} {
+				delete(hours, change.Hour)
+			} else {
+				hours[change.Hour] = counts
+			}
+		}
+	}
+	return hours
+}
+
+func TestNewVoterFollowsFirstVote(t *testing.T) {
+	links := newVoteLinks(Services{VotersURL: "voters.test", PollsURL: "polls.test"})
+	vote := func(voteID uint, voterID uint, date time.Time) *Vote {
+		v, _ := links.NewVote(voteID, voterID, 1, 1)
+		v.VoteDate = date
+		return v
+	}
+	nine := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	ten := nine.Add(time.Hour)
+
+	participation := map[uint]voterPolls{}
+	apply := func(before *Vote, after *Vote) []turnoutChange {
+		changes, err := applyVoteWrite(participation, before, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+
+	late := vote(1, 7, ten.Add(30*time.Minute))
+	early := vote(2, 7, nine.Add(10*time.Minute))
+	first := apply(nil, late)
+	second := apply(nil, early)
+	want := map[time.Time][2]int{nine: {1, 1}, ten: {1, 0}}
+	if got := turnoutOf(first, second); !reflect.DeepEqual(got, want) {
+		t.Errorf("after two votes got %v, want %v", got, want)
+	}
+
+	// Applying a write twice changes nothing
+	if again := apply(nil, early); len(again) != 0 {
+		t.Errorf("recording a vote again changed %v", again)
+	}
+
+	deleted := apply(early, nil)
+	want = map[time.Time][2]int{ten: {1, 1}}
+	if got := turnoutOf(first, second, deleted); !reflect.DeepEqual(got, want) {
+		t.Errorf("after deleting the first vote got %v, want %v", got, want)
+	}
+
+	moved := apply(late, vote(1, 8, late.VoteDate))
+	if got := turnoutOf(first, second, deleted, moved); !reflect.DeepEqual(got, want) {
+		t.Errorf("after moving the vote to another voter got %v, want %v", got, want)
+	}
+	if _, ok := participation[7]; ok {
+		t.Errorf("voter 7 has no votes left but is still recorded: %v", participation[7])
+	}
+	if len(participation[8][1]) != 1 {
+		t.Errorf("voter 8 should have the moved vote, has %v", participation[8])
+	}
+}
+
+func totals(hours []TurnoutBucket) (votes uint, newVoters uint) {
+	for _, hour := range hours {
+		votes += hour.Votes
+		newVoters += hour.NewVoters
+	}
+	return votes, newVoters
+}
+
+// TestStoresKeepAggregates writes the same votes to the memory and redis
+// stores and checks their aggregates against each other and against the
+// redis aggregates built from scratch
+func TestStoresKeepAggregates(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	services := Services{VotersURL: "voters.test", PollsURL: "polls.test"}
+	redisStore := NewWithClient(client, services)
+	stores := map[string]VoteStore{"memory": NewMemory(services), "redis": redisStore}
+	// Build the empty aggregates, so that the writes update them
+	if _, err := redisStore.GetTurnout(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, store := range stores {
+		for _, keys := range []VoteKeys{
+			{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1},
+			{VoteID: 2, VoterID: 2, PollID: 1, PollOptionID: 2},
+			{VoteID: 3, VoterID: 1, PollID: 2, PollOptionID: 1},
+			{VoteID: 4, VoterID: 3, PollID: 1, PollOptionID: 1},
+		} {
+			if err := store.AddVote(ctx, keys); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		cast, err := store.GetVote(ctx, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := store.UpdateVote(ctx, 2, VoteKeys{VoteID: 2, VoterID: 2, PollID: 2, PollOptionID: 2}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Turnout counts a vote in the hour it was first cast
+		if updated, err := store.GetVote(ctx, 2); err != nil || !updated.VoteDate.Equal(cast.VoteDate) {
+			t.Errorf("%s: the update moved the vote's date from %s: %+v %v", name, cast.VoteDate, updated, err)
+		}
+		if _, err := store.PatchVote(ctx, 2, MergePatch(`{"PollOptionID": 1}`), func(VoteKeys, *VoteKeys) error { return nil }); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if patched, err := store.GetVote(ctx, 2); err != nil || !patched.VoteDate.Equal(cast.VoteDate) {
+			t.Errorf("%s: the patch moved the vote's date from %s: %+v %v", name, cast.VoteDate, patched, err)
+		}
+		if _, err := store.UpdateVote(ctx, 4, VoteKeys{VoteID: 4, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := store.DeleteVote(ctx, 3); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	if client.Exists(ctx, redisAnalyticsBuiltKey).Val() == 0 {
+		t.Fatal("the redis aggregates were dropped instead of updated")
+	}
+
+	read := func(store VoteStore) ([]TurnoutBucket, []TurnoutBucket, []PollParticipation) {
+		pollOne, err := store.GetTurnout(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pollTwo, err := store.GetTurnout(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		voterOne, err := store.GetParticipation(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range voterOne {
+			voterOne[i].FirstVoteDate, voterOne[i].LastVoteDate = time.Time{}, time.Time{}
+		}
+		return pollOne, pollTwo, voterOne
+	}
+
+	memoryOne, memoryTwo, memoryVoter := read(stores["memory"])
+	if votes, newVoters := totals(memoryOne); votes != 2 || newVoters != 1 {
+		t.Errorf("poll 1 should have 2 votes by 1 new voter, has %+v", memoryOne)
+	}
+	if votes, newVoters := totals(memoryTwo); votes != 1 || newVoters != 1 {
+		t.Errorf("poll 2 should have 1 vote by 1 new voter, has %+v", memoryTwo)
+	}
+	wantVoter := []PollParticipation{{PollID: 1, VoteIDs: []uint{1, 4}}}
+	if !reflect.DeepEqual(memoryVoter, wantVoter) {
+		t.Errorf("voter 1 participated in %+v, want %+v", memoryVoter, wantVoter)
+	}
+
+	redisOne, redisTwo, redisVoter := read(redisStore)
+	if !reflect.DeepEqual(redisOne, memoryOne) || !reflect.DeepEqual(redisTwo, memoryTwo) || !reflect.DeepEqual(redisVoter, memoryVoter) {
+		t.Errorf("redis aggregates %+v %+v %+v differ from memory %+v %+v %+v",
+			redisOne, redisTwo, redisVoter, memoryOne, memoryTwo, memoryVoter)
+	}
+
+	client.Del(ctx, redisAnalyticsBuiltKey)
+	builtOne, builtTwo, builtVoter := read(redisStore)
+	if !reflect.DeepEqual(builtOne, redisOne) || !reflect.DeepEqual(builtTwo, redisTwo) || !reflect.DeepEqual(builtVoter, redisVoter) {
+		t.Errorf("rebuilt aggregates %+v %+v %+v differ from kept %+v %+v %+v",
+			builtOne, builtTwo, builtVoter, redisOne, redisTwo, redisVoter)
+	}
+}
+
+func TestConcurrentCastsCountOnce(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	store := NewWithClient(client, Services{VotersURL: "voters.test", PollsURL: "polls.test"})
+	if _, err := store.GetTurnout(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	const casts = 10
+	errs := make(chan error, casts)
+	for i := 0; i < casts; i++ {
+		go func(voterID uint) {
+			errs <- store.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: voterID, PollID: 1, PollOptionID: 1})
+		}(uint(i + 1))
+	}
+	cast := 0
+	for i := 0; i < casts; i++ {
+		switch err := <-errs; {
+		case err == nil:
+			cast++
+		case !errors.Is(err, ErrVoteExists):
+			t.Errorf("expected ErrVoteExists, got %v", err)
+		}
+	}
+	if cast != 1 {
+		t.Fatalf("%d casts created vote 1, expected 1", cast)
+	}
+
+	turnout, err := store.GetTurnout(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if votes, newVoters := totals(turnout); votes != 1 || newVoters != 1 {
+		t.Errorf("poll 1 should have 1 vote by 1 new voter, has %+v", turnout)
+	}
+}
+
+// A vote written while the aggregates are built fails the build, which
+// starts over and counts it
+func TestBuildRestartsOnWrite(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	store := NewWithClient(client, Services{VotersURL: "voters.test", PollsURL: "polls.test"})
+	if err := store.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := client.Watch(ctx, func(tx *redis.Tx) error {
+		if err := store.AddVote(ctx, VoteKeys{VoteID: 2, VoterID: 2, PollID: 1, PollOptionID: 2}); err != nil {
+			return err
+		}
+		return store.saveBuiltAggregates(ctx, tx)
+	}, redisAnalyticsBuiltKey, redisAnalyticsWritesKey)
+	if !errors.Is(err, redis.TxFailedErr) {
+		t.Fatalf("expected the write to fail the build, got %v", err)
+	}
+	if client.Exists(ctx, redisAnalyticsBuiltKey).Val() != 0 {
+		t.Fatal("the failed build marked the aggregates built")
+	}
+
+	turnout, err := store.GetTurnout(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if votes, newVoters := totals(turnout); votes != 2 || newVoters != 2 {
+		t.Errorf("poll 1 should have 2 votes by 2 new voters, has %+v", turnout)
+	}
+	if client.Exists(ctx, redisAnalyticsWritesKey).Val() != 0 {
+		t.Error("the build left the writes counter behind")
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// startRedis runs a throwaway redis-server for the benchmarks, which time
+// the redis store with details from stubs of the voter and poll apis:
+//
+//	go test -run '^$' -bench . ./db/
+//
+// RedisJSON is loaded from REDISJSON_MODULE unless the server has it built
+// in, as redis-stack-server does. The benchmarks are skipped when neither
+// is available.
+func startRedis(tb testing.TB) *redis.Client {
+	tb.Helper()
+
+	binary := ""
+	for _, name := range []string{"redis-stack-server", "redis-server"} {
+		if path, err := exec.LookPath(name); err == nil {
+			binary = path
+			break
+		}
+	}
+	if binary == "" {
+		tb.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	args := []string{"--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", tb.TempDir()}
+	if module := os.Getenv("REDISJSON_MODULE"); module != "" {
+		args = append(args, "--loadmodule", module)
+	}
+	server := exec.Command(binary, args...)
+	if err := server.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	tb.Cleanup(func() { client.Close() })
+
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(ctx).Err() != nil {
+		if time.Now().After(deadline) {
+			tb.Fatal("redis-server did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := client.Do(ctx, "JSON.SET", "probe", ".", "{}").Err(); err != nil {
+		tb.Skip("redis-server has no RedisJSON, set REDISJSON_MODULE to the module's path: ", err)
+	}
+	client.Del(ctx, "probe")
+	return client
+}
+
+// newBenchData keeps votes in a fresh redis and looks up details over HTTP
+// from the contract stubs, which know voter 1 and poll 1 with options 1
+// and 2
+func newBenchData(b *testing.B) *VoteData {
+	b.Helper()
+	client := startRedis(b)
+	_, votersURL := startStub(b, "voter-api")
+	_, pollsURL := startStub(b, "poll-api")
+
+	services := DefaultServices()
+	services.VotersURL = votersURL
+	services.PollsURL = pollsURL
+	data, err := NewWithStore(NewWithClient(client, services), services)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkAddVote(b *testing.B) {
+	data := newBenchData(b)
+	ctx := context.Background()
+
+	var lastID uint64
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			id := uint(atomic.AddUint64(&lastID, 1))
+			if err := data.AddVote(ctx, VoteKeys{VoteID: id, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				id := uint(atomic.AddUint64(&lastID, 1))
+				if err := data.AddVote(ctx, VoteKeys{VoteID: id, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkGetVoteDetails(b *testing.B) {
+	data := newBenchData(b)
+	ctx := context.Background()
+	if err := data.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+		b.Fatal(err)
+	}
+	header := http.Header{"X-Api-Key": {"reader-key"}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := data.GetVoteDetails(ctx, 1, header); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllVotes(b *testing.B) {
+	data := newBenchData(b)
+	ctx := context.Background()
+
+	stored := 0
+	for _, count := range []int{100, 1000} {
+		// Each size adds to the votes of the one before
+		for ; stored < count; stored++ {
+			vote := VoteKeys{VoteID: uint(stored + 1), VoterID: 1, PollID: 1, PollOptionID: uint(stored%2 + 1)}
+			if err := data.AddVote(ctx, vote); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.Run(fmt.Sprintf("%d votes", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				votes, err := data.GetAllVotes(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(votes) != count {
+					b.Fatalf("expected %d votes, got %d", count, len(votes))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"votes-api/client/pollclient"
+	"votes-api/client/voterclient"
+	"votes-api/contracts"
+)
+
+// startStub serves the contract with provider and returns its host:port
+func startStub(t testing.TB, provider string) (*contracts.Stub, string) {
+	t.Helper()
+	contract, err := contracts.Load(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := contracts.NewStub(contract)
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, strings.TrimPrefix(server.URL, "http://")
+}
+
+// The HTTP detail lookups must get everything they read from the
+// responses the contracts pin, and every interaction must be one of
+// theirs, so the contracts neither miss nor overstate what the vote api
+// depends on
+func TestDetailLookupsMeetContracts(t *testing.T) {
+	voterStub, votersURL := startStub(t, "voter-api")
+	pollStub, pollsURL := startStub(t, "poll-api")
+
+	services := DefaultServices()
+	services.VotersURL = votersURL
+	services.PollsURL = pollsURL
+	details, err := newDetailSource(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	header := http.Header{"X-Api-Key": {"reader-key"}}
+
+	voter, err := details.GetVoter(ctx, 1, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voter != (voterclient.Voter{VoterID: 1, FirstName: "Michael", LastName: "Dratch"}) {
+		t.Errorf("unexpected voter %+v", voter)
+	}
+	if forwarded := voterStub.LastHeader().Get("X-Api-Key"); forwarded != "reader-key" {
+		t.Errorf("expected the caller's API key to be forwarded, got %q", forwarded)
+	}
+
+	registered, err := details.CountVoters(ctx, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registered != 1 {
+		t.Errorf("expected 1 registered voter, got %d", registered)
+	}
+
+	poll, err := details.GetPoll(ctx, 1, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPoll := pollclient.Poll{
+		PollID:       1,
+		PollTitle:    "Favorite Color",
+		PollQuestion: "What is your favorite color?",
+		PollOptions: []pollclient.PollOption{
+			{PollOptionID: 1, PollOptionText: "Blue"},
+			{PollOptionID: 2, PollOptionText: "Brown"},
+		},
+	}
+	if !reflect.DeepEqual(poll, expectedPoll) {
+		t.Errorf("unexpected poll %+v", poll)
+	}
+
+	pollOption, err := details.GetPollOption(ctx, 1, 1, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pollOption != (pollclient.PollOption{PollOptionID: 1, PollOptionText: "Blue"}) {
+		t.Errorf("unexpected poll option %+v", pollOption)
+	}
+
+	for provider, stub := range map[string]*contracts.Stub{"voter-api": voterStub, "poll-api": pollStub} {
+		if unused := stub.Unused(); len(unused) > 0 {
+			t.Errorf("the vote api never makes these requests in its contract with %s: %v", provider, unused)
+		}
+	}
+}
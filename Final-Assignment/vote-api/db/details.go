@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"shared/auth"
+	"votes-api/client/pollclient"
+	"votes-api/client/voterclient"
+	"votes-api/logging"
+	pollv1 "votes-api/pb/poll/v1"
+	voterv1 "votes-api/pb/voter/v1"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	DetailTransportHTTP       = "http"
+	DetailTransportGRPC       = "grpc"
+	VotersGrpcDefaultLocation = "0.0.0.0:2081"
+	PollsGrpcDefaultLocation  = "0.0.0.0:2082"
+)
+
+// detailHTTPClient traces the lookups, passing the trace context in the
+// traceparent header, and sends the request ID on
+var detailHTTPClient = &http.Client{Transport: otelhttp.NewTransport(logging.Transport(http.DefaultTransport))}
+
+// detailSource looks up the voter, poll and poll option a vote refers to.
+// header carries the caller's credentials to forward.
+type detailSource interface {
+	GetVoter(ctx context.Context, voterID uint, header http.Header) (voterclient.Voter, error)
+	GetPoll(ctx context.Context, pollID uint, header http.Header) (pollclient.Poll, error)
+	GetPollOption(ctx context.Context, pollID uint, pollOptionID uint, header http.Header) (pollclient.PollOption, error)
+	CountVoters(ctx context.Context, header http.Header) (uint, error)
+}
+
+// Pick the transport for detail lookups, "http" (the default) or "grpc"
+func newDetailSource(services Services) (detailSource, error) {
+	var details detailSource
+	switch services.DetailTransport {
+	case "", DetailTransportHTTP:
+		voterClient := voterclient.New("http://" + services.VotersURL)
+		voterClient.HTTPClient = detailHTTPClient
+		pollClient := pollclient.New("http://" + services.PollsURL)
+		pollClient.HTTPClient = detailHTTPClient
+		details = &restDetails{voterClient: voterClient, pollClient: pollClient}
+	case DetailTransportGRPC:
+		grpcDetails, err := newGrpcDetails(services.VotersGrpcURL, services.PollsGrpcURL)
+		if err != nil {
+			return nil, err
+		}
+		details = grpcDetails
+	default:
+		return nil, services.Validate()
+	}
+	return &countCache{detailSource: details, ttl: voterCountTTL}, nil
+}
+
+// voterCountTTL is how long the size of the voter roll is kept, since
+// counting it lists every voter
+const voterCountTTL = time.Minute
+
+// countCache keeps the size of the voter roll for ttl, so that the results
+// and turnout routes do not list every voter on each request. It is counted
+// with the credentials of the request that finds it missing or stale.
+type countCache struct {
+	detailSource
+	ttl time.Duration
+
+	mutex     sync.Mutex
+	count     uint
+	countedAt time.Time
+}
+
+func (c *countCache) CountVoters(ctx context.Context, header http.Header) (uint, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.countedAt.IsZero() && time.Since(c.countedAt) < c.ttl {
+		return c.count, nil
+	}
+	count, err := c.detailSource.CountVoters(ctx, header)
+	if err != nil {
+		return 0, err
+	}
+	c.count, c.countedAt = count, time.Now()
+	return count, nil
+}
+
+// restDetails uses the clients generated from the OpenAPI documents
+type restDetails struct {
+	voterClient *voterclient.Client
+	pollClient  *pollclient.Client
+}
+
+func (r *restDetails) GetVoter(ctx context.Context, voterID uint, header http.Header) (voterclient.Voter, error) {
+	return r.voterClient.GetVoter(ctx, voterID, forwardHeaders(header))
+}
+
+func (r *restDetails) GetPoll(ctx context.Context, pollID uint, header http.Header) (pollclient.Poll, error) {
+	return r.pollClient.GetPoll(ctx, pollID, forwardHeaders(header))
+}
+
+func (r *restDetails) GetPollOption(ctx context.Context, pollID uint, pollOptionID uint, header http.Header) (pollclient.PollOption, error) {
+	return r.pollClient.GetPollOption(ctx, pollID, pollOptionID, forwardHeaders(header))
+}
+
+func (r *restDetails) CountVoters(ctx context.Context, header http.Header) (uint, error) {
+	voters, err := r.voterClient.ListVoters(ctx, forwardHeaders(header))
+	if err != nil {
+		return 0, err
+	}
+	return uint(len(voters)), nil
+}
+
+// forwardHeaders copies header onto the outgoing requests of the
+// generated clients
+func forwardHeaders(header http.Header) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		return nil
+	}
+}
+
+// grpcDetails calls the gRPC services of the voter and poll apis. The
+// connections are only opened on first use.
+type grpcDetails struct {
+	voters voterv1.VoterServiceClient
+	polls  pollv1.PollServiceClient
+}
+
+func newGrpcDetails(votersAddress string, pollsAddress string) (*grpcDetails, error) {
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor()),
+	}
+
+	votersConnection, err := grpc.Dial(votersAddress, options...)
+	if err != nil {
+		return nil, err
+	}
+	pollsConnection, err := grpc.Dial(pollsAddress, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcDetails{
+		voters: voterv1.NewVoterServiceClient(votersConnection),
+		polls:  pollv1.NewPollServiceClient(pollsConnection),
+	}, nil
+}
+
+func (g *grpcDetails) GetVoter(ctx context.Context, voterID uint, header http.Header) (voterclient.Voter, error) {
+	voter, err := g.voters.GetVoter(auth.OutgoingMetadata(ctx, header), &voterv1.GetVoterRequest{VoterId: uint64(voterID)})
+	if err != nil {
+		return voterclient.Voter{}, err
+	}
+	return voterclient.Voter{
+		VoterID:   uint(voter.GetVoterId()),
+		FirstName: voter.GetFirstName(),
+		LastName:  voter.GetLastName(),
+	}, nil
+}
+
+func (g *grpcDetails) GetPoll(ctx context.Context, pollID uint, header http.Header) (pollclient.Poll, error) {
+	poll, err := g.polls.GetPoll(auth.OutgoingMetadata(ctx, header), &pollv1.GetPollRequest{PollId: uint64(pollID)})
+	if err != nil {
+		return pollclient.Poll{}, err
+	}
+
+	pollOptions := make([]pollclient.PollOption, 0, len(poll.GetPollOptions()))
+	for _, pollOption := range poll.GetPollOptions() {
+		pollOptions = append(pollOptions, fromProtoPollOption(pollOption))
+	}
+	return pollclient.Poll{
+		PollID:       uint(poll.GetPollId()),
+		PollTitle:    poll.GetPollTitle(),
+		PollQuestion: poll.GetPollQuestion(),
+		PollOptions:  pollOptions,
+	}, nil
+}
+
+func (g *grpcDetails) GetPollOption(ctx context.Context, pollID uint, pollOptionID uint, header http.Header) (pollclient.PollOption, error) {
+	pollOption, err := g.polls.GetPollOption(auth.OutgoingMetadata(ctx, header), &pollv1.GetPollOptionRequest{
+		PollId:       uint64(pollID),
+		PollOptionId: uint64(pollOptionID),
+	})
+	if err != nil {
+		return pollclient.PollOption{}, err
+	}
+	return fromProtoPollOption(pollOption), nil
+}
+
+func (g *grpcDetails) CountVoters(ctx context.Context, header http.Header) (uint, error) {
+	voters, err := g.voters.ListVoters(auth.OutgoingMetadata(ctx, header), &voterv1.ListVotersRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return uint(len(voters.GetVoters())), nil
+}
+
+func fromProtoPollOption(pollOption *pollv1.PollOption) pollclient.PollOption {
+	return pollclient.PollOption{
+		PollOptionID:   uint(pollOption.GetPollOptionId()),
+		PollOptionText: pollOption.GetPollOptionText(),
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// countingDetails counts the voter roll lookups it answers
+type countingDetails struct {
+	detailSource
+	voters  uint
+	lookups int
+	err     error
+}
+
+func (d *countingDetails) CountVoters(ctx context.Context, header http.Header) (uint, error) {
+	d.lookups++
+	return d.voters, d.err
+}
+
+// The voter roll is counted once per ttl, and a failed count is not kept
+func TestVoterCountCached(t *testing.T) {
+	source := &countingDetails{voters: 3}
+	details := &countCache{detailSource: source, ttl: time.Hour}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if count, err := details.CountVoters(ctx, nil); err != nil || count != 3 {
+			t.Fatalf("expected 3 voters, got %d %v", count, err)
+		}
+	}
+	if source.lookups != 1 {
+		t.Errorf("expected one lookup, got %d", source.lookups)
+	}
+
+	source.voters = 4
+	details.countedAt = time.Now().Add(-2 * time.Hour)
+	if count, err := details.CountVoters(ctx, nil); err != nil || count != 4 || source.lookups != 2 {
+		t.Errorf("expected a stale count to be looked up again, got %d %v after %d lookups", count, err, source.lookups)
+	}
+
+	failing := &countingDetails{err: errors.New("voter api is down")}
+	details = &countCache{detailSource: failing, ttl: time.Hour}
+	for i := 0; i < 2; i++ {
+		if _, err := details.CountVoters(ctx, nil); err == nil {
+			t.Error("expected the lookup's error")
+		}
+	}
+	if failing.lookups != 2 {
+		t.Errorf("expected a failed count to be looked up again, got %d lookups", failing.lookups)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sources of a vote's revisions, the write that made each one
+const (
+	RevisionCast   = "cast"
+	RevisionUpdate = "update"
+	RevisionPatch  = "patch"
+)
+
+// Revision is a choice a vote held, from Date until the next revision
+type Revision struct {
+	PollID       uint
+	PollOptionID uint
+	Date         time.Time
+	Source       string
+}
+
+// VoteHistory lists a vote's revisions, oldest first. CastDate is when the
+// voter first voted, which the vote's VoteDate keeps through changes.
+type VoteHistory struct {
+	VoteID    uint
+	CastDate  time.Time
+	Revisions []Revision
+}
+
+// revisionOf is the choice vote holds, made by source
+func revisionOf(vote Vote, source string) (Revision, error) {
+	keys, err := VoteKeysFromVote(vote)
+	if err != nil {
+		return Revision{}, err
+	}
+	return Revision{PollID: keys.PollID, PollOptionID: keys.PollOptionID, Date: vote.VoteDate, Source: source}, nil
+}
+
+// revisionsOf is the revisions a vote document keeps. Votes cast before
+// revisions were kept get one for their current choice.
+func revisionsOf(vote Vote) ([]Revision, error) {
+	if len(vote.Revisions) > 0 {
+		return vote.Revisions, nil
+	}
+	revision, err := revisionOf(vote, RevisionCast)
+	if err != nil {
+		return nil, err
+	}
+	return []Revision{revision}, nil
+}
+
+// revise gives written the revisions of existing, nil for a new vote,
+// followed by its own, for the stores that keep them in the vote document.
+// A changed vote keeps the date it was cast, so its revision is dated now.
+func revise(existing *Vote, written *Vote, source string) error {
+	var revisions []Revision
+	if existing != nil {
+		var err error
+		if revisions, err = revisionsOf(*existing); err != nil {
+			return err
+		}
+	}
+	revision, err := revisionOf(*written, source)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		revision.Date = time.Now()
+	}
+	written.Revisions = append(append([]Revision{}, revisions...), revision)
+	return nil
+}
+
+func historyOf(voteID uint, revisions []Revision) VoteHistory {
+	return VoteHistory{VoteID: voteID, CastDate: revisions[0].Date, Revisions: revisions}
+}
+
+// RevotePolicy modes: votes can be changed until the poll closes, within
+// WindowMinutes of being cast, or not at all
+const (
+	RevoteUntilClose = "until-close"
+	RevoteWindow     = "window"
+	RevoteForbid     = "forbid"
+)
+
+var ErrRevoteNotAllowed = errors.New("the poll does not allow changing this vote")
+
+// RevotePolicy says whether the votes in a poll can be changed. No vote
+// can be changed once the poll closes at ClosesAt, if it is set.
+type RevotePolicy struct {
+	Mode          string     `binding:"required,oneof=until-close window forbid"`
+	ClosesAt      *time.Time `json:",omitempty"`
+	WindowMinutes uint       `json:",omitempty" binding:"required_if=Mode window"`
+}
+
+// Polls without a policy of their own never close and allow changes
+var DefaultRevotePolicy = RevotePolicy{Mode: RevoteUntilClose}
+
+// Allows checks a change at now to a vote first cast at castDate
+func (policy RevotePolicy) Allows(castDate time.Time, now time.Time) error {
+	if policy.ClosesAt != nil && !now.Before(*policy.ClosesAt) {
+		return fmt.Errorf("%w: the poll closed at %s", ErrRevoteNotAllowed, policy.ClosesAt.Format(time.RFC3339))
+	}
+	switch policy.Mode {
+	case RevoteForbid:
+		return fmt.Errorf("%w: votes in the poll cannot be changed", ErrRevoteNotAllowed)
+	case RevoteWindow:
+		window := time.Duration(policy.WindowMinutes) * time.Minute
+		if now.Sub(castDate) > window {
+			return fmt.Errorf("%w: votes can only be changed within %s of being cast", ErrRevoteNotAllowed, window)
+		}
+	}
+	return nil
+}
+
+// castDateOf is when a vote the memory and redis stores keep was first cast
+func castDateOf(vote Vote) (time.Time, error) {
+	revisions, err := revisionsOf(vote)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return revisions[0].Date, nil
+}
+
+// checkRevote returns ErrRevoteNotAllowed when the policy of the poll a
+// vote cast at castDate is in, or of the poll the change moves it to, does
+// not allow changing it now. The stores call it in the write that changes
+// the vote, with policyOf reading the policies in that write.
+func checkRevote(castDate time.Time, fromPollID uint, toPollID uint, policyOf func(pollID uint) (RevotePolicy, error)) error {
+	now := time.Now()
+	for _, pollID := range []uint{fromPollID, toPollID} {
+		policy, err := policyOf(pollID)
+		if err != nil {
+			return err
+		}
+		if err := policy.Allows(castDate, now); err != nil {
+			return err
+		}
+		if toPollID == fromPollID {
+			break
+		}
+	}
+	return nil
+}
+
+// SetPollRevotePolicy sets the policy of a poll the poll api knows.
+// header carries the caller's credentials to forward with the lookup.
+func (v *VoteData) SetPollRevotePolicy(ctx context.Context, pollID uint, policy RevotePolicy, header http.Header) error {
+	if _, err := v.details.GetPoll(ctx, pollID, header); err != nil {
+		return fmt.Errorf("%w: %w", ErrReferenceNotFound, err)
+	}
+	return v.SetRevotePolicy(ctx, pollID, policy)
+}
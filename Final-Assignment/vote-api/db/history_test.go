@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRevotePolicyAllows(t *testing.T) {
+	cast := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	closes := cast.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		policy  RevotePolicy
+		at      time.Time
+		allowed bool
+	}{
+		{"default", DefaultRevotePolicy, cast.Add(24 * time.Hour), true},
+		{"before close", RevotePolicy{Mode: RevoteUntilClose, ClosesAt: &closes}, closes.Add(-time.Second), true},
+		{"at close", RevotePolicy{Mode: RevoteUntilClose, ClosesAt: &closes}, closes, false},
+		{"within window", RevotePolicy{Mode: RevoteWindow, WindowMinutes: 10}, cast.Add(10 * time.Minute), true},
+		{"after window", RevotePolicy{Mode: RevoteWindow, WindowMinutes: 10}, cast.Add(11 * time.Minute), false},
+		{"window after close", RevotePolicy{Mode: RevoteWindow, WindowMinutes: 120, ClosesAt: &closes}, closes.Add(time.Minute), false},
+		{"forbid", RevotePolicy{Mode: RevoteForbid}, cast, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Allows(cast, test.at)
+			if test.allowed && err != nil {
+				t.Errorf("expected the change to be allowed, got %v", err)
+			}
+			if !test.allowed && !errors.Is(err, ErrRevoteNotAllowed) {
+				t.Errorf("expected ErrRevoteNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+// TestStoresKeepRevisions checks that the memory and redis stores keep
+// every choice of a vote, also for votes written before revisions were
+func TestStoresKeepRevisions(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	services := Services{VotersURL: "voters.test", PollsURL: "polls.test"}
+	redisStore := NewWithClient(client, services)
+	stores := map[string]VoteStore{"memory": NewMemory(services), "redis": redisStore}
+
+	for name, store := range stores {
+		if err := store.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := store.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 2}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		history, err := store.GetVoteHistory(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		revisions := history.Revisions
+		if len(revisions) != 2 || revisions[0].PollOptionID != 1 || revisions[0].Source != RevisionCast ||
+			revisions[1].PollOptionID != 2 || revisions[1].Source != RevisionUpdate {
+			t.Errorf("%s: unexpected revisions %+v", name, revisions)
+		}
+		if !history.CastDate.Equal(revisions[0].Date) || revisions[1].Date.Before(history.CastDate) {
+			t.Errorf("%s: unexpected dates in %+v", name, history)
+		}
+		if _, err := store.GetVoteHistory(ctx, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound for a missing vote, got %v", name, err)
+		}
+
+		policy, err := store.GetRevotePolicy(ctx, 1)
+		if err != nil || policy.Mode != DefaultRevotePolicy.Mode {
+			t.Errorf("%s: expected the default policy, got %+v %v", name, policy, err)
+		}
+		closes := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		if err := store.SetRevotePolicy(ctx, 1, RevotePolicy{Mode: RevoteWindow, WindowMinutes: 5, ClosesAt: &closes}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		policy, err = store.GetRevotePolicy(ctx, 1)
+		if err != nil || policy.Mode != RevoteWindow || policy.WindowMinutes != 5 || policy.ClosesAt == nil || !policy.ClosesAt.Equal(closes) {
+			t.Errorf("%s: policy not kept, got %+v %v", name, policy, err)
+		}
+	}
+
+	// A vote written before revisions were kept
+	legacy, _ := redisStore.NewVote(2, 2, 2, 3)
+	if _, err := redisStore.jsonHandler(ctx).JSONSet(redisVoteKeyFromId(2), ".", legacy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := redisStore.UpdateVote(ctx, 2, VoteKeys{VoteID: 2, VoterID: 2, PollID: 2, PollOptionID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := redisStore.GetVoteHistory(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Revisions) != 2 || history.Revisions[0].PollOptionID != 3 || !history.CastDate.Equal(legacy.VoteDate) {
+		t.Errorf("expected the legacy choice to come first, got %+v", history)
+	}
+}
+
+// TestStoresEnforceRevotePolicy checks that the memory and redis stores
+// refuse changes the policy of the vote's poll, or of the poll it moves
+// to, does not allow
+func TestStoresEnforceRevotePolicy(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	services := Services{VotersURL: "voters.test", PollsURL: "polls.test"}
+	stores := map[string]VoteStore{"memory": NewMemory(services), "redis": NewWithClient(client, services)}
+	allow := func(VoteKeys, *VoteKeys) error { return nil }
+
+	for name, store := range stores {
+		if err := store.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := store.SetRevotePolicy(ctx, 2, RevotePolicy{Mode: RevoteForbid}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := store.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 2, PollOptionID: 1}); !errors.Is(err, ErrRevoteNotAllowed) {
+			t.Errorf("%s: moving the vote to a poll that forbids changes should fail, got %v", name, err)
+		}
+		if _, err := store.PatchVote(ctx, 1, MergePatch(`{"PollID":2}`), allow); !errors.Is(err, ErrRevoteNotAllowed) {
+			t.Errorf("%s: patching the vote into a poll that forbids changes should fail, got %v", name, err)
+		}
+		if _, err := store.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 2}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if err := store.SetRevotePolicy(ctx, 1, RevotePolicy{Mode: RevoteForbid}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := store.PatchVote(ctx, 1, MergePatch(`{"PollOptionID":1}`), allow); !errors.Is(err, ErrRevoteNotAllowed) {
+			t.Errorf("%s: patching a vote in a poll that forbids changes should fail, got %v", name, err)
+		}
+		history, err := store.GetVoteHistory(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		latest := history.Revisions[len(history.Revisions)-1]
+		if len(history.Revisions) != 2 || latest.PollID != 1 || latest.PollOptionID != 2 {
+			t.Errorf("%s: refused changes should not be written, got %+v", name, history.Revisions)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryVoteData keeps votes in a map. Nothing survives a restart, so it is
+// meant for tests and local development.
+type MemoryVoteData struct {
+	voteLinks
+	mutex sync.RWMutex
+	votes map[uint]Vote
+	// The analytics aggregates, kept up to date by every write
+	participation map[uint]voterPolls
+	turnout       map[uint]map[time.Time]TurnoutBucket
+	policies      map[uint]RevotePolicy
+}
+
+func NewMemory(services Services) *MemoryVoteData {
+	return &MemoryVoteData{
+		voteLinks: newVoteLinks(services),
+		votes:     map[uint]Vote{},
+
+		participation: map[uint]voterPolls{},
+		turnout:       map[uint]map[time.Time]TurnoutBucket{},
+		policies:      map[uint]RevotePolicy{},
+	}
+}
+
+func (v *MemoryVoteData) GetAllVotes(ctx context.Context) ([]Vote, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	var votes []Vote
+	for _, vote := range v.votes {
+		votes = append(votes, vote)
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i].VoteID < votes[j].VoteID })
+	return votes, nil
+}
+
+func (v *MemoryVoteData) GetVote(ctx context.Context, voteID uint) (Vote, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	vote, ok := v.votes[voteID]
+	if !ok {
+		return Vote{}, ErrNotFound
+	}
+	return vote, nil
+}
+
+func (v *MemoryVoteData) AddVote(ctx context.Context, voteKeys VoteKeys) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.votes[voteKeys.VoteID]; ok {
+		return ErrVoteExists
+	}
+
+	newVote, _ := v.NewVote(voteKeys.VoteID, voteKeys.VoterID, voteKeys.PollID, voteKeys.PollOptionID)
+	if err := revise(nil, newVote, RevisionCast); err != nil {
+		return err
+	}
+	v.votes[voteKeys.VoteID] = *newVote
+	return v.recordWrite(nil, newVote)
+}
+
+// UpdateVote recasts the vote, keeping the date it was cast, and returns
+// the vote as it was before. The revote policies are checked under the
+// same lock as the write.
+func (v *MemoryVoteData) UpdateVote(ctx context.Context, voteID uint, updateData VoteKeys) (Vote, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	existingVote, ok := v.votes[voteID]
+	if !ok {
+		return Vote{}, errors.New("Item does not exist")
+	}
+	if err := v.checkRevote(existingVote, updateData.PollID); err != nil {
+		return Vote{}, err
+	}
+
+	updatedVote, _ := v.NewVote(updateData.VoteID,
+		updateData.VoterID,
+		updateData.PollID,
+		updateData.PollOptionID)
+	updatedVote.VoteDate = existingVote.VoteDate
+	if err := revise(&existingVote, updatedVote, RevisionUpdate); err != nil {
+		return Vote{}, err
+	}
+	v.votes[voteID] = *updatedVote
+	return existingVote, v.recordWrite(&existingVote, updatedVote)
+}
+
+// PatchVote holds the write lock while the patch is applied, so
+// concurrent writes are never lost
+func (v *MemoryVoteData) PatchVote(ctx context.Context, voteID uint, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (Vote, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	vote, ok := v.votes[voteID]
+	if !ok {
+		return Vote{}, ErrNotFound
+	}
+
+	patchedVote, err := v.patchVote(vote, patch, check)
+	if err != nil {
+		return Vote{}, err
+	}
+	patchedKeys, err := VoteKeysFromVote(*patchedVote)
+	if err != nil {
+		return Vote{}, err
+	}
+	if err := v.checkRevote(vote, patchedKeys.PollID); err != nil {
+		return Vote{}, err
+	}
+	if err := revise(&vote, patchedVote, RevisionPatch); err != nil {
+		return Vote{}, err
+	}
+	v.votes[voteID] = *patchedVote
+	return *patchedVote, v.recordWrite(&vote, patchedVote)
+}
+
+// DeleteVote removes the vote and returns it
+func (v *MemoryVoteData) DeleteVote(ctx context.Context, voteID uint) (Vote, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	vote, ok := v.votes[voteID]
+	if !ok {
+		return Vote{}, errors.New("Attempted to delete a non-existent vote")
+	}
+	delete(v.votes, voteID)
+	return vote, v.recordWrite(&vote, nil)
+}
+
+// GetTurnout reads the poll's hourly buckets
+func (v *MemoryVoteData) GetTurnout(ctx context.Context, pollID uint) ([]TurnoutBucket, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	hours := []TurnoutBucket{}
+	for _, bucket := range v.turnout[pollID] {
+		hours = append(hours, bucket)
+	}
+	sortBuckets(hours)
+	return hours, nil
+}
+
+func (v *MemoryVoteData) GetParticipation(ctx context.Context, voterID uint) ([]PollParticipation, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	return participationOf(v.participation[voterID]), nil
+}
+
+func (v *MemoryVoteData) GetVoteHistory(ctx context.Context, voteID uint) (VoteHistory, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	vote, ok := v.votes[voteID]
+	if !ok {
+		return VoteHistory{}, ErrNotFound
+	}
+	revisions, err := revisionsOf(vote)
+	if err != nil {
+		return VoteHistory{}, err
+	}
+	return historyOf(voteID, revisions), nil
+}
+
+func (v *MemoryVoteData) GetRevotePolicy(ctx context.Context, pollID uint) (RevotePolicy, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	return v.policyOf(pollID)
+}
+
+// policyOf reads the poll's policy, with v.mutex held
+func (v *MemoryVoteData) policyOf(pollID uint) (RevotePolicy, error) {
+	policy, ok := v.policies[pollID]
+	if !ok {
+		return DefaultRevotePolicy, nil
+	}
+	return policy, nil
+}
+
+// checkRevote checks changing existing to a vote in pollID, with v.mutex
+// held
+func (v *MemoryVoteData) checkRevote(existing Vote, pollID uint) error {
+	castDate, err := castDateOf(existing)
+	if err != nil {
+		return err
+	}
+	existingKeys, err := VoteKeysFromVote(existing)
+	if err != nil {
+		return err
+	}
+	return checkRevote(castDate, existingKeys.PollID, pollID, v.policyOf)
+}
+
+func (v *MemoryVoteData) SetRevotePolicy(ctx context.Context, pollID uint, policy RevotePolicy) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.policies[pollID] = policy
+	return nil
+}
+
+// recordWrite updates the aggregates for a vote going from before to
+// after. The caller holds the write lock.
+func (v *MemoryVoteData) recordWrite(before *Vote, after *Vote) error {
+	changes, err := applyVoteWrite(v.participation, before, after)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		hours := v.turnout[change.PollID]
+		if hours == nil {
+			hours = map[time.Time]TurnoutBucket{}
+			v.turnout[change.PollID] = hours
+		}
+		bucket := hours[change.Hour]
+		bucket.Start = change.Hour
+		bucket.Votes = uint(int(bucket.Votes) + change.Votes)
+		bucket.NewVoters = uint(int(bucket.NewVoters) + change.NewVoters)
+		if bucket.Votes == 0 && bucket.NewVoters == 0 {
+			delete(hours, change.Hour)
+		} else {
+			hours[change.Hour] = bucket
+		}
+	}
+	return nil
+}
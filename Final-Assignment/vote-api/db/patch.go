@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+	// How often a patch is retried when the record changes underneath it
+	maxPatchAttempts = 5
+)
+
+var (
+	ErrNotFound      = errors.New("item does not exist")
+	ErrInvalidPatch  = errors.New("patch could not be applied")
+	ErrIDChanged     = errors.New("a patch may not change the record's id")
+	ErrPatchConflict = errors.New("record kept changing while the patch was applied")
+)
+
+// Patch rewrites the JSON document of a stored record
+type Patch interface {
+	Apply(document []byte) ([]byte, error)
+}
+
+// MergePatch is an RFC 7396 JSON Merge Patch document
+type MergePatch []byte
+
+func (patch MergePatch) Apply(document []byte) ([]byte, error) {
+	patched, err := jsonpatch.MergePatch(document, patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return patched, nil
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document
+type JSONPatch []byte
+
+func (patch JSONPatch) Apply(document []byte) ([]byte, error) {
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	patched, err := operations.Apply(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return patched, nil
+}
+
+// PatchFromContentType picks the patch format named by a request's
+// Content-Type, returning nil for unsupported types
+func PatchFromContentType(contentType string, body []byte) Patch {
+	switch contentType {
+	case MergePatchContentType:
+		return MergePatch(body)
+	case JSONPatchContentType:
+		return JSONPatch(body)
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostgresDefaultLocation = "postgres://postgres@0.0.0.0:5432/postgres?sslmode=disable"
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	postgresForeignKeyViolation = "23503"
+	postgresUniqueViolation     = "23505"
+)
+
+// PostgresVoteData keeps votes in the votes table by the ids they were
+// cast with. Foreign keys make sure the voter, poll and poll option exist,
+// and a voter can vote only once in each poll.
+type PostgresVoteData struct {
+	voteLinks
+	db *sql.DB
+}
+
+func NewPostgres(location string, services Services) (*PostgresVoteData, error) {
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("Error connecting to postgres: %w", err)
+	}
+	if err := Migrate(database); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return &PostgresVoteData{voteLinks: newVoteLinks(services), db: database}, nil
+}
+
+// voteWriteError turns constraint violations into ErrReferenceNotFound
+// and ErrAlreadyVoted
+func voteWriteError(err error) error {
+	var postgresError *pq.Error
+	if !errors.As(err, &postgresError) {
+		return err
+	}
+	switch {
+	case postgresError.Code == postgresForeignKeyViolation:
+		return fmt.Errorf("%w: %s", ErrReferenceNotFound, postgresError.Detail)
+	case postgresError.Code == postgresUniqueViolation && postgresError.Constraint == "votes_one_per_voter_and_poll":
+		return ErrAlreadyVoted
+	}
+	return err
+}
+
+const voteColumns = `vote_id, voter_id, poll_id, poll_option_id, vote_date`
+
+func (v *PostgresVoteData) scanVote(row interface{ Scan(...interface{}) error }) (Vote, error) {
+	var keys VoteKeys
+	var voteDate time.Time
+	if err := row.Scan(&keys.VoteID, &keys.VoterID, &keys.PollID, &keys.PollOptionID, &voteDate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Vote{}, ErrNotFound
+		}
+		return Vote{}, err
+	}
+
+	vote, _ := v.NewVote(keys.VoteID, keys.VoterID, keys.PollID, keys.PollOptionID)
+	vote.VoteDate = voteDate
+	return *vote, nil
+}
+
+func (v *PostgresVoteData) GetAllVotes(ctx context.Context) ([]Vote, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT `+voteColumns+` FROM votes ORDER BY vote_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []Vote
+	for rows.Next() {
+		vote, err := v.scanVote(rows)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
+}
+
+func (v *PostgresVoteData) GetVote(ctx context.Context, voteID uint) (Vote, error) {
+	return v.scanVote(v.db.QueryRowContext(ctx, `SELECT `+voteColumns+` FROM votes WHERE vote_id = $1`, voteID))
+}
+
+// addRevision appends the vote's choice to its revisions. The vote's row
+// was written in tx, which keeps concurrent writes from numbering two
+// revisions alike.
+func addRevision(ctx context.Context, tx *sql.Tx, voteID uint, keys VoteKeys, date time.Time, source string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO vote_revisions (vote_id, revision, poll_id, poll_option_id, revised_at, source)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5 FROM vote_revisions WHERE vote_id = $1`,
+		voteID, keys.PollID, keys.PollOptionID, date, source)
+	return err
+}
+
+func (v *PostgresVoteData) AddVote(ctx context.Context, voteKeys VoteKeys) error {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	voteDate := time.Now()
+	result, err := tx.ExecContext(ctx, `INSERT INTO votes (`+voteColumns+`) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (vote_id) DO NOTHING`,
+		voteKeys.VoteID, voteKeys.VoterID, voteKeys.PollID, voteKeys.PollOptionID, voteDate)
+	if err != nil {
+		return voteWriteError(err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return ErrVoteExists
+	}
+	if err := addRevision(ctx, tx, voteKeys.VoteID, voteKeys, voteDate, RevisionCast); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateVote recasts the vote, which keeps the date it was cast. The vote's
+// row is locked while it is read, checked against the revote policies and
+// written, and the vote is returned as it was before.
+func (v *PostgresVoteData) UpdateVote(ctx context.Context, voteID uint, updateData VoteKeys) (Vote, error) {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Vote{}, err
+	}
+	defer tx.Rollback()
+
+	existingVote, err := v.scanVote(tx.QueryRowContext(ctx, `SELECT `+voteColumns+` FROM votes WHERE vote_id = $1 FOR UPDATE`, voteID))
+	if errors.Is(err, ErrNotFound) {
+		return Vote{}, errors.New("Item does not exist")
+	}
+	if err != nil {
+		return Vote{}, err
+	}
+	if err := v.checkRevote(ctx, tx, existingVote, updateData.PollID); err != nil {
+		return Vote{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE votes SET voter_id = $2, poll_id = $3, poll_option_id = $4
+		WHERE vote_id = $1`,
+		voteID, updateData.VoterID, updateData.PollID, updateData.PollOptionID)
+	if err != nil {
+		return Vote{}, voteWriteError(err)
+	}
+	if err := addRevision(ctx, tx, voteID, updateData, time.Now(), RevisionUpdate); err != nil {
+		return Vote{}, err
+	}
+	return existingVote, tx.Commit()
+}
+
+// PatchVote locks the vote's row for the length of a transaction, so
+// concurrent writes are never lost
+func (v *PostgresVoteData) PatchVote(ctx context.Context, voteID uint, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (Vote, error) {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Vote{}, err
+	}
+	defer tx.Rollback()
+
+	vote, err := v.scanVote(tx.QueryRowContext(ctx, `SELECT `+voteColumns+` FROM votes WHERE vote_id = $1 FOR UPDATE`, voteID))
+	if err != nil {
+		return Vote{}, err
+	}
+
+	patchedVote, err := v.patchVote(vote, patch, check)
+	if err != nil {
+		return Vote{}, err
+	}
+	patchedKeys, err := VoteKeysFromVote(*patchedVote)
+	if err != nil {
+		return Vote{}, err
+	}
+	if err := v.checkRevote(ctx, tx, vote, patchedKeys.PollID); err != nil {
+		return Vote{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE votes SET voter_id = $2, poll_id = $3, poll_option_id = $4
+		WHERE vote_id = $1`,
+		voteID, patchedKeys.VoterID, patchedKeys.PollID, patchedKeys.PollOptionID)
+	if err != nil {
+		return Vote{}, voteWriteError(err)
+	}
+	if err := addRevision(ctx, tx, voteID, patchedKeys, time.Now(), RevisionPatch); err != nil {
+		return Vote{}, err
+	}
+	return *patchedVote, tx.Commit()
+}
+
+// DeleteVote removes the vote and returns it as it was
+func (v *PostgresVoteData) DeleteVote(ctx context.Context, voteID uint) (Vote, error) {
+	vote, err := v.scanVote(v.db.QueryRowContext(ctx, `DELETE FROM votes WHERE vote_id = $1 RETURNING `+voteColumns, voteID))
+	if errors.Is(err, ErrNotFound) {
+		return Vote{}, errors.New("Attempted to delete a non-existent vote")
+	}
+	return vote, err
+}
+
+// GetTurnout reads the poll's hourly buckets, which a trigger on votes
+// keeps in poll_turnout_hourly. Every vote is its voter's only one in the
+// poll, so each vote is a new voter.
+func (v *PostgresVoteData) GetTurnout(ctx context.Context, pollID uint) ([]TurnoutBucket, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT hour, votes FROM poll_turnout_hourly
+		WHERE poll_id = $1 AND votes > 0 ORDER BY hour`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []TurnoutBucket{}
+	for rows.Next() {
+		var bucket TurnoutBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Votes); err != nil {
+			return nil, err
+		}
+		bucket.Start = bucket.Start.UTC()
+		bucket.NewVoters = bucket.Votes
+		hours = append(hours, bucket)
+	}
+	return hours, rows.Err()
+}
+
+// GetParticipation reads the voter's votes through the index of the one
+// vote per voter and poll constraint
+func (v *PostgresVoteData) GetParticipation(ctx context.Context, voterID uint) ([]PollParticipation, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT poll_id, vote_id, vote_date FROM votes WHERE voter_id = $1`, voterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := voterPolls{}
+	for rows.Next() {
+		var pollID uint
+		var vote participationVote
+		if err := rows.Scan(&pollID, &vote.VoteID, &vote.VoteDate); err != nil {
+			return nil, err
+		}
+		polls[pollID] = append(polls[pollID], vote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return participationOf(polls), nil
+}
+
+func (v *PostgresVoteData) GetVoteHistory(ctx context.Context, voteID uint) (VoteHistory, error) {
+	rows, err := v.db.QueryContext(ctx, `SELECT poll_id, poll_option_id, revised_at, source FROM vote_revisions
+		WHERE vote_id = $1 ORDER BY revision`, voteID)
+	if err != nil {
+		return VoteHistory{}, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var revision Revision
+		if err := rows.Scan(&revision.PollID, &revision.PollOptionID, &revision.Date, &revision.Source); err != nil {
+			return VoteHistory{}, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return VoteHistory{}, err
+	}
+	if len(revisions) == 0 {
+		return VoteHistory{}, ErrNotFound
+	}
+	return historyOf(voteID, revisions), nil
+}
+
+const revotePolicyQuery = `SELECT mode, closes_at, window_minutes FROM revote_policies WHERE poll_id = $1`
+
+func scanRevotePolicy(row *sql.Row) (RevotePolicy, error) {
+	var policy RevotePolicy
+	var closesAt sql.NullTime
+	err := row.Scan(&policy.Mode, &closesAt, &policy.WindowMinutes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultRevotePolicy, nil
+	}
+	if err != nil {
+		return RevotePolicy{}, err
+	}
+	if closesAt.Valid {
+		policy.ClosesAt = &closesAt.Time
+	}
+	return policy, nil
+}
+
+func (v *PostgresVoteData) GetRevotePolicy(ctx context.Context, pollID uint) (RevotePolicy, error) {
+	return scanRevotePolicy(v.db.QueryRowContext(ctx, revotePolicyQuery, pollID))
+}
+
+// checkRevote checks changing existing, whose row tx holds, to a vote in
+// pollID. The rows of the polls whose policies are read stay locked until
+// tx ends, so SetRevotePolicy cannot change them, or add one to a poll
+// that had none, before the vote is written.
+func (v *PostgresVoteData) checkRevote(ctx context.Context, tx *sql.Tx, existing Vote, pollID uint) error {
+	existingKeys, err := VoteKeysFromVote(existing)
+	if err != nil {
+		return err
+	}
+	var castDate time.Time
+	err = tx.QueryRowContext(ctx, `SELECT revised_at FROM vote_revisions WHERE vote_id = $1 ORDER BY revision LIMIT 1`,
+		existingKeys.VoteID).Scan(&castDate)
+	if err != nil {
+		return err
+	}
+	return checkRevote(castDate, existingKeys.PollID, pollID, func(pollID uint) (RevotePolicy, error) {
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM polls WHERE poll_id = $1 FOR SHARE`, pollID); err != nil {
+			return RevotePolicy{}, err
+		}
+		return scanRevotePolicy(tx.QueryRowContext(ctx, revotePolicyQuery, pollID))
+	})
+}
+
+// SetRevotePolicy returns ErrReferenceNotFound for a poll that does not
+// exist. It locks the poll's row against the vote writes checking the
+// policy, so each sees the policy from before or after the change.
+func (v *PostgresVoteData) SetRevotePolicy(ctx context.Context, pollID uint, policy RevotePolicy) error {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lockedID uint
+	err = tx.QueryRowContext(ctx, `SELECT poll_id FROM polls WHERE poll_id = $1 FOR NO KEY UPDATE`, pollID).Scan(&lockedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: poll %d does not exist", ErrReferenceNotFound, pollID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO revote_policies (poll_id, mode, closes_at, window_minutes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (poll_id) DO UPDATE SET mode = $2, closes_at = $3, window_minutes = $4`,
+		pollID, policy.Mode, policy.ClosesAt, policy.WindowMinutes)
+	if err != nil {
+		return voteWriteError(err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startPostgres runs a throwaway postgres cluster for one test and returns
+// its address. initdb and postgres are looked up in POSTGRES_BIN, for
+// example /usr/lib/postgresql/16/bin, and then on the PATH. The test is
+// skipped when they are not installed.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	binaries := map[string]string{}
+	for _, name := range []string{"initdb", "postgres"} {
+		if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+			binaries[name] = filepath.Join(dir, name)
+		} else if path, err := exec.LookPath(name); err == nil {
+			binaries[name] = path
+		} else {
+			t.Skip("postgres is not installed, set POSTGRES_BIN to the directory holding initdb and postgres")
+		}
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres refuses to run as root")
+	}
+
+	dataDir := t.TempDir()
+	initdb := exec.Command(binaries["initdb"], "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, output)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binaries["postgres"], "-D", dataDir, "-p", fmt.Sprint(port),
+		"-c", "listen_addresses=127.0.0.1", "-c", "unix_socket_directories="+dataDir, "-c", "fsync=off")
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Signal(os.Interrupt)
+		server.Wait()
+	})
+
+	location := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for database.Ping() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("postgres did not start")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return location
+}
+
+// newTestPostgres seeds voters 1 and 2 and poll 1 with options 1 and 2,
+// as the voter and poll apis would
+func newTestPostgres(t *testing.T) *PostgresVoteData {
+	t.Helper()
+	services := DefaultServices()
+	services.VotersURL = "voters.test"
+	services.PollsURL = "polls.test"
+	votes, err := NewPostgres(startPostgres(t), services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { votes.db.Close() })
+
+	_, err = votes.db.Exec(`INSERT INTO voters (voter_id, first_name) VALUES (1, 'Ada'), (2, 'Grace');
+		INSERT INTO polls (poll_id, poll_title) VALUES (1, 'Lunch');
+		INSERT INTO poll_options (poll_id, poll_option_id, poll_option_text, position)
+			VALUES (1, 1, 'Soup', 0), (1, 2, 'Salad', 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return votes
+}
+
+func TestPostgresVotes(t *testing.T) {
+	votes := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	vote, err := votes.GetVote(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vote.Voter != "http://voters.test/voters/1" || vote.Poll != "http://polls.test/polls/1" ||
+		vote.PollOption != "http://polls.test/polls/1/polloption/2" {
+		t.Errorf("unexpected links %+v", vote)
+	}
+	if vote.VoteDate.IsZero() {
+		t.Error("vote has no date")
+	}
+
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 2, PollID: 1, PollOptionID: 1}); err == nil || errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("adding a vote id twice should fail as a duplicate vote, got %v", err)
+	}
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 2, VoterID: 1, PollID: 1, PollOptionID: 1}); !errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("a second vote in the same poll should fail with ErrAlreadyVoted, got %v", err)
+	}
+	for _, keys := range []VoteKeys{
+		{VoteID: 3, VoterID: 9, PollID: 1, PollOptionID: 1},
+		{VoteID: 3, VoterID: 2, PollID: 9, PollOptionID: 1},
+		{VoteID: 3, VoterID: 2, PollID: 1, PollOptionID: 9},
+	} {
+		if err := votes.AddVote(ctx, keys); !errors.Is(err, ErrReferenceNotFound) {
+			t.Errorf("%+v should fail with ErrReferenceNotFound, got %v", keys, err)
+		}
+	}
+
+	allow := func(VoteKeys, *VoteKeys) error { return nil }
+	patched, err := votes.PatchVote(ctx, 1, MergePatch(`{"PollOptionID":1}`), allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.PollOption != "http://polls.test/polls/1/polloption/1" {
+		t.Errorf("patch did not move the vote: %+v", patched)
+	}
+	if stored, err := votes.GetVote(ctx, 1); err != nil || !stored.VoteDate.Equal(vote.VoteDate) || !patched.VoteDate.Equal(vote.VoteDate) {
+		t.Errorf("the patch moved the vote's date from %s: %+v %v", vote.VoteDate, stored, err)
+	}
+	if _, err := votes.PatchVote(ctx, 1, MergePatch(`{"PollOptionID":9}`), allow); !errors.Is(err, ErrReferenceNotFound) {
+		t.Errorf("patching to a missing option should fail with ErrReferenceNotFound, got %v", err)
+	}
+
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 2, VoterID: 2, PollID: 1, PollOptionID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votes.UpdateVote(ctx, 2, VoteKeys{VoteID: 2, VoterID: 1, PollID: 1, PollOptionID: 2}); !errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("recasting a vote as a voter who voted should fail with ErrAlreadyVoted, got %v", err)
+	}
+
+	all, err := votes.GetAllVotes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].VoteID != 1 || all[1].VoteID != 2 {
+		t.Errorf("unexpected votes %+v", all)
+	}
+
+	if _, err := votes.DeleteVote(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votes.GetVote(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestPostgresVoteHistory(t *testing.T) {
+	votes := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votes.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	allow := func(VoteKeys, *VoteKeys) error { return nil }
+	if _, err := votes.PatchVote(ctx, 1, MergePatch(`{"PollOptionID":1}`), allow); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := votes.GetVoteHistory(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := []string{}
+	for _, revision := range history.Revisions {
+		sources = append(sources, revision.Source)
+	}
+	if len(history.Revisions) != 3 || history.Revisions[1].PollOptionID != 2 ||
+		strings.Join(sources, ",") != "cast,update,patch" || !history.CastDate.Equal(history.Revisions[0].Date) {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	if err := votes.SetRevotePolicy(ctx, 1, RevotePolicy{Mode: RevoteWindow, WindowMinutes: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if policy, err := votes.GetRevotePolicy(ctx, 1); err != nil || policy.Mode != RevoteWindow || policy.WindowMinutes != 5 || policy.ClosesAt != nil {
+		t.Errorf("policy not kept, got %+v %v", policy, err)
+	}
+	if err := votes.SetRevotePolicy(ctx, 9, RevotePolicy{Mode: RevoteForbid}); !errors.Is(err, ErrReferenceNotFound) {
+		t.Errorf("a policy for a missing poll should fail with ErrReferenceNotFound, got %v", err)
+	}
+	if err := votes.SetRevotePolicy(ctx, 1, RevotePolicy{Mode: RevoteForbid}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votes.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 2}); !errors.Is(err, ErrRevoteNotAllowed) {
+		t.Errorf("expected ErrRevoteNotAllowed updating a vote in a poll that forbids changes, got %v", err)
+	}
+	if _, err := votes.PatchVote(ctx, 1, MergePatch(`{"PollOptionID":2}`), allow); !errors.Is(err, ErrRevoteNotAllowed) {
+		t.Errorf("expected ErrRevoteNotAllowed patching a vote in a poll that forbids changes, got %v", err)
+	}
+
+	if _, err := votes.DeleteVote(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votes.GetVoteHistory(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+// A policy set on a poll without one waits for a vote change that has
+// already checked the poll's policy, rather than slipping in before it is
+// written
+func TestPostgresRevotePolicyWaitsForVoteChange(t *testing.T) {
+	votes := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := votes.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	vote, err := votes.GetVote(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := votes.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := votes.checkRevote(ctx, tx, vote, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	set := make(chan error, 1)
+	go func() { set <- votes.SetRevotePolicy(ctx, 1, RevotePolicy{Mode: RevoteForbid}) }()
+	select {
+	case err := <-set:
+		t.Fatalf("the policy was set while a vote change had checked it: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-set; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,312 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/redisclient"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The analytics keys share the {votes} hash tag, so that under Redis
+// Cluster they live in one slot and a write updates them in one
+// transaction
+const (
+	RedisAnalyticsKeyPrefix = "analytics:{votes}:"
+	// Set once the aggregates have been built from the votes
+	redisAnalyticsBuiltKey = RedisAnalyticsKeyPrefix + "built"
+	// Counts the vote writes made while the aggregates are not built, so
+	// that a build which missed one starts over
+	redisAnalyticsWritesKey = RedisAnalyticsKeyPrefix + "writes"
+)
+
+// Fields of a poll's turnout hash, followed by the hour's unix time
+const (
+	turnoutVotesField     = "votes:"
+	turnoutNewVotersField = "new_voters:"
+)
+
+func redisParticipationKey(voterID uint) string {
+	return fmt.Sprintf("%svoter:%d", RedisAnalyticsKeyPrefix, voterID)
+}
+
+func redisTurnoutKey(pollID uint) string {
+	return fmt.Sprintf("%spoll:%d", RedisAnalyticsKeyPrefix, pollID)
+}
+
+// recordWrite brings the aggregates up to date after vote voteID was
+// written, before holding what it was, nil for a new vote. The vote is read
+// again, so that of two writes to one vote recorded out of order the last
+// one recorded leaves the aggregates right. If they cannot be updated they
+// are dropped and rebuilt when next read.
+func (v *RedisVoteData) recordWrite(ctx context.Context, voteID uint, before *Vote) error {
+	err := v.updateAggregates(ctx, voteID, before)
+	if err == nil {
+		return nil
+	}
+	if dropErr := DropRedisAnalytics(ctx, v.cacheClient); dropErr != nil {
+		return fmt.Errorf("Error updating vote analytics: %w", err)
+	}
+	return nil
+}
+
+// DropRedisAnalytics has the aggregates rebuilt from the votes when next
+// read, for tools that write votes to redis directly
+func DropRedisAnalytics(ctx context.Context, client redis.UniversalClient) error {
+	return client.Del(ctx, redisAnalyticsBuiltKey).Err()
+}
+
+func (v *RedisVoteData) updateAggregates(ctx context.Context, voteID uint, before *Vote) error {
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		current, err := v.currentVote(ctx, voteID)
+		if err != nil {
+			return err
+		}
+		voterIDs, err := votersOf(before, current)
+		if err != nil {
+			return err
+		}
+		keys := []string{redisAnalyticsBuiltKey}
+		for _, voterID := range voterIDs {
+			keys = append(keys, redisParticipationKey(voterID))
+		}
+
+		err = v.cacheClient.Watch(ctx, func(tx *redis.Tx) error {
+			built, err := tx.Exists(ctx, redisAnalyticsBuiltKey).Result()
+			if err != nil {
+				return err
+			}
+			if built == 0 {
+				// Not built yet, the votes are read when they are. A build
+				// running now may have read them before this write.
+				_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.Incr(ctx, redisAnalyticsWritesKey)
+					return nil
+				})
+				return err
+			}
+			recheck, err := v.currentVote(ctx, voteID)
+			if err != nil {
+				return err
+			}
+			if !sameVoter(current, recheck) {
+				return redis.TxFailedErr
+			}
+
+			participation := map[uint]voterPolls{}
+			for _, voterID := range voterIDs {
+				document, err := tx.Get(ctx, redisParticipationKey(voterID)).Bytes()
+				if errors.Is(err, redis.Nil) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				polls := voterPolls{}
+				if err := json.Unmarshal(document, &polls); err != nil {
+					return err
+				}
+				participation[voterID] = polls
+			}
+			changes, err := applyVoteWrite(participation, before, recheck)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return v.saveAggregates(ctx, pipe, voterIDs, participation, changes)
+			})
+			return err
+		}, keys...)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return err
+	}
+	return ErrPatchConflict
+}
+
+// currentVote reads a vote, nil if it does not exist
+func (v *RedisVoteData) currentVote(ctx context.Context, voteID uint) (*Vote, error) {
+	var vote Vote
+	if err := v.getVoteFromRedis(ctx, redisVoteKeyFromId(int(voteID)), &vote); err != nil {
+		if isRedisNilError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &vote, nil
+}
+
+func sameVoter(a *Vote, b *Vote) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Voter == b.Voter
+}
+
+// saveAggregates queues the participation of voterIDs and the turnout
+// changes on pipe
+func (v *RedisVoteData) saveAggregates(ctx context.Context, pipe redis.Pipeliner, voterIDs []uint,
+	participation map[uint]voterPolls, changes []turnoutChange) error {
+	for _, voterID := range voterIDs {
+		polls, ok := participation[voterID]
+		if !ok {
+			pipe.Del(ctx, redisParticipationKey(voterID))
+			continue
+		}
+		document, err := json.Marshal(polls)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, redisParticipationKey(voterID), document, 0)
+	}
+	for _, change := range changes {
+		hour := strconv.FormatInt(change.Hour.Unix(), 10)
+		if change.Votes != 0 {
+			pipe.HIncrBy(ctx, redisTurnoutKey(change.PollID), turnoutVotesField+hour, int64(change.Votes))
+		}
+		if change.NewVoters != 0 {
+			pipe.HIncrBy(ctx, redisTurnoutKey(change.PollID), turnoutNewVotersField+hour, int64(change.NewVoters))
+		}
+	}
+	return nil
+}
+
+// buildAggregates computes the aggregates from every vote, when they were
+// never built or were dropped. The build watches the writes counter, so a
+// vote written while the votes are read fails it and it starts over.
+func (v *RedisVoteData) buildAggregates(ctx context.Context) error {
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		err := v.cacheClient.Watch(ctx, func(tx *redis.Tx) error {
+			built, err := tx.Exists(ctx, redisAnalyticsBuiltKey).Result()
+			if err != nil || built > 0 {
+				return err
+			}
+			return v.saveBuiltAggregates(ctx, tx)
+		}, redisAnalyticsBuiltKey, redisAnalyticsWritesKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return err
+	}
+	return ErrPatchConflict
+}
+
+// saveBuiltAggregates replaces whatever aggregates are left with those of
+// the votes and marks them built, in one transaction of tx
+func (v *RedisVoteData) saveBuiltAggregates(ctx context.Context, tx *redis.Tx) error {
+	votes, err := v.GetAllVotes(ctx)
+	if err != nil {
+		return err
+	}
+	participation := map[uint]voterPolls{}
+	var changes []turnoutChange
+	for i := range votes {
+		voteChanges, err := applyVoteWrite(participation, nil, &votes[i])
+		if err != nil {
+			return err
+		}
+		changes = append(changes, voteChanges...)
+	}
+	voterIDs := make([]uint, 0, len(participation))
+	for voterID := range participation {
+		voterIDs = append(voterIDs, voterID)
+	}
+
+	var staleKeys []string
+	err = redisclient.ForEachNode(ctx, v.cacheClient, func(ctx context.Context, node redis.Cmdable) error {
+		nodeKeys, err := node.Keys(ctx, RedisAnalyticsKeyPrefix+"*").Result()
+		staleKeys = append(staleKeys, nodeKeys...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(staleKeys) > 0 {
+			pipe.Del(ctx, staleKeys...)
+		}
+		if err := v.saveAggregates(ctx, pipe, voterIDs, participation, changes); err != nil {
+			return err
+		}
+		pipe.Set(ctx, redisAnalyticsBuiltKey, time.Now().UTC().Format(time.RFC3339), 0)
+		return nil
+	})
+	return err
+}
+
+// GetTurnout reads the poll's turnout hash, building the aggregates first
+// if they are missing
+func (v *RedisVoteData) GetTurnout(ctx context.Context, pollID uint) ([]TurnoutBucket, error) {
+	if err := v.buildAggregates(ctx); err != nil {
+		return nil, err
+	}
+	fields, err := v.cacheClient.HGetAll(ctx, redisTurnoutKey(pollID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byHour := map[int64]*TurnoutBucket{}
+	for field, value := range fields {
+		name, hour := turnoutVotesField, strings.TrimPrefix(field, turnoutVotesField)
+		if hour == field {
+			name, hour = turnoutNewVotersField, strings.TrimPrefix(field, turnoutNewVotersField)
+		}
+		unix, err := strconv.ParseInt(hour, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading turnout field %s: %w", field, err)
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading turnout field %s: %w", field, err)
+		}
+		if count < 0 {
+			count = 0
+		}
+		bucket := byHour[unix]
+		if bucket == nil {
+			bucket = &TurnoutBucket{Start: time.Unix(unix, 0).UTC()}
+			byHour[unix] = bucket
+		}
+		if name == turnoutVotesField {
+			bucket.Votes = uint(count)
+		} else {
+			bucket.NewVoters = uint(count)
+		}
+	}
+
+	hours := []TurnoutBucket{}
+	for _, bucket := range byHour {
+		if bucket.Votes > 0 {
+			hours = append(hours, *bucket)
+		}
+	}
+	sortBuckets(hours)
+	return hours, nil
+}
+
+func (v *RedisVoteData) GetParticipation(ctx context.Context, voterID uint) ([]PollParticipation, error) {
+	if err := v.buildAggregates(ctx); err != nil {
+		return nil, err
+	}
+	document, err := v.cacheClient.Get(ctx, redisParticipationKey(voterID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return []PollParticipation{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	polls := voterPolls{}
+	if err := json.Unmarshal(document, &polls); err != nil {
+		return nil, err
+	}
+	return participationOf(polls), nil
+}
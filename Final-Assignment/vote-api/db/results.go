@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"votes-api/client/pollclient"
+)
+
+type OptionTally struct {
+	PollOptionID uint
+	Votes        uint
+}
+
+// PollResults counts the votes cast in one poll. Options nobody voted for
+// are left out because votes do not know a poll's full option list.
+type PollResults struct {
+	PollID     uint
+	Tallies    []OptionTally
+	TotalVotes uint
+	// How many different voters cast the votes
+	Voters uint
+	// When the first and last votes were cast, zero without votes
+	FirstVoteDate time.Time
+	LastVoteDate  time.Time
+}
+
+// PollReport adds what the vote api has to ask the other services for:
+// the poll with its full option list, and the size of the voter roll
+type PollReport struct {
+	Poll             pollclient.Poll
+	Results          PollResults
+	Votes            []Vote
+	RegisteredVoters uint
+}
+
+func (v *VoteData) GetPollResults(ctx context.Context, pollID uint) (PollResults, error) {
+	votes, err := v.GetPollVotes(ctx, pollID)
+	if err != nil {
+		return PollResults{}, err
+	}
+	return TallyVotes(pollID, votes)
+}
+
+// GetPollVotes lists the votes cast in one poll, oldest first
+func (v *VoteData) GetPollVotes(ctx context.Context, pollID uint) ([]Vote, error) {
+	votes, err := v.GetAllVotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pollVotes := []Vote{}
+	for _, vote := range votes {
+		keys, err := VoteKeysFromVote(vote)
+		if err != nil {
+			return nil, err
+		}
+		if keys.PollID == pollID {
+			pollVotes = append(pollVotes, vote)
+		}
+	}
+	sort.Slice(pollVotes, func(i, j int) bool {
+		if pollVotes[i].VoteDate.Equal(pollVotes[j].VoteDate) {
+			return pollVotes[i].VoteID < pollVotes[j].VoteID
+		}
+		return pollVotes[i].VoteDate.Before(pollVotes[j].VoteDate)
+	})
+	return pollVotes, nil
+}
+
+// TallyVotes is the one place results are counted; the JSON, CSV, HTML
+// and gRPC forms of the results all come from it. Votes of other polls
+// are ignored.
+func TallyVotes(pollID uint, votes []Vote) (PollResults, error) {
+	counts := map[uint]uint{}
+	voters := map[uint]bool{}
+	results := PollResults{PollID: pollID, Tallies: []OptionTally{}}
+	for _, vote := range votes {
+		keys, err := VoteKeysFromVote(vote)
+		if err != nil {
+			return PollResults{}, err
+		}
+		if keys.PollID != pollID {
+			continue
+		}
+		counts[keys.PollOptionID]++
+		voters[keys.VoterID] = true
+		results.TotalVotes++
+
+		if results.FirstVoteDate.IsZero() || vote.VoteDate.Before(results.FirstVoteDate) {
+			results.FirstVoteDate = vote.VoteDate
+		}
+		if vote.VoteDate.After(results.LastVoteDate) {
+			results.LastVoteDate = vote.VoteDate
+		}
+	}
+	results.Voters = uint(len(voters))
+
+	for pollOptionID, count := range counts {
+		results.Tallies = append(results.Tallies, OptionTally{PollOptionID: pollOptionID, Votes: count})
+	}
+	sort.Slice(results.Tallies, func(i, j int) bool {
+		return results.Tallies[i].PollOptionID < results.Tallies[j].PollOptionID
+	})
+	return results, nil
+}
+
+// GetPollReport tallies a poll and looks up the poll and the voter roll
+// with the caller's credentials, forwarded in header
+func (v *VoteData) GetPollReport(ctx context.Context, pollID uint, header http.Header) (PollReport, error) {
+	poll, err := v.details.GetPoll(ctx, pollID, header)
+	if err != nil {
+		return PollReport{}, errors.New("Error: could not get poll details: " + err.Error())
+	}
+
+	registeredVoters, err := v.details.CountVoters(ctx, header)
+	if err != nil {
+		return PollReport{}, errors.New("Error: could not count voters: " + err.Error())
+	}
+
+	votes, err := v.GetPollVotes(ctx, pollID)
+	if err != nil {
+		return PollReport{}, err
+	}
+	results, err := TallyVotes(pollID, votes)
+	if err != nil {
+		return PollReport{}, err
+	}
+
+	return PollReport{
+		Poll:             poll,
+		Results:          results,
+		Votes:            votes,
+		RegisteredVoters: registeredVoters,
+	}, nil
+}
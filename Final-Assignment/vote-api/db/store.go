@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The storage backends, picked with STORAGE_BACKEND or storage.backend
+const (
+	RedisBackend    = "redis"
+	MemoryBackend   = "memory"
+	PostgresBackend = "postgres"
+)
+
+// Returned by stores that keep voters and polls alongside votes, when a
+// vote names a voter, poll or option that does not exist, or a voter votes
+// twice in one poll
+var (
+	ErrReferenceNotFound = errors.New("vote refers to a voter, poll or poll option that does not exist")
+	ErrAlreadyVoted      = errors.New("voter has already voted in this poll")
+)
+
+// ErrVoteExists is returned when a vote is cast with an id already taken
+var ErrVoteExists = errors.New("item already exists")
+
+// VoteStore is where votes are kept. RedisVoteData (RedisJSON),
+// MemoryVoteData and PostgresVoteData implement it. Each keeps the turnout
+// and participation aggregates and every vote's revisions up to date as
+// votes are written.
+type VoteStore interface {
+	GetAllVotes(ctx context.Context) ([]Vote, error)
+	GetVote(ctx context.Context, voteID uint) (Vote, error)
+	AddVote(ctx context.Context, voteKeys VoteKeys) error
+	// UpdateVote and DeleteVote return the vote as it was before the write,
+	// read in the same transaction as the write
+	UpdateVote(ctx context.Context, voteID uint, updateData VoteKeys) (Vote, error)
+	PatchVote(ctx context.Context, voteID uint, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (Vote, error)
+	DeleteVote(ctx context.Context, voteID uint) (Vote, error)
+	// GetTurnout lists the hours of a poll with votes, oldest first,
+	// without the running totals
+	GetTurnout(ctx context.Context, pollID uint) ([]TurnoutBucket, error)
+	GetParticipation(ctx context.Context, voterID uint) ([]PollParticipation, error)
+	// GetVoteHistory returns ErrNotFound for a vote that does not exist
+	GetVoteHistory(ctx context.Context, voteID uint) (VoteHistory, error)
+	// GetRevotePolicy returns DefaultRevotePolicy for a poll without one
+	GetRevotePolicy(ctx context.Context, pollID uint) (RevotePolicy, error)
+	SetRevotePolicy(ctx context.Context, pollID uint, policy RevotePolicy) error
+}
+
+var (
+	_ VoteStore = (*RedisVoteData)(nil)
+	_ VoteStore = (*MemoryVoteData)(nil)
+	_ VoteStore = (*PostgresVoteData)(nil)
+)
+
+// VoteData is what the REST and gRPC handlers work with: a VoteStore,
+// plus the voter and poll services the votes link to for details and
+// results
+type VoteData struct {
+	VoteStore
+	details detailSource
+	// Set by StartTimeline
+	timeline *Timeline
+}
+
+// Config picks the storage backend
+type Config struct {
+	Backend     string `config:"backend" env:"STORAGE_BACKEND" usage:"Storage backend: redis, memory or postgres"`
+	DatabaseURL string `config:"database_url" env:"DATABASE_URL" usage:"Postgres connection URL" secret:"url"`
+}
+
+func Defaults() Config {
+	return Config{Backend: RedisBackend, DatabaseURL: PostgresDefaultLocation}
+}
+
+// Validate checks the backend name
+func (config Config) Validate() error {
+	switch config.Backend {
+	case RedisBackend, MemoryBackend, PostgresBackend:
+		return nil
+	}
+	return fmt.Errorf("Error: STORAGE_BACKEND must be redis, memory or postgres, got %s", config.Backend)
+}
+
+// Services locates the voter and poll apis that votes link to and that
+// details are looked up from. The public URLs are what clients outside
+// the docker network use, falling back to the internal addresses.
+type Services struct {
+	VotersURL       string `config:"voters_url" env:"VOTERS_URL" usage:"host:port of the voter api"`
+	PollsURL        string `config:"polls_url" env:"POLLS_URL" usage:"host:port of the poll api"`
+	VotersPublicURL string `config:"voters_public_url" env:"VOTERS_PUBLIC_URL" usage:"Base URL of voter links in responses"`
+	PollsPublicURL  string `config:"polls_public_url" env:"POLLS_PUBLIC_URL" usage:"Base URL of poll links in responses"`
+	DetailTransport string `config:"detail_transport" env:"DETAIL_TRANSPORT" usage:"How ?detail=true looks up voters and polls: http or grpc"`
+	VotersGrpcURL   string `config:"voters_grpc_url" env:"VOTERS_GRPC_URL" usage:"host:port of the voter gRPC service"`
+	PollsGrpcURL    string `config:"polls_grpc_url" env:"POLLS_GRPC_URL" usage:"host:port of the poll gRPC service"`
+}
+
+func DefaultServices() Services {
+	return Services{
+		VotersURL:       VotersDefaultLocation,
+		PollsURL:        PollsDefaultLocation,
+		DetailTransport: DetailTransportHTTP,
+		VotersGrpcURL:   VotersGrpcDefaultLocation,
+		PollsGrpcURL:    PollsGrpcDefaultLocation,
+	}
+}
+
+// Validate checks the detail transport
+func (services Services) Validate() error {
+	switch services.DetailTransport {
+	case DetailTransportHTTP, DetailTransportGRPC:
+		return nil
+	}
+	return errors.New("Error: DETAIL_TRANSPORT must be http or grpc, got " + services.DetailTransport)
+}
+
+// New opens the store config names, "redis" (the default), "memory" or
+// "postgres". The redis store uses redisClient, which the other backends
+// ignore.
+func New(config Config, services Services, redisClient redis.UniversalClient) (*VoteData, error) {
+	var store VoteStore
+	switch config.Backend {
+	case "", RedisBackend:
+		store = NewWithClient(redisClient, services)
+
+	case MemoryBackend:
+		store = NewMemory(services)
+
+	case PostgresBackend:
+		databaseUrl := config.DatabaseURL
+		if databaseUrl == "" {
+			databaseUrl = PostgresDefaultLocation
+		}
+		votes, err := NewPostgres(databaseUrl, services)
+		if err != nil {
+			return nil, err
+		}
+		store = votes
+
+	default:
+		return nil, config.Validate()
+	}
+
+	return NewWithStore(store, services)
+}
+
+// NewWithStore looks up details for the votes in store through the
+// transport services names
+func NewWithStore(store VoteStore, services Services) (*VoteData, error) {
+	details, err := newDetailSource(services)
+	if err != nil {
+		return nil, err
+	}
+	return &VoteData{VoteStore: store, details: details}, nil
+}
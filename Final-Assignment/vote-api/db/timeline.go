@@ -0,0 +1,596 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/redisclient"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The timeline keeps a running count of the votes of each option of a poll
+// in RedisTimeSeries. A poll's series share the {poll:<id>} hash tag,
+// because under Redis Cluster a downsampling rule must stay in one slot.
+const (
+	RedisTimelineKeyPrefix = "timeline:"
+	// Set once the votes cast before the timeline was recorded are in it
+	redisTimelineBackfilledKey = RedisTimelineKeyPrefix + "backfilled"
+	// Held by the api that is backfilling, which renews it while it works
+	redisTimelineBackfillLockKey = RedisTimelineKeyPrefix + "backfill-lock"
+	backfillLockTTL              = time.Minute
+	// How often an api waiting for another's backfill checks on it
+	backfillWaitInterval = time.Second
+	// Most buckets one timeline may have
+	MaxTimelineBuckets = 1000
+)
+
+var (
+	ErrTimelineUnavailable = errors.New("the results timeline needs RedisTimeSeries, which is not loaded or not enabled")
+	ErrInvalidTimeline     = errors.New("invalid timeline range")
+)
+
+// TimelineConfig sets how long vote counts are kept. Downsampling rules
+// keep the count at the end of each of their buckets, for longer than the
+// raw counts.
+type TimelineConfig struct {
+	Enabled    bool          `config:"enabled" env:"TIMELINE_ENABLED" usage:"Record vote counts in RedisTimeSeries for the results timeline"`
+	Retention  time.Duration `config:"retention" env:"TIMELINE_RETENTION" usage:"How long raw vote counts are kept, 0 for ever"`
+	Downsample string        `config:"downsample" env:"TIMELINE_DOWNSAMPLE" usage:"Downsampling rules, comma separated <bucket>:<retention>, or off"`
+}
+
+func DefaultTimeline() TimelineConfig {
+	return TimelineConfig{Enabled: true, Retention: 7 * 24 * time.Hour, Downsample: "1h:2160h,24h:0"}
+}
+
+// DownsampleRule keeps the count at the end of each Bucket for Retention,
+// 0 for ever
+type DownsampleRule struct {
+	Bucket    time.Duration
+	Retention time.Duration
+}
+
+// ParseDownsampleRules reads rules like "1h:2160h,24h:0"
+func ParseDownsampleRules(spec string) ([]DownsampleRule, error) {
+	if spec == "" || spec == "off" {
+		return nil, nil
+	}
+	var rules []DownsampleRule
+	for _, part := range strings.Split(spec, ",") {
+		bucketSpec, retentionSpec, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			return nil, fmt.Errorf("Error: downsampling rule %q is not <bucket>:<retention>", part)
+		}
+		bucket, err := time.ParseDuration(bucketSpec)
+		if err != nil || bucket < time.Millisecond || bucket%time.Millisecond != 0 {
+			return nil, fmt.Errorf("Error: downsampling bucket %q must be a whole number of milliseconds", bucketSpec)
+		}
+		retention, err := time.ParseDuration(retentionSpec)
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("Error: downsampling retention %q must be a duration, 0 for ever", retentionSpec)
+		}
+		rules = append(rules, DownsampleRule{Bucket: bucket, Retention: retention})
+	}
+	return rules, nil
+}
+
+// Validate checks the retention and the downsampling rules
+func (config TimelineConfig) Validate() error {
+	if config.Retention < 0 {
+		return errors.New("Error: TIMELINE_RETENTION must not be negative")
+	}
+	if _, err := ParseDownsampleRules(config.Downsample); err != nil {
+		return fmt.Errorf("%w (TIMELINE_DOWNSAMPLE)", err)
+	}
+	return nil
+}
+
+// Timeline records and reads the vote count series
+type Timeline struct {
+	client    redis.UniversalClient
+	retention time.Duration
+	rules     []DownsampleRule
+	// Series this api has created, with their rules
+	created sync.Map
+}
+
+func NewTimeline(client redis.UniversalClient, config TimelineConfig) (*Timeline, error) {
+	rules, err := ParseDownsampleRules(config.Downsample)
+	if err != nil {
+		return nil, err
+	}
+	return &Timeline{client: client, retention: config.Retention, rules: rules}, nil
+}
+
+func timelineKey(pollID uint, pollOptionID uint) string {
+	return fmt.Sprintf("%s{poll:%d}:option:%d", RedisTimelineKeyPrefix, pollID, pollOptionID)
+}
+
+func downsampledKey(key string, rule DownsampleRule) string {
+	return key + ":" + strconv.FormatInt(rule.Bucket.Milliseconds(), 10)
+}
+
+func isTimeSeriesError(err error, message string) bool {
+	return err != nil && strings.Contains(err.Error(), message)
+}
+
+// Available reports whether redis has RedisTimeSeries loaded
+func (t *Timeline) Available(ctx context.Context) (bool, error) {
+	err := t.client.Do(ctx, "TS.INFO", RedisTimelineKeyPrefix+"probe").Err()
+	switch {
+	case err == nil, isTimeSeriesError(err, "TSDB"):
+		return true, nil
+	case isTimeSeriesError(err, "unknown command"):
+		return false, nil
+	}
+	return false, err
+}
+
+// ensure creates the series of an option and its downsampled series
+func (t *Timeline) ensure(ctx context.Context, pollID uint, pollOptionID uint) error {
+	key := timelineKey(pollID, pollOptionID)
+	if _, ok := t.created.Load(key); ok {
+		return nil
+	}
+
+	labels := []interface{}{"LABELS", "poll", pollID, "option", pollOptionID}
+	if err := t.create(ctx, key, t.retention, labels); err != nil {
+		return err
+	}
+	for _, rule := range t.rules {
+		bucket := rule.Bucket.Milliseconds()
+		destination := downsampledKey(key, rule)
+		if err := t.create(ctx, destination, rule.Retention, append(labels, "bucket", bucket)); err != nil {
+			return err
+		}
+		err := t.client.Do(ctx, "TS.CREATERULE", key, destination, "AGGREGATION", "last", bucket).Err()
+		if err != nil && !isTimeSeriesError(err, "already") {
+			return err
+		}
+	}
+	t.created.Store(key, true)
+	return nil
+}
+
+func (t *Timeline) create(ctx context.Context, key string, retention time.Duration, labels []interface{}) error {
+	args := append([]interface{}{"TS.CREATE", key, "RETENTION", retention.Milliseconds()}, labels...)
+	err := t.client.Do(ctx, args...).Err()
+	if err != nil && !isTimeSeriesError(err, "already exists") {
+		return err
+	}
+	return nil
+}
+
+// incrementSeries is TS.INCRBY on a series that exists. TS.INCRBY alone
+// would create a missing one without its retention and downsampling rules.
+var incrementSeries = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return redis.error_reply("TSDB: the key does not exist")
+end
+return redis.call("TS.INCRBY", KEYS[1], ARGV[1])
+`)
+
+// Record adds delta to an option's count, at redis' clock so that apis
+// with different clocks do not write out of order. A series another api's
+// backfill dropped since this one created it is created again.
+func (t *Timeline) Record(ctx context.Context, pollID uint, pollOptionID uint, delta int64) error {
+	key := timelineKey(pollID, pollOptionID)
+	if err := t.ensure(ctx, pollID, pollOptionID); err != nil {
+		return err
+	}
+	err := incrementSeries.Run(ctx, t.client, []string{key}, delta).Err()
+	if !isTimeSeriesError(err, "does not exist") {
+		return err
+	}
+	t.created.Delete(key)
+	if err := t.ensure(ctx, pollID, pollOptionID); err != nil {
+		return err
+	}
+	return incrementSeries.Run(ctx, t.client, []string{key}, delta).Err()
+}
+
+// Backfill counts the votes listVotes returns into new series at their
+// VoteDate, unless that was done before. Series left from before are
+// dropped first. While another api is backfilling it waits for it to
+// finish, or takes over if that api stops without finishing, so that no
+// vote is recorded into a series about to be dropped.
+func (t *Timeline) Backfill(ctx context.Context, listVotes func(ctx context.Context) ([]Vote, error)) error {
+	for {
+		done, err := t.client.Exists(ctx, redisTimelineBackfilledKey).Result()
+		if err != nil || done > 0 {
+			return err
+		}
+		locked, err := t.client.SetNX(ctx, redisTimelineBackfillLockKey, time.Now().UTC().Format(time.RFC3339), backfillLockTTL).Result()
+		if err != nil {
+			return err
+		}
+		if locked {
+			defer t.client.Del(context.WithoutCancel(ctx), redisTimelineBackfillLockKey)
+			return t.backfill(ctx, listVotes)
+		}
+
+		select {
+		case <-time.After(backfillWaitInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backfill does the work of Backfill while holding the lock
+func (t *Timeline) backfill(ctx context.Context, listVotes func(ctx context.Context) ([]Vote, error)) error {
+	votes, err := listVotes(ctx)
+	if err != nil {
+		return err
+	}
+
+	var staleKeys []string
+	err = redisclient.ForEachNode(ctx, t.client, func(ctx context.Context, node redis.Cmdable) error {
+		nodeKeys, err := node.Keys(ctx, RedisTimelineKeyPrefix+"{*").Result()
+		staleKeys = append(staleKeys, nodeKeys...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// One key at a time, since under Cluster they are in different slots
+	for _, key := range staleKeys {
+		if err := t.client.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+	}
+	t.created.Range(func(key, _ interface{}) bool {
+		t.created.Delete(key)
+		return true
+	})
+
+	sorted := append([]Vote(nil), votes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].VoteDate.Before(sorted[j].VoteDate) })
+
+	// One sample per series and millisecond, with the count after its votes
+	type backfillSample struct {
+		keys      VoteKeys
+		timestamp int64
+		count     int64
+	}
+	var samples []backfillSample
+	lastSample := map[string]int{}
+	counts := map[string]int64{}
+	for _, vote := range sorted {
+		keys, err := VoteKeysFromVote(vote)
+		if err != nil {
+			return err
+		}
+		key := timelineKey(keys.PollID, keys.PollOptionID)
+		counts[key]++
+		timestamp := vote.VoteDate.UnixMilli()
+		if i, ok := lastSample[key]; ok && samples[i].timestamp == timestamp {
+			samples[i].count = counts[key]
+			continue
+		}
+		lastSample[key] = len(samples)
+		samples = append(samples, backfillSample{keys: keys, timestamp: timestamp, count: counts[key]})
+	}
+
+	renewed := time.Now()
+	for _, sample := range samples {
+		if err := t.ensure(ctx, sample.keys.PollID, sample.keys.PollOptionID); err != nil {
+			return err
+		}
+		// TS.ADD, unlike TS.INCRBY, takes a sample older than the newest, and
+		// ON_DUPLICATE SUM keeps a write recorded in the same millisecond
+		err := t.client.Do(ctx, "TS.ADD", timelineKey(sample.keys.PollID, sample.keys.PollOptionID),
+			sample.timestamp, sample.count, "ON_DUPLICATE", "SUM").Err()
+		if err != nil {
+			return err
+		}
+
+		if time.Since(renewed) > backfillLockTTL/2 {
+			if err := t.client.Expire(ctx, redisTimelineBackfillLockKey, backfillLockTTL).Err(); err != nil {
+				return err
+			}
+			renewed = time.Now()
+		}
+	}
+	return t.client.Set(ctx, redisTimelineBackfilledKey, time.Now().UTC().Format(time.RFC3339), 0).Err()
+}
+
+// ResetTimeline has the next vote api to start count every vote into the
+// timeline again, for tools that write votes to redis directly
+func ResetTimeline(ctx context.Context, client redis.UniversalClient) error {
+	return client.Del(ctx, redisTimelineBackfilledKey).Err()
+}
+
+// source picks the series to read a range from: the raw counts while they
+// reach back to from, else the finest downsampling that does and whose
+// buckets fit in bucket. resolution is "raw" or the downsampling bucket.
+func (t *Timeline) source(from time.Time, bucket time.Duration) (rule *DownsampleRule, resolution string) {
+	reaches := func(retention time.Duration) bool {
+		return retention == 0 || time.Since(from) <= retention
+	}
+	if reaches(t.retention) {
+		return nil, "raw"
+	}
+	for i := range t.rules {
+		candidate := &t.rules[i]
+		if bucket%candidate.Bucket != 0 || !reaches(candidate.Retention) {
+			continue
+		}
+		if rule == nil || candidate.Bucket < rule.Bucket {
+			rule = candidate
+		}
+	}
+	if rule == nil {
+		return nil, "raw"
+	}
+	return rule, rule.Bucket.String()
+}
+
+// Counts reads how many votes an option had at the end of each of count
+// buckets from from. Buckets without a sample keep the count before them.
+func (t *Timeline) Counts(ctx context.Context, pollID uint, pollOptionID uint, from time.Time, bucket time.Duration, count int) ([]int64, string, error) {
+	rule, resolution := t.source(from, bucket)
+	key := timelineKey(pollID, pollOptionID)
+	if rule != nil {
+		key = downsampledKey(key, *rule)
+	}
+	fromMs, bucketMs := from.UnixMilli(), bucket.Milliseconds()
+	counts := make([]int64, count)
+
+	before, err := t.client.Do(ctx, "TS.REVRANGE", key, "-", fromMs-1, "COUNT", 1).Result()
+	if isTimeSeriesError(err, "does not exist") {
+		return counts, resolution, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	samples, err := timeSeriesSamples(before)
+	if err != nil {
+		return nil, "", err
+	}
+	var last int64
+	if len(samples) > 0 {
+		last = samples[0].value
+	}
+
+	toMs := fromMs + int64(count)*bucketMs - 1
+	reply, err := t.client.Do(ctx, "TS.RANGE", key, fromMs, toMs, "ALIGN", fromMs, "AGGREGATION", "last", bucketMs).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if samples, err = timeSeriesSamples(reply); err != nil {
+		return nil, "", err
+	}
+	byBucket := map[int64]int64{}
+	for _, sample := range samples {
+		byBucket[(sample.timestamp-fromMs)/bucketMs] = sample.value
+	}
+	for i := range counts {
+		if value, ok := byBucket[int64(i)]; ok {
+			last = value
+		}
+		counts[i] = last
+	}
+	return counts, resolution, nil
+}
+
+type timeSeriesSample struct {
+	timestamp int64
+	value     int64
+}
+
+// timeSeriesSamples reads the [[timestamp, "value"], ...] reply of TS.RANGE
+func timeSeriesSamples(reply interface{}) ([]timeSeriesSample, error) {
+	rows, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Error: unexpected TS.RANGE reply %v", reply)
+	}
+	samples := make([]timeSeriesSample, 0, len(rows))
+	for _, row := range rows {
+		pair, ok := row.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("Error: unexpected TS.RANGE sample %v", row)
+		}
+		timestamp, ok := pair[0].(int64)
+		if !ok {
+			return nil, fmt.Errorf("Error: unexpected TS.RANGE timestamp %v", pair[0])
+		}
+		value, err := strconv.ParseFloat(fmt.Sprint(pair[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error: unexpected TS.RANGE value %v", pair[1])
+		}
+		samples = append(samples, timeSeriesSample{timestamp: timestamp, value: int64(math.Round(value))})
+	}
+	return samples, nil
+}
+
+// timelineStore records every vote write it passes on to the store in the
+// timeline. A write is not failed when the timeline cannot be updated.
+type timelineStore struct {
+	VoteStore
+	timeline *Timeline
+}
+
+func (s *timelineStore) AddVote(ctx context.Context, voteKeys VoteKeys) error {
+	if err := s.VoteStore.AddVote(ctx, voteKeys); err != nil {
+		return err
+	}
+	s.record(ctx, nil, &voteKeys)
+	return nil
+}
+
+func (s *timelineStore) UpdateVote(ctx context.Context, voteID uint, updateData VoteKeys) (Vote, error) {
+	before, err := s.VoteStore.UpdateVote(ctx, voteID, updateData)
+	if err != nil {
+		return Vote{}, err
+	}
+	s.record(ctx, keysOf(before), &updateData)
+	return before, nil
+}
+
+func (s *timelineStore) PatchVote(ctx context.Context, voteID uint, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (Vote, error) {
+	var before *VoteKeys
+	vote, err := s.VoteStore.PatchVote(ctx, voteID, patch, func(existing VoteKeys, patched *VoteKeys) error {
+		before = &existing
+		return check(existing, patched)
+	})
+	if err != nil {
+		return Vote{}, err
+	}
+	if after, err := VoteKeysFromVote(vote); err == nil {
+		s.record(ctx, before, &after)
+	}
+	return vote, nil
+}
+
+func (s *timelineStore) DeleteVote(ctx context.Context, voteID uint) (Vote, error) {
+	before, err := s.VoteStore.DeleteVote(ctx, voteID)
+	if err != nil {
+		return Vote{}, err
+	}
+	s.record(ctx, keysOf(before), nil)
+	return before, nil
+}
+
+// keysOf is nil for a vote whose links cannot be read
+func keysOf(vote Vote) *VoteKeys {
+	keys, err := VoteKeysFromVote(vote)
+	if err != nil {
+		return nil
+	}
+	return &keys
+}
+
+// record moves a vote from the option of before to the option of after,
+// either of which is nil when the vote is cast or deleted
+func (s *timelineStore) record(ctx context.Context, before *VoteKeys, after *VoteKeys) {
+	if before != nil && after != nil && before.PollID == after.PollID && before.PollOptionID == after.PollOptionID {
+		return
+	}
+	var err error
+	if before != nil {
+		err = s.timeline.Record(ctx, before.PollID, before.PollOptionID, -1)
+	}
+	if after != nil && err == nil {
+		err = s.timeline.Record(ctx, after.PollID, after.PollOptionID, 1)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Error recording vote in the results timeline", "error", err)
+	}
+}
+
+// StartTimeline records vote writes in RedisTimeSeries from now on, after
+// counting in the votes cast before, which may mean waiting for another
+// api to do that. Without RedisTimeSeries the timeline stays off.
+func (v *VoteData) StartTimeline(ctx context.Context, client redis.UniversalClient, config TimelineConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	timeline, err := NewTimeline(client, config)
+	if err != nil {
+		return err
+	}
+	available, err := timeline.Available(ctx)
+	if err != nil {
+		return fmt.Errorf("Error probing for RedisTimeSeries: %w", err)
+	}
+	if !available {
+		slog.WarnContext(ctx, "RedisTimeSeries is not loaded, the results timeline is off")
+		return nil
+	}
+
+	if err := timeline.Backfill(ctx, v.GetAllVotes); err != nil {
+		return fmt.Errorf("Error backfilling the results timeline: %w", err)
+	}
+	v.VoteStore = &timelineStore{VoteStore: v.VoteStore, timeline: timeline}
+	v.timeline = timeline
+	return nil
+}
+
+// PollTimeline is how many votes each option of a poll had at the end of
+// each bucket, for charts
+type PollTimeline struct {
+	PollID    uint
+	PollTitle string
+	From      time.Time
+	To        time.Time
+	Bucket    string
+	// The series the counts were read from: raw or a downsampling bucket
+	Resolution string
+	// Start of each bucket
+	Buckets    []time.Time
+	Options    []OptionTimeline
+	TotalVotes []int64
+}
+
+// OptionTimeline has an option's count at the end of each bucket
+type OptionTimeline struct {
+	PollOptionID   uint
+	PollOptionText string
+	Votes          []int64
+}
+
+// GetPollTimeline reads the counts of every option of the poll, which is
+// looked up with the caller's credentials, forwarded in header
+func (v *VoteData) GetPollTimeline(ctx context.Context, pollID uint, from time.Time, to time.Time, bucket time.Duration, header http.Header) (PollTimeline, error) {
+	if v.timeline == nil {
+		return PollTimeline{}, ErrTimelineUnavailable
+	}
+	if !to.After(from) {
+		return PollTimeline{}, fmt.Errorf("%w: to must be after from", ErrInvalidTimeline)
+	}
+	if bucket < time.Millisecond || bucket%time.Millisecond != 0 {
+		return PollTimeline{}, fmt.Errorf("%w: bucket must be a whole number of milliseconds", ErrInvalidTimeline)
+	}
+	count := int((to.Sub(from) + bucket - 1) / bucket)
+	if count > MaxTimelineBuckets {
+		return PollTimeline{}, fmt.Errorf("%w: %d buckets, at most %d", ErrInvalidTimeline, count, MaxTimelineBuckets)
+	}
+
+	poll, err := v.details.GetPoll(ctx, pollID, header)
+	if err != nil {
+		return PollTimeline{}, errors.New("Error: could not get poll details: " + err.Error())
+	}
+
+	timeline := PollTimeline{
+		PollID:     pollID,
+		PollTitle:  poll.PollTitle,
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Bucket:     bucket.String(),
+		Buckets:    make([]time.Time, count),
+		Options:    []OptionTimeline{},
+		TotalVotes: make([]int64, count),
+	}
+	for i := range timeline.Buckets {
+		timeline.Buckets[i] = timeline.From.Add(time.Duration(i) * bucket)
+	}
+	for _, pollOption := range poll.PollOptions {
+		votes, resolution, err := v.timeline.Counts(ctx, pollID, pollOption.PollOptionID, from, bucket, count)
+		if err != nil {
+			return PollTimeline{}, err
+		}
+		timeline.Resolution = resolution
+		timeline.Options = append(timeline.Options, OptionTimeline{
+			PollOptionID:   pollOption.PollOptionID,
+			PollOptionText: pollOption.PollOptionText,
+			Votes:          votes,
+		})
+		for i, votes := range votes {
+			timeline.TotalVotes[i] += votes
+		}
+	}
+	if timeline.Resolution == "" {
+		_, timeline.Resolution = v.timeline.source(from, bucket)
+	}
+	return timeline, nil
+}
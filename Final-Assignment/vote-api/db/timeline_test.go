@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseDownsampleRules(t *testing.T) {
+	tests := []struct {
+		spec  string
+		rules []DownsampleRule
+		valid bool
+	}{
+		{"", nil, true},
+		{"off", nil, true},
+		{"1h:2160h, 24h:0", []DownsampleRule{{time.Hour, 2160 * time.Hour}, {24 * time.Hour, 0}}, true},
+		{"1h", nil, false},
+		{"1us:1h", nil, false},
+		{"1h:-1h", nil, false},
+	}
+	for _, test := range tests {
+		rules, err := ParseDownsampleRules(test.spec)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: unexpected error %v", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Errorf("%q: got %v, want %v", test.spec, rules, test.rules)
+		}
+	}
+}
+
+// TestTimelineCounts backfills old votes, records new writes and reads the
+// counts back bucket by bucket
+func TestTimelineCounts(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	timeline, err := NewTimeline(client, DefaultTimeline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, err := timeline.Available(ctx); err != nil || !available {
+		t.Skip("redis-server has no RedisTimeSeries: ", err)
+	}
+
+	links := newVoteLinks(Services{VotersURL: "voters.test", PollsURL: "polls.test"})
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Hour)
+	var votes []Vote
+	for i, offset := range []time.Duration{10 * time.Minute, 70 * time.Minute, 80 * time.Minute} {
+		vote, _ := links.NewVote(uint(i+1), uint(i+1), 1, 1)
+		vote.VoteDate = start.Add(offset)
+		votes = append(votes, *vote)
+	}
+	listVotes := func(ctx context.Context) ([]Vote, error) { return votes, nil }
+	if err := timeline.Backfill(ctx, listVotes); err != nil {
+		t.Fatal(err)
+	}
+	// Backfilling twice counts nothing twice
+	if err := timeline.Backfill(ctx, listVotes); err != nil {
+		t.Fatal(err)
+	}
+	if err := timeline.Record(ctx, 1, 1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := timeline.Record(ctx, 1, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, resolution, err := timeline.Counts(ctx, 1, 1, start, time.Hour, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 3, 3, 2}; !reflect.DeepEqual(counts, want) || resolution != "raw" {
+		t.Errorf("option 1 counts %v from %s, want %v from raw", counts, resolution, want)
+	}
+	counts, _, err = timeline.Counts(ctx, 1, 2, start, time.Hour, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{0, 0, 0, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("option 2 counts %v, want %v", counts, want)
+	}
+	counts, _, err = timeline.Counts(ctx, 1, 3, start, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{0, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("option without votes counts %v, want %v", counts, want)
+	}
+}
+
+// TestTimelineFollowsConcurrentWrites moves one vote between options from
+// several goroutines and checks that the counts end up on the option the
+// vote ends up on
+func TestTimelineFollowsConcurrentWrites(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	timeline, err := NewTimeline(client, DefaultTimeline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, err := timeline.Available(ctx); err != nil || !available {
+		t.Skip("redis-server has no RedisTimeSeries: ", err)
+	}
+	store := &timelineStore{VoteStore: NewWithClient(client, Services{VotersURL: "voters.test", PollsURL: "polls.test"}), timeline: timeline}
+
+	if err := store.AddVote(ctx, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func(optionID uint) {
+			defer wait.Done()
+			_, err := store.UpdateVote(ctx, 1, VoteKeys{VoteID: 1, VoterID: 1, PollID: 1, PollOptionID: optionID})
+			if err != nil && !errors.Is(err, ErrPatchConflict) {
+				t.Error(err)
+			}
+		}(uint(i%3 + 1))
+	}
+	wait.Wait()
+
+	vote, err := store.GetVote(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := VoteKeysFromVote(vote)
+	count := func(optionID uint) int64 {
+		counts, _, err := timeline.Counts(ctx, 1, optionID, time.Now().Truncate(time.Hour), time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counts[0]
+	}
+	for optionID := uint(1); optionID <= 3; optionID++ {
+		want := int64(0)
+		if optionID == keys.PollOptionID {
+			want = 1
+		}
+		if got := count(optionID); got != want {
+			t.Errorf("option %d counts %d, want %d", optionID, got, want)
+		}
+	}
+
+	before, err := store.DeleteVote(ctx, 1)
+	if err != nil || before.PollOption != vote.PollOption {
+		t.Fatalf("expected the deleted vote back, got %+v %v", before, err)
+	}
+	if got := count(keys.PollOptionID); got != 0 {
+		t.Errorf("option %d counts %d after the delete, want 0", keys.PollOptionID, got)
+	}
+}
+
+// TestTimelineBackfillWaits starts an api while another holds the backfill
+// lock. It waits rather than recording into series about to be dropped, and
+// takes over once the lock is gone without the backfill done.
+func TestTimelineBackfillWaits(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	timeline, err := NewTimeline(client, DefaultTimeline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, err := timeline.Available(ctx); err != nil || !available {
+		t.Skip("redis-server has no RedisTimeSeries: ", err)
+	}
+
+	if err := client.Set(ctx, redisTimelineBackfillLockKey, "other api", backfillLockTTL).Err(); err != nil {
+		t.Fatal(err)
+	}
+	listed := make(chan struct{}, 1)
+	finished := make(chan error, 1)
+	go func() {
+		finished <- timeline.Backfill(ctx, func(ctx context.Context) ([]Vote, error) {
+			listed <- struct{}{}
+			return nil, nil
+		})
+	}()
+
+	select {
+	case err := <-finished:
+		t.Fatalf("backfill returned while another api held the lock: %v", err)
+	case <-time.After(backfillWaitInterval / 2):
+	}
+
+	client.Del(ctx, redisTimelineBackfillLockKey)
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * backfillWaitInterval):
+		t.Fatal("backfill did not take over the released lock")
+	}
+	select {
+	case <-listed:
+	default:
+		t.Error("the votes were not listed by the api that took over")
+	}
+	if done, err := client.Exists(ctx, redisTimelineBackfilledKey).Result(); err != nil || done == 0 {
+		t.Errorf("backfill was not marked done: %v", err)
+	}
+	if locked, err := client.Exists(ctx, redisTimelineBackfillLockKey).Result(); err != nil || locked != 0 {
+		t.Errorf("backfill lock was not released: %v", err)
+	}
+}
+
+// TestTimelineRecreatesDroppedSeries drops the series of an option behind
+// the timeline's back, as another api's backfill does, and checks that the
+// next write creates it again with its downsampled series
+func TestTimelineRecreatesDroppedSeries(t *testing.T) {
+	client := startRedis(t)
+	ctx := context.Background()
+	timeline, err := NewTimeline(client, DefaultTimeline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, err := timeline.Available(ctx); err != nil || !available {
+		t.Skip("redis-server has no RedisTimeSeries: ", err)
+	}
+
+	if err := timeline.Record(ctx, 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	key := timelineKey(1, 1)
+	keys := []string{key}
+	for _, rule := range timeline.rules {
+		keys = append(keys, downsampledKey(key, rule))
+	}
+	for _, key := range keys {
+		if err := client.Del(ctx, key).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := timeline.Record(ctx, 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if exists, err := client.Exists(ctx, key).Result(); err != nil || exists == 0 {
+			t.Errorf("%s was not created again: %v", key, err)
+		}
+	}
+}
@@ -5,29 +5,36 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"path"
 	"strconv"
 	"time"
 
+	"shared/redisclient"
+	"votes-api/client/pollclient"
+	"votes-api/client/voterclient"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
 )
 
 const (
 	RedisNilError = "redis: nil"
-	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisVoteKeyPrefix = "vote:"
+	RedisRevotePolicyKeyPrefix = "revote-policy:"
 	VotersDefaultLocation = "0.0.0.0:1081"
 	PollsDefaultLocation = "0.0.0.0:1082"
 )
 
 type cache struct {
-	cacheClient *redis.Client
-	jsonHelper *rejson.Handler
-	context context.Context
+	cacheClient redis.UniversalClient
+}
+
+// jsonHandler runs RedisJSON commands under the caller's ctx
+func (c cache) jsonHandler(ctx context.Context) *rejson.Handler {
+	jsonHelper := rejson.NewReJSONHandler()
+	jsonHelper.SetGoRedisClientWithContext(ctx, c.cacheClient)
+	return jsonHelper
 }
 
 type Vote struct {
@@ -36,106 +43,55 @@ type Vote struct {
 	Poll string
 	PollOption string
 	VoteDate time.Time
+	// Oldest first, kept in the document by the redis and memory stores
+	Revisions []Revision `json:",omitempty"`
 }
 
 type VoteDetails struct {
 	VoteID uint
-	Voter Voter
-	Poll Poll
-	PollOption PollOption
+	Voter voterclient.Voter
+	Poll pollclient.Poll
+	PollOption pollclient.PollOption
 	VoteDate time.Time
 }
 
 type VoteKeys struct {
-	VoteID uint
-	VoterID uint
-	PollID uint
-	PollOptionID uint
+	VoteID uint `binding:"required"`
+	VoterID uint `binding:"required"`
+	PollID uint `binding:"required"`
+	PollOptionID uint `binding:"required"`
 }
 
-type Voter struct {
-	VoterID uint
-	FirstName string
-	LastName string
-}
-
-type Poll struct {
-	PollID uint
-	PollTitle string
-	PollQuestion string
-	PollOptions []PollOption
-}
-
-type PollOption struct {
-	PollOptionID uint
-	PollOptionText string
+// RedisVoteData keeps each vote as a RedisJSON document
+type RedisVoteData struct {
+	cache
+	voteLinks
 }
 
-
-type VoteData struct {
-	cache
+// voteLinks turns the ids a vote is cast with into links to the voter and
+// poll services, for every store
+type voteLinks struct {
 	votersUrl string
 	pollsUrl string
 }
 
-// Creat New Vote Data Handler 
-func New() (*VoteData, error){
-
-	redisUrl := os.Getenv("REDIS_URL")
-
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
+func newVoteLinks(services Services) voteLinks {
+	return voteLinks{
+		votersUrl: services.VotersURL,
+		pollsUrl: services.PollsURL,
 	}
-
-	return NewWithCacheInstance(redisUrl)
 }
 
-func NewWithCacheInstance(location string) (*VoteData, error) {
-	
-	client := redis.NewClient(&redis.Options{
-		Addr:location,
-	})
-
-	ctx := context.Background()
-
-	err := client.Ping(ctx).Err()
-	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
-		return nil, err
-	}
-
-	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
+// NewWithClient keeps votes in redis through client, which is shared
+// with the rate limiter and the idempotency store
+func NewWithClient(client redis.UniversalClient, services Services) *RedisVoteData {
 
-	return &VoteData{
+	return &RedisVoteData{
 		cache: cache{
 			cacheClient: client,
-			jsonHelper: jsonHelper,
-			context: ctx,
 		},
-		votersUrl: getVotersUrl(),
-		pollsUrl: getPollsUrl(),
-	}, nil
-}
-
-func getVotersUrl() string {
-	votersUrl := os.Getenv("VOTERS_URL")
-
-	if votersUrl == "" {
-		votersUrl = VotersDefaultLocation
+		voteLinks: newVoteLinks(services),
 	}
-
-	return votersUrl
-}
-
-func getPollsUrl() string {
-	pollsUrl := os.Getenv("POLLS_URL")
-
-	if pollsUrl == ""{
-		pollsUrl = PollsDefaultLocation
-	}
-
-	return pollsUrl
 }
 
 func isRedisNilError(err error) bool {
@@ -146,8 +102,8 @@ func redisVoteKeyFromId(id int) string {
 	return fmt.Sprintf("%s%d", RedisVoteKeyPrefix, id)
 }
 
-func (v *VoteData) getVoteFromRedis(key string, vote *Vote) error {
-	voteObject, err := v.jsonHelper.JSONGet(key, ".")
+func (v *RedisVoteData) getVoteFromRedis(ctx context.Context, key string, vote *Vote) error {
+	voteObject, err := v.jsonHandler(ctx).JSONGet(key, ".")
 	if err != nil {
 		return err
 	}
@@ -160,7 +116,7 @@ func (v *VoteData) getVoteFromRedis(key string, vote *Vote) error {
 	return nil
 }
 
-func (v *VoteData) NewVote(voteID uint, voterID uint, pollID uint, pollOptionID uint) (*Vote, error){
+func (v voteLinks) NewVote(voteID uint, voterID uint, pollID uint, pollOptionID uint) (*Vote, error){
 	voter := &Vote{
 		VoteID: voteID,
 		Voter: v.getVoterUrl(voterID),
@@ -172,28 +128,36 @@ func (v *VoteData) NewVote(voteID uint, voterID uint, pollID uint, pollOptionID
 	return voter, nil
 }
 
-func (v *VoteData) getVoterUrl(voterID uint) string {
+func (v voteLinks) getVoterUrl(voterID uint) string {
 	return "http://" + v.votersUrl + "/voters/" + strconv.FormatUint(uint64(voterID), 10)
 }
 
-func (v *VoteData) getPollUrl(pollID uint) string {
+func (v voteLinks) getPollUrl(pollID uint) string {
 	return "http://" + v.pollsUrl + "/polls/" + strconv.FormatUint(uint64(pollID), 10)
 }
 
-func (v *VoteData) getPollOptionUrl(pollID uint, optionID uint) string {
+func (v voteLinks) getPollOptionUrl(pollID uint, optionID uint) string {
 	url :=  "http://" + v.pollsUrl + "/polls/" + strconv.FormatUint(uint64(pollID), 10) 
 	url += "/polloption/" + strconv.FormatUint(uint64(optionID), 10) 
 	return url
 }
 
-func (v *VoteData) GetAllVotes() ([]Vote, error){
+func (v *RedisVoteData) GetAllVotes(ctx context.Context) ([]Vote, error){
 	var voters []Vote
-	var voter Vote
 
 	pattern := RedisVoteKeyPrefix + "*"
-	ks, _ := v.cacheClient.Keys(v.context, pattern).Result()
+	var ks []string
+	err := redisclient.ForEachNode(ctx, v.cacheClient, func(ctx context.Context, node redis.Cmdable) error {
+		nodeKeys, err := node.Keys(ctx, pattern).Result()
+		ks = append(ks, nodeKeys...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 	for _,key := range ks {
-		err := v.getVoteFromRedis(key, &voter)
+		var voter Vote
+		err := v.getVoteFromRedis(ctx, key, &voter)
 		if err != nil {
 			return nil, err
 		}
@@ -202,11 +166,11 @@ func (v *VoteData) GetAllVotes() ([]Vote, error){
 	return voters, nil
 } 
 
-func (v *VoteData) GetVote(voterID uint) (Vote, error){
+func (v *RedisVoteData) GetVote(ctx context.Context, voterID uint) (Vote, error){
 	
 	var vote Vote
 	pattern := redisVoteKeyFromId(int(voterID))
-	err := v.getVoteFromRedis(pattern, &vote)
+	err := v.getVoteFromRedis(ctx, pattern, &vote)
 	if err != nil {
 		return Vote{}, err
 	}
@@ -214,23 +178,33 @@ func (v *VoteData) GetVote(voterID uint) (Vote, error){
 	return vote, nil
 } 
 
-func (v *VoteData) GetVoteDetails(voteID uint) (VoteDetails, error){
+// GetVoteDetails follows the vote's links to the voter and poll services.
+// header carries the caller's credentials to forward with those requests.
+func (v *VoteData) GetVoteDetails(ctx context.Context, voteID uint, header http.Header) (VoteDetails, error){
 	
-	var vote Vote
-	pattern := redisVoteKeyFromId(int(voteID))
-	err := v.getVoteFromRedis(pattern, &vote)
+	vote, err := v.GetVote(ctx, voteID)
 	if err != nil {
 		return VoteDetails{}, err
 	}
 	
-	voterDetails, err := getVoterDetails(vote)
-	if err != nil { return VoteDetails{}, err}
+	keys, err := VoteKeysFromVote(vote)
+	if err != nil {
+		return VoteDetails{}, err
+	}
+	voterDetails, err := v.details.GetVoter(ctx, keys.VoterID, header)
+	if err != nil {
+		return VoteDetails{}, errors.New("Error: could not get voter details: " + err.Error())
+	}
 
-	pollDetails, err := getPollDetails(vote)
-	if err != nil { return VoteDetails{}, err}
+	pollDetails, err := v.details.GetPoll(ctx, keys.PollID, header)
+	if err != nil {
+		return VoteDetails{}, errors.New("Error: could not get poll details: " + err.Error())
+	}
 
-	pollOptionDetails, err := getPollOptionDetails(vote)
-	if err != nil { return VoteDetails{}, err}
+	pollOptionDetails, err := v.details.GetPollOption(ctx, keys.PollID, keys.PollOptionID, header)
+	if err != nil {
+		return VoteDetails{}, errors.New("Error: could not get poll option details: " + err.Error())
+	}
 
 	voteDetails := VoteDetails{
 		VoteID: vote.VoteID,
@@ -242,109 +216,310 @@ func (v *VoteData) GetVoteDetails(voteID uint) (VoteDetails, error){
 	return voteDetails, nil
 } 
 
-func getVoterDetails(vote Vote) (Voter, error){
-	resp, err := http.Get(vote.Voter)
+// VoteKeysFromVote recovers the ids a vote was created from out of its links
+func VoteKeysFromVote(vote Vote) (VoteKeys, error) {
+	voterID, err := lastPathID(vote.Voter)
 	if err != nil {
-		return Voter{}, errors.New("Error: failed request from voter service: " + vote.Voter + err.Error())
+		return VoteKeys{}, err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	pollID, err := lastPathID(vote.Poll)
 	if err != nil {
-		return Voter{}, errors.New("Error: could not read body from voter request")
+		return VoteKeys{}, err
 	}
-
-	var voter Voter
-	err = json.Unmarshal([]byte(body), &voter)
+	pollOptionID, err := lastPathID(vote.PollOption)
 	if err != nil {
-		return Voter{}, err
+		return VoteKeys{}, err
 	}
 
-	return voter, nil
+	return VoteKeys{
+		VoteID: vote.VoteID,
+		VoterID: voterID,
+		PollID: pollID,
+		PollOptionID: pollOptionID,
+	}, nil
 }
 
-func getPollDetails(vote Vote) (Poll, error){
-	resp, err := http.Get(vote.Poll)
+func lastPathID(link string) (uint, error) {
+	id, err := strconv.ParseUint(path.Base(path.Clean(link)), 10, 64)
 	if err != nil {
-		return Poll{}, errors.New("Error: could not get poll details")
+		return 0, errors.New("Error: link does not end in an id: " + link)
+	}
+	return uint(id), nil
+}
+
+func (v *RedisVoteData) AddVote(ctx context.Context, voteKeys VoteKeys) error {
+
+	redisKey := redisVoteKeyFromId(int(voteKeys.VoteID))
+	newVote, _ := v.NewVote(voteKeys.VoteID, voteKeys.VoterID, voteKeys.PollID, voteKeys.PollOptionID)
+	if err := revise(nil, newVote, RevisionCast); err != nil {
+		return err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	voteObject, err := json.Marshal(newVote)
 	if err != nil {
-		return Poll{}, errors.New("Error: could not get poll details")
+		return err
+	}
+
+	// NX leaves a vote that already exists alone, so of two casts with one
+	// ID only the one that created the key counts in the aggregates
+	if err := v.cacheClient.Do(ctx, "JSON.SET", redisKey, ".", voteObject, "NX").Err(); err != nil {
+		if isRedisNilError(err) {
+			return ErrVoteExists
+		}
+		return err
 	}
 
-	var poll Poll
-	err = json.Unmarshal([]byte(body), &poll)
+	return v.recordWrite(ctx, voteKeys.VoteID, nil)
+}
+
+// UpdateVote recasts the vote inside a redis transaction on its key and
+// returns the vote as it was before. The vote keeps the date it was cast.
+func (v *RedisVoteData) UpdateVote(ctx context.Context, voteID uint, updateData VoteKeys) (Vote, error) {
+	redisKey := redisVoteKeyFromId(int(voteID))
+
+	existingVote, err := v.watchVote(ctx, voteID, func(tx *redis.Tx, existingVote *Vote) error {
+		if err := v.checkRevote(ctx, tx, *existingVote, updateData.PollID); err != nil {
+			return err
+		}
+		updatedVote,_ := v.NewVote(updateData.VoteID,
+			updateData.VoterID,
+			updateData.PollID,
+			updateData.PollOptionID)
+		updatedVote.VoteDate = existingVote.VoteDate
+		if err := revise(existingVote, updatedVote, RevisionUpdate); err != nil {
+			return err
+		}
+		voteObject, err := json.Marshal(updatedVote)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", redisKey, ".", voteObject)
+			return nil
+		})
+		return err
+	})
 	if err != nil {
-		return Poll{}, err
+		return Vote{}, err
 	}
-	return poll, nil
+
+	return *existingVote, v.recordWrite(ctx, voteID, existingVote)
 }
 
-func getPollOptionDetails(vote Vote) (PollOption, error){
-	resp, err := http.Get(vote.PollOption)
+// patchVote applies patch to the VoteKeys document existingVote was
+// created from and builds the patched vote, for every store's PatchVote.
+// The patched vote keeps the date existingVote was cast.
+func (v voteLinks) patchVote(existingVote Vote, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (*Vote, error) {
+	existingKeys, err := VoteKeysFromVote(existingVote)
 	if err != nil {
-		return PollOption{}, errors.New("Error: could not get poll option details")
+		return nil, err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	keysDocument, err := json.Marshal(existingKeys)
 	if err != nil {
-		return PollOption{}, errors.New("Error: could not get poll option details")
+		return nil, err
 	}
-	var pollOption PollOption
-	err = json.Unmarshal([]byte(body), &pollOption)
+
+	patchedDocument, err := patch.Apply(keysDocument)
 	if err != nil {
-		return PollOption{}, err
+		return nil, err
 	}
-	return pollOption, nil
-}
 
-func (v *VoteData) AddVote(voteKeys VoteKeys) error {
+	var patchedKeys VoteKeys
+	if err := json.Unmarshal(patchedDocument, &patchedKeys); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	if patchedKeys.VoteID != existingVote.VoteID {
+		return nil, ErrIDChanged
+	}
+	if err := check(existingKeys, &patchedKeys); err != nil {
+		return nil, err
+	}
 
-	redisKey := redisVoteKeyFromId(int(voteKeys.VoteID))
-	var existingItem Vote
-	if err := v.getVoteFromRedis(redisKey, &existingItem); err == nil {
-		return errors.New("item already exists")
+	patchedVote, err := v.NewVote(patchedKeys.VoteID,
+		patchedKeys.VoterID,
+		patchedKeys.PollID,
+		patchedKeys.PollOptionID)
+	if err != nil {
+		return nil, err
 	}
+	patchedVote.VoteDate = existingVote.VoteDate
+	return patchedVote, nil
+}
 
-	newVote, _ := v.NewVote(voteKeys.VoteID, voteKeys.VoterID, voteKeys.PollID, voteKeys.PollOptionID)
+// watchVote runs write in a redis transaction that watches the vote,
+// given the vote as it is. It fails with ErrNotFound when the vote does
+// not exist, is retried when the vote or a key write watches changes
+// before the write is made and returns the vote as it was before the
+// write.
+func (v *RedisVoteData) watchVote(ctx context.Context, voteID uint, write func(tx *redis.Tx, existingVote *Vote) error) (*Vote, error) {
+	redisKey := redisVoteKeyFromId(int(voteID))
+	var existingVote *Vote
 
-	if _, err := v.jsonHelper.JSONSet(redisKey, ".", newVote); err != nil {
-		return err
+	readAndWrite := func(tx *redis.Tx) error {
+		getCmd := redis.NewCmd(ctx, "JSON.GET", redisKey, ".")
+		_ = tx.Process(ctx, getCmd)
+		document, err := getCmd.Text()
+		if err != nil {
+			if isRedisNilError(err) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		existingVote = &Vote{}
+		if err := json.Unmarshal([]byte(document), existingVote); err != nil {
+			return err
+		}
+		return write(tx, existingVote)
 	}
 
-	return nil
-}
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		err := v.cacheClient.Watch(ctx, readAndWrite, redisKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return existingVote, nil
+	}
 
-func (v *VoteData) UpdateVote(voteID uint, updateData VoteKeys) error {
+	return nil, ErrPatchConflict
+}
 
+// PatchVote applies patch to the VoteKeys document the vote was created
+// from inside a redis transaction, so that concurrent writes are never
+// lost. check is given the keys before and after the patch and can reject
+// the change before it is saved.
+func (v *RedisVoteData) PatchVote(ctx context.Context, voteID uint, patch Patch, check func(existing VoteKeys, patched *VoteKeys) error) (Vote, error) {
 	redisKey := redisVoteKeyFromId(int(voteID))
-	var existingVote Vote
-	if err := v.getVoteFromRedis(redisKey, &existingVote); err != nil {
-		return errors.New("Item does not exist")
+	var patchedVote *Vote
+
+	existingVote, err := v.watchVote(ctx, voteID, func(tx *redis.Tx, existingVote *Vote) error {
+		var err error
+		patchedVote, err = v.patchVote(*existingVote, patch, check)
+		if err != nil {
+			return err
+		}
+		patchedKeys, err := VoteKeysFromVote(*patchedVote)
+		if err != nil {
+			return err
+		}
+		if err := v.checkRevote(ctx, tx, *existingVote, patchedKeys.PollID); err != nil {
+			return err
+		}
+		if err := revise(existingVote, patchedVote, RevisionPatch); err != nil {
+			return err
+		}
+		voteObject, err := json.Marshal(patchedVote)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", redisKey, ".", voteObject)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Vote{}, err
 	}
 
-	updatedVote,_ := v.NewVote(updateData.VoteID, 
-		updateData.VoterID, 
-		updateData.PollID, 
-		updateData.PollOptionID)
+	return *patchedVote, v.recordWrite(ctx, voteID, existingVote)
+}
 
-	if _, err := v.jsonHelper.JSONSet(redisKey, ".", updatedVote); err != nil {
+// DeleteVote removes the vote inside a redis transaction on its key and
+// returns the vote as it was
+func (v *RedisVoteData) DeleteVote(ctx context.Context, voteID uint) (Vote, error) {
+	redisKey := redisVoteKeyFromId(int(voteID))
+
+	existingVote, err := v.watchVote(ctx, voteID, func(tx *redis.Tx, existingVote *Vote) error {
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, redisKey)
+			return nil
+		})
 		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return Vote{}, errors.New("Attempted to delete a non-existent vote")
+	}
+	if err != nil {
+		return Vote{}, err
 	}
 
-	return nil
+	return *existingVote, v.recordWrite(ctx, voteID, existingVote)
 }
 
-func (v *VoteData) DeleteVote(voteID uint) error {
-	pattern := redisVoteKeyFromId(int(voteID))
-	numDeleted, err := v.cacheClient.Del(v.context, pattern).Result()
+func (v *RedisVoteData) GetVoteHistory(ctx context.Context, voteID uint) (VoteHistory, error) {
+	vote, err := v.currentVote(ctx, voteID)
 	if err != nil {
-		return err
+		return VoteHistory{}, err
 	}
-	if numDeleted == 0 {
-		return errors.New("Attempted to delete a non-existent vote")
+	if vote == nil {
+		return VoteHistory{}, ErrNotFound
 	}
+	revisions, err := revisionsOf(*vote)
+	if err != nil {
+		return VoteHistory{}, err
+	}
+	return historyOf(voteID, revisions), nil
+}
 
-	return nil
+func redisRevotePolicyKey(pollID uint) string {
+	return fmt.Sprintf("%s%d", RedisRevotePolicyKeyPrefix, pollID)
 }
 
+func (v *RedisVoteData) GetRevotePolicy(ctx context.Context, pollID uint) (RevotePolicy, error) {
+	policyObject, err := v.jsonHandler(ctx).JSONGet(redisRevotePolicyKey(pollID), ".")
+	if err != nil {
+		if isRedisNilError(err) {
+			return DefaultRevotePolicy, nil
+		}
+		return RevotePolicy{}, err
+	}
+	var policy RevotePolicy
+	if err := json.Unmarshal(policyObject.([]byte), &policy); err != nil {
+		return RevotePolicy{}, err
+	}
+	return policy, nil
+}
 
+// checkRevote checks changing existing to a vote in pollID. The policies
+// are watched in tx, so a policy set before the vote is written fails the
+// transaction and the write is tried again.
+func (v *RedisVoteData) checkRevote(ctx context.Context, tx *redis.Tx, existing Vote, pollID uint) error {
+	castDate, err := castDateOf(existing)
+	if err != nil {
+		return err
+	}
+	existingKeys, err := VoteKeysFromVote(existing)
+	if err != nil {
+		return err
+	}
+	return checkRevote(castDate, existingKeys.PollID, pollID, func(pollID uint) (RevotePolicy, error) {
+		policyKey := redisRevotePolicyKey(pollID)
+		if err := tx.Watch(ctx, policyKey).Err(); err != nil {
+			return RevotePolicy{}, err
+		}
+		getCmd := redis.NewCmd(ctx, "JSON.GET", policyKey, ".")
+		_ = tx.Process(ctx, getCmd)
+		document, err := getCmd.Text()
+		if err != nil {
+			if isRedisNilError(err) {
+				return DefaultRevotePolicy, nil
+			}
+			return RevotePolicy{}, err
+		}
+		var policy RevotePolicy
+		if err := json.Unmarshal([]byte(document), &policy); err != nil {
+			return RevotePolicy{}, err
+		}
+		return policy, nil
+	})
+}
 
+func (v *RedisVoteData) SetRevotePolicy(ctx context.Context, pollID uint, policy RevotePolicy) error {
+	_, err := v.jsonHandler(ctx).JSONSet(redisRevotePolicyKey(pollID), ".", policy)
+	return err
+}
@@ -0,0 +1,213 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"shared/auth"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"votes-api/db"
+	"votes-api/logging"
+	votev1 "votes-api/pb/vote/v1"
+
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// How often WatchResults checks for new votes unless configured otherwise
+const DefaultWatchInterval = 2 * time.Second
+
+// VoteServer serves the vote service over gRPC from the same VoteData as
+// the REST handlers
+type VoteServer struct {
+	votev1.UnimplementedVoteServiceServer
+	db      *db.VoteData
+	watcher *resultsWatcher
+}
+
+func NewVoteServer(dbHandler *db.VoteData, watchInterval time.Duration) *VoteServer {
+	return &VoteServer{db: dbHandler, watcher: newResultsWatcher(dbHandler, watchInterval)}
+}
+
+// NewServer creates a gRPC server with the vote service registered. Every
+// call must carry credentials accepted by authenticator and only voters
+// and admins may cast votes. Cast votes share the REST api's rate limits
+// and idempotency keys. WatchResults re-tallies every watchInterval.
+func NewServer(dbHandler *db.VoteData, authenticator auth.Authenticator, limiter *ratelimit.Limiter, idempotencyStore *idempotency.Store, watchInterval time.Duration) *grpc.Server {
+	roles := auth.MethodRoles{
+		votev1.VoteService_CastVote_FullMethodName: {auth.RoleVoter, auth.RoleAdmin},
+	}
+	limitedRoutes := map[string]string{
+		votev1.VoteService_CastVote_FullMethodName: "cast-vote",
+	}
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			limiter.FailureUnaryInterceptor("auth"),
+			auth.UnaryInterceptor(authenticator, roles),
+			idempotencyStore.UnaryInterceptor(votev1.VoteService_CastVote_FullMethodName),
+			limiter.UnaryInterceptor(limitedRoutes),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			limiter.FailureStreamInterceptor("auth"),
+			auth.StreamInterceptor(authenticator, roles),
+		),
+	)
+	votev1.RegisterVoteServiceServer(server, NewVoteServer(dbHandler, watchInterval))
+	return server
+}
+
+func (s *VoteServer) GetVote(ctx context.Context, req *votev1.GetVoteRequest) (*votev1.Vote, error) {
+	vote, err := s.db.GetVote(ctx, uint(req.GetVoteId()))
+	if err != nil {
+		slog.WarnContext(ctx, "Vote not found", "error", err)
+		return nil, status.Errorf(codes.NotFound, "vote %d not found", req.GetVoteId())
+	}
+	return toProtoVote(vote)
+}
+
+func (s *VoteServer) ListVotes(ctx context.Context, req *votev1.ListVotesRequest) (*votev1.ListVotesResponse, error) {
+	votes, err := s.db.GetAllVotes(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting all votes", "error", err)
+		return nil, status.Error(codes.Internal, "could not list votes")
+	}
+
+	response := &votev1.ListVotesResponse{}
+	for _, vote := range votes {
+		message, err := toProtoVote(vote)
+		if err != nil {
+			return nil, err
+		}
+		response.Votes = append(response.Votes, message)
+	}
+	return response, nil
+}
+
+func (s *VoteServer) CastVote(ctx context.Context, req *votev1.CastVoteRequest) (*votev1.Vote, error) {
+	voteKeys := db.VoteKeys{
+		VoteID:       uint(req.GetVoteId()),
+		VoterID:      uint(req.GetVoterId()),
+		PollID:       uint(req.GetPollId()),
+		PollOptionID: uint(req.GetPollOptionId()),
+	}
+	if err := binding.Validator.ValidateStruct(voteKeys); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Admins may act for any voter, everyone else only as the voter bound
+	// to their credentials
+	principal := auth.PrincipalFromContext(ctx)
+	if !principal.HasRole(auth.RoleAdmin) && principal.VoterID != voteKeys.VoterID {
+		return nil, status.Error(codes.PermissionDenied, "voters may only cast votes as themselves")
+	}
+
+	if err := s.db.AddVote(ctx, voteKeys); err != nil {
+		return nil, castVoteError(ctx, err)
+	}
+
+	vote, err := s.db.GetVote(ctx, voteKeys.VoteID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toProtoVote(vote)
+}
+
+// castVoteError turns an error from AddVote into the call's status
+func castVoteError(ctx context.Context, err error) error {
+	var netError net.Error
+	switch {
+	case errors.Is(err, db.ErrVoteExists), errors.Is(err, db.ErrAlreadyVoted):
+		slog.WarnContext(ctx, "Error adding vote", "error", err)
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, db.ErrReferenceNotFound):
+		slog.WarnContext(ctx, "Error adding vote", "error", err)
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.As(err, &netError):
+		slog.ErrorContext(ctx, "Error adding vote", "error", err)
+		return status.Error(codes.Unavailable, "the vote store cannot be reached")
+	}
+	slog.ErrorContext(ctx, "Error adding vote", "error", err)
+	return status.Error(codes.Internal, "could not add vote")
+}
+
+// WatchResults sends the poll's results, then again whenever the watcher
+// finds they changed
+func (s *VoteServer) WatchResults(req *votev1.WatchResultsRequest, stream votev1.VoteService_WatchResultsServer) error {
+	pollID := uint(req.GetPollId())
+	updates, stop := s.watcher.watch(pollID)
+	defer stop()
+
+	results, err := s.db.GetPollResults(stream.Context(), pollID)
+	var last *votev1.PollResults
+	for {
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "Error tallying poll", "error", err)
+			return status.Error(codes.Internal, "could not tally poll")
+		}
+
+		current := toProtoResults(results)
+		if last == nil || !sameTallies(last, current) {
+			current.AsOf = timestamppb.Now()
+			if err := stream.Send(current); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case update := <-updates:
+			results, err = update.results, update.err
+		}
+	}
+}
+
+func sameTallies(a *votev1.PollResults, b *votev1.PollResults) bool {
+	return proto.Equal(
+		&votev1.PollResults{TotalVotes: a.GetTotalVotes(), Tallies: a.GetTallies()},
+		&votev1.PollResults{TotalVotes: b.GetTotalVotes(), Tallies: b.GetTallies()})
+}
+
+func toProtoVote(vote db.Vote) (*votev1.Vote, error) {
+	keys, err := db.VoteKeysFromVote(vote)
+	if err != nil {
+		slog.Error("Error reading vote links", "error", err)
+		return nil, status.Error(codes.Internal, "vote has invalid links")
+	}
+	return &votev1.Vote{
+		VoteId:       uint64(keys.VoteID),
+		VoterId:      uint64(keys.VoterID),
+		PollId:       uint64(keys.PollID),
+		PollOptionId: uint64(keys.PollOptionID),
+		VoteDate:     timestamppb.New(vote.VoteDate),
+	}, nil
+}
+
+func toProtoResults(results db.PollResults) *votev1.PollResults {
+	message := &votev1.PollResults{
+		PollId:     uint64(results.PollID),
+		TotalVotes: uint64(results.TotalVotes),
+	}
+	for _, tally := range results.Tallies {
+		message.Tallies = append(message.Tallies, &votev1.OptionTally{
+			PollOptionId: uint64(tally.PollOptionID),
+			Votes:        uint64(tally.Votes),
+		})
+	}
+	return message
+}
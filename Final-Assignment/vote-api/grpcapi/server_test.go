@@ -0,0 +1,223 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+
+	"shared/auth"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"votes-api/db"
+	votev1 "votes-api/pb/vote/v1"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startRedis runs a throwaway redis-server for one test, skipping the
+// test when none is installed
+func startRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	binary, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server is not installed")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binary, "--port", fmt.Sprint(port), "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir())
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("127.0.0.1:%d", port)})
+	t.Cleanup(func() { client.Close() })
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(context.Background()).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return client
+}
+
+// newTestClient serves the vote service from a memory store over an in
+// process connection, with the given rate limits, e.g. "cast-vote": "2/1m"
+func newTestClient(t *testing.T, rules map[string]string) votev1.VoteServiceClient {
+	t.Helper()
+	redisClient := startRedis(t)
+	authenticator, err := auth.New(auth.Config{APIKeys: "admin-key:admin,voter1-key:voter:1,reader-key:reader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.New(redisClient)
+	for route, spec := range rules {
+		rule, err := ratelimit.ParseRule(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiter.SetRule(route, rule)
+	}
+
+	services := db.DefaultServices()
+	dbHandler, err := db.NewWithStore(db.NewMemory(services), services)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(dbHandler, authenticator, limiter, idempotency.New(redisClient, idempotency.DefaultTTL), 20*time.Millisecond)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return votev1.NewVoteServiceClient(conn)
+}
+
+// as adds an API key, and any other metadata pairs, to a call's context
+func as(apiKey string, pairs ...string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), append([]string{"x-api-key", apiKey}, pairs...)...)
+}
+
+func vote(voteID uint64, voterID uint64, optionID uint64) *votev1.CastVoteRequest {
+	return &votev1.CastVoteRequest{VoteId: voteID, VoterId: voterID, PollId: 1, PollOptionId: optionID}
+}
+
+func TestCastVoteStatus(t *testing.T) {
+	client := newTestClient(t, nil)
+	if _, err := client.CastVote(as("admin-key"), vote(1, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		request *votev1.CastVoteRequest
+		code    codes.Code
+	}{
+		{"vote id taken", "admin-key", vote(1, 2, 1), codes.AlreadyExists},
+		{"missing poll option", "admin-key", &votev1.CastVoteRequest{VoteId: 2, VoterId: 2, PollId: 1}, codes.InvalidArgument},
+		{"cast as another voter", "voter1-key", vote(2, 2, 1), codes.PermissionDenied},
+		{"readers cannot vote", "reader-key", vote(2, 1, 1), codes.PermissionDenied},
+		{"unknown key", "other-key", vote(2, 1, 1), codes.Unauthenticated},
+		{"own vote", "voter1-key", vote(2, 1, 2), codes.OK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := client.CastVote(as(test.apiKey), test.request); status.Code(err) != test.code {
+				t.Errorf("expected %s, got %v", test.code, err)
+			}
+		})
+	}
+}
+
+func TestCastVoteIdempotency(t *testing.T) {
+	client := newTestClient(t, nil)
+
+	var header metadata.MD
+	first, err := client.CastVote(as("voter1-key", "idempotency-key", "k1"), vote(1, 1, 1), grpc.Header(&header))
+	if err != nil || header.Get(idempotency.ReplayedHeader) != nil {
+		t.Fatalf("first call: unexpected %v %v", err, header)
+	}
+	retry, err := client.CastVote(as("voter1-key", "idempotency-key", "k1"), vote(1, 1, 1), grpc.Header(&header))
+	if err != nil || retry.GetVoteDate().AsTime() != first.GetVoteDate().AsTime() || len(header.Get(idempotency.ReplayedHeader)) != 1 {
+		t.Errorf("retry: expected the first vote to be replayed, got %v %v %v", retry, err, header)
+	}
+
+	if _, err := client.CastVote(as("voter1-key", "idempotency-key", "k1"), vote(1, 1, 2)); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("key reused for another vote: expected InvalidArgument, got %v", err)
+	}
+	if _, err := client.CastVote(as("voter1-key", "idempotency-key", "k2"), vote(1, 1, 1)); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("new key: expected the vote to be cast again and refused, got %v", err)
+	}
+	_, err = client.CastVote(as("voter1-key", "idempotency-key", "k2"), vote(1, 1, 1), grpc.Header(&header))
+	if status.Code(err) != codes.AlreadyExists || len(header.Get(idempotency.ReplayedHeader)) != 1 {
+		t.Errorf("refusals are replayed too, got %v %v", err, header)
+	}
+}
+
+func TestCastVoteRateLimits(t *testing.T) {
+	client := newTestClient(t, map[string]string{"cast-vote": "2/1m@voter", "auth": "2/1m"})
+
+	for i := uint64(1); i <= 2; i++ {
+		if _, err := client.CastVote(as("voter1-key"), vote(i, 1, 1)); err != nil {
+			t.Fatalf("vote %d: %v", i, err)
+		}
+	}
+	var header metadata.MD
+	_, err := client.CastVote(as("voter1-key"), vote(3, 1, 1), grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted || len(header.Get("retry-after")) != 1 {
+		t.Errorf("third vote: expected ResourceExhausted with retry-after, got %v %v", err, header)
+	}
+	if _, err := client.CastVote(as("admin-key"), vote(3, 2, 1)); err != nil {
+		t.Errorf("another caller: expected the vote to be cast, got %v", err)
+	}
+	if _, err := client.ListVotes(as("voter1-key"), &votev1.ListVotesRequest{}); err != nil {
+		t.Errorf("methods without a rule are not limited, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListVotes(as("guess"), &votev1.ListVotesRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("failed attempt %d: expected Unauthenticated, got %v", i+1, err)
+		}
+	}
+	if _, err := client.ListVotes(as("admin-key"), &votev1.ListVotesRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("after two failures: expected the client to be turned away, got %v", err)
+	}
+}
+
+func TestWatchResults(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx, cancel := context.WithTimeout(as("reader-key"), 5*time.Second)
+	defer cancel()
+
+	streams := make([]votev1.VoteService_WatchResultsClient, 2)
+	for i := range streams {
+		stream, err := client.WatchResults(ctx, &votev1.WatchResultsRequest{PollId: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results, err := stream.Recv(); err != nil || results.GetTotalVotes() != 0 {
+			t.Fatalf("stream %d: expected empty results first, got %v %v", i+1, results, err)
+		}
+		streams[i] = stream
+	}
+
+	if _, err := client.CastVote(as("admin-key"), vote(1, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CastVote(as("admin-key"), &votev1.CastVoteRequest{VoteId: 2, VoterId: 2, PollId: 2, PollOptionId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i, stream := range streams {
+		results, err := stream.Recv()
+		if err != nil || results.GetTotalVotes() != 1 || len(results.GetTallies()) != 1 || results.GetTallies()[0].GetPollOptionId() != 2 {
+			t.Errorf("stream %d: expected the vote for option 2, got %v %v", i+1, results, err)
+		}
+	}
+}
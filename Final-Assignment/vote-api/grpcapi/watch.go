@@ -0,0 +1,104 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"votes-api/db"
+)
+
+// resultsUpdate is a poll's results at one tick of the watcher
+type resultsUpdate struct {
+	results db.PollResults
+	err     error
+}
+
+// resultsWatcher tallies every watched poll once per interval from a
+// single read of the votes, however many streams watch them, and hands
+// each stream the results of its poll. It runs only while polls are
+// watched.
+type resultsWatcher struct {
+	db       *db.VoteData
+	interval time.Duration
+
+	lock     sync.Mutex
+	watchers map[uint]map[chan resultsUpdate]bool
+	running  bool
+}
+
+func newResultsWatcher(dbHandler *db.VoteData, interval time.Duration) *resultsWatcher {
+	return &resultsWatcher{db: dbHandler, interval: interval, watchers: map[uint]map[chan resultsUpdate]bool{}}
+}
+
+// watch subscribes to the results of pollID. Only the latest update is
+// kept for a stream that falls behind. Call the returned function to stop.
+func (w *resultsWatcher) watch(pollID uint) (<-chan resultsUpdate, func()) {
+	updates := make(chan resultsUpdate, 1)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.watchers[pollID] == nil {
+		w.watchers[pollID] = map[chan resultsUpdate]bool{}
+	}
+	w.watchers[pollID][updates] = true
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+
+	return updates, func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.watchers[pollID], updates)
+		if len(w.watchers[pollID]) == 0 {
+			delete(w.watchers, pollID)
+		}
+	}
+}
+
+func (w *resultsWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.lock.Lock()
+		if len(w.watchers) == 0 {
+			w.running = false
+			w.lock.Unlock()
+			return
+		}
+		pollIDs := make([]uint, 0, len(w.watchers))
+		for pollID := range w.watchers {
+			pollIDs = append(pollIDs, pollID)
+		}
+		w.lock.Unlock()
+
+		votes, err := w.db.GetAllVotes(context.Background())
+		if err != nil {
+			slog.Error("Error reading votes for watched polls", "error", err)
+		}
+		for _, pollID := range pollIDs {
+			update := resultsUpdate{err: err}
+			if err == nil {
+				update.results, update.err = db.TallyVotes(pollID, votes)
+			}
+			w.send(pollID, update)
+		}
+	}
+}
+
+func (w *resultsWatcher) send(pollID uint, update resultsUpdate) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for updates := range w.watchers[pollID] {
+		// The watcher is the only sender, so once the stale update is
+		// dropped there is room for this one
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- update
+	}
+}
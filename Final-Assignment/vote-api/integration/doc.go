@@ -0,0 +1,9 @@
+// Package integration holds the test that builds the vote, voter and poll
+// apis, starts them against a throwaway redis-server and replays the
+// scenario of test.sh with assertions on each response. It has no code of
+// its own; run it with
+//
+//	go test ./integration
+//
+// It is skipped with -short and when redis-server is not installed.
+package integration
@@ -0,0 +1,296 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"votes-api/api"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// apiKeys are the demo keys of docker-compose.yml that test.sh uses
+const apiKeys = "admin-key:admin,manager-key:poll-manager,reader-key:reader," +
+	"voter1-key:voter:1,voter2-key:voter:2,voter3-key:voter:3"
+
+// services are the directories of the three apis, relative to this one
+var services = map[string]string{
+	"vote-api":  "..",
+	"voter-api": "../../voter-api",
+	"poll-api":  "../../poll-api",
+}
+
+// startRedis runs a throwaway redis-server with RedisJSON, loaded from
+// REDISJSON_MODULE unless the server has it built in, and returns its
+// address. The test is skipped when neither is available.
+func startRedis(t *testing.T) string {
+	t.Helper()
+
+	binary := ""
+	for _, name := range []string{"redis-stack-server", "redis-server"} {
+		if path, err := exec.LookPath(name); err == nil {
+			binary = path
+			break
+		}
+	}
+	if binary == "" {
+		t.Skip("redis-server is not installed")
+	}
+
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	_, port, _ := net.SplitHostPort(addr)
+	args := []string{"--port", port, "--bind", "127.0.0.1", "--save", "", "--appendonly", "no", "--dir", t.TempDir()}
+	if module := os.Getenv("REDISJSON_MODULE"); module != "" {
+		args = append(args, "--loadmodule", module)
+	}
+	server := exec.Command(binary, args...)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Kill()
+		server.Wait()
+	})
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Ping(ctx).Err() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("redis-server did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := client.Do(ctx, "JSON.SET", "probe", ".", "{}").Err(); err != nil {
+		t.Skip("redis-server has no RedisJSON, set REDISJSON_MODULE to the module's path: ", err)
+	}
+	client.Del(ctx, "probe")
+	return addr
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// build compiles the three apis into a temporary directory and returns it
+func build(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range services {
+		command := exec.Command("go", "build", "-o", filepath.Join(dir, name), ".")
+		command.Dir = source
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("building %s: %v\n%s", name, err, output)
+		}
+	}
+	return dir
+}
+
+// service is a running api
+type service struct {
+	URL      string
+	GRPCAddr string
+}
+
+// start runs the api name from bin with env on top of the settings every
+// api needs, and waits for its health check. Its log is printed if the
+// test fails.
+func start(t *testing.T, bin string, name string, redisAddr string, env ...string) service {
+	t.Helper()
+	port := freePort(t)
+	grpcPort := freePort(t)
+
+	logFile, err := os.Create(filepath.Join(t.TempDir(), name+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := exec.Command(filepath.Join(bin, name))
+	command.Env = append(os.Environ(),
+		"HOST=127.0.0.1",
+		fmt.Sprintf("PORT=%d", port),
+		fmt.Sprintf("GRPC_PORT=%d", grpcPort),
+		"STORAGE_BACKEND=redis",
+		"REDIS_URL="+redisAddr,
+		"API_KEYS="+apiKeys,
+		"LOG_FORMAT=text",
+		"GIN_MODE=release",
+	)
+	command.Env = append(command.Env, env...)
+	command.Stdout = logFile
+	command.Stderr = logFile
+	if err := command.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		command.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		command.Process.Kill()
+		<-exited
+		logFile.Close()
+		if t.Failed() {
+			output, _ := os.ReadFile(logFile.Name())
+			t.Logf("%s log:\n%s", name, output)
+		}
+	})
+
+	s := service{
+		URL:      fmt.Sprintf("http://127.0.0.1:%d", port),
+		GRPCAddr: fmt.Sprintf("127.0.0.1:%d", grpcPort),
+	}
+	health := s.URL + "/" + strings.TrimSuffix(name, "-api") + "s/health"
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		select {
+		case <-exited:
+			t.Fatalf("%s exited before it was healthy", name)
+		default:
+		}
+		if response, err := http.Get(health); err == nil {
+			response.Body.Close()
+			if response.StatusCode == http.StatusOK {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not healthy in time", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// call sends body to url with apiKey, checks the status and decodes the
+// response into target unless it is nil
+func call(t *testing.T, apiKey string, method string, url string, body string, status int, target interface{}) {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("X-API-Key", apiKey)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != status {
+		t.Fatalf("%s %s: expected %d, got %d %s", method, url, status, response.StatusCode, data)
+	}
+	if target == nil {
+		return
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		t.Fatalf("%s %s: response %q is not JSON: %v", method, url, data, err)
+	}
+}
+
+// vote is what test.sh sends and reads back for each vote
+type vote struct {
+	VoteID, VoterID, PollOptionID uint
+	apiKey, firstName, option     string
+}
+
+var votes = []vote{
+	{1, 1, 1, "voter1-key", "Michael", "Blue"},
+	{2, 2, 2, "voter2-key", "Bob", "Brown"},
+	{3, 3, 1, "voter3-key", "Rocky", "Blue"},
+}
+
+func TestReplayScenario(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts redis-server and the three apis")
+	}
+	redisAddr := startRedis(t)
+	bin := build(t)
+
+	polls := start(t, bin, "poll-api", redisAddr)
+	voters := start(t, bin, "voter-api", redisAddr)
+	serviceEnv := []string{
+		"VOTERS_URL=" + strings.TrimPrefix(voters.URL, "http://"),
+		"POLLS_URL=" + strings.TrimPrefix(polls.URL, "http://"),
+		"VOTERS_GRPC_URL=" + voters.GRPCAddr,
+		"POLLS_GRPC_URL=" + polls.GRPCAddr,
+		"VOTERS_PUBLIC_URL=http://voters.test",
+		"POLLS_PUBLIC_URL=http://polls.test",
+	}
+	votesHTTP := start(t, bin, "vote-api", redisAddr, append(serviceEnv, "DETAIL_TRANSPORT=http")...)
+
+	call(t, "manager-key", http.MethodPost, polls.URL+"/polls/1",
+		`{"PollID": 1, "PollTitle": "Favorite Color", "PollQuestion": "What is your favorite color?"}`, http.StatusOK, nil)
+	call(t, "manager-key", http.MethodPost, polls.URL+"/polls/1/polloption/1",
+		`{"PollOptionID": 1, "PollOptionText": "Blue"}`, http.StatusOK, nil)
+	call(t, "manager-key", http.MethodPost, polls.URL+"/polls/1/polloption/2",
+		`{"PollOptionID": 2, "PollOptionText": "Brown"}`, http.StatusOK, nil)
+
+	call(t, "admin-key", http.MethodPost, voters.URL+"/voters/1",
+		`{"VoterID": 1, "FirstName": "Michael", "LastName": "Dratch"}`, http.StatusOK, nil)
+	call(t, "admin-key", http.MethodPost, voters.URL+"/voters/2",
+		`{"VoterID": 2, "FirstName": "Bob", "LastName": "Dylan"}`, http.StatusOK, nil)
+	call(t, "admin-key", http.MethodPost, voters.URL+"/voters/3",
+		`{"VoterID": 3, "FirstName": "Rocky", "LastName": "Balboa"}`, http.StatusOK, nil)
+
+	for _, v := range votes {
+		body := fmt.Sprintf(`{"VoteID": %d, "VoterID": %d, "PollID": 1, "PollOptionID": %d}`, v.VoteID, v.VoterID, v.PollOptionID)
+		call(t, v.apiKey, http.MethodPost, fmt.Sprintf("%s/votes/%d", votesHTTP.URL, v.VoteID), body, http.StatusOK, nil)
+	}
+	call(t, "voter1-key", http.MethodPost, votesHTTP.URL+"/votes/4",
+		`{"VoteID": 4, "VoterID": 2, "PollID": 1, "PollOptionID": 1}`, http.StatusForbidden, nil)
+
+	for _, v := range votes {
+		var got api.VoteV1
+		call(t, "reader-key", http.MethodGet, fmt.Sprintf("%s/votes/%d", votesHTTP.URL, v.VoteID), "", http.StatusOK, &got)
+		if got.Voter != fmt.Sprintf("http://voters.test/voters/%d", v.VoterID) ||
+			got.PollOption != fmt.Sprintf("http://polls.test/polls/1/polloption/%d", v.PollOptionID) {
+			t.Errorf("unexpected vote %+v", got)
+		}
+	}
+
+	votesGRPC := start(t, bin, "vote-api", redisAddr, append(serviceEnv, "DETAIL_TRANSPORT=grpc")...)
+	for transport, votesAPI := range map[string]service{"http": votesHTTP, "grpc": votesGRPC} {
+		t.Run("details over "+transport, func(t *testing.T) {
+			for _, v := range votes {
+				var got api.VoteDetailsV1
+				call(t, "reader-key", http.MethodGet, fmt.Sprintf("%s/votes/%d?detail=true", votesAPI.URL, v.VoteID), "", http.StatusOK, &got)
+				if got.Voter.VoterID != v.VoterID || got.Voter.FirstName != v.firstName ||
+					got.Poll.PollTitle != "Favorite Color" || got.PollOption.PollOptionText != v.option {
+					t.Errorf("unexpected details of vote %d: %+v", v.VoteID, got)
+				}
+			}
+		})
+	}
+
+	var report api.ResultsReport
+	call(t, "reader-key", http.MethodGet, votesHTTP.URL+"/polls/1/results", "", http.StatusOK, &report)
+	if report.TotalVotes != 3 || report.RegisteredVoters != 3 || report.Turnout != 1 {
+		t.Errorf("unexpected results %+v", report)
+	}
+	for _, option := range report.Options {
+		if expected := map[string]uint{"Blue": 2, "Brown": 1}[option.PollOptionText]; option.Votes != expected {
+			t.Errorf("expected %d votes for %s, got %d", expected, option.PollOptionText, option.Votes)
+		}
+	}
+}
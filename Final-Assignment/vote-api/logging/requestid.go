@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	requestIDMetadata  = "x-request-id"
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying requestID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID of ctx, or "" if it has none
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// newRequestID returns a random 128 bit ID in hex
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID accepts the IDs of callers that are short and printable,
+// so they can be logged and sent on as they are
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDOrNew keeps a valid incoming ID and replaces any other with a
+// new one
+func requestIDOrNew(requestID string) string {
+	if validRequestID(requestID) {
+		return requestID
+	}
+	return newRequestID()
+}
+
+// RequestID takes the request ID from the X-Request-ID header, or makes
+// one up, returns it in the response and puts it in the request context
+// for the logs and downstream calls
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := requestIDOrNew(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := c.Request.Context()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+		c.Request = c.Request.WithContext(WithRequestID(ctx, requestID))
+		c.Next()
+	}
+}
+
+// Transport sends the request ID of each request's context on in the
+// X-Request-ID header
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return requestIDTransport{base: base}
+}
+
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFrom(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not change the request they are given
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return t.base.RoundTrip(req)
+}
+
+// UnaryServerInterceptor is RequestID and AccessLog for gRPC, reading the
+// x-request-id metadata
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = incomingRequestID(ctx)
+		start := time.Now()
+		reply, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return reply, err
+	}
+}
+
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := incomingRequestID(stream.Context())
+		start := time.Now()
+		err := handler(srv, &requestIDStream{ServerStream: stream, ctx: ctx})
+		logCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	slog.LogAttrs(ctx, slog.LevelInfo, "Call",
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("latency", time.Since(start)),
+	)
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
+
+func incomingRequestID(ctx context.Context) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	requestID = requestIDOrNew(requestID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+	return WithRequestID(ctx, requestID)
+}
+
+// UnaryClientInterceptor sends the request ID of each call's context on
+// in the x-request-id metadata
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	if requestID := RequestIDFrom(ctx); requestID != "" {
+		return metadata.AppendToOutgoingContext(ctx, requestIDMetadata, requestID)
+	}
+	return ctx
+}
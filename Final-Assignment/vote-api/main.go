@@ -1,51 +1,185 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
 
+	"shared/auth"
+	"shared/configloader"
+	"shared/httpserver"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"shared/redisclient"
+	"shared/telemetry"
+	"shared/validation"
+	"shared/versioning"
 	"votes-api/api"
+	"votes-api/config"
+	"votes-api/db"
+	"votes-api/grpcapi"
+	"votes-api/logging"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// Global variables to hold the command line flags to drive the todo CLI
-// application
+// serviceName names the service in traces
+const serviceName = "vote-api"
+
+// Global variables to hold the loaded configuration and where it came
+// from, so that it can be reloaded
 var (
-	hostFlag string
-	portFlag uint
+	settings config.Config
+	configSources *configloader.Sources
 )
 
-func processCmdLineFlags() {
-
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
+func processCmdLineFlags() error {
 
-	flag.Parse()
+	var err error
+	settings, configSources, err = config.Parse(flag.CommandLine, os.Args[1:])
+	return err
 }
 
 func main() {
-	processCmdLineFlags()
-	r := gin.Default()
-	r.Use(cors.Default())
+	if err := processCmdLineFlags(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := logging.Setup(settings.LogLevel, settings.LogFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), settings.Tracing, serviceName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := validation.Register(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.New()
+	redisClient, err := redisclient.Connect(settings.Redis)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/votes", apiHandler.ListAllVotes)
+	dbHandler, err := db.New(settings.Storage, settings.Services, redisClient)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := dbHandler.StartTimeline(context.Background(), redisClient, settings.Timeline); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiHandler := api.NewWithData(dbHandler, settings.PublicBaseURL, settings.Services)
+
+	authenticator, err := auth.New(settings.Auth)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	r.GET("/votes/:id", apiHandler.GetVote)
-	r.POST("/votes/:id", apiHandler.AddVote)
-	r.PUT("/votes/:id", apiHandler.UpdateVote)
-	r.DELETE("/votes/:id", apiHandler.DeleteVote)
+	limiter := ratelimit.New(redisClient)
+	idempotencyStore := idempotency.New(redisClient, settings.IdempotencyTTL)
+
+	v1Deprecation, err := settings.APIV1.V1Deprecation()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Log level and rate limits follow the config when it is reloaded
+	liveConfig := config.NewLive(settings, configSources)
+	liveConfig.OnReload(func(settings config.Config) {
+		logging.SetLevel(settings.LogLevel)
+		applyRateLimits(limiter, settings.RateLimits)
+	})
+	applyRateLimits(limiter, settings.RateLimits)
+
+	r := setupRouter(apiHandler, authenticator, limiter, idempotencyStore, v1Deprecation, liveConfig)
+
+	grpcPath := fmt.Sprintf("%s:%d", settings.Host, settings.GRPCPort)
+	listener, err := net.Listen("tcp", grpcPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	grpcServer := grpcapi.NewServer(dbHandler, authenticator, limiter, idempotencyStore, settings.ResultsWatchInterval)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
+
+	ctx, stop := httpserver.SignalContext()
+	defer stop()
+	liveConfig.WatchSignals(ctx)
+
+	serverPath := fmt.Sprintf("%s:%d", settings.Host, settings.Port)
+	err = settings.HTTP.Run(ctx, serverPath, r, grpcServer)
+	redisClient.Close()
+	shutdownTracing()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// applyRateLimits sets the rule of each limited route. The specs were
+// checked when the config was validated.
+func applyRateLimits(limiter *ratelimit.Limiter, limits config.RateLimits) {
+	castVoteLimit, _ := ratelimit.ParseRule(limits.CastVote)
+	limiter.SetRule("cast-vote", castVoteLimit)
+	changeVoteLimit, _ := ratelimit.ParseRule(limits.ChangeVote)
+	limiter.SetRule("change-vote", changeVoteLimit)
+	authLimit, _ := ratelimit.ParseRule(limits.Auth)
+	limiter.SetRule("auth", authLimit)
+}
+
+func setupRouter(apiHandler *api.VoteAPI, authenticator auth.Authenticator, limiter *ratelimit.Limiter,
+	idempotencyStore *idempotency.Store, v1Deprecation *versioning.Deprecation, liveConfig *config.Live) *gin.Engine {
+	r := gin.New()
+	r.Use(otelgin.Middleware(serviceName), logging.RequestID(), logging.AccessLog(), gin.Recovery())
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders(auth.AuthorizationHeader, auth.APIKeyHeader, idempotency.IdempotencyKeyHeader, logging.RequestIDHeader)
+	corsConfig.AddExposeHeaders("Deprecation", "Sunset", "Link", logging.RequestIDHeader)
+	r.Use(cors.New(corsConfig))
+
+	limitAuthFailures := limiter.FailureMiddleware("auth")
+	authenticated := r.Group("/", limitAuthFailures, auth.Middleware(authenticator))
+	routeMiddleware := api.RouteMiddleware{
+		Idempotent:      idempotencyStore.Middleware(),
+		LimitCastVote:   limiter.Middleware("cast-vote"),
+		LimitChangeVote: limiter.Middleware("change-vote"),
+	}
+
+	// The unprefixed routes are aliases of v1
+	apiHandler.Routes(authenticated.Group("/", versioning.Middleware(versioning.V1, "", v1Deprecation)), routeMiddleware)
+	apiHandler.Routes(authenticated.Group("/v1", versioning.Middleware(versioning.V1, "/v1", v1Deprecation)), routeMiddleware)
+	apiHandler.Routes(authenticated.Group("/v2", versioning.Middleware(versioning.V2, "/v2", nil)), routeMiddleware)
 
 	r.GET("/votes/health", apiHandler.HealthCheck)
-	
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
-}
\ No newline at end of file
+	r.GET("/debug/config", limitAuthFailures, auth.Middleware(authenticator), auth.RequireRole(auth.RoleAdmin), liveConfig.Handler)
+
+	r.GET("/schemas/:name", validation.SchemaHandler(map[string]map[string]interface{}{
+		"vote.json": validation.Schema("/schemas/vote.json", "VoteKeys", db.VoteKeys{}),
+	}))
+	r.GET("/openapi.json", api.ServeOpenAPIDocument)
+	r.GET("/v1/openapi.json", api.ServeOpenAPIDocument)
+	r.GET("/v2/openapi.json", api.ServeOpenAPIDocumentV2)
+
+	return r
+}
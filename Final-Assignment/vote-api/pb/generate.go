@@ -0,0 +1,7 @@
+// Package pb holds the code generated from the protobuf definitions in
+// ../../proto. Regenerate it with protoc, protoc-gen-go v1.31 and
+// protoc-gen-go-grpc v1.3 installed by running go generate in this
+// directory.
+package pb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=paths=source_relative,Mpoll/v1/poll.proto=votes-api/pb/poll/v1;pollv1,Mvoter/v1/voter.proto=votes-api/pb/voter/v1;voterv1,Mvote/v1/vote.proto=votes-api/pb/vote/v1;votev1 --go-grpc_out=. --go-grpc_opt=paths=source_relative,Mpoll/v1/poll.proto=votes-api/pb/poll/v1;pollv1,Mvoter/v1/voter.proto=votes-api/pb/voter/v1;voterv1,Mvote/v1/vote.proto=votes-api/pb/vote/v1;votev1 poll/v1/poll.proto voter/v1/voter.proto vote/v1/vote.proto
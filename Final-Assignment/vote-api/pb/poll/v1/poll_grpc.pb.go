@@ -0,0 +1,186 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.29.3
+// source: poll/v1/poll.proto
+
+// Polls as stored by the poll api. Field names follow the JSON bodies of
+// the REST api.
+
+package pollv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PollService_GetPoll_FullMethodName       = "/poll.v1.PollService/GetPoll"
+	PollService_ListPolls_FullMethodName     = "/poll.v1.PollService/ListPolls"
+	PollService_GetPollOption_FullMethodName = "/poll.v1.PollService/GetPollOption"
+)
+
+// PollServiceClient is the client API for PollService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PollServiceClient interface {
+	GetPoll(ctx context.Context, in *GetPollRequest, opts ...grpc.CallOption) (*Poll, error)
+	ListPolls(ctx context.Context, in *ListPollsRequest, opts ...grpc.CallOption) (*ListPollsResponse, error)
+	GetPollOption(ctx context.Context, in *GetPollOptionRequest, opts ...grpc.CallOption) (*PollOption, error)
+}
+
+type pollServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPollServiceClient(cc grpc.ClientConnInterface) PollServiceClient {
+	return &pollServiceClient{cc}
+}
+
+func (c *pollServiceClient) GetPoll(ctx context.Context, in *GetPollRequest, opts ...grpc.CallOption) (*Poll, error) {
+	out := new(Poll)
+	err := c.cc.Invoke(ctx, PollService_GetPoll_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pollServiceClient) ListPolls(ctx context.Context, in *ListPollsRequest, opts ...grpc.CallOption) (*ListPollsResponse, error) {
+	out := new(ListPollsResponse)
+	err := c.cc.Invoke(ctx, PollService_ListPolls_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pollServiceClient) GetPollOption(ctx context.Context, in *GetPollOptionRequest, opts ...grpc.CallOption) (*PollOption, error) {
+	out := new(PollOption)
+	err := c.cc.Invoke(ctx, PollService_GetPollOption_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PollServiceServer is the server API for PollService service.
+// All implementations must embed UnimplementedPollServiceServer
+// for forward compatibility
+type PollServiceServer interface {
+	GetPoll(context.Context, *GetPollRequest) (*Poll, error)
+	ListPolls(context.Context, *ListPollsRequest) (*ListPollsResponse, error)
+	GetPollOption(context.Context, *GetPollOptionRequest) (*PollOption, error)
+	mustEmbedUnimplementedPollServiceServer()
+}
+
+// UnimplementedPollServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPollServiceServer struct {
+}
+
+func (UnimplementedPollServiceServer) GetPoll(context.Context, *GetPollRequest) (*Poll, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPoll not implemented")
+}
+func (UnimplementedPollServiceServer) ListPolls(context.Context, *ListPollsRequest) (*ListPollsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPolls not implemented")
+}
+func (UnimplementedPollServiceServer) GetPollOption(context.Context, *GetPollOptionRequest) (*PollOption, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPollOption not implemented")
+}
+func (UnimplementedPollServiceServer) mustEmbedUnimplementedPollServiceServer() {}
+
+// UnsafePollServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PollServiceServer will
+// result in compilation errors.
+type UnsafePollServiceServer interface {
+	mustEmbedUnimplementedPollServiceServer()
+}
+
+func RegisterPollServiceServer(s grpc.ServiceRegistrar, srv PollServiceServer) {
+	s.RegisterService(&PollService_ServiceDesc, srv)
+}
+
+func _PollService_GetPoll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PollServiceServer).GetPoll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PollService_GetPoll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PollServiceServer).GetPoll(ctx, req.(*GetPollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PollService_ListPolls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPollsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PollServiceServer).ListPolls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PollService_ListPolls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PollServiceServer).ListPolls(ctx, req.(*ListPollsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PollService_GetPollOption_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPollOptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PollServiceServer).GetPollOption(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PollService_GetPollOption_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PollServiceServer).GetPollOption(ctx, req.(*GetPollOptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PollService_ServiceDesc is the grpc.ServiceDesc for PollService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PollService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "poll.v1.PollService",
+	HandlerType: (*PollServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPoll",
+			Handler:    _PollService_GetPoll_Handler,
+		},
+		{
+			MethodName: "ListPolls",
+			Handler:    _PollService_ListPolls_Handler,
+		},
+		{
+			MethodName: "GetPollOption",
+			Handler:    _PollService_GetPollOption_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "poll/v1/poll.proto",
+}
@@ -0,0 +1,255 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.29.3
+// source: vote/v1/vote.proto
+
+// Votes as stored by the vote api. Where the REST api links to the voter,
+// poll and poll option, a Vote carries their ids.
+
+package votev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VoteService_GetVote_FullMethodName      = "/vote.v1.VoteService/GetVote"
+	VoteService_ListVotes_FullMethodName    = "/vote.v1.VoteService/ListVotes"
+	VoteService_CastVote_FullMethodName     = "/vote.v1.VoteService/CastVote"
+	VoteService_WatchResults_FullMethodName = "/vote.v1.VoteService/WatchResults"
+)
+
+// VoteServiceClient is the client API for VoteService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VoteServiceClient interface {
+	GetVote(ctx context.Context, in *GetVoteRequest, opts ...grpc.CallOption) (*Vote, error)
+	ListVotes(ctx context.Context, in *ListVotesRequest, opts ...grpc.CallOption) (*ListVotesResponse, error)
+	CastVote(ctx context.Context, in *CastVoteRequest, opts ...grpc.CallOption) (*Vote, error)
+	// WatchResults sends the poll's current results and then a new message
+	// every time they change, until the client cancels.
+	WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (VoteService_WatchResultsClient, error)
+}
+
+type voteServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVoteServiceClient(cc grpc.ClientConnInterface) VoteServiceClient {
+	return &voteServiceClient{cc}
+}
+
+func (c *voteServiceClient) GetVote(ctx context.Context, in *GetVoteRequest, opts ...grpc.CallOption) (*Vote, error) {
+	out := new(Vote)
+	err := c.cc.Invoke(ctx, VoteService_GetVote_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voteServiceClient) ListVotes(ctx context.Context, in *ListVotesRequest, opts ...grpc.CallOption) (*ListVotesResponse, error) {
+	out := new(ListVotesResponse)
+	err := c.cc.Invoke(ctx, VoteService_ListVotes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voteServiceClient) CastVote(ctx context.Context, in *CastVoteRequest, opts ...grpc.CallOption) (*Vote, error) {
+	out := new(Vote)
+	err := c.cc.Invoke(ctx, VoteService_CastVote_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voteServiceClient) WatchResults(ctx context.Context, in *WatchResultsRequest, opts ...grpc.CallOption) (VoteService_WatchResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VoteService_ServiceDesc.Streams[0], VoteService_WatchResults_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &voteServiceWatchResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VoteService_WatchResultsClient interface {
+	Recv() (*PollResults, error)
+	grpc.ClientStream
+}
+
+type voteServiceWatchResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *voteServiceWatchResultsClient) Recv() (*PollResults, error) {
+	m := new(PollResults)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VoteServiceServer is the server API for VoteService service.
+// All implementations must embed UnimplementedVoteServiceServer
+// for forward compatibility
+type VoteServiceServer interface {
+	GetVote(context.Context, *GetVoteRequest) (*Vote, error)
+	ListVotes(context.Context, *ListVotesRequest) (*ListVotesResponse, error)
+	CastVote(context.Context, *CastVoteRequest) (*Vote, error)
+	// WatchResults sends the poll's current results and then a new message
+	// every time they change, until the client cancels.
+	WatchResults(*WatchResultsRequest, VoteService_WatchResultsServer) error
+	mustEmbedUnimplementedVoteServiceServer()
+}
+
+// UnimplementedVoteServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedVoteServiceServer struct {
+}
+
+func (UnimplementedVoteServiceServer) GetVote(context.Context, *GetVoteRequest) (*Vote, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVote not implemented")
+}
+func (UnimplementedVoteServiceServer) ListVotes(context.Context, *ListVotesRequest) (*ListVotesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVotes not implemented")
+}
+func (UnimplementedVoteServiceServer) CastVote(context.Context, *CastVoteRequest) (*Vote, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CastVote not implemented")
+}
+func (UnimplementedVoteServiceServer) WatchResults(*WatchResultsRequest, VoteService_WatchResultsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchResults not implemented")
+}
+func (UnimplementedVoteServiceServer) mustEmbedUnimplementedVoteServiceServer() {}
+
+// UnsafeVoteServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VoteServiceServer will
+// result in compilation errors.
+type UnsafeVoteServiceServer interface {
+	mustEmbedUnimplementedVoteServiceServer()
+}
+
+func RegisterVoteServiceServer(s grpc.ServiceRegistrar, srv VoteServiceServer) {
+	s.RegisterService(&VoteService_ServiceDesc, srv)
+}
+
+func _VoteService_GetVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoteServiceServer).GetVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoteService_GetVote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoteServiceServer).GetVote(ctx, req.(*GetVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoteService_ListVotes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVotesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoteServiceServer).ListVotes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoteService_ListVotes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoteServiceServer).ListVotes(ctx, req.(*ListVotesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoteService_CastVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CastVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoteServiceServer).CastVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoteService_CastVote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoteServiceServer).CastVote(ctx, req.(*CastVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoteService_WatchResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VoteServiceServer).WatchResults(m, &voteServiceWatchResultsServer{stream})
+}
+
+type VoteService_WatchResultsServer interface {
+	Send(*PollResults) error
+	grpc.ServerStream
+}
+
+type voteServiceWatchResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *voteServiceWatchResultsServer) Send(m *PollResults) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// VoteService_ServiceDesc is the grpc.ServiceDesc for VoteService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VoteService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vote.v1.VoteService",
+	HandlerType: (*VoteServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetVote",
+			Handler:    _VoteService_GetVote_Handler,
+		},
+		{
+			MethodName: "ListVotes",
+			Handler:    _VoteService_ListVotes_Handler,
+		},
+		{
+			MethodName: "CastVote",
+			Handler:    _VoteService_CastVote_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchResults",
+			Handler:       _VoteService_WatchResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "vote/v1/vote.proto",
+}
@@ -0,0 +1,149 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.29.3
+// source: voter/v1/voter.proto
+
+// Voters as stored by the voter api. Field names follow the JSON bodies of
+// the REST api.
+
+package voterv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VoterService_GetVoter_FullMethodName   = "/voter.v1.VoterService/GetVoter"
+	VoterService_ListVoters_FullMethodName = "/voter.v1.VoterService/ListVoters"
+)
+
+// VoterServiceClient is the client API for VoterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VoterServiceClient interface {
+	GetVoter(ctx context.Context, in *GetVoterRequest, opts ...grpc.CallOption) (*Voter, error)
+	ListVoters(ctx context.Context, in *ListVotersRequest, opts ...grpc.CallOption) (*ListVotersResponse, error)
+}
+
+type voterServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVoterServiceClient(cc grpc.ClientConnInterface) VoterServiceClient {
+	return &voterServiceClient{cc}
+}
+
+func (c *voterServiceClient) GetVoter(ctx context.Context, in *GetVoterRequest, opts ...grpc.CallOption) (*Voter, error) {
+	out := new(Voter)
+	err := c.cc.Invoke(ctx, VoterService_GetVoter_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *voterServiceClient) ListVoters(ctx context.Context, in *ListVotersRequest, opts ...grpc.CallOption) (*ListVotersResponse, error) {
+	out := new(ListVotersResponse)
+	err := c.cc.Invoke(ctx, VoterService_ListVoters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VoterServiceServer is the server API for VoterService service.
+// All implementations must embed UnimplementedVoterServiceServer
+// for forward compatibility
+type VoterServiceServer interface {
+	GetVoter(context.Context, *GetVoterRequest) (*Voter, error)
+	ListVoters(context.Context, *ListVotersRequest) (*ListVotersResponse, error)
+	mustEmbedUnimplementedVoterServiceServer()
+}
+
+// UnimplementedVoterServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedVoterServiceServer struct {
+}
+
+func (UnimplementedVoterServiceServer) GetVoter(context.Context, *GetVoterRequest) (*Voter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVoter not implemented")
+}
+func (UnimplementedVoterServiceServer) ListVoters(context.Context, *ListVotersRequest) (*ListVotersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVoters not implemented")
+}
+func (UnimplementedVoterServiceServer) mustEmbedUnimplementedVoterServiceServer() {}
+
+// UnsafeVoterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VoterServiceServer will
+// result in compilation errors.
+type UnsafeVoterServiceServer interface {
+	mustEmbedUnimplementedVoterServiceServer()
+}
+
+func RegisterVoterServiceServer(s grpc.ServiceRegistrar, srv VoterServiceServer) {
+	s.RegisterService(&VoterService_ServiceDesc, srv)
+}
+
+func _VoterService_GetVoter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVoterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).GetVoter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_GetVoter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).GetVoter(ctx, req.(*GetVoterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VoterService_ListVoters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVotersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VoterServiceServer).ListVoters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VoterService_ListVoters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VoterServiceServer).ListVoters(ctx, req.(*ListVotersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// VoterService_ServiceDesc is the grpc.ServiceDesc for VoterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VoterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "voter.v1.VoterService",
+	HandlerType: (*VoterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetVoter",
+			Handler:    _VoterService_GetVoter_Handler,
+		},
+		{
+			MethodName: "ListVoters",
+			Handler:    _VoterService_ListVoters_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "voter/v1/voter.proto",
+}
@@ -0,0 +1,5 @@
+// Package pollclient is generated from the poll API's OpenAPI document.
+// Run go generate after changing poll-api/api/openapi.json.
+package pollclient
+
+//go:generate go run ../../cmd/clientgen -spec ../../../poll-api/api/openapi.json -package pollclient -out client.gen.go
@@ -0,0 +1,5 @@
+// Package voteclient is generated from the vote API's OpenAPI document.
+// Run go generate after changing vote-api/api/openapi.json.
+package voteclient
+
+//go:generate go run ../../cmd/clientgen -spec ../../../vote-api/api/openapi.json -package voteclient -out client.gen.go
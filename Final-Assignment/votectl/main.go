@@ -0,0 +1,166 @@
+// Command votectl drives the poll, voter and vote apis from the shell, in
+// place of the curl calls of test.sh:
+//
+//	votectl poll create 1 --title "Favorite Color" --option Blue --option Brown
+//	votectl voter import voters.csv --mode upsert
+//	votectl vote cast 1 --voter 1 --poll 1 --option 1
+//	votectl results show 1 --watch
+//
+// Where the services are and the credentials to send come from a profile
+// in the config file, see profile.go, and can be overridden with flags.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"votectl/client/pollclient"
+	"votectl/client/voteclient"
+	"votectl/client/voterclient"
+
+	"github.com/spf13/cobra"
+)
+
+// Global variables to hold the flags every command takes
+var (
+	configFileFlag string
+	profileFlag    string
+	outputFlag     string
+	timeoutFlag    time.Duration
+	overrides      Profile
+)
+
+// Clients of the three apis for the selected profile, set up before any
+// command runs
+var (
+	polls  *pollclient.Client
+	voters *voterclient.Client
+	votes  *voteclient.Client
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "votectl",
+	Short: "A command line client of the poll, voter and vote apis",
+	// Errors are printed once, by main, without the usage
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(outputFlag); err != nil {
+			return err
+		}
+		config, err := loadConfig(configFileFlag)
+		if err != nil {
+			return err
+		}
+		_, profile, err := config.Select(profileFlag)
+		if err != nil {
+			return err
+		}
+		connect(profile.WithEnv().Override(overrides))
+		return nil
+	},
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&configFileFlag, "config", "", "Config file, default $VOTECTL_CONFIG or "+defaultConfigFile())
+	flags.StringVar(&profileFlag, "profile", "", "Profile of the config file to use, default $VOTECTL_PROFILE or the current one")
+	flags.StringVarP(&outputFlag, "output", "o", OutputTable, "Output format: table, json or yaml")
+	flags.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "Timeout of each request")
+	flags.StringVar(&overrides.VotesURL, "votes-url", "", "Base URL of the vote api")
+	flags.StringVar(&overrides.VotersURL, "voters-url", "", "Base URL of the voter api")
+	flags.StringVar(&overrides.PollsURL, "polls-url", "", "Base URL of the poll api")
+	flags.StringVar(&overrides.APIKey, "api-key", "", "API key to send, prefer $VOTECTL_API_KEY so it stays out of the process list")
+	flags.StringVar(&overrides.Token, "token", "", "Bearer token to send, prefer $VOTECTL_TOKEN")
+
+	rootCmd.AddCommand(pollCmd, voterCmd, voteCmd, resultsCmd, configCmd)
+}
+
+// connect points the clients at the services of profile, sending its
+// credentials with every request
+func connect(profile Profile) {
+	httpClient := &http.Client{Timeout: timeoutFlag}
+	credentials := func(req *http.Request) error {
+		if profile.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+profile.Token)
+		} else if profile.APIKey != "" {
+			req.Header.Set("X-API-Key", profile.APIKey)
+		}
+		return nil
+	}
+
+	polls = pollclient.New(strings.TrimRight(profile.PollsURL, "/"), credentials)
+	polls.HTTPClient = httpClient
+	voters = voterclient.New(strings.TrimRight(profile.VotersURL, "/"), credentials)
+	voters.HTTPClient = httpClient
+	votes = voteclient.New(strings.TrimRight(profile.VotesURL, "/"), credentials)
+	votes.HTTPClient = httpClient
+}
+
+// describe turns an error answer of a service into a message naming the
+// status and any fields that failed validation
+func describe(err error) error {
+	var status int
+	var body []byte
+	var pollErr *pollclient.APIError
+	var voterErr *voterclient.APIError
+	var voteErr *voteclient.APIError
+	switch {
+	case errors.As(err, &pollErr):
+		status, body = pollErr.StatusCode, pollErr.Body
+	case errors.As(err, &voterErr):
+		status, body = voterErr.StatusCode, voterErr.Body
+	case errors.As(err, &voteErr):
+		status, body = voteErr.StatusCode, voteErr.Body
+	default:
+		return err
+	}
+
+	message := fmt.Sprintf("Error: the service answered %d %s", status, http.StatusText(status))
+	var validation struct {
+		Errors []struct{ Field, Message string }
+	}
+	if json.Unmarshal(body, &validation) == nil && len(validation.Errors) > 0 {
+		for _, fieldError := range validation.Errors {
+			message += fmt.Sprintf("\n  %s: %s", fieldError.Field, fieldError.Message)
+		}
+	}
+	switch status {
+	case http.StatusUnauthorized:
+		message += "\nCheck the API key or token of the profile"
+	case http.StatusForbidden:
+		message += "\nThe credentials of the profile are not allowed to do this"
+	}
+	return errors.New(message)
+}
+
+// run executes the command line args and returns the exit status, 1 when
+// the command or the service it called failed
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(stderr, describe(err))
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	status := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(status)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"votectl/client/pollclient"
+	"votectl/client/voteclient"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// answer is what the fake api sends back for one route
+type answer struct {
+	status int
+	body   interface{}
+}
+
+// request is what the fake api was sent
+type request struct {
+	route  string
+	header http.Header
+	body   string
+}
+
+// fakeAPI stands in for all three apis. It answers routes, keyed by
+// "METHOD /path", and records every request.
+type fakeAPI struct {
+	routes map[string]answer
+
+	mutex    sync.Mutex
+	requests []request
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	route := r.Method + " " + r.URL.Path
+	api.mutex.Lock()
+	api.requests = append(api.requests, request{route, r.Header, string(body)})
+	api.mutex.Unlock()
+
+	answer, ok := api.routes[route]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if answer.status == 0 {
+		answer.status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(answer.status)
+	json.NewEncoder(w).Encode(answer.body)
+}
+
+func (api *fakeAPI) routesCalled() []string {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	routes := make([]string, 0, len(api.requests))
+	for _, r := range api.requests {
+		routes = append(routes, r.route)
+	}
+	return routes
+}
+
+// newFakeAPI serves routes and writes a config file whose current profile
+// points every api at it with the API key "profile-key"
+func newFakeAPI(t *testing.T, routes map[string]answer) (*fakeAPI, string) {
+	t.Helper()
+	api := &fakeAPI{routes: routes}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	for _, name := range []string{"VOTECTL_CONFIG", "VOTECTL_PROFILE", "VOTECTL_API_KEY", "VOTECTL_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	config := &Config{
+		CurrentProfile: "test",
+		Profiles: map[string]Profile{
+			"test": {VotesURL: server.URL, VotersURL: server.URL, PollsURL: server.URL + "/", APIKey: "profile-key"},
+		},
+		path: filepath.Join(t.TempDir(), "config.yaml"),
+	}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return api, config.path
+}
+
+// resetFlags puts every flag back to its default, since the commands and
+// their flags are package variables shared by all runs
+func resetFlags(cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if value, ok := flag.Value.(pflag.SliceValue); ok {
+			value.Replace(nil)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, child := range cmd.Commands() {
+		resetFlags(child)
+	}
+}
+
+// votectl runs a command line and returns its exit status and output
+func votectl(t *testing.T, configFile string, args ...string) (int, string, string) {
+	t.Helper()
+	resetFlags(rootCmd)
+	var stdout, stderr bytes.Buffer
+	status := run(context.Background(), append([]string{"--config", configFile}, args...), strings.NewReader(""), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+var pollRoutes = map[string]answer{
+	"POST /polls/1":              {body: pollclient.Poll{PollID: 1, PollTitle: "Colors"}},
+	"POST /polls/1/polloption/1": {body: pollclient.PollOption{PollOptionID: 1, PollOptionText: "Red"}},
+	"POST /polls/1/polloption/2": {body: pollclient.PollOption{PollOptionID: 2, PollOptionText: "Blue"}},
+	"GET /polls/1": {body: pollclient.Poll{PollID: 1, PollTitle: "Colors", PollQuestion: "Which?", PollOptions: []pollclient.PollOption{
+		{PollOptionID: 1, PollOptionText: "Red"}, {PollOptionID: 2, PollOptionText: "Blue"},
+	}}},
+}
+
+// The arguments and flags of a command end up in the requests it sends
+func TestFlagsBecomeRequests(t *testing.T) {
+	api, configFile := newFakeAPI(t, pollRoutes)
+
+	status, stdout, stderr := votectl(t, configFile, "poll", "create", "1", "--title", "Colors", "--question", "Which?", "--option", "Red", "--option", "Blue")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %d: %s", status, stderr)
+	}
+	want := []string{"POST /polls/1", "POST /polls/1/polloption/1", "POST /polls/1/polloption/2", "GET /polls/1"}
+	if routes := api.routesCalled(); !reflect.DeepEqual(routes, want) {
+		t.Fatalf("expected requests %v, got %v", want, routes)
+	}
+
+	var poll pollclient.Poll
+	if err := json.Unmarshal([]byte(api.requests[0].body), &poll); err != nil || poll.PollTitle != "Colors" || poll.PollQuestion != "Which?" {
+		t.Errorf("unexpected poll sent: %s", api.requests[0].body)
+	}
+	var option pollclient.PollOption
+	if err := json.Unmarshal([]byte(api.requests[2].body), &option); err != nil || option.PollOptionID != 2 || option.PollOptionText != "Blue" {
+		t.Errorf("unexpected option sent: %s", api.requests[2].body)
+	}
+	for _, r := range api.requests {
+		if r.header.Get("X-API-Key") != "profile-key" || r.header.Get("Authorization") != "" {
+			t.Errorf("%s was not sent the profile's API key: %v", r.route, r.header)
+		}
+	}
+	if !strings.Contains(stdout, "Title:     Colors") || !strings.Contains(stdout, "2       Blue") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+}
+
+// Flags win over the environment, which wins over the profile, and a token
+// is sent instead of an API key
+func TestCredentialFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		args          []string
+		apiKey, token string
+	}{
+		{"profile", nil, nil, "profile-key", ""},
+		{"environment", map[string]string{"VOTECTL_API_KEY": "env-key"}, nil, "env-key", ""},
+		{"flag", map[string]string{"VOTECTL_API_KEY": "env-key"}, []string{"--api-key", "flag-key"}, "flag-key", ""},
+		{"token", nil, []string{"--token", "secret"}, "", "Bearer secret"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api, configFile := newFakeAPI(t, pollRoutes)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			if status, _, stderr := votectl(t, configFile, append(test.args, "poll", "show", "1")...); status != 0 {
+				t.Fatalf("expected exit status 0, got %d: %s", status, stderr)
+			}
+			header := api.requests[0].header
+			if header.Get("X-API-Key") != test.apiKey || header.Get("Authorization") != test.token {
+				t.Errorf("expected API key %q and authorization %q, got %v", test.apiKey, test.token, header)
+			}
+		})
+	}
+}
+
+// Arguments and flags that cannot be right fail before anything is sent
+func TestBadArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"id", []string{"poll", "show", "abc"}, `poll id must be a positive number, got "abc"`},
+		{"zero id", []string{"vote", "show", "0"}, "vote id must be a positive number"},
+		{"missing flag", []string{"vote", "cast", "1", "--voter", "1", "--poll", "1"}, `required flag(s) "option" not set`},
+		{"argument count", []string{"poll", "show"}, "accepts 1 arg(s), received 0"},
+		{"output", []string{"-o", "xml", "poll", "show", "1"}, `unknown output format "xml"`},
+		{"profile", []string{"--profile", "staging", "poll", "show", "1"}, `no profile "staging"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api, configFile := newFakeAPI(t, pollRoutes)
+			status, _, stderr := votectl(t, configFile, test.args...)
+			if status != 1 {
+				t.Errorf("expected exit status 1, got %d", status)
+			}
+			if !strings.Contains(stderr, test.message) {
+				t.Errorf("expected %q in the error, got %q", test.message, stderr)
+			}
+			if routes := api.routesCalled(); len(routes) > 0 {
+				t.Errorf("expected no requests, got %v", routes)
+			}
+		})
+	}
+}
+
+// Results come out as a table, or as JSON and YAML with the api's field
+// names
+func TestOutputFormats(t *testing.T) {
+	report := voteclient.ResultsReport{
+		PollID:    1,
+		PollTitle: "Pets",
+		Options: []voteclient.OptionResult{
+			{PollOptionID: 1, PollOptionText: "Cat", Votes: 3, Share: 0.75},
+			{PollOptionID: 2, PollOptionText: "Dog", Votes: 1, Share: 0.25},
+		},
+		TotalVotes:       4,
+		RegisteredVoters: 8,
+		Turnout:          0.5,
+	}
+	_, configFile := newFakeAPI(t, map[string]answer{"GET /polls/1/results": {body: report}})
+
+	status, table, stderr := votectl(t, configFile, "results", "show", "1")
+	if status != 0 {
+		t.Fatalf("expected exit status 0, got %d: %s", status, stderr)
+	}
+	for _, line := range []string{
+		"Poll 1: Pets",
+		"1       Cat   3      75.0%  " + strings.Repeat("#", 23),
+		"2       Dog   1      25.0%  " + strings.Repeat("#", 8),
+		"Turnout:    50.0% of 8 registered voters",
+		"Last vote:  -",
+	} {
+		if !strings.Contains(table, line+"\n") {
+			t.Errorf("expected the line %q in the table:\n%s", line, table)
+		}
+	}
+
+	decoders := map[string]func(data []byte, v interface{}) error{
+		OutputJSON: json.Unmarshal,
+		OutputYAML: yaml.Unmarshal,
+	}
+	for output, decode := range decoders {
+		status, out, stderr := votectl(t, configFile, "results", "show", "1", "-o", output)
+		if status != 0 {
+			t.Fatalf("%s: expected exit status 0, got %d: %s", output, status, stderr)
+		}
+		var fields map[string]interface{}
+		if err := decode([]byte(out), &fields); err != nil {
+			t.Fatalf("%s: %v\n%s", output, err, out)
+		}
+		if fields["PollTitle"] != "Pets" || fields["Turnout"] != 0.5 || len(fields["Options"].([]interface{})) != 2 {
+			t.Errorf("%s: unexpected output:\n%s", output, out)
+		}
+	}
+	if _, yamlOut, _ := votectl(t, configFile, "results", "show", "1", "-o", "yaml"); !strings.HasPrefix(yamlOut, "PollID: 1\nPollTitle: Pets\n") {
+		t.Errorf("expected the YAML in the api's field order:\n%s", yamlOut)
+	}
+}
+
+// An error answer exits with status 1 and says what the service objected to
+func TestAPIErrors(t *testing.T) {
+	validation := map[string]interface{}{
+		"Errors": []map[string]string{{"Field": "PollTitle", "Message": "PollTitle is required"}},
+	}
+	tests := []struct {
+		name     string
+		answer   answer
+		messages []string
+	}{
+		{"validation", answer{http.StatusBadRequest, validation}, []string{
+			"Error: the service answered 400 Bad Request\n  PollTitle: PollTitle is required\n",
+		}},
+		{"unauthorized", answer{http.StatusUnauthorized, nil}, []string{
+			"Error: the service answered 401 Unauthorized\n", "Check the API key or token of the profile",
+		}},
+		{"forbidden", answer{http.StatusForbidden, nil}, []string{
+			"Error: the service answered 403 Forbidden\n", "not allowed to do this",
+		}},
+		{"server", answer{http.StatusInternalServerError, nil}, []string{
+			"Error: the service answered 500 Internal Server Error\n",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, configFile := newFakeAPI(t, map[string]answer{"POST /polls/1": test.answer})
+			status, stdout, stderr := votectl(t, configFile, "poll", "create", "1", "--title", "Colors", "--option", "Red")
+			if status != 1 {
+				t.Errorf("expected exit status 1, got %d", status)
+			}
+			for _, message := range test.messages {
+				if !strings.Contains(stderr, message) {
+					t.Errorf("expected %q in the error, got %q", message, stderr)
+				}
+			}
+			if stdout != "" {
+				t.Errorf("expected no output, got %q", stdout)
+			}
+		})
+	}
+
+	// An option the service refuses fails the command after the poll was created
+	routes := map[string]answer{"POST /polls/1": pollRoutes["POST /polls/1"], "POST /polls/1/polloption/1": {http.StatusConflict, nil}}
+	_, configFile := newFakeAPI(t, routes)
+	status, _, stderr := votectl(t, configFile, "poll", "create", "1", "--title", "Colors", "--option", "Red")
+	if status != 1 || !strings.Contains(stderr, "409 Conflict") {
+		t.Errorf("expected exit status 1 for a 409, got %d %q", status, stderr)
+	}
+}
+
+// A config file that cannot be read is an error, a missing one is not
+func TestConfigFile(t *testing.T) {
+	api, configFile := newFakeAPI(t, pollRoutes)
+	if err := os.WriteFile(configFile, []byte("profiles: [not, a, map"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if status, _, stderr := votectl(t, configFile, "poll", "show", "1"); status != 1 || !strings.Contains(stderr, "Error reading config file") {
+		t.Errorf("expected a config error, got %d %q", status, stderr)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	status, stdout, _ := votectl(t, missing, "config", "show", "-o", "json")
+	if status != 0 || !strings.Contains(stdout, `"VotesURL": "http://localhost:1080"`) {
+		t.Errorf("expected the default profile, got %d %s", status, stdout)
+	}
+	if routes := api.routesCalled(); len(routes) > 0 {
+		t.Errorf("expected no requests, got %v", routes)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"votectl/client/pollclient"
+
+	"github.com/spf13/cobra"
+)
+
+// Global variables to hold the flags of the poll commands
+var (
+	pollTitleFlag      string
+	pollQuestionFlag   string
+	pollOptionsFlag    []string
+	pollOptionTextFlag string
+)
+
+var pollCmd = &cobra.Command{
+	Use:   "poll",
+	Short: "Create and read polls",
+}
+
+var pollCreateCmd = &cobra.Command{
+	Use:     "create POLL_ID",
+	Short:   "Create a poll, with options numbered from 1 in the order given",
+	Example: `  votectl poll create 1 --title "Favorite Color" --question "What is your favorite color?" --option Blue --option Brown`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("poll id", args[0])
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		poll := pollclient.Poll{PollID: id, PollTitle: pollTitleFlag, PollQuestion: pollQuestionFlag}
+		if _, err := polls.CreatePoll(ctx, id, poll); err != nil {
+			return err
+		}
+		for i, text := range pollOptionsFlag {
+			optionID := uint(i + 1)
+			option := pollclient.PollOption{PollOptionID: optionID, PollOptionText: text}
+			if _, err := polls.CreatePollOption(ctx, id, optionID, option); err != nil {
+				return fmt.Errorf("Error adding option %d to poll %d: %w", optionID, id, err)
+			}
+		}
+
+		created, err := polls.GetPoll(ctx, id)
+		if err != nil {
+			return err
+		}
+		return renderPoll(cmd, created)
+	},
+}
+
+var pollListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every poll",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := polls.ListPolls(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return render(cmd, list, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "ID\tTITLE\tQUESTION\tOPTIONS")
+			for _, poll := range list {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", poll.PollID, poll.PollTitle, poll.PollQuestion, len(poll.PollOptions))
+			}
+		})
+	},
+}
+
+var pollShowCmd = &cobra.Command{
+	Use:   "show POLL_ID",
+	Short: "Show a poll and its options",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("poll id", args[0])
+		if err != nil {
+			return err
+		}
+		poll, err := polls.GetPoll(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		return renderPoll(cmd, poll)
+	},
+}
+
+var pollAddOptionCmd = &cobra.Command{
+	Use:     "add-option POLL_ID OPTION_ID",
+	Short:   "Add an option to a poll",
+	Example: `  votectl poll add-option 1 3 --text Green`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("poll id", args[0])
+		if err != nil {
+			return err
+		}
+		optionID, err := parseID("option id", args[1])
+		if err != nil {
+			return err
+		}
+		option := pollclient.PollOption{PollOptionID: optionID, PollOptionText: pollOptionTextFlag}
+		created, err := polls.CreatePollOption(cmd.Context(), id, optionID, option)
+		if err != nil {
+			return err
+		}
+		return render(cmd, created, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "OPTION\tTEXT")
+			fmt.Fprintf(w, "%d\t%s\n", created.PollOptionID, created.PollOptionText)
+		})
+	},
+}
+
+func renderPoll(cmd *cobra.Command, poll pollclient.Poll) error {
+	return render(cmd, poll, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Poll:\t%d\n", poll.PollID)
+		fmt.Fprintf(w, "Title:\t%s\n", poll.PollTitle)
+		fmt.Fprintf(w, "Question:\t%s\n", poll.PollQuestion)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "OPTION\tTEXT")
+		for _, option := range poll.PollOptions {
+			fmt.Fprintf(w, "%d\t%s\n", option.PollOptionID, option.PollOptionText)
+		}
+	})
+}
+
+// parseID reads an id argument, which the apis number from 1
+func parseID(name string, value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("Error: %s must be a positive number, got %q", name, value)
+	}
+	return uint(id), nil
+}
+
+func init() {
+	pollCreateCmd.Flags().StringVar(&pollTitleFlag, "title", "", "Title of the poll")
+	pollCreateCmd.Flags().StringVar(&pollQuestionFlag, "question", "", "Question the poll asks")
+	pollCreateCmd.Flags().StringArrayVar(&pollOptionsFlag, "option", nil, "Text of an option, repeat for each option")
+	pollCreateCmd.MarkFlagRequired("title")
+
+	pollAddOptionCmd.Flags().StringVar(&pollOptionTextFlag, "text", "", "Text of the option")
+	pollAddOptionCmd.MarkFlagRequired("text")
+
+	pollCmd.AddCommand(pollCreateCmd, pollListCmd, pollShowCmd, pollAddOptionCmd)
+}
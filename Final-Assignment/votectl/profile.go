@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is used when the config file names no current profile,
+// and holds the addresses docker-compose.yml publishes
+const DefaultProfile = "default"
+
+// Profile is where one deployment of the services is and the credentials
+// to send it. A token, when set, is sent instead of the API key.
+type Profile struct {
+	VotesURL  string `yaml:"votes_url,omitempty"`
+	VotersURL string `yaml:"voters_url,omitempty"`
+	PollsURL  string `yaml:"polls_url,omitempty"`
+	APIKey    string `yaml:"api_key,omitempty"`
+	Token     string `yaml:"token,omitempty"`
+}
+
+// Config is the config file:
+//
+//	current_profile: local
+//	profiles:
+//	  local:
+//	    votes_url: http://localhost:1080
+//	    voters_url: http://localhost:1081
+//	    polls_url: http://localhost:1082
+//	    api_key: admin-key
+type Config struct {
+	CurrentProfile string             `yaml:"current_profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+
+	path string
+}
+
+func defaultProfile() Profile {
+	return Profile{
+		VotesURL:  "http://localhost:1080",
+		VotersURL: "http://localhost:1081",
+		PollsURL:  "http://localhost:1082",
+	}
+}
+
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "votectl.yaml"
+	}
+	return filepath.Join(dir, "votectl", "config.yaml")
+}
+
+// loadConfig reads path, $VOTECTL_CONFIG or the default file. A missing
+// file is an empty config.
+func loadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("VOTECTL_CONFIG")
+	}
+	if path == "" {
+		path = defaultConfigFile()
+	}
+
+	config := &Config{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Error reading config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Save writes the config back to its file, readable only by its owner
+// since profiles hold credentials
+func (config *Config) Save() error {
+	data, err := marshalYAML(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(config.path), 0o700); err != nil {
+		return fmt.Errorf("Error writing config file: %w", err)
+	}
+	if err := os.WriteFile(config.path, data, 0o600); err != nil {
+		return fmt.Errorf("Error writing config file: %w", err)
+	}
+	return nil
+}
+
+// Select returns the profile name, $VOTECTL_PROFILE or the current one, on
+// top of the defaults. Only the default profile may be missing.
+func (config *Config) Select(name string) (string, Profile, error) {
+	if name == "" {
+		name = os.Getenv("VOTECTL_PROFILE")
+	}
+	if name == "" {
+		name = config.CurrentProfile
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok && name != DefaultProfile {
+		return "", Profile{}, fmt.Errorf("Error: no profile %q in %s", name, config.path)
+	}
+	return name, defaultProfile().Override(profile), nil
+}
+
+// Override returns the profile with the fields set in other replaced
+func (profile Profile) Override(other Profile) Profile {
+	for _, field := range []struct{ target, value *string }{
+		{&profile.VotesURL, &other.VotesURL},
+		{&profile.VotersURL, &other.VotersURL},
+		{&profile.PollsURL, &other.PollsURL},
+		{&profile.APIKey, &other.APIKey},
+		{&profile.Token, &other.Token},
+	} {
+		if *field.value != "" {
+			*field.target = *field.value
+		}
+	}
+	return profile
+}
+
+// WithEnv takes the credentials from $VOTECTL_API_KEY and $VOTECTL_TOKEN
+// when they are set
+func (profile Profile) WithEnv() Profile {
+	return profile.Override(Profile{
+		APIKey: os.Getenv("VOTECTL_API_KEY"),
+		Token:  os.Getenv("VOTECTL_TOKEN"),
+	})
+}
+
+// mask hides all but the end of a credential
+func mask(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change the profiles of the config file",
+	// The config commands read the file themselves, so they work on one
+	// whose current profile is missing
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return checkOutput(outputFlag)
+	},
+}
+
+var showSecretsFlag bool
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the profile in use, after flags and environment",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig(configFileFlag)
+		if err != nil {
+			return err
+		}
+		name, profile, err := config.Select(profileFlag)
+		if err != nil {
+			return err
+		}
+		profile = profile.WithEnv().Override(overrides)
+		if !showSecretsFlag {
+			profile.APIKey = mask(profile.APIKey)
+			profile.Token = mask(profile.Token)
+		}
+
+		shown := struct {
+			Profile    string
+			ConfigFile string
+			VotesURL   string
+			VotersURL  string
+			PollsURL   string
+			APIKey     string `json:",omitempty"`
+			Token      string `json:",omitempty"`
+		}{name, config.path, profile.VotesURL, profile.VotersURL, profile.PollsURL, profile.APIKey, profile.Token}
+		return render(cmd, shown, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "Profile:\t%s\n", shown.Profile)
+			fmt.Fprintf(w, "Config file:\t%s\n", shown.ConfigFile)
+			fmt.Fprintf(w, "Votes:\t%s\n", shown.VotesURL)
+			fmt.Fprintf(w, "Voters:\t%s\n", shown.VotersURL)
+			fmt.Fprintf(w, "Polls:\t%s\n", shown.PollsURL)
+			if shown.Token != "" {
+				fmt.Fprintf(w, "Token:\t%s\n", shown.Token)
+			} else if shown.APIKey != "" {
+				fmt.Fprintf(w, "API key:\t%s\n", shown.APIKey)
+			}
+		})
+	},
+}
+
+var configSetProfileCmd = &cobra.Command{
+	Use:   "set-profile NAME",
+	Short: "Create or change a profile from the URL and credential flags",
+	Example: "  votectl config set-profile local --api-key admin-key\n" +
+		"  votectl config set-profile staging --votes-url https://votes.example.com --token $TOKEN",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig(configFileFlag)
+		if err != nil {
+			return err
+		}
+		if config.Profiles == nil {
+			config.Profiles = map[string]Profile{}
+		}
+		config.Profiles[args[0]] = config.Profiles[args[0]].Override(overrides)
+		if config.CurrentProfile == "" {
+			config.CurrentProfile = args[0]
+		}
+		if err := config.Save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Saved profile %s to %s\n", args[0], config.path)
+		return nil
+	},
+}
+
+var configUseProfileCmd = &cobra.Command{
+	Use:   "use-profile NAME",
+	Short: "Make a profile the current one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadConfig(configFileFlag)
+		if err != nil {
+			return err
+		}
+		if _, ok := config.Profiles[args[0]]; !ok && args[0] != DefaultProfile {
+			names := make([]string, 0, len(config.Profiles))
+			for name := range config.Profiles {
+				names = append(names, name)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("Error: no profile %q in %s, add one with set-profile", args[0], config.path)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("Error: no profile %q in %s, it has %s", args[0], config.path, strings.Join(names, ", "))
+		}
+		config.CurrentProfile = args[0]
+		return config.Save()
+	},
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&showSecretsFlag, "show-secrets", false, "Show the API key or token in full")
+	configCmd.AddCommand(configShowCmd, configSetProfileCmd, configUseProfileCmd)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"votectl/client/voteclient"
+
+	"github.com/spf13/cobra"
+)
+
+// Global variables to hold the flags of the results commands
+var (
+	resultsWatchFlag    bool
+	resultsIntervalFlag time.Duration
+)
+
+// barWidth is how many characters the bar of an option with every vote is
+const barWidth = 30
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Read the results of polls",
+}
+
+var resultsShowCmd = &cobra.Command{
+	Use:   "show POLL_ID",
+	Short: "Show a poll's results, with --watch as they change",
+	Long: "Show a poll's results. With --watch the results are fetched every --interval\n" +
+		"and shown again whenever a vote changed them, until interrupted. Tables are\n" +
+		"redrawn in place on a terminal; JSON and YAML print each new report.",
+	Example: "  votectl results show 1\n" +
+		"  votectl results show 1 --watch --interval 5s",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("poll id", args[0])
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		report, err := votes.GetPollResults(ctx, id, nil)
+		if err != nil {
+			return err
+		}
+		if !resultsWatchFlag {
+			return renderResults(cmd, report)
+		}
+		if resultsIntervalFlag <= 0 {
+			return fmt.Errorf("Error: --interval must be positive")
+		}
+
+		ticker := time.NewTicker(resultsIntervalFlag)
+		defer ticker.Stop()
+		first := true
+		var shown voteclient.ResultsReport
+		for {
+			if first || changed(shown, report) {
+				if err := showWatched(cmd, report, first); err != nil {
+					return err
+				}
+				shown, first = report, false
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return nil
+			}
+			if report, err = votes.GetPollResults(ctx, id, nil); err != nil {
+				return err
+			}
+		}
+	},
+}
+
+// changed reports whether a vote was cast, changed or deleted between two
+// reports of a poll
+func changed(before voteclient.ResultsReport, after voteclient.ResultsReport) bool {
+	if before.TotalVotes != after.TotalVotes || before.RegisteredVoters != after.RegisteredVoters ||
+		!before.LastVoteDate.Equal(after.LastVoteDate) || len(before.Options) != len(after.Options) {
+		return true
+	}
+	for i := range before.Options {
+		if before.Options[i] != after.Options[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// showWatched shows a report of --watch. A table on a terminal replaces the
+// one before; YAML documents are separated.
+func showWatched(cmd *cobra.Command, report voteclient.ResultsReport, first bool) error {
+	switch outputFlag {
+	case OutputTable:
+		if isTerminal(cmd) {
+			fmt.Fprint(cmd.OutOrStdout(), "\033[H\033[2J")
+		} else if !first {
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+	case OutputYAML:
+		if !first {
+			fmt.Fprintln(cmd.OutOrStdout(), "---")
+		}
+	}
+	return renderResults(cmd, report)
+}
+
+func isTerminal(cmd *cobra.Command) bool {
+	file, ok := cmd.OutOrStdout().(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func renderResults(cmd *cobra.Command, report voteclient.ResultsReport) error {
+	return render(cmd, report, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Poll %d: %s\n", report.PollID, report.PollTitle)
+		if report.PollQuestion != "" {
+			fmt.Fprintln(w, report.PollQuestion)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "OPTION\tTEXT\tVOTES\tSHARE\t")
+		for _, option := range report.Options {
+			bar := strings.Repeat("#", int(option.Share*barWidth+0.5))
+			fmt.Fprintf(w, "%d\t%s\t%d\t%.1f%%\t%s\n", option.PollOptionID, option.PollOptionText, option.Votes, option.Share*100, bar)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Votes:\t%d\n", report.TotalVotes)
+		fmt.Fprintf(w, "Turnout:\t%.1f%% of %d registered voters\n", report.Turnout*100, report.RegisteredVoters)
+		fmt.Fprintf(w, "Last vote:\t%s\n", date(report.LastVoteDate))
+		fmt.Fprintf(w, "As of:\t%s\n", date(report.GeneratedAt))
+	})
+}
+
+func init() {
+	resultsShowCmd.Flags().BoolVarP(&resultsWatchFlag, "watch", "w", false, "Keep showing the results as votes come in")
+	resultsShowCmd.Flags().DurationVar(&resultsIntervalFlag, "interval", 2*time.Second, "How often --watch fetches the results")
+
+	resultsCmd.AddCommand(resultsShowCmd)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"votectl/client/voteclient"
+
+	"github.com/spf13/cobra"
+)
+
+// Global variables to hold the flags of the vote commands
+var (
+	voteVoterFlag  uint
+	votePollFlag   uint
+	voteOptionFlag uint
+	voteDetailFlag bool
+)
+
+var voteCmd = &cobra.Command{
+	Use:   "vote",
+	Short: "Cast and read votes",
+}
+
+var voteCastCmd = &cobra.Command{
+	Use:     "cast VOTE_ID",
+	Short:   "Cast a vote for an option of a poll",
+	Example: `  votectl vote cast 1 --voter 1 --poll 1 --option 1`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("vote id", args[0])
+		if err != nil {
+			return err
+		}
+		keys := voteclient.VoteKeys{VoteID: id, VoterID: voteVoterFlag, PollID: votePollFlag, PollOptionID: voteOptionFlag}
+		vote, err := votes.CastVote(cmd.Context(), id, keys)
+		if err != nil {
+			return err
+		}
+		return renderVote(cmd, vote)
+	},
+}
+
+var voteShowCmd = &cobra.Command{
+	Use:   "show VOTE_ID",
+	Short: "Show a vote, with --detail the voter, poll and option it is for",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("vote id", args[0])
+		if err != nil {
+			return err
+		}
+		data, err := votes.GetVote(cmd.Context(), id, &voteclient.GetVoteParams{Detail: &voteDetailFlag})
+		if err != nil {
+			return err
+		}
+
+		if !voteDetailFlag {
+			var vote voteclient.Vote
+			if err := json.Unmarshal(data, &vote); err != nil {
+				return fmt.Errorf("Error reading vote: %w", err)
+			}
+			return renderVote(cmd, vote)
+		}
+
+		var vote voteclient.VoteDetails
+		if err := json.Unmarshal(data, &vote); err != nil {
+			return fmt.Errorf("Error reading vote: %w", err)
+		}
+		return render(cmd, vote, func(w *tabwriter.Writer) {
+			fmt.Fprintf(w, "Vote:\t%d\n", vote.VoteID)
+			fmt.Fprintf(w, "Voter:\t%d %s %s\n", vote.Voter.VoterID, vote.Voter.FirstName, vote.Voter.LastName)
+			fmt.Fprintf(w, "Poll:\t%d %s\n", vote.Poll.PollID, vote.Poll.PollTitle)
+			fmt.Fprintf(w, "Question:\t%s\n", vote.Poll.PollQuestion)
+			fmt.Fprintf(w, "Option:\t%d %s\n", vote.PollOption.PollOptionID, vote.PollOption.PollOptionText)
+			fmt.Fprintf(w, "Cast:\t%s\n", date(vote.VoteDate))
+		})
+	},
+}
+
+func renderVote(cmd *cobra.Command, vote voteclient.Vote) error {
+	return render(cmd, vote, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "VOTE\tVOTER\tPOLL\tOPTION\tCAST")
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			vote.VoteID, lastID(vote.Voter), lastID(vote.Poll), lastID(vote.PollOption), date(vote.VoteDate))
+	})
+}
+
+func init() {
+	voteCastCmd.Flags().UintVar(&voteVoterFlag, "voter", 0, "ID of the voter casting the vote")
+	voteCastCmd.Flags().UintVar(&votePollFlag, "poll", 0, "ID of the poll")
+	voteCastCmd.Flags().UintVar(&voteOptionFlag, "option", 0, "ID of the option voted for")
+	for _, name := range []string{"voter", "poll", "option"} {
+		voteCastCmd.MarkFlagRequired(name)
+	}
+
+	voteShowCmd.Flags().BoolVar(&voteDetailFlag, "detail", false, "Look up the voter, poll and option in their apis")
+
+	voteCmd.AddCommand(voteCastCmd, voteShowCmd)
+}
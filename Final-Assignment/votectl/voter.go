@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"votectl/client/voterclient"
+
+	"github.com/spf13/cobra"
+)
+
+// Global variables to hold the flags of the voter commands
+var (
+	voterFirstNameFlag string
+	voterLastNameFlag  string
+	importFormatFlag   string
+	importModeFlag     string
+	importDryRunFlag   bool
+)
+
+// Content types of voter imports
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+var voterCmd = &cobra.Command{
+	Use:   "voter",
+	Short: "Create and import voters",
+}
+
+var voterCreateCmd = &cobra.Command{
+	Use:     "create VOTER_ID",
+	Short:   "Create a voter",
+	Example: `  votectl voter create 1 --first-name Michael --last-name Dratch`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID("voter id", args[0])
+		if err != nil {
+			return err
+		}
+		voter := voterclient.Voter{VoterID: id, FirstName: voterFirstNameFlag, LastName: voterLastNameFlag}
+		created, err := voters.CreateVoter(cmd.Context(), id, voter)
+		if err != nil {
+			return err
+		}
+		return render(cmd, created, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "ID\tFIRST NAME\tLAST NAME")
+			fmt.Fprintf(w, "%d\t%s\t%s\n", created.VoterID, created.FirstName, created.LastName)
+		})
+	},
+}
+
+var voterImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import voters from a CSV or NDJSON file, - for stdin",
+	Long: "Import voters from a CSV file with a VoterID,FirstName,LastName header or from\n" +
+		"NDJSON, one voter object per line. The format follows the file's extension\n" +
+		"unless --format names it. Large imports run as a job on the voter api, which\n" +
+		"this command waits for.",
+	Example: "  votectl voter import voters.csv --mode upsert\n" +
+		"  cat voters.ndjson | votectl voter import - --format ndjson --dry-run",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		contentType, err := importContentType(args[0])
+		if err != nil {
+			return err
+		}
+		var input io.Reader = cmd.InOrStdin()
+		if args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("Error opening import: %w", err)
+			}
+			defer file.Close()
+			input = file
+		}
+
+		params := &voterclient.ImportVotersParams{Mode: &importModeFlag, DryRun: &importDryRunFlag}
+		report, err := voters.ImportVoters(cmd.Context(), params, contentType, input)
+		var apiErr *voterclient.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusAccepted {
+			report, err = waitForImport(cmd, apiErr.Body)
+		}
+		if err != nil {
+			return err
+		}
+		return renderImportReport(cmd, report)
+	},
+}
+
+func importContentType(name string) (string, error) {
+	format := importFormatFlag
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".csv":
+			format = "csv"
+		case ".ndjson", ".jsonl", ".json":
+			format = "ndjson"
+		default:
+			return "", fmt.Errorf("Error: cannot tell the format of %s, pass --format csv or ndjson", name)
+		}
+	}
+	switch format {
+	case "csv":
+		return csvContentType, nil
+	case "ndjson":
+		return ndjsonContentType, nil
+	}
+	return "", fmt.Errorf("Error: unknown import format %q, expected csv or ndjson", format)
+}
+
+// waitForImport polls the job the voter api answered a large import with
+// until it finishes, showing its progress on stderr
+func waitForImport(cmd *cobra.Command, accepted []byte) (voterclient.ImportReport, error) {
+	var job voterclient.ImportJob
+	if err := json.Unmarshal(accepted, &job); err != nil {
+		return voterclient.ImportReport{}, fmt.Errorf("Error reading import job: %w", err)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for job.Status == "running" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Import job %s: %d of %d rows\n", job.JobID, job.Processed, job.Total)
+		select {
+		case <-ticker.C:
+		case <-cmd.Context().Done():
+			return voterclient.ImportReport{}, fmt.Errorf("Error: stopped waiting for import job %s, it keeps running", job.JobID)
+		}
+		var err error
+		if job, err = voters.GetImportJob(cmd.Context(), job.JobID); err != nil {
+			return voterclient.ImportReport{}, err
+		}
+	}
+	if job.Status != "succeeded" {
+		return voterclient.ImportReport{}, fmt.Errorf("Error: import job %s %s: %s", job.JobID, job.Status, job.Error)
+	}
+	return job.Report, nil
+}
+
+func renderImportReport(cmd *cobra.Command, report voterclient.ImportReport) error {
+	return render(cmd, report, func(w *tabwriter.Writer) {
+		if report.DryRun {
+			fmt.Fprintln(w, "Dry run, nothing was written")
+		}
+		if report.Aborted {
+			fmt.Fprintln(w, "Aborted on a conflict, nothing was written")
+		}
+		fmt.Fprintf(w, "Rows:\t%d\n", report.Total)
+		fmt.Fprintf(w, "Created:\t%d\n", report.Created)
+		fmt.Fprintf(w, "Updated:\t%d\n", report.Updated)
+		fmt.Fprintf(w, "Skipped:\t%d\n", report.Skipped)
+		fmt.Fprintf(w, "Invalid:\t%d\n", report.Invalid)
+		fmt.Fprintf(w, "Conflicts:\t%d\n", report.Conflicts)
+		if len(report.Rows) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "ROW\tVOTER\tSTATUS\tERRORS")
+		for _, row := range report.Rows {
+			var messages []string
+			for _, fieldError := range row.Errors {
+				messages = append(messages, fieldError.Field+": "+fieldError.Message)
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", row.Row, row.VoterID, row.Status, strings.Join(messages, "; "))
+		}
+	})
+}
+
+func init() {
+	voterCreateCmd.Flags().StringVar(&voterFirstNameFlag, "first-name", "", "First name of the voter")
+	voterCreateCmd.Flags().StringVar(&voterLastNameFlag, "last-name", "", "Last name of the voter")
+	voterCreateCmd.MarkFlagRequired("first-name")
+
+	voterImportCmd.Flags().StringVar(&importFormatFlag, "format", "", "csv or ndjson, default from the file's extension")
+	voterImportCmd.Flags().StringVar(&importModeFlag, "mode", "fail", "What to do with voters that exist: fail, skip or upsert")
+	voterImportCmd.Flags().BoolVar(&importDryRunFlag, "dry-run", false, "Report what the import would do without writing")
+
+	voterCmd.AddCommand(voterCreateCmd, voterImportCmd)
+}
@@ -2,18 +2,27 @@ package api
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"shared/hal"
+	"shared/validation"
 	"voter-api/db"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-redis/redis/v8"
 )
 
 type VoterAPI struct {
-	db *db.VoterData
+	db db.VoterStore
+	publicBaseURL string
+	importAsyncRows int
+	importMaxBytes int64
+	importJobs *importJobs
 	bootTime time.Time
 	totalCalls int
 	totalErrors int
@@ -25,21 +34,34 @@ type HealthCheckData struct {
 	TotalErrors int
 }
 
-func New() (*VoterAPI, error) {
-	dbHandler, err := db.New()
+func New(storage db.Config, redisClient redis.UniversalClient, publicBaseURL string, importAsyncRows int, importMaxBytes int64) (*VoterAPI, error) {
+	dbHandler, err := db.New(storage, redisClient)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewWithData(dbHandler, publicBaseURL, importAsyncRows, importMaxBytes), nil
+}
+
+// NewWithData creates the handlers on top of an existing VoterStore, so it can
+// be shared with the gRPC server. Links are built from publicBaseURL, or
+// from the request when it is empty, imports of more than importAsyncRows
+// rows run in the background and import bodies may be up to
+// importMaxBytes long.
+func NewWithData(dbHandler db.VoterStore, publicBaseURL string, importAsyncRows int, importMaxBytes int64) *VoterAPI {
 	return &VoterAPI{   db: dbHandler, 
+						publicBaseURL: publicBaseURL,
+						importAsyncRows: importAsyncRows,
+						importMaxBytes: importMaxBytes,
+						importJobs: newImportJobs(),
 						bootTime: time.Now(),
 						totalCalls: 0,
-						totalErrors: 0,}, nil
+						totalErrors: 0,}
 }
 
 func (voterAPI *VoterAPI) ListAllVoters(c *gin.Context) {
 	voterAPI.totalCalls++
-	voterList, err := voterAPI.db.GetAllVoters()
+	voterList, err := voterAPI.db.GetAllVoters(c.Request.Context())
 	if err != nil {
 		voterAPI.handleInternalServerError(c, "Error Getting All Voters: ", err)
 		return
@@ -49,7 +71,11 @@ func (voterAPI *VoterAPI) ListAllVoters(c *gin.Context) {
 		voterList = make([]db.Voter, 0)
 	}
 
-	c.JSON(http.StatusOK, voterList)
+	hal.Collection(c, http.StatusOK, "voters", voterList, func(voter db.Voter) interface{} {
+		return presentVoter(c, voter)
+	}, func(voter db.Voter) hal.Links {
+		return voterAPI.voterLinks(c, voter)
+	}, voterAPI.votersLinks(c))
 }
 
 func (voterAPI *VoterAPI) GetVoter(c *gin.Context) {
@@ -60,13 +86,13 @@ func (voterAPI *VoterAPI) GetVoter(c *gin.Context) {
 		return
 	}
 
-	voter, err := voterAPI.db.GetVoter(id)
+	voter, err := voterAPI.db.GetVoter(c.Request.Context(), id)
 	if err != nil {
 		voterAPI.handleBadRequestError(c, "Voter not found: ", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, voter)
+	hal.JSON(c, http.StatusOK, presentVoter(c, voter), voterAPI.voterLinks(c, voter))
 }
 
 func (voterAPI *VoterAPI) AddVoter(c *gin.Context) {
@@ -80,7 +106,7 @@ func (voterAPI *VoterAPI) AddVoter(c *gin.Context) {
 
 	var voter db.Voter
 	if err := c.ShouldBindJSON(&voter); err != nil {
-		voterAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		voterAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -89,12 +115,12 @@ func (voterAPI *VoterAPI) AddVoter(c *gin.Context) {
 		return
 	}
 
-	if err := voterAPI.db.AddVoter(voter); err != nil {
+	if err := voterAPI.db.AddVoter(c.Request.Context(), voter); err != nil {
 		voterAPI.handleInternalServerError(c, "Error adding voter: ", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, voter)
+	hal.JSON(c, http.StatusOK, presentVoter(c, voter), voterAPI.voterLinks(c, voter))
 }
 
 func (voterAPI *VoterAPI) UpdateVoter(c *gin.Context) {
@@ -105,9 +131,10 @@ func (voterAPI *VoterAPI) UpdateVoter(c *gin.Context) {
 		return
 	}
 
+	// PUT replaces the whole voter, PATCH is used to change single fields
 	var voter db.Voter
 	if err := c.ShouldBindJSON(&voter); err != nil {
-		voterAPI.handleBadRequestError(c, "Error binding JSON: ", err)
+		voterAPI.handleValidationError(c, err)
 		return
 	}
 
@@ -116,12 +143,35 @@ func (voterAPI *VoterAPI) UpdateVoter(c *gin.Context) {
 		return
 	}
 
-	err = voterAPI.db.UpdateVoter(voter.VoterID, voter)
+	err = voterAPI.db.UpdateVoter(c.Request.Context(), voter.VoterID, voter)
 	if err != nil {
 		voterAPI.handleBadRequestError(c, "Voter does not exist", err)
 		return
 	}
-	c.JSON(http.StatusOK, voter)
+	hal.JSON(c, http.StatusOK, presentVoter(c, voter), voterAPI.voterLinks(c, voter))
+}
+
+// PatchVoter accepts either a JSON Merge Patch or a JSON Patch document,
+// chosen by the Content-Type header
+func (voterAPI *VoterAPI) PatchVoter(c *gin.Context) {
+	voterAPI.totalCalls++
+	id, err := getParameterUint(c, "id")
+	if err != nil {
+		voterAPI.handleBadRequestError(c, "Error converting voter id to int", err)
+		return
+	}
+
+	patch, ok := voterAPI.patchFromRequest(c)
+	if !ok {
+		return
+	}
+
+	voter, err := voterAPI.db.PatchVoter(c.Request.Context(), id, patch, binding.Validator.ValidateStruct)
+	if err != nil {
+		voterAPI.handlePatchError(c, err)
+		return
+	}
+	hal.JSON(c, http.StatusOK, presentVoter(c, voter), voterAPI.voterLinks(c, voter))
 }
 
 func (voterAPI *VoterAPI) DeleteVoter(c *gin.Context) {
@@ -132,7 +182,11 @@ func (voterAPI *VoterAPI) DeleteVoter(c *gin.Context) {
 		return
 	}
 
-	err = voterAPI.db.DeleteVoter(id)
+	err = voterAPI.db.DeleteVoter(c.Request.Context(), id)
+	if errors.Is(err, db.ErrInUse) {
+		voterAPI.handleInUseError(c, "Error deleting voter: ", err)
+		return
+	}
 	if err != nil {
 		voterAPI.handleBadRequestError(c, "Voter does not exist", err)
 		return
@@ -141,6 +195,39 @@ func (voterAPI *VoterAPI) DeleteVoter(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (voterAPI *VoterAPI) patchFromRequest(c *gin.Context) (db.Patch, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		voterAPI.handleBadRequestError(c, "Error reading patch: ", err)
+		return nil, false
+	}
+
+	patch := db.PatchFromContentType(c.ContentType(), body)
+	if patch == nil {
+		voterAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Unsupported patch content type", "content_type", c.ContentType())
+		c.Header("Accept-Patch", db.MergePatchContentType+", "+db.JSONPatchContentType)
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	return patch, true
+}
+
+func (voterAPI *VoterAPI) handlePatchError(c *gin.Context, err error) {
+	switch {
+	case validation.IsValidationError(err):
+		voterAPI.handleValidationError(c, err)
+	case errors.Is(err, db.ErrPatchConflict):
+		voterAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error patching voter", "error", err)
+		c.AbortWithStatus(http.StatusConflict)
+	case errors.Is(err, db.ErrNotFound), errors.Is(err, db.ErrInvalidPatch), errors.Is(err, db.ErrIDChanged):
+		voterAPI.handleBadRequestError(c, "Error patching voter: ", err)
+	default:
+		voterAPI.handleInternalServerError(c, "Error patching voter: ", err)
+	}
+}
+
 func getParameterUint(c *gin.Context, name string) (uint, error) {
 	paramS := c.Param(name)
 	param64, err := strconv.ParseUint(paramS, 10, 64)
@@ -152,16 +239,31 @@ func getParameterUint(c *gin.Context, name string) (uint, error) {
 
 func (voterAPI *VoterAPI) handleBadRequestError(c *gin.Context, errorMessage string, err error) {
 	voterAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.WarnContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusBadRequest)
 }
 
+func (voterAPI *VoterAPI) handleValidationError(c *gin.Context, err error) {
+	voterAPI.totalErrors++
+	fieldErrors := validation.Errors(err)
+	slog.WarnContext(c.Request.Context(), "Error validating request body", "errors", fieldErrors)
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": fieldErrors})
+}
+
 func (voterAPI *VoterAPI) handleInternalServerError(c *gin.Context, errorMessage string, err error) {
 	voterAPI.totalErrors++
-	log.Println(errorMessage, err)
+	slog.ErrorContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
 	c.AbortWithStatus(http.StatusInternalServerError)
 }
 
+// handleInUseError answers writes the store refused because they would
+// orphan votes
+func (voterAPI *VoterAPI) handleInUseError(c *gin.Context, errorMessage string, err error) {
+	voterAPI.totalErrors++
+	slog.WarnContext(c.Request.Context(), strings.TrimRight(errorMessage, ": "), "error", err)
+	c.AbortWithStatus(http.StatusConflict)
+}
+
 func (voterAPI * VoterAPI) HealthCheck(c *gin.Context) {
 	healthData := HealthCheckData{UpTime: time.Now().Sub(voterAPI.bootTime).String(), 
 									TotalCalls: voterAPI.totalCalls,
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"shared/auth"
+	"shared/hal"
+	"shared/validation"
+	"voter-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter serves the handlers from a memory store behind API key
+// auth, as the service does, without rate limits or idempotency
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := validation.Register(); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{APIKeys: "admin-key:admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	voterAPI := NewWithData(db.NewMemory(), "http://voters.test", DefaultImportAsyncRows, DefaultImportMaxBytes)
+	r := gin.New()
+	voterAPI.Routes(r.Group("/", auth.Middleware(authenticator)), RouteMiddleware{})
+	r.GET("/voters/health", voterAPI.HealthCheck)
+	return r
+}
+
+func serve(r *gin.Engine, method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(auth.APIKeyHeader, "admin-key")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), target); err != nil {
+		t.Fatalf("response %q is not JSON: %v", recorder.Body.String(), err)
+	}
+}
+
+func addVoters(t *testing.T, r *gin.Engine) {
+	t.Helper()
+	steps := []struct{ path, body string }{
+		{"/voters/1", `{"VoterID": 1, "FirstName": "Michael", "LastName": "Dratch"}`},
+		{"/voters/2", `{"VoterID": 2, "FirstName": "Bob", "LastName": "Dylan"}`},
+	}
+	for _, step := range steps {
+		if recorder := serve(r, http.MethodPost, step.path, step.body, nil); recorder.Code != http.StatusOK {
+			t.Fatalf("POST %s: expected 200, got %d %s", step.path, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func TestAddAndGetVoter(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	recorder := serve(r, http.MethodGet, "/voters/1", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	var voter VoterV1
+	decode(t, recorder, &voter)
+	if voter != (VoterV1{VoterID: 1, FirstName: "Michael", LastName: "Dratch"}) {
+		t.Errorf("unexpected voter %+v", voter)
+	}
+
+	recorder = serve(r, http.MethodGet, "/voters", "", nil)
+	var voters []VoterV1
+	decode(t, recorder, &voters)
+	if len(voters) != 2 {
+		t.Errorf("expected 2 voters, got %d", len(voters))
+	}
+}
+
+func TestVoterRequestErrors(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown voter", http.MethodGet, "/voters/9", "", http.StatusBadRequest},
+		{"id is not a number", http.MethodGet, "/voters/one", "", http.StatusBadRequest},
+		{"ids differ", http.MethodPost, "/voters/3", `{"VoterID": 4, "FirstName": "Rocky"}`, http.StatusBadRequest},
+		{"missing first name", http.MethodPost, "/voters/3", `{"VoterID": 3}`, http.StatusBadRequest},
+		{"update unknown voter", http.MethodPut, "/voters/9", `{"VoterID": 9, "FirstName": "Rocky"}`, http.StatusBadRequest},
+		{"delete unknown voter", http.MethodDelete, "/voters/9", "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, test.method, test.path, test.body, nil)
+			if recorder.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestUpdatePatchAndDeleteVoter(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	recorder := serve(r, http.MethodPut, "/voters/2", `{"VoterID": 2, "FirstName": "Robert", "LastName": "Zimmerman"}`, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	recorder = serve(r, http.MethodPatch, "/voters/2", `[{"op": "replace", "path": "/FirstName", "value": "Bob"}]`,
+		http.Header{"Content-Type": {db.JSONPatchContentType}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("json patch: expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var voter VoterV1
+	decode(t, recorder, &voter)
+	if voter != (VoterV1{VoterID: 2, FirstName: "Bob", LastName: "Zimmerman"}) {
+		t.Errorf("unexpected voter %+v", voter)
+	}
+
+	if recorder := serve(r, http.MethodDelete, "/voters/2", "", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", recorder.Code)
+	}
+	if recorder := serve(r, http.MethodGet, "/voters/2", "", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("deleted voter: expected 400, got %d", recorder.Code)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header http.Header
+		errors []validation.FieldError
+	}{
+		{"several fields", http.MethodPost, "/voters/3", `{"LastName": "` + strings.Repeat("a", 51) + `"}`, nil,
+			[]validation.FieldError{
+				{Field: "VoterID", Rule: "required", Message: "VoterID is required"},
+				{Field: "FirstName", Rule: "required", Message: "FirstName is required"},
+				{Field: "LastName", Rule: "max", Message: "LastName must be at most 50 characters long"},
+			}},
+		{"not a name", http.MethodPut, "/voters/1", `{"VoterID": 1, "FirstName": "R2-D2"}`, nil,
+			[]validation.FieldError{{Field: "FirstName", Rule: "personname", Message: "FirstName may only contain letters, spaces, hyphens, apostrophes and periods"}}},
+		{"wrong type", http.MethodPost, "/voters/3", `{"VoterID": 3, "FirstName": 7}`, nil,
+			[]validation.FieldError{{Field: "FirstName", Rule: "type", Message: "FirstName must be a string"}}},
+		{"patched into an invalid voter", http.MethodPatch, "/voters/1", `{"FirstName": null}`, http.Header{"Content-Type": {db.MergePatchContentType}},
+			[]validation.FieldError{{Field: "FirstName", Rule: "required", Message: "FirstName is required"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve(r, test.method, test.path, test.body, test.header)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d %s", recorder.Code, recorder.Body)
+			}
+			var body struct {
+				Errors []validation.FieldError `json:"errors"`
+			}
+			decode(t, recorder, &body)
+			if !reflect.DeepEqual(body.Errors, test.errors) {
+				t.Errorf("expected %+v, got %+v", test.errors, body.Errors)
+			}
+		})
+	}
+}
+
+func TestPatchFormats(t *testing.T) {
+	mergePatch := http.Header{"Content-Type": {db.MergePatchContentType}}
+	jsonPatch := http.Header{"Content-Type": {db.JSONPatchContentType}}
+	michael := VoterV1{VoterID: 1, FirstName: "Michael", LastName: "Dratch"}
+
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		status int
+		voter  VoterV1
+	}{
+		{"merge patch sets a field", `{"FirstName": "Mike"}`, mergePatch, http.StatusOK, VoterV1{VoterID: 1, FirstName: "Mike", LastName: "Dratch"}},
+		{"merge patch null removes a field", `{"LastName": null}`, mergePatch, http.StatusOK, VoterV1{VoterID: 1, FirstName: "Michael"}},
+		{"json patch replaces", `[{"op": "replace", "path": "/LastName", "value": "Drach"}]`, jsonPatch, http.StatusOK, VoterV1{VoterID: 1, FirstName: "Michael", LastName: "Drach"}},
+		{"json patch failed test", `[{"op": "test", "path": "/FirstName", "value": "Bob"}, {"op": "remove", "path": "/LastName"}]`, jsonPatch, http.StatusBadRequest, michael},
+		{"merge patch body to json patch", `{"FirstName": "Mike"}`, jsonPatch, http.StatusBadRequest, michael},
+		{"merge patch changes the id", `{"VoterID": 2}`, mergePatch, http.StatusBadRequest, michael},
+		{"json patch changes the id", `[{"op": "replace", "path": "/VoterID", "value": 3}]`, jsonPatch, http.StatusBadRequest, michael},
+		{"plain json", `{"FirstName": "Mike"}`, http.Header{"Content-Type": {"application/json"}}, http.StatusUnsupportedMediaType, michael},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestRouter(t)
+			addVoters(t, r)
+
+			recorder := serve(r, http.MethodPatch, "/voters/1", test.body, test.header)
+			if recorder.Code != test.status {
+				t.Fatalf("expected %d, got %d %s", test.status, recorder.Code, recorder.Body)
+			}
+
+			var voter VoterV1
+			decode(t, serve(r, http.MethodGet, "/voters/1", "", nil), &voter)
+			if voter != test.voter {
+				t.Errorf("expected %+v, got %+v", test.voter, voter)
+			}
+			var other VoterV1
+			decode(t, serve(r, http.MethodGet, "/voters/2", "", nil), &other)
+			if other.FirstName != "Bob" {
+				t.Errorf("a patch should never touch another voter, got %+v", other)
+			}
+		})
+	}
+}
+
+func TestImportReportsEachRow(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	csv := "VoterID,FirstName,LastName\n1,Michael,Dratch\n3,Rocky,Balboa\n4,,Nobody\n"
+	recorder := serve(r, http.MethodPost, "/voters:import?mode=skip", csv, http.Header{"Content-Type": {CSVContentType}})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", recorder.Code, recorder.Body)
+	}
+	var report ImportReport
+	decode(t, recorder, &report)
+	if report.Total != 3 || report.Created != 1 || report.Skipped != 1 || report.Invalid != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	recorder = serve(r, http.MethodPost, "/voters:import", csv, http.Header{"Content-Type": {"text/plain"}})
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d", recorder.Code)
+	}
+}
+
+func TestAsyncImportJob(t *testing.T) {
+	r := newTestRouter(t)
+
+	ndjson := `{"VoterID": 5, "FirstName": "Ada", "LastName": "Lovelace"}` + "\n"
+	recorder := serve(r, http.MethodPost, "/voters:import?async=true", ndjson, http.Header{"Content-Type": {NDJSONContentType}})
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d %s", recorder.Code, recorder.Body)
+	}
+	var job ImportJob
+	decode(t, recorder, &job)
+	if location := recorder.Header().Get("Location"); location != "http://voters.test/voter-imports/"+job.JobID {
+		t.Errorf("unexpected Location %q", location)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status == JobRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("import job did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+		decode(t, serve(r, http.MethodGet, "/voter-imports/"+job.JobID, "", nil), &job)
+	}
+	if job.Status != JobSucceeded || job.Report == nil || job.Report.Created != 1 {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if recorder := serve(r, http.MethodGet, "/voters/5", "", nil); recorder.Code != http.StatusOK {
+		t.Errorf("imported voter: expected 200, got %d", recorder.Code)
+	}
+}
+
+// blockingStore holds every write until the import is cancelled
+type blockingStore struct {
+	db.VoterStore
+	writing chan struct{}
+}
+
+func (s blockingStore) SetVoters(ctx context.Context, voters []db.Voter, overwrite bool) ([]bool, error) {
+	close(s.writing)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestImportLimitsAndShutdown(t *testing.T) {
+	store := blockingStore{VoterStore: db.NewMemory(), writing: make(chan struct{})}
+	voterAPI := NewWithData(store, "http://voters.test", DefaultImportAsyncRows, 100)
+	r := gin.New()
+	r.POST("/voters:import", voterAPI.ImportVoters)
+	r.GET("/voter-imports/:jobid", voterAPI.GetImportJob)
+	header := http.Header{"Content-Type": {NDJSONContentType}}
+
+	ndjson := `{"VoterID": 5, "FirstName": "Ada", "LastName": "Lovelace"}` + "\n"
+	if recorder := serve(r, http.MethodPost, "/voters:import", strings.Repeat(ndjson, 2), header); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("import over the limit: expected 413, got %d", recorder.Code)
+	}
+
+	recorder := serve(r, http.MethodPost, "/voters:import?async=true", ndjson, header)
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d %s", recorder.Code, recorder.Body)
+	}
+	var job ImportJob
+	decode(t, recorder, &job)
+
+	<-store.writing
+	graceful := make(chan struct{})
+	go func() {
+		voterAPI.GracefulStop()
+		close(graceful)
+	}()
+	select {
+	case <-graceful:
+		t.Fatal("GracefulStop returned while a job was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	voterAPI.Stop()
+	<-graceful
+
+	decode(t, serve(r, http.MethodGet, "/voter-imports/"+job.JobID, "", nil), &job)
+	if job.Status != JobFailed || job.Error == "" {
+		t.Errorf("expected the job to be recorded as failed, got %+v", job)
+	}
+	if recorder := serve(r, http.MethodPost, "/voters:import?async=true", ndjson, header); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("import during shutdown: expected 503, got %d", recorder.Code)
+	}
+}
+
+func TestHALLinks(t *testing.T) {
+	r := newTestRouter(t)
+	addVoters(t, r)
+
+	recorder := serve(r, http.MethodGet, "/voters/1", "", http.Header{"Accept": {hal.MediaType}})
+	var document struct {
+		Links hal.Links `json:"_links"`
+	}
+	decode(t, recorder, &document)
+	if self := document.Links["self"].Href; self != "http://voters.test/voters/1" {
+		t.Errorf("unexpected self link %q", self)
+	}
+}
+
+func TestRequestsNeedCredentials(t *testing.T) {
+	r := newTestRouter(t)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/voters", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"shared/versioning"
+	"voter-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response bodies, one per API version. They are kept apart from the
+// struct in db so that the storage format and each version's wire format
+// can change independently.
+
+// VoterV1 is a voter as v1 has always returned it
+type VoterV1 struct {
+	VoterID   uint
+	FirstName string
+	LastName  string
+}
+
+// VoterV2 has the same fields as v1 today; it exists so that v2 can
+// change without touching v1 clients
+type VoterV2 struct {
+	VoterID   uint
+	FirstName string
+	LastName  string
+}
+
+func presentVoter(c *gin.Context, voter db.Voter) interface{} {
+	if versioning.From(c) == versioning.V2 {
+		return VoterV2{VoterID: voter.VoterID, FirstName: voter.FirstName, LastName: voter.LastName}
+	}
+	return VoterV1{VoterID: voter.VoterID, FirstName: voter.FirstName, LastName: voter.LastName}
+}
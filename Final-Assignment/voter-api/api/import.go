@@ -0,0 +1,604 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/validation"
+	"voter-api/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	CSVContentType    = "text/csv"
+	NDJSONContentType = "application/x-ndjson"
+	// Imports with more rows than this run as a job unless ?async says otherwise
+	DefaultImportAsyncRows = 1000
+	DefaultImportMaxBytes  = 32 << 20
+	// How long a job that is cut off by a shutdown gets to record that
+	jobSaveTimeout = 2 * time.Second
+)
+
+// How an import treats a voter that already exists
+const (
+	ImportUpsert = "upsert"
+	ImportSkip   = "skip"
+	ImportFail   = "fail"
+)
+
+// What happened to a row
+const (
+	RowCreated  = "created"
+	RowUpdated  = "updated"
+	RowSkipped  = "skipped"
+	RowInvalid  = "invalid"
+	RowConflict = "conflict"
+)
+
+// Import job states
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+var csvColumns = []string{"VoterID", "FirstName", "LastName"}
+
+// ImportReport counts what an import did. Rows lists every row that was
+// not created or updated, with the reason.
+type ImportReport struct {
+	Mode      string
+	DryRun    bool
+	Total     int
+	Created   int
+	Updated   int
+	Skipped   int
+	Invalid   int
+	Conflicts int
+	// Set in fail mode when a conflict stopped the import before anything
+	// was written
+	Aborted bool
+	Rows    []RowResult
+}
+
+type RowResult struct {
+	Row     int
+	VoterID uint `json:",omitempty"`
+	Status  string
+	Errors  []validation.FieldError `json:",omitempty"`
+}
+
+type ImportJob struct {
+	JobID      string
+	Status     string
+	Processed  int
+	Total      int
+	Report     *ImportReport `json:",omitempty"`
+	Error      string        `json:",omitempty"`
+	CreatedAt  time.Time
+	FinishedAt *time.Time `json:",omitempty"`
+}
+
+type importRow struct {
+	row    int
+	voter  db.Voter
+	errors []validation.FieldError
+}
+
+// ImportVoters loads a CSV or NDJSON stream of voters. ?mode picks what
+// happens to voters that already exist (fail, skip or upsert, default
+// fail) and ?dryRun=true reports the outcome without writing. Large
+// imports are answered with 202 and a job to poll.
+func (voterAPI *VoterAPI) ImportVoters(c *gin.Context) {
+	voterAPI.totalCalls++
+
+	mode := c.DefaultQuery("mode", ImportFail)
+	if mode != ImportFail && mode != ImportSkip && mode != ImportUpsert {
+		voterAPI.handleBadRequestError(c, "Error importing voters: ", fmt.Errorf("unknown mode %q, expected fail, skip or upsert", mode))
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		voterAPI.handleBadRequestError(c, "Error importing voters: dryRun must be true or false", err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, voterAPI.importMaxBytes)
+	var rows []importRow
+	switch c.ContentType() {
+	case CSVContentType:
+		rows, err = parseCSVVoters(c.Request.Body)
+	case NDJSONContentType, "application/ndjson", "application/jsonl":
+		rows, err = parseNDJSONVoters(c.Request.Body)
+	default:
+		voterAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error importing voters: unsupported content type", "content_type", c.ContentType())
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		voterAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error importing voters: body too large", "limit", tooLarge.Limit)
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		voterAPI.handleBadRequestError(c, "Error reading import: ", err)
+		return
+	}
+
+	async := len(rows) > voterAPI.importAsyncRows
+	if value, ok := c.GetQuery("async"); ok {
+		if async, err = strconv.ParseBool(value); err != nil {
+			voterAPI.handleBadRequestError(c, "Error importing voters: async must be true or false", err)
+			return
+		}
+	}
+
+	if !async {
+		report, err := voterAPI.runImport(c.Request.Context(), rows, mode, dryRun, func(int) {})
+		if err != nil {
+			voterAPI.handleInternalServerError(c, "Error importing voters: ", err)
+			return
+		}
+		status := http.StatusOK
+		if report.Aborted {
+			status = http.StatusConflict
+		}
+		c.JSON(status, report)
+		return
+	}
+
+	jobCtx, done, ok := voterAPI.importJobs.start(c.Request.Context())
+	if !ok {
+		voterAPI.totalErrors++
+		slog.WarnContext(c.Request.Context(), "Error importing voters: shutting down")
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+	job := ImportJob{JobID: newJobID(), Status: JobRunning, Total: len(rows), CreatedAt: time.Now().UTC()}
+	if err := voterAPI.db.SaveImportJob(c.Request.Context(), job.JobID, job); err != nil {
+		done()
+		voterAPI.handleInternalServerError(c, "Error creating import job: ", err)
+		return
+	}
+	go func() {
+		defer done()
+		voterAPI.runImportJob(jobCtx, job, rows, mode, dryRun)
+	}()
+
+	c.Header("Location", voterAPI.baseURL(c)+"/voter-imports/"+job.JobID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (voterAPI *VoterAPI) GetImportJob(c *gin.Context) {
+	voterAPI.totalCalls++
+
+	var job ImportJob
+	if err := voterAPI.db.GetImportJob(c.Request.Context(), c.Param("jobid"), &job); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			voterAPI.totalErrors++
+			slog.WarnContext(c.Request.Context(), "Error getting import job", "error", err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		voterAPI.handleInternalServerError(c, "Error getting import job: ", err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportVoters streams the whole roll as NDJSON, or as CSV when asked for
+// with ?format=csv or Accept: text/csv. Voters are read and written a
+// batch at a time so the roll is never held in memory.
+func (voterAPI *VoterAPI) ExportVoters(c *gin.Context) {
+	voterAPI.totalCalls++
+
+	format := c.Query("format")
+	if format == "" && c.NegotiateFormat(NDJSONContentType, CSVContentType) == CSVContentType {
+		format = "csv"
+	}
+
+	var writeBatch func([]db.Voter) error
+	if format == "csv" {
+		c.Header("Content-Type", CSVContentType+"; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="voters.csv"`)
+		writer := csv.NewWriter(c.Writer)
+		writer.Write(csvColumns)
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			voterAPI.handleInternalServerError(c, "Error exporting voters: ", err)
+			return
+		}
+		writeBatch = func(voters []db.Voter) error {
+			for _, voter := range voters {
+				record := []string{strconv.FormatUint(uint64(voter.VoterID), 10), voter.FirstName, voter.LastName}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		c.Header("Content-Type", NDJSONContentType)
+		c.Header("Content-Disposition", `attachment; filename="voters.ndjson"`)
+		encoder := json.NewEncoder(c.Writer)
+		writeBatch = func(voters []db.Voter) error {
+			for _, voter := range voters {
+				if err := encoder.Encode(presentVoter(c, voter)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	// The roll may take longer to send than the server's write timeout
+	// allows for one response
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Request.Context(), "Error clearing the write deadline of the export", "error", err)
+	}
+
+	c.Status(http.StatusOK)
+	err := voterAPI.db.ScanVoters(c.Request.Context(), func(voters []db.Voter) error {
+		if err := writeBatch(voters); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// The status line has already been sent, so all that is left is
+		// to cut the stream short
+		voterAPI.totalErrors++
+		slog.ErrorContext(c.Request.Context(), "Error exporting voters", "error", err)
+		c.Abort()
+	}
+}
+
+// runImport validates every row, checks the valid ones against redis and
+// then writes them in pipelined batches. progress is told how many rows
+// have been handled after each batch.
+func (voterAPI *VoterAPI) runImport(ctx context.Context, rows []importRow, mode string, dryRun bool, progress func(int)) (ImportReport, error) {
+	report := ImportReport{Mode: mode, DryRun: dryRun, Total: len(rows), Rows: []RowResult{}}
+
+	// Invalid rows and repeats of a VoterID are never written
+	firstRow := map[uint]int{}
+	var candidates []importRow
+	for _, row := range rows {
+		if len(row.errors) > 0 {
+			report.Invalid++
+			report.Rows = append(report.Rows, RowResult{Row: row.row, VoterID: row.voter.VoterID, Status: RowInvalid, Errors: row.errors})
+			continue
+		}
+		if first, ok := firstRow[row.voter.VoterID]; ok {
+			report.Conflicts++
+			report.Rows = append(report.Rows, RowResult{Row: row.row, VoterID: row.voter.VoterID, Status: RowConflict, Errors: []validation.FieldError{{
+				Field:   "VoterID",
+				Rule:    "unique",
+				Message: fmt.Sprintf("VoterID %d already appears on row %d", row.voter.VoterID, first),
+			}}})
+			continue
+		}
+		firstRow[row.voter.VoterID] = row.row
+		candidates = append(candidates, row)
+	}
+
+	// Find the voters that already exist, a batch at a time
+	existing := map[uint]bool{}
+	for start := 0; start < len(candidates); start += db.BulkBatchSize {
+		batch := candidates[start:batchEnd(start, len(candidates))]
+		voterIDs := make([]uint, len(batch))
+		for i, row := range batch {
+			voterIDs[i] = row.voter.VoterID
+		}
+		found, err := voterAPI.db.ExistingVoters(ctx, voterIDs)
+		if err != nil {
+			return report, err
+		}
+		for voterID := range found {
+			existing[voterID] = true
+		}
+	}
+
+	var writes []importRow
+	for _, row := range candidates {
+		switch {
+		case !existing[row.voter.VoterID]:
+			writes = append(writes, row)
+		case mode == ImportUpsert:
+			writes = append(writes, row)
+		case mode == ImportSkip:
+			report.Skipped++
+			report.Rows = append(report.Rows, RowResult{Row: row.row, VoterID: row.voter.VoterID, Status: RowSkipped})
+		default:
+			report.Conflicts++
+			report.Rows = append(report.Rows, voterExists(row))
+		}
+	}
+
+	if mode == ImportFail && report.Conflicts > 0 {
+		report.Aborted = true
+		return report, nil
+	}
+
+	if dryRun {
+		for _, row := range writes {
+			countWrite(&report, existing[row.voter.VoterID])
+		}
+		return report, nil
+	}
+
+	// Only upserts overwrite, so a voter created by someone else since the
+	// check above is never clobbered
+	handled := len(rows) - len(writes)
+	progress(handled)
+	for start := 0; start < len(writes); start += db.BulkBatchSize {
+		batch := writes[start:batchEnd(start, len(writes))]
+		voters := make([]db.Voter, len(batch))
+		for i, row := range batch {
+			voters[i] = row.voter
+		}
+
+		written, err := voterAPI.db.SetVoters(ctx, voters, mode == ImportUpsert)
+		if err != nil {
+			return report, err
+		}
+		for i, row := range batch {
+			switch {
+			case written[i]:
+				countWrite(&report, existing[row.voter.VoterID])
+			case mode == ImportSkip:
+				report.Skipped++
+				report.Rows = append(report.Rows, RowResult{Row: row.row, VoterID: row.voter.VoterID, Status: RowSkipped})
+			default:
+				report.Conflicts++
+				report.Rows = append(report.Rows, voterExists(row))
+			}
+		}
+
+		handled += len(batch)
+		progress(handled)
+	}
+
+	return report, nil
+}
+
+// runImportJob outlives the request that started it, so ctx is not
+// cancelled with the request but keeps its request and trace IDs for the
+// logs. It is cancelled when shutdown stops waiting for the job, which is
+// then recorded as failed.
+func (voterAPI *VoterAPI) runImportJob(ctx context.Context, job ImportJob, rows []importRow, mode string, dryRun bool) {
+	report, err := voterAPI.runImport(ctx, rows, mode, dryRun, func(processed int) {
+		job.Processed = processed
+		if err := voterAPI.db.SaveImportJob(ctx, job.JobID, job); err != nil {
+			slog.ErrorContext(ctx, "Error saving import job progress", "error", err)
+		}
+	})
+
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	job.Processed = job.Total
+	job.Report = &report
+	job.Status = JobSucceeded
+	if err != nil && ctx.Err() != nil {
+		slog.WarnContext(ctx, "Import job stopped by shutdown", "job_id", job.JobID, "error", err)
+		job.Status = JobFailed
+		job.Error = "import stopped because the service shut down"
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Error running import job", "job_id", job.JobID, "error", err)
+		job.Status = JobFailed
+		job.Error = err.Error()
+	} else if report.Aborted {
+		job.Status = JobFailed
+		job.Error = "import aborted because voters already exist"
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobSaveTimeout)
+	defer cancel()
+	if err := voterAPI.db.SaveImportJob(saveCtx, job.JobID, job); err != nil {
+		slog.ErrorContext(ctx, "Error saving import job", "job_id", job.JobID, "error", err)
+	}
+}
+
+// importJobs tracks the import jobs running in the background, so that
+// shutdown waits for them like for requests in flight
+type importJobs struct {
+	lock     sync.Mutex
+	running  sync.WaitGroup
+	stopping bool
+	// Cancelled once shutdown stops waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newImportJobs() *importJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &importJobs{ctx: ctx, cancel: cancel}
+}
+
+// start registers a job started by the request with ctx. The job runs
+// with the returned context and calls done when it ends. No jobs start
+// once shutdown has begun.
+func (jobs *importJobs) start(ctx context.Context) (context.Context, func(), bool) {
+	jobs.lock.Lock()
+	defer jobs.lock.Unlock()
+	if jobs.stopping {
+		return nil, nil, false
+	}
+
+	jobs.running.Add(1)
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(jobs.ctx, cancel)
+	return jobCtx, func() {
+		stop()
+		cancel()
+		jobs.running.Done()
+	}, true
+}
+
+func (jobs *importJobs) stop() {
+	jobs.lock.Lock()
+	defer jobs.lock.Unlock()
+	jobs.stopping = true
+}
+
+// GracefulStop waits for the running import jobs to finish. With Stop it
+// lets httpserver.Config.Run shut the jobs down alongside the server.
+func (voterAPI *VoterAPI) GracefulStop() {
+	voterAPI.importJobs.stop()
+	voterAPI.importJobs.running.Wait()
+}
+
+// Stop cuts the running import jobs off and waits for them to record
+// that they failed
+func (voterAPI *VoterAPI) Stop() {
+	voterAPI.importJobs.stop()
+	voterAPI.importJobs.cancel()
+	voterAPI.importJobs.running.Wait()
+}
+
+func batchEnd(start int, total int) int {
+	if start+db.BulkBatchSize < total {
+		return start + db.BulkBatchSize
+	}
+	return total
+}
+
+func countWrite(report *ImportReport, existed bool) {
+	if existed {
+		report.Updated++
+	} else {
+		report.Created++
+	}
+}
+
+func voterExists(row importRow) RowResult {
+	return RowResult{Row: row.row, VoterID: row.voter.VoterID, Status: RowConflict, Errors: []validation.FieldError{{
+		Field:   "VoterID",
+		Rule:    "exists",
+		Message: fmt.Sprintf("voter %d already exists", row.voter.VoterID),
+	}}}
+}
+
+// parseCSVVoters reads a CSV with a header row naming its columns, in any
+// order and case. Rows are numbered from 1, not counting the header.
+func parseCSVVoters(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		column := ""
+		for _, known := range csvColumns {
+			if strings.EqualFold(strings.TrimSpace(name), known) {
+				column = known
+			}
+		}
+		if column == "" {
+			return nil, fmt.Errorf("unknown CSV column %q, expected %s", name, strings.Join(csvColumns, ", "))
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["VoterID"]; !ok {
+		return nil, errors.New("CSV has no VoterID column")
+	}
+
+	var rows []importRow
+	for number := 1; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		row := importRow{row: number}
+		if err != nil {
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return nil, err
+			}
+			row.errors = []validation.FieldError{{Rule: "csv", Message: err.Error()}}
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		voterID, err := strconv.ParseUint(field("VoterID"), 10, 0)
+		if err != nil {
+			row.errors = []validation.FieldError{{Field: "VoterID", Rule: "type", Message: "VoterID must be a non-negative integer"}}
+			rows = append(rows, row)
+			continue
+		}
+		row.voter = db.Voter{VoterID: uint(voterID), FirstName: field("FirstName"), LastName: field("LastName")}
+		rows = append(rows, validateRow(row))
+	}
+}
+
+// parseNDJSONVoters reads one voter object per line. Blank lines are
+// skipped but still counted, so row numbers match line numbers.
+func parseNDJSONVoters(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		row := importRow{row: number}
+		if err := json.Unmarshal([]byte(line), &row.voter); err != nil {
+			row.errors = validation.Errors(err)
+			rows = append(rows, row)
+			continue
+		}
+		rows = append(rows, validateRow(row))
+	}
+	return rows, scanner.Err()
+}
+
+func validateRow(row importRow) importRow {
+	if err := binding.Validator.ValidateStruct(&row.voter); err != nil {
+		row.errors = validation.Errors(err)
+	}
+	return row
+}
+
+func newJobID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"shared/auth"
+	"shared/hal"
+	"shared/versioning"
+	"voter-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Links are built from the service's public base URL so that they work
+// for clients outside the docker network. Action links are only listed
+// when the caller is allowed to perform them.
+
+// baseURL includes the version prefix of the request's route group
+func (voterAPI *VoterAPI) baseURL(c *gin.Context) string {
+	return hal.BaseURL(c, voterAPI.publicBaseURL) + versioning.Prefix(c)
+}
+
+func canManageVoters(c *gin.Context) bool {
+	principal := auth.PrincipalFrom(c)
+	return principal != nil && principal.HasRole(auth.RoleAdmin)
+}
+
+func (voterAPI *VoterAPI) votersLinks(c *gin.Context) hal.Links {
+	base := voterAPI.baseURL(c)
+	links := hal.Links{
+		"self":  {Href: base + "/voters"},
+		"voter": {Href: base + "/voters/{id}", Templated: true},
+	}
+	if canManageVoters(c) {
+		links.Action("create", http.MethodPost, base+"/voters/{id}")
+	}
+	return links
+}
+
+func (voterAPI *VoterAPI) voterLinks(c *gin.Context, voter db.Voter) hal.Links {
+	base := voterAPI.baseURL(c)
+	self := fmt.Sprintf("%s/voters/%d", base, voter.VoterID)
+	links := hal.Links{
+		"self":       {Href: self},
+		"collection": {Href: base + "/voters"},
+	}
+	if canManageVoters(c) {
+		links.Action("update", http.MethodPut, self)
+		links.Action("patch", http.MethodPatch, self)
+		links.Action("delete", http.MethodDelete, self)
+	}
+	return links
+}
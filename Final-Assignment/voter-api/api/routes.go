@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"shared/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteMiddleware is what the routes run besides authorization. Idempotent
+// guards the create route and LimitWrites every write. Nil fields are
+// skipped.
+type RouteMiddleware struct {
+	Idempotent  gin.HandlerFunc
+	LimitWrites gin.HandlerFunc
+}
+
+// Routes registers the voter routes on group, which authenticates its
+// requests, each behind the roles it requires. The service registers them
+// once for each API version.
+func (voterAPI *VoterAPI) Routes(group *gin.RouterGroup, middleware RouteMiddleware) {
+	anyRole := auth.RequireRole()
+	admin := auth.RequireRole(auth.RoleAdmin)
+	idempotent := orNext(middleware.Idempotent)
+	limitWrites := orNext(middleware.LimitWrites)
+
+	group.GET("/voters", anyRole, voterAPI.ListAllVoters)
+
+	group.GET("/voters/:id", anyRole, voterAPI.GetVoter)
+	group.POST("/voters/:id", admin, idempotent, limitWrites, voterAPI.AddVoter)
+	group.PUT("/voters/:id", admin, limitWrites, voterAPI.UpdateVoter)
+	group.PATCH("/voters/:id", admin, limitWrites, voterAPI.PatchVoter)
+	group.DELETE("/voters/:id", admin, limitWrites, voterAPI.DeleteVoter)
+
+	group.POST("/voters:import", customMethod("import"), admin, limitWrites, voterAPI.ImportVoters)
+	group.GET("/voters:export", customMethod("export"), anyRole, voterAPI.ExportVoters)
+	group.GET("/voter-imports/:jobid", admin, voterAPI.GetImportJob)
+}
+
+// customMethod guards a custom method route such as /voters:import. gin
+// reads ":import" as a path parameter named "import" that also matches
+// /votersxyz, so anything other than the literal method name is a 404.
+func customMethod(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param(name) != ":"+name {
+			c.AbortWithStatus(http.StatusNotFound)
+		}
+	}
+}
+
+// orNext is handler, or one that passes the request on when it is nil
+func orNext(handler gin.HandlerFunc) gin.HandlerFunc {
+	if handler == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return handler
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"shared/auth"
+	"shared/idempotency"
+	"shared/ratelimit"
+	"voter-api/api"
+	"voter-api/config"
+	"voter-api/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// voteAPIContract is what the vote api reads from this service. The vote
+// api owns it; a change here that breaks it fails this test.
+const voteAPIContract = "../vote-api/contracts/voter-api.json"
+
+type contract struct {
+	Consumer     string
+	Provider     string
+	Interactions []struct {
+		Description string
+		State       string
+		Request     struct {
+			Method  string
+			Path    string
+			Headers map[string]string
+		}
+		Response struct {
+			Status int
+			Body   json.RawMessage
+		}
+	}
+}
+
+// providerStates loads the data each state of the contract names
+var providerStates = map[string]func(ctx context.Context, store db.VoterStore) error{
+	"voter 1 exists": func(ctx context.Context, store db.VoterStore) error {
+		return store.AddVoter(ctx, db.Voter{VoterID: 1, FirstName: "Michael", LastName: "Dratch"})
+	},
+}
+
+func TestMeetsVoteAPIContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	data, err := os.ReadFile(voteAPIContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var voteAPI contract
+	if err := json.Unmarshal(data, &voteAPI); err != nil {
+		t.Fatal(err)
+	}
+	if voteAPI.Provider != serviceName {
+		t.Fatalf("%s is a contract with %s", voteAPIContract, voteAPI.Provider)
+	}
+
+	authenticator, err := auth.New(auth.Config{APIKeys: "reader-key:reader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, interaction := range voteAPI.Interactions {
+		t.Run(interaction.Description, func(t *testing.T) {
+			store := db.NewMemory()
+			setup, ok := providerStates[interaction.State]
+			if !ok {
+				t.Fatalf("unknown provider state %q", interaction.State)
+			}
+			if err := setup(context.Background(), store); err != nil {
+				t.Fatal(err)
+			}
+			r := setupRouter(api.NewWithData(store, "", api.DefaultImportAsyncRows, api.DefaultImportMaxBytes), authenticator,
+				&ratelimit.Limiter{}, &idempotency.Store{}, nil, &config.Live{})
+
+			req := httptest.NewRequest(interaction.Request.Method, interaction.Request.Path, nil)
+			for name, value := range interaction.Request.Headers {
+				req.Header.Set(name, value)
+			}
+			req.Header.Set(auth.APIKeyHeader, "reader-key")
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			if recorder.Code != interaction.Response.Status {
+				t.Fatalf("expected status %d, got %d", interaction.Response.Status, recorder.Code)
+			}
+			if len(interaction.Response.Body) == 0 {
+				return
+			}
+			var expected, actual interface{}
+			if err := json.Unmarshal(interaction.Response.Body, &expected); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("response %q is not JSON: %v", recorder.Body, err)
+			}
+			for _, mismatch := range matchJSON("body", expected, actual) {
+				t.Error(mismatch)
+			}
+		})
+	}
+}
+
+// matchJSON lists where actual lacks a field of expected or holds a
+// different value. Fields expected does not name are ignored.
+func matchJSON(path string, expected interface{}, actual interface{}) []string {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		object, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %v", path, actual)}
+		}
+		var mismatches []string
+		for name, value := range expected {
+			field, ok := object[name]
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s.%s is missing", path, name))
+				continue
+			}
+			mismatches = append(mismatches, matchJSON(path+"."+name, value, field)...)
+		}
+		return mismatches
+	case []interface{}:
+		array, ok := actual.([]interface{})
+		if !ok || len(array) != len(expected) {
+			return []string{fmt.Sprintf("%s: expected %d items, got %v", path, len(expected), actual)}
+		}
+		var mismatches []string
+		for i := range expected {
+			mismatches = append(mismatches, matchJSON(fmt.Sprintf("%s[%d]", path, i), expected[i], array[i])...)
+		}
+		return mismatches
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		return []string{fmt.Sprintf("%s: expected %v, got %v", path, expected, actual)}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"shared/redisclient"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	RedisImportJobKeyPrefix = "voter-import:"
+	// How many voters go into one pipeline or SCAN call
+	BulkBatchSize = 500
+	// How long the status of an import job is kept after it was last written
+	importJobTTL = 24 * time.Hour
+)
+
+// ExistingVoters reports which of voterIDs are stored, using one pipelined
+// EXISTS per voter
+func (v *VoterData) ExistingVoters(ctx context.Context, voterIDs []uint) (map[uint]bool, error) {
+	existing := make(map[uint]bool, len(voterIDs))
+	if len(voterIDs) == 0 {
+		return existing, nil
+	}
+
+	commands := make([]*redis.IntCmd, len(voterIDs))
+	_, err := v.cacheClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, voterID := range voterIDs {
+			commands[i] = pipe.Exists(ctx, redisVoterKeyFromId(int(voterID)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, command := range commands {
+		if command.Val() > 0 {
+			existing[voterIDs[i]] = true
+		}
+	}
+	return existing, nil
+}
+
+// SetVoters writes voters in one pipeline. Without overwrite a voter that
+// already exists is left alone. The result says for each voter whether it
+// was written.
+func (v *VoterData) SetVoters(ctx context.Context, voters []Voter, overwrite bool) ([]bool, error) {
+	written := make([]bool, len(voters))
+	if len(voters) == 0 {
+		return written, nil
+	}
+
+	commands := make([]*redis.Cmd, len(voters))
+	_, err := v.cacheClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, voter := range voters {
+			voterObject, err := json.Marshal(voter)
+			if err != nil {
+				return err
+			}
+			args := []interface{}{"JSON.SET", redisVoterKeyFromId(int(voter.VoterID)), ".", voterObject}
+			if !overwrite {
+				args = append(args, "NX")
+			}
+			commands[i] = pipe.Do(ctx, args...)
+		}
+		return nil
+	})
+	if err != nil && !isRedisNilError(err) {
+		return nil, err
+	}
+
+	for i, command := range commands {
+		err := command.Err()
+		switch {
+		case err == nil:
+			written[i] = true
+		case isRedisNilError(err):
+			// NX found an existing voter
+		default:
+			return nil, err
+		}
+	}
+	return written, nil
+}
+
+// ScanVoters walks every stored voter with SCAN, handing them to fn in
+// batches of up to BulkBatchSize. Unlike GetAllVoters it never holds the
+// whole roll in memory. In a cluster each master is scanned in turn.
+func (v *VoterData) ScanVoters(ctx context.Context, fn func([]Voter) error) error {
+	return redisclient.ForEachNode(ctx, v.cacheClient, func(ctx context.Context, node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, RedisVoterKeyPrefix+"*", BulkBatchSize).Result()
+			if err != nil {
+				return err
+			}
+
+			voters, err := v.getVoters(ctx, keys)
+			if err != nil {
+				return err
+			}
+			if len(voters) > 0 {
+				if err := fn(voters); err != nil {
+					return err
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	})
+}
+
+// getVoters reads keys in one pipeline, skipping keys deleted in between
+func (v *VoterData) getVoters(ctx context.Context, keys []string) ([]Voter, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	commands := make([]*redis.Cmd, len(keys))
+	_, err := v.cacheClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			commands[i] = pipe.Do(ctx, "JSON.GET", key, ".")
+		}
+		return nil
+	})
+	if err != nil && !isRedisNilError(err) {
+		return nil, err
+	}
+
+	voters := make([]Voter, 0, len(keys))
+	for _, command := range commands {
+		document, err := command.Text()
+		if err != nil {
+			if isRedisNilError(err) {
+				continue
+			}
+			return nil, err
+		}
+		var voter Voter
+		if err := json.Unmarshal([]byte(document), &voter); err != nil {
+			return nil, err
+		}
+		voters = append(voters, voter)
+	}
+	return voters, nil
+}
+
+// SaveImportJob stores the status of an import job. Jobs are kept in redis
+// so that any replica can answer for them.
+func (v *VoterData) SaveImportJob(ctx context.Context, jobID string, job interface{}) error {
+	jobObject, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return v.cacheClient.Set(ctx, RedisImportJobKeyPrefix+jobID, jobObject, importJobTTL).Err()
+}
+
+func (v *VoterData) GetImportJob(ctx context.Context, jobID string, job interface{}) error {
+	jobObject, err := v.cacheClient.Get(ctx, RedisImportJobKeyPrefix+jobID).Bytes()
+	if err != nil {
+		if isRedisNilError(err) {
+			return fmt.Errorf("%w: import job %s", ErrNotFound, jobID)
+		}
+		return err
+	}
+	return json.Unmarshal(jobObject, job)
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryVoterData keeps voters in a map, like the VoterList the api
+// started out with. Nothing survives a restart, so it is meant for tests
+// and local development.
+type MemoryVoterData struct {
+	mutex      sync.RWMutex
+	voters     map[uint]Voter
+	importJobs map[string]memoryImportJob
+}
+
+type memoryImportJob struct {
+	document []byte
+	expires  time.Time
+}
+
+func NewMemory() *MemoryVoterData {
+	return &MemoryVoterData{
+		voters:     map[uint]Voter{},
+		importJobs: map[string]memoryImportJob{},
+	}
+}
+
+// sortedVoters lists the stored voters by id; the caller holds the lock
+func (v *MemoryVoterData) sortedVoters() []Voter {
+	var voters []Voter
+	for _, voter := range v.voters {
+		voters = append(voters, voter)
+	}
+	sort.Slice(voters, func(i, j int) bool { return voters[i].VoterID < voters[j].VoterID })
+	return voters
+}
+
+func (v *MemoryVoterData) GetAllVoters(ctx context.Context) ([]Voter, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	return v.sortedVoters(), nil
+}
+
+func (v *MemoryVoterData) GetVoter(ctx context.Context, voterID uint) (Voter, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	voter, ok := v.voters[voterID]
+	if !ok {
+		return Voter{}, ErrNotFound
+	}
+	return voter, nil
+}
+
+func (v *MemoryVoterData) AddVoter(ctx context.Context, voter Voter) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.voters[voter.VoterID]; ok {
+		return errors.New("item already exists")
+	}
+
+	newVoter, _ := NewVoter(voter.VoterID, voter.FirstName, voter.LastName)
+	v.voters[voter.VoterID] = *newVoter
+	return nil
+}
+
+func (v *MemoryVoterData) UpdateVoter(ctx context.Context, voterID uint, updateData Voter) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.voters[voterID]; !ok {
+		return errors.New("Item does not exist")
+	}
+
+	v.voters[voterID] = updateData
+	return nil
+}
+
+// PatchVoter holds the write lock while the patch is applied, so
+// concurrent writes are never lost
+func (v *MemoryVoterData) PatchVoter(ctx context.Context, voterID uint, patch Patch, validate func(interface{}) error) (Voter, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	voter, ok := v.voters[voterID]
+	if !ok {
+		return Voter{}, ErrNotFound
+	}
+	document, err := json.Marshal(voter)
+	if err != nil {
+		return Voter{}, err
+	}
+
+	patchedDocument, err := patch.Apply(document)
+	if err != nil {
+		return Voter{}, err
+	}
+
+	var patchedVoter Voter
+	if err := json.Unmarshal(patchedDocument, &patchedVoter); err != nil {
+		return Voter{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	if patchedVoter.VoterID != voterID {
+		return Voter{}, ErrIDChanged
+	}
+	if err := validate(&patchedVoter); err != nil {
+		return Voter{}, err
+	}
+
+	v.voters[voterID] = patchedVoter
+	return patchedVoter, nil
+}
+
+func (v *MemoryVoterData) DeleteVoter(ctx context.Context, voterID uint) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.voters[voterID]; !ok {
+		return errors.New("Attempted to delete a non-existent voter")
+	}
+	delete(v.voters, voterID)
+	return nil
+}
+
+func (v *MemoryVoterData) ExistingVoters(ctx context.Context, voterIDs []uint) (map[uint]bool, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	existing := make(map[uint]bool, len(voterIDs))
+	for _, voterID := range voterIDs {
+		if _, ok := v.voters[voterID]; ok {
+			existing[voterID] = true
+		}
+	}
+	return existing, nil
+}
+
+func (v *MemoryVoterData) SetVoters(ctx context.Context, voters []Voter, overwrite bool) ([]bool, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	written := make([]bool, len(voters))
+	for i, voter := range voters {
+		if _, ok := v.voters[voter.VoterID]; ok && !overwrite {
+			continue
+		}
+		v.voters[voter.VoterID] = voter
+		written[i] = true
+	}
+	return written, nil
+}
+
+// ScanVoters hands fn a snapshot of the roll in batches of up to
+// BulkBatchSize, so fn may write to the store
+func (v *MemoryVoterData) ScanVoters(ctx context.Context, fn func([]Voter) error) error {
+	v.mutex.RLock()
+	voters := v.sortedVoters()
+	v.mutex.RUnlock()
+
+	for start := 0; start < len(voters); start += BulkBatchSize {
+		end := start + BulkBatchSize
+		if end > len(voters) {
+			end = len(voters)
+		}
+		if err := fn(voters[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveImportJob keeps a copy of the job's JSON so that callers never share
+// it with the store. Jobs expire like they do in redis.
+func (v *MemoryVoterData) SaveImportJob(ctx context.Context, jobID string, job interface{}) error {
+	jobObject, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	now := time.Now()
+	for id, stored := range v.importJobs {
+		if now.After(stored.expires) {
+			delete(v.importJobs, id)
+		}
+	}
+	v.importJobs[jobID] = memoryImportJob{document: jobObject, expires: now.Add(importJobTTL)}
+	return nil
+}
+
+func (v *MemoryVoterData) GetImportJob(ctx context.Context, jobID string, job interface{}) error {
+	v.mutex.RLock()
+	stored, ok := v.importJobs[jobID]
+	v.mutex.RUnlock()
+
+	if !ok || time.Now().After(stored.expires) {
+		return fmt.Errorf("%w: import job %s", ErrNotFound, jobID)
+	}
+	return json.Unmarshal(stored.document, job)
+}
@@ -0,0 +1,47 @@
+-- The poll, voter and vote apis share one database so that votes can
+-- reference voters, polls and poll options with foreign keys. Every api
+-- embeds the same migrations and whichever starts first applies them.
+
+CREATE TABLE polls (
+    poll_id BIGINT PRIMARY KEY,
+    poll_title TEXT NOT NULL,
+    poll_question TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE poll_options (
+    poll_id BIGINT NOT NULL REFERENCES polls (poll_id) ON DELETE CASCADE,
+    poll_option_id BIGINT NOT NULL,
+    poll_option_text TEXT NOT NULL,
+    -- Keeps the options in the order they were added
+    position INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, poll_option_id)
+);
+
+CREATE TABLE voters (
+    voter_id BIGINT PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE voter_import_jobs (
+    job_id TEXT PRIMARY KEY,
+    document JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Voters, polls and options with votes cannot be deleted. Renumbering an
+-- option carries its votes along.
+CREATE TABLE votes (
+    vote_id BIGINT PRIMARY KEY,
+    voter_id BIGINT NOT NULL,
+    poll_id BIGINT NOT NULL,
+    poll_option_id BIGINT NOT NULL,
+    vote_date TIMESTAMPTZ NOT NULL,
+    CONSTRAINT votes_voter_fkey FOREIGN KEY (voter_id) REFERENCES voters (voter_id),
+    CONSTRAINT votes_poll_fkey FOREIGN KEY (poll_id) REFERENCES polls (poll_id),
+    CONSTRAINT votes_poll_option_fkey FOREIGN KEY (poll_id, poll_option_id)
+        REFERENCES poll_options (poll_id, poll_option_id) ON UPDATE CASCADE,
+    CONSTRAINT votes_one_per_voter_and_poll UNIQUE (voter_id, poll_id)
+);
+
+CREATE INDEX votes_poll_idx ON votes (poll_id, poll_option_id);
@@ -0,0 +1,301 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	PostgresDefaultLocation = "postgres://postgres@0.0.0.0:5432/postgres?sslmode=disable"
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	postgresForeignKeyViolation = "23503"
+)
+
+// PostgresVoterData keeps voters in the voters table and import jobs in
+// voter_import_jobs. Votes reference voters, so voters who voted cannot be
+// deleted.
+type PostgresVoterData struct {
+	db *sql.DB
+}
+
+func NewPostgres(location string) (*PostgresVoterData, error) {
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("Error connecting to postgres: %w", err)
+	}
+
+	if err := Migrate(database); err != nil {
+		database.Close()
+		return nil, err
+	}
+
+	return &PostgresVoterData{db: database}, nil
+}
+
+// inUse turns the error of a write that would orphan votes into ErrInUse
+func inUse(err error) error {
+	var postgresError *pq.Error
+	if errors.As(err, &postgresError) && postgresError.Code == postgresForeignKeyViolation {
+		return fmt.Errorf("%w: %s", ErrInUse, postgresError.Message)
+	}
+	return err
+}
+
+const voterColumns = `voter_id, first_name, last_name`
+
+func scanVoter(row interface{ Scan(...interface{}) error }) (Voter, error) {
+	var voter Voter
+	if err := row.Scan(&voter.VoterID, &voter.FirstName, &voter.LastName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Voter{}, ErrNotFound
+		}
+		return Voter{}, err
+	}
+	return voter, nil
+}
+
+func (v *PostgresVoterData) queryVoters(ctx context.Context, query string, args ...interface{}) ([]Voter, error) {
+	rows, err := v.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var voters []Voter
+	for rows.Next() {
+		voter, err := scanVoter(rows)
+		if err != nil {
+			return nil, err
+		}
+		voters = append(voters, voter)
+	}
+	return voters, rows.Err()
+}
+
+func (v *PostgresVoterData) GetAllVoters(ctx context.Context) ([]Voter, error) {
+	return v.queryVoters(ctx, `SELECT `+voterColumns+` FROM voters ORDER BY voter_id`)
+}
+
+func (v *PostgresVoterData) GetVoter(ctx context.Context, voterID uint) (Voter, error) {
+	return scanVoter(v.db.QueryRowContext(ctx, `SELECT `+voterColumns+` FROM voters WHERE voter_id = $1`, voterID))
+}
+
+func (v *PostgresVoterData) AddVoter(ctx context.Context, voter Voter) error {
+	result, err := v.db.ExecContext(ctx, `INSERT INTO voters (voter_id, first_name, last_name) VALUES ($1, $2, $3)
+		ON CONFLICT (voter_id) DO NOTHING`, voter.VoterID, voter.FirstName, voter.LastName)
+	if err != nil {
+		return err
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("item already exists")
+	}
+	return nil
+}
+
+func (v *PostgresVoterData) UpdateVoter(ctx context.Context, voterID uint, updateData Voter) error {
+	result, err := v.db.ExecContext(ctx, `UPDATE voters SET first_name = $2, last_name = $3 WHERE voter_id = $1`,
+		voterID, updateData.FirstName, updateData.LastName)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errors.New("Item does not exist")
+	}
+	return nil
+}
+
+// PatchVoter locks the voter's row for the length of a transaction, so
+// concurrent writes are never lost
+func (v *PostgresVoterData) PatchVoter(ctx context.Context, voterID uint, patch Patch, validate func(interface{}) error) (Voter, error) {
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Voter{}, err
+	}
+	defer tx.Rollback()
+
+	voter, err := scanVoter(tx.QueryRowContext(ctx, `SELECT `+voterColumns+` FROM voters WHERE voter_id = $1 FOR UPDATE`, voterID))
+	if err != nil {
+		return Voter{}, err
+	}
+	document, err := json.Marshal(voter)
+	if err != nil {
+		return Voter{}, err
+	}
+
+	patchedDocument, err := patch.Apply(document)
+	if err != nil {
+		return Voter{}, err
+	}
+
+	var patchedVoter Voter
+	if err := json.Unmarshal(patchedDocument, &patchedVoter); err != nil {
+		return Voter{}, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	if patchedVoter.VoterID != voterID {
+		return Voter{}, ErrIDChanged
+	}
+	if err := validate(&patchedVoter); err != nil {
+		return Voter{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE voters SET first_name = $2, last_name = $3 WHERE voter_id = $1`,
+		voterID, patchedVoter.FirstName, patchedVoter.LastName)
+	if err != nil {
+		return Voter{}, err
+	}
+	return patchedVoter, tx.Commit()
+}
+
+// DeleteVoter fails with ErrInUse if the voter has voted
+func (v *PostgresVoterData) DeleteVoter(ctx context.Context, voterID uint) error {
+	result, err := v.db.ExecContext(ctx, `DELETE FROM voters WHERE voter_id = $1`, voterID)
+	if err != nil {
+		return inUse(err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("Attempted to delete a non-existent voter")
+	}
+	return nil
+}
+
+func voterIDArray(voterIDs []uint) pq.Int64Array {
+	ids := make(pq.Int64Array, len(voterIDs))
+	for i, voterID := range voterIDs {
+		ids[i] = int64(voterID)
+	}
+	return ids
+}
+
+func (v *PostgresVoterData) ExistingVoters(ctx context.Context, voterIDs []uint) (map[uint]bool, error) {
+	existing := make(map[uint]bool, len(voterIDs))
+	if len(voterIDs) == 0 {
+		return existing, nil
+	}
+
+	rows, err := v.db.QueryContext(ctx, `SELECT voter_id FROM voters WHERE voter_id = ANY($1)`, voterIDArray(voterIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var voterID uint
+		if err := rows.Scan(&voterID); err != nil {
+			return nil, err
+		}
+		existing[voterID] = true
+	}
+	return existing, rows.Err()
+}
+
+// SetVoters writes voters in one transaction. Without overwrite a voter
+// that already exists is left alone.
+func (v *PostgresVoterData) SetVoters(ctx context.Context, voters []Voter, overwrite bool) ([]bool, error) {
+	written := make([]bool, len(voters))
+	if len(voters) == 0 {
+		return written, nil
+	}
+
+	query := `INSERT INTO voters (voter_id, first_name, last_name) VALUES ($1, $2, $3)
+		ON CONFLICT (voter_id) DO NOTHING`
+	if overwrite {
+		query = `INSERT INTO voters (voter_id, first_name, last_name) VALUES ($1, $2, $3)
+		ON CONFLICT (voter_id) DO UPDATE SET first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name`
+	}
+
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer statement.Close()
+
+	for i, voter := range voters {
+		result, err := statement.ExecContext(ctx, voter.VoterID, voter.FirstName, voter.LastName)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		written[i] = affected > 0
+	}
+	return written, tx.Commit()
+}
+
+// ScanVoters pages through the voters by id, handing them to fn in batches
+// of up to BulkBatchSize
+func (v *PostgresVoterData) ScanVoters(ctx context.Context, fn func([]Voter) error) error {
+	var after int64 = -1
+	for {
+		voters, err := v.queryVoters(ctx, `SELECT `+voterColumns+` FROM voters WHERE voter_id > $1
+			ORDER BY voter_id LIMIT $2`, after, BulkBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(voters) == 0 {
+			return nil
+		}
+		if err := fn(voters); err != nil {
+			return err
+		}
+		after = int64(voters[len(voters)-1].VoterID)
+	}
+}
+
+func (v *PostgresVoterData) SaveImportJob(ctx context.Context, jobID string, job interface{}) error {
+	jobObject, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = v.db.ExecContext(ctx, `DELETE FROM voter_import_jobs WHERE expires_at < now()`)
+	if err != nil {
+		return err
+	}
+	_, err = v.db.ExecContext(ctx, `INSERT INTO voter_import_jobs (job_id, document, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (job_id) DO UPDATE SET document = EXCLUDED.document, expires_at = EXCLUDED.expires_at`,
+		jobID, string(jobObject), time.Now().Add(importJobTTL))
+	return err
+}
+
+func (v *PostgresVoterData) GetImportJob(ctx context.Context, jobID string, job interface{}) error {
+	var jobObject []byte
+	err := v.db.QueryRowContext(ctx, `SELECT document FROM voter_import_jobs
+		WHERE job_id = $1 AND expires_at > now()`, jobID).Scan(&jobObject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: import job %s", ErrNotFound, jobID)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jobObject, job)
+}
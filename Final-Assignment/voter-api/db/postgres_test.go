@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startPostgres runs a throwaway postgres cluster for one test and returns
+// its address. initdb and postgres are looked up in POSTGRES_BIN, for
+// example /usr/lib/postgresql/16/bin, and then on the PATH. The test is
+// skipped when they are not installed.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	binaries := map[string]string{}
+	for _, name := range []string{"initdb", "postgres"} {
+		if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+			binaries[name] = filepath.Join(dir, name)
+		} else if path, err := exec.LookPath(name); err == nil {
+			binaries[name] = path
+		} else {
+			t.Skip("postgres is not installed, set POSTGRES_BIN to the directory holding initdb and postgres")
+		}
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres refuses to run as root")
+	}
+
+	dataDir := t.TempDir()
+	initdb := exec.Command(binaries["initdb"], "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, output)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := exec.Command(binaries["postgres"], "-D", dataDir, "-p", fmt.Sprint(port),
+		"-c", "listen_addresses=127.0.0.1", "-c", "unix_socket_directories="+dataDir, "-c", "fsync=off")
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Process.Signal(os.Interrupt)
+		server.Wait()
+	})
+
+	location := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	database, err := sql.Open("postgres", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for database.Ping() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("postgres did not start")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return location
+}
+
+func newTestPostgres(t *testing.T) *PostgresVoterData {
+	t.Helper()
+	voters, err := NewPostgres(startPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { voters.db.Close() })
+	return voters
+}
+
+func TestPostgresVoters(t *testing.T) {
+	voters := newTestPostgres(t)
+	ctx := context.Background()
+
+	if err := voters.AddVoter(ctx, Voter{VoterID: 1, FirstName: "Ada", LastName: "Lovelace"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := voters.AddVoter(ctx, Voter{VoterID: 1, FirstName: "Again"}); err == nil {
+		t.Error("adding a voter twice should fail")
+	}
+	if err := voters.UpdateVoter(ctx, 1, Voter{VoterID: 1, FirstName: "Ada", LastName: "King"}); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := voters.PatchVoter(ctx, 1, MergePatch(`{"FirstName":"Augusta"}`), func(interface{}) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter, err := voters.GetVoter(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Voter{VoterID: 1, FirstName: "Augusta", LastName: "King"}); voter != want || patched != want {
+		t.Errorf("got %+v and patched %+v, want %+v", voter, patched, want)
+	}
+
+	// A vote makes the voter undeletable
+	_, err = voters.db.Exec(`INSERT INTO polls (poll_id, poll_title) VALUES (1, 'Lunch');
+		INSERT INTO poll_options (poll_id, poll_option_id, poll_option_text, position) VALUES (1, 1, 'Soup', 0);
+		INSERT INTO votes (vote_id, voter_id, poll_id, poll_option_id, vote_date) VALUES (1, 1, 1, 1, now())`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := voters.DeleteVoter(ctx, 1); !errors.Is(err, ErrInUse) {
+		t.Errorf("deleting a voter who voted should fail with ErrInUse, got %v", err)
+	}
+	if _, err := voters.db.Exec(`DELETE FROM votes`); err != nil {
+		t.Fatal(err)
+	}
+	if err := voters.DeleteVoter(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := voters.GetVoter(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestPostgresBulkVoters(t *testing.T) {
+	voters := newTestPostgres(t)
+	ctx := context.Background()
+
+	roll := make([]Voter, 2*BulkBatchSize+10)
+	for i := range roll {
+		roll[i] = Voter{VoterID: uint(i + 1), FirstName: fmt.Sprint("Voter", i+1)}
+	}
+	written, err := voters.SetVoters(ctx, roll, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range written {
+		if !ok {
+			t.Fatalf("voter %d was not written", roll[i].VoterID)
+		}
+	}
+
+	changed := []Voter{{VoterID: 1, FirstName: "Changed"}, {VoterID: 100000, FirstName: "New"}}
+	written, err = voters.SetVoters(ctx, changed, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, []bool{false, true}) {
+		t.Errorf("without overwrite only the new voter should be written, got %v", written)
+	}
+	written, err = voters.SetVoters(ctx, changed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, []bool{true, true}) {
+		t.Errorf("with overwrite both voters should be written, got %v", written)
+	}
+
+	existing, err := voters.ExistingVoters(ctx, []uint{1, 2, 99999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(existing, map[uint]bool{1: true, 2: true}) {
+		t.Errorf("unexpected existing voters %v", existing)
+	}
+
+	scanned := 0
+	var last uint
+	err = voters.ScanVoters(ctx, func(batch []Voter) error {
+		if len(batch) > BulkBatchSize {
+			t.Errorf("batch of %d voters is larger than %d", len(batch), BulkBatchSize)
+		}
+		for _, voter := range batch {
+			if voter.VoterID <= last {
+				t.Errorf("voter %d scanned out of order", voter.VoterID)
+			}
+			last = voter.VoterID
+		}
+		scanned += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != len(roll)+1 {
+		t.Errorf("scanned %d voters, want %d", scanned, len(roll)+1)
+	}
+}
+
+func TestPostgresImportJobs(t *testing.T) {
+	voters := newTestPostgres(t)
+	ctx := context.Background()
+
+	type job struct{ State string }
+	if err := voters.SaveImportJob(ctx, "a", job{"running"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := voters.SaveImportJob(ctx, "a", job{"succeeded"}); err != nil {
+		t.Fatal(err)
+	}
+	var stored job
+	if err := voters.GetImportJob(ctx, "a", &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != "succeeded" {
+		t.Errorf("expected the last saved state, got %q", stored.State)
+	}
+	if err := voters.GetImportJob(ctx, "missing", &stored); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
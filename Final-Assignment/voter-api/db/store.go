@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The storage backends, picked with STORAGE_BACKEND or storage.backend
+const (
+	RedisBackend    = "redis"
+	MemoryBackend   = "memory"
+	PostgresBackend = "postgres"
+)
+
+// ErrInUse is returned by stores that keep votes alongside voters, when a
+// write would remove a voter who has voted
+var ErrInUse = errors.New("record is referenced by votes")
+
+// VoterStore is everything the REST and gRPC handlers need from a storage
+// backend. VoterData (RedisJSON), MemoryVoterData and PostgresVoterData
+// implement it.
+type VoterStore interface {
+	GetAllVoters(ctx context.Context) ([]Voter, error)
+	GetVoter(ctx context.Context, voterID uint) (Voter, error)
+	AddVoter(ctx context.Context, voter Voter) error
+	UpdateVoter(ctx context.Context, voterID uint, updateData Voter) error
+	PatchVoter(ctx context.Context, voterID uint, patch Patch, validate func(interface{}) error) (Voter, error)
+	DeleteVoter(ctx context.Context, voterID uint) error
+
+	// Bulk access for imports and exports
+	ExistingVoters(ctx context.Context, voterIDs []uint) (map[uint]bool, error)
+	SetVoters(ctx context.Context, voters []Voter, overwrite bool) ([]bool, error)
+	ScanVoters(ctx context.Context, fn func([]Voter) error) error
+	SaveImportJob(ctx context.Context, jobID string, job interface{}) error
+	GetImportJob(ctx context.Context, jobID string, job interface{}) error
+}
+
+var (
+	_ VoterStore = (*VoterData)(nil)
+	_ VoterStore = (*MemoryVoterData)(nil)
+	_ VoterStore = (*PostgresVoterData)(nil)
+)
+
+// Config picks the storage backend
+type Config struct {
+	Backend     string `config:"backend" env:"STORAGE_BACKEND" usage:"Storage backend: redis, memory or postgres"`
+	DatabaseURL string `config:"database_url" env:"DATABASE_URL" usage:"Postgres connection URL" secret:"url"`
+}
+
+func Defaults() Config {
+	return Config{Backend: RedisBackend, DatabaseURL: PostgresDefaultLocation}
+}
+
+// Validate checks the backend name
+func (config Config) Validate() error {
+	switch config.Backend {
+	case RedisBackend, MemoryBackend, PostgresBackend:
+		return nil
+	}
+	return fmt.Errorf("Error: STORAGE_BACKEND must be redis, memory or postgres, got %s", config.Backend)
+}
+
+// New opens the store config names, "redis" (the default), "memory" or
+// "postgres". The redis store uses redisClient, which the other backends
+// ignore.
+func New(config Config, redisClient redis.UniversalClient) (VoterStore, error) {
+	switch config.Backend {
+	case "", RedisBackend:
+		return NewWithClient(redisClient), nil
+
+	case MemoryBackend:
+		return NewMemory(), nil
+
+	case PostgresBackend:
+		databaseUrl := config.DatabaseURL
+		if databaseUrl == "" {
+			databaseUrl = PostgresDefaultLocation
+		}
+		voters, err := NewPostgres(databaseUrl)
+		if err != nil {
+			return nil, err
+		}
+		return voters, nil
+	}
+	return nil, config.Validate()
+}
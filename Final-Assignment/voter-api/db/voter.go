@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+
+	"shared/redisclient"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
@@ -14,63 +14,40 @@ import (
 
 const (
 	RedisNilError = "redis: nil"
-	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisVoterKeyPrefix = "voter:"
 )
 
 type cache struct {
-	cacheClient *redis.Client
-	jsonHelper *rejson.Handler
-	context context.Context
+	cacheClient redis.UniversalClient
+}
+
+// jsonHandler runs RedisJSON commands under the caller's ctx
+func (c cache) jsonHandler(ctx context.Context) *rejson.Handler {
+	jsonHelper := rejson.NewReJSONHandler()
+	jsonHelper.SetGoRedisClientWithContext(ctx, c.cacheClient)
+	return jsonHelper
 }
 
 type Voter struct {
-	VoterID uint
-	FirstName string
-	LastName string
+	VoterID uint `binding:"required"`
+	FirstName string `binding:"required,max=50,personname"`
+	LastName string `binding:"max=50,personname"`
 }
 
+// VoterData keeps each voter as a RedisJSON document
 type VoterData struct {
 	cache
 }
 
-// Creat New Voter Data Handler 
-func New() (*VoterData, error){
-
-	redisUrl := os.Getenv("REDIS_URL")
-
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-
-	return NewWithCacheInstance(redisUrl)
-
-}
-
-func NewWithCacheInstance(location string) (*VoterData, error) {
-	
-	client := redis.NewClient(&redis.Options{
-		Addr:location,
-	})
-
-	ctx := context.Background()
-
-	err := client.Ping(ctx).Err()
-	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
-		return nil, err
-	}
-
-	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
+// NewWithClient keeps voters in redis through client, which is shared
+// with the rate limiter and the idempotency store
+func NewWithClient(client redis.UniversalClient) *VoterData {
 
 	return &VoterData{
 		cache: cache{
 			cacheClient: client,
-			jsonHelper: jsonHelper,
-			context: ctx,
 		},
-	}, nil
+	}
 }
 
 func isRedisNilError(err error) bool {
@@ -82,8 +59,8 @@ func redisVoterKeyFromId(id int) string {
 }
 
 
-func (v *VoterData) getVoterFromRedis(key string, voter *Voter) error {
-	voterObject, err := v.jsonHelper.JSONGet(key, ".")
+func (v *VoterData) getVoterFromRedis(ctx context.Context, key string, voter *Voter) error {
+	voterObject, err := v.jsonHandler(ctx).JSONGet(key, ".")
 	if err != nil {
 		return err
 	}
@@ -107,14 +84,22 @@ func NewVoter(voterID uint, firstName string, lastName string) (*Voter, error){
 }
 
 
-func (v *VoterData) GetAllVoters() ([]Voter, error){
+func (v *VoterData) GetAllVoters(ctx context.Context) ([]Voter, error){
 	var voters []Voter
 	var voter Voter
 
 	pattern := RedisVoterKeyPrefix + "*"
-	ks, _ := v.cacheClient.Keys(v.context, pattern).Result()
+	var ks []string
+	err := redisclient.ForEachNode(ctx, v.cacheClient, func(ctx context.Context, node redis.Cmdable) error {
+		nodeKeys, err := node.Keys(ctx, pattern).Result()
+		ks = append(ks, nodeKeys...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 	for _,key := range ks {
-		err := v.getVoterFromRedis(key, &voter)
+		err := v.getVoterFromRedis(ctx, key, &voter)
 		if err != nil {
 			return nil, err
 		}
@@ -123,11 +108,11 @@ func (v *VoterData) GetAllVoters() ([]Voter, error){
 	return voters, nil
 } 
 
-func (v *VoterData) GetVoter(voterID uint) (Voter, error){
+func (v *VoterData) GetVoter(ctx context.Context, voterID uint) (Voter, error){
 	
 	var voter Voter
 	pattern := redisVoterKeyFromId(int(voterID))
-	err := v.getVoterFromRedis(pattern, &voter)
+	err := v.getVoterFromRedis(ctx, pattern, &voter)
 	if err != nil {
 		return Voter{}, err
 	}
@@ -135,54 +120,101 @@ func (v *VoterData) GetVoter(voterID uint) (Voter, error){
 	return voter, nil
 } 
 
-func (v *VoterData) AddVoter(voter Voter) error {
+func (v *VoterData) AddVoter(ctx context.Context, voter Voter) error {
 
 	redisKey := redisVoterKeyFromId(int(voter.VoterID))
 	var existingItem Voter
-	if err := v.getVoterFromRedis(redisKey, &existingItem); err == nil {
+	if err := v.getVoterFromRedis(ctx, redisKey, &existingItem); err == nil {
 		return errors.New("item already exists")
 	}
 
 	newVoter, _ := NewVoter(voter.VoterID, voter.FirstName, voter.LastName)
 
-	if _, err := v.jsonHelper.JSONSet(redisKey, ".", newVoter); err != nil {
+	if _, err := v.jsonHandler(ctx).JSONSet(redisKey, ".", newVoter); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (v *VoterData) UpdateVoter(voterID uint, updateData Voter) error {
+func (v *VoterData) UpdateVoter(ctx context.Context, voterID uint, updateData Voter) error {
 
 	redisKey := redisVoterKeyFromId(int(voterID))
 	var existingVoter Voter
-	if err := v.getVoterFromRedis(redisKey, &existingVoter); err != nil {
+	if err := v.getVoterFromRedis(ctx, redisKey, &existingVoter); err != nil {
 		return errors.New("Item does not exist")
 	}
 
-	updatedVoter:= removeZeroValuesFromUpdateData(existingVoter, updateData)
-
-	if _, err := v.jsonHelper.JSONSet(redisKey, ".", updatedVoter); err != nil {
+	if _, err := v.jsonHandler(ctx).JSONSet(redisKey, ".", updateData); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func removeZeroValuesFromUpdateData(oldData Voter, updateData Voter) Voter {
-	if updateData.FirstName == "" {
-		updateData.FirstName = oldData.FirstName
+// PatchVoter applies patch to the stored voter inside a redis transaction
+// so that concurrent writes are never lost. validate is run on the patched
+// voter before it is saved.
+func (v *VoterData) PatchVoter(ctx context.Context, voterID uint, patch Patch, validate func(interface{}) error) (Voter, error) {
+	redisKey := redisVoterKeyFromId(int(voterID))
+	var patchedVoter Voter
+
+	applyPatch := func(tx *redis.Tx) error {
+		getCmd := redis.NewCmd(ctx, "JSON.GET", redisKey, ".")
+		_ = tx.Process(ctx, getCmd)
+		document, err := getCmd.Text()
+		if err != nil {
+			if isRedisNilError(err) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		patchedDocument, err := patch.Apply([]byte(document))
+		if err != nil {
+			return err
+		}
+
+		patchedVoter = Voter{}
+		if err := json.Unmarshal(patchedDocument, &patchedVoter); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		if patchedVoter.VoterID != voterID {
+			return ErrIDChanged
+		}
+		if err := validate(&patchedVoter); err != nil {
+			return err
+		}
+
+		voterObject, err := json.Marshal(patchedVoter)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", redisKey, ".", voterObject)
+			return nil
+		})
+		return err
 	}
-	if updateData.LastName == "" {
-		updateData.LastName = oldData.LastName
+
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		err := v.cacheClient.Watch(ctx, applyPatch, redisKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return Voter{}, err
+		}
+		return patchedVoter, nil
 	}
 
-	return updateData
+	return Voter{}, ErrPatchConflict
 }
 
-func (v *VoterData) DeleteVoter(voterID uint) error {
+func (v *VoterData) DeleteVoter(ctx context.Context, voterID uint) error {
 	pattern := redisVoterKeyFromId(int(voterID))
-	numDeleted, err := v.cacheClient.Del(v.context, pattern).Result()
+	numDeleted, err := v.cacheClient.Del(ctx, pattern).Result()
 	if err != nil {
 		return err
 	}
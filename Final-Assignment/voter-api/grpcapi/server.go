@@ -0,0 +1,80 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+
+	"shared/auth"
+	"shared/ratelimit"
+	"voter-api/db"
+	"voter-api/logging"
+	voterv1 "voter-api/pb/voter/v1"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VoterServer serves the voter service over gRPC from the same VoterStore
+// as the REST handlers
+type VoterServer struct {
+	voterv1.UnimplementedVoterServiceServer
+	db db.VoterStore
+}
+
+func NewVoterServer(dbHandler db.VoterStore) *VoterServer {
+	return &VoterServer{db: dbHandler}
+}
+
+// NewServer creates a gRPC server with the voter service registered. Every
+// call must carry credentials accepted by authenticator, and failed
+// authentications count against the REST api's auth limit.
+func NewServer(dbHandler db.VoterStore, authenticator auth.Authenticator, limiter *ratelimit.Limiter) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			limiter.FailureUnaryInterceptor("auth"),
+			auth.UnaryInterceptor(authenticator, nil),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			limiter.FailureStreamInterceptor("auth"),
+			auth.StreamInterceptor(authenticator, nil),
+		),
+	)
+	voterv1.RegisterVoterServiceServer(server, NewVoterServer(dbHandler))
+	return server
+}
+
+func (s *VoterServer) GetVoter(ctx context.Context, req *voterv1.GetVoterRequest) (*voterv1.Voter, error) {
+	voter, err := s.db.GetVoter(ctx, uint(req.GetVoterId()))
+	if err != nil {
+		slog.WarnContext(ctx, "Voter not found", "error", err)
+		return nil, status.Errorf(codes.NotFound, "voter %d not found", req.GetVoterId())
+	}
+	return toProtoVoter(voter), nil
+}
+
+func (s *VoterServer) ListVoters(ctx context.Context, req *voterv1.ListVotersRequest) (*voterv1.ListVotersResponse, error) {
+	voters, err := s.db.GetAllVoters(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting all voters", "error", err)
+		return nil, status.Error(codes.Internal, "could not list voters")
+	}
+
+	response := &voterv1.ListVotersResponse{}
+	for _, voter := range voters {
+		response.Voters = append(response.Voters, toProtoVoter(voter))
+	}
+	return response, nil
+}
+
+func toProtoVoter(voter db.Voter) *voterv1.Voter {
+	return &voterv1.Voter{
+		VoterId:   uint64(voter.VoterID),
+		FirstName: voter.FirstName,
+		LastName:  voter.LastName,
+	}
+}
@@ -0,0 +1,7 @@
+// Package pb holds the code generated from the protobuf definitions in
+// ../../proto. Regenerate it with protoc, protoc-gen-go v1.31 and
+// protoc-gen-go-grpc v1.3 installed by running go generate in this
+// directory.
+package pb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=paths=source_relative,Mvoter/v1/voter.proto=voter-api/pb/voter/v1;voterv1 --go-grpc_out=. --go-grpc_opt=paths=source_relative,Mvoter/v1/voter.proto=voter-api/pb/voter/v1;voterv1 voter/v1/voter.proto
@@ -0,0 +1,357 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v5.29.3
+// source: voter/v1/voter.proto
+
+// Voters as stored by the voter api. Field names follow the JSON bodies of
+// the REST api.
+
+package voterv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Voter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId   uint64 `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+	FirstName string `protobuf:"bytes,2,opt,name=first_name,json=firstName,proto3" json:"first_name,omitempty"`
+	LastName  string `protobuf:"bytes,3,opt,name=last_name,json=lastName,proto3" json:"last_name,omitempty"`
+}
+
+func (x *Voter) Reset() {
+	*x = Voter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Voter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Voter) ProtoMessage() {}
+
+func (x *Voter) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Voter.ProtoReflect.Descriptor instead.
+func (*Voter) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Voter) GetVoterId() uint64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+func (x *Voter) GetFirstName() string {
+	if x != nil {
+		return x.FirstName
+	}
+	return ""
+}
+
+func (x *Voter) GetLastName() string {
+	if x != nil {
+		return x.LastName
+	}
+	return ""
+}
+
+type GetVoterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	VoterId uint64 `protobuf:"varint,1,opt,name=voter_id,json=voterId,proto3" json:"voter_id,omitempty"`
+}
+
+func (x *GetVoterRequest) Reset() {
+	*x = GetVoterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVoterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVoterRequest) ProtoMessage() {}
+
+func (x *GetVoterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVoterRequest.ProtoReflect.Descriptor instead.
+func (*GetVoterRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetVoterRequest) GetVoterId() uint64 {
+	if x != nil {
+		return x.VoterId
+	}
+	return 0
+}
+
+type ListVotersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListVotersRequest) Reset() {
+	*x = ListVotersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVotersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVotersRequest) ProtoMessage() {}
+
+func (x *ListVotersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVotersRequest.ProtoReflect.Descriptor instead.
+func (*ListVotersRequest) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{2}
+}
+
+type ListVotersResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Voters []*Voter `protobuf:"bytes,1,rep,name=voters,proto3" json:"voters,omitempty"`
+}
+
+func (x *ListVotersResponse) Reset() {
+	*x = ListVotersResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_voter_v1_voter_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListVotersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVotersResponse) ProtoMessage() {}
+
+func (x *ListVotersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_voter_v1_voter_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVotersResponse.ProtoReflect.Descriptor instead.
+func (*ListVotersResponse) Descriptor() ([]byte, []int) {
+	return file_voter_v1_voter_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListVotersResponse) GetVoters() []*Voter {
+	if x != nil {
+		return x.Voters
+	}
+	return nil
+}
+
+var File_voter_v1_voter_proto protoreflect.FileDescriptor
+
+var file_voter_v1_voter_proto_rawDesc = []byte{
+	0x0a, 0x14, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x2f, 0x76, 0x6f, 0x74, 0x65, 0x72,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x22, 0x5e, 0x0a, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x19, 0x0a, 0x08, 0x76, 0x6f, 0x74,
+	0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x6f, 0x74,
+	0x65, 0x72, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4e,
+	0x61, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x61, 0x73, 0x74, 0x4e, 0x61, 0x6d, 0x65,
+	0x22, 0x2c, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x49, 0x64, 0x22, 0x13,
+	0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x22, 0x3d, 0x0a, 0x12, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x27, 0x0a, 0x06, 0x76, 0x6f, 0x74,
+	0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x52, 0x06, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x73, 0x32, 0x8f, 0x01, 0x0a, 0x0c, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x12, 0x36, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12,
+	0x19, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x56, 0x6f,
+	0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x76, 0x6f, 0x74,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x47, 0x0a, 0x0a, 0x4c,
+	0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x73, 0x12, 0x1b, 0x2e, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x76,
+	0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_voter_v1_voter_proto_rawDescOnce sync.Once
+	file_voter_v1_voter_proto_rawDescData = file_voter_v1_voter_proto_rawDesc
+)
+
+func file_voter_v1_voter_proto_rawDescGZIP() []byte {
+	file_voter_v1_voter_proto_rawDescOnce.Do(func() {
+		file_voter_v1_voter_proto_rawDescData = protoimpl.X.CompressGZIP(file_voter_v1_voter_proto_rawDescData)
+	})
+	return file_voter_v1_voter_proto_rawDescData
+}
+
+var file_voter_v1_voter_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_voter_v1_voter_proto_goTypes = []interface{}{
+	(*Voter)(nil),              // 0: voter.v1.Voter
+	(*GetVoterRequest)(nil),    // 1: voter.v1.GetVoterRequest
+	(*ListVotersRequest)(nil),  // 2: voter.v1.ListVotersRequest
+	(*ListVotersResponse)(nil), // 3: voter.v1.ListVotersResponse
+}
+var file_voter_v1_voter_proto_depIdxs = []int32{
+	0, // 0: voter.v1.ListVotersResponse.voters:type_name -> voter.v1.Voter
+	1, // 1: voter.v1.VoterService.GetVoter:input_type -> voter.v1.GetVoterRequest
+	2, // 2: voter.v1.VoterService.ListVoters:input_type -> voter.v1.ListVotersRequest
+	0, // 3: voter.v1.VoterService.GetVoter:output_type -> voter.v1.Voter
+	3, // 4: voter.v1.VoterService.ListVoters:output_type -> voter.v1.ListVotersResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_voter_v1_voter_proto_init() }
+func file_voter_v1_voter_proto_init() {
+	if File_voter_v1_voter_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_voter_v1_voter_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Voter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetVoterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVotersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_voter_v1_voter_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListVotersResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_voter_v1_voter_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_voter_v1_voter_proto_goTypes,
+		DependencyIndexes: file_voter_v1_voter_proto_depIdxs,
+		MessageInfos:      file_voter_v1_voter_proto_msgTypes,
+	}.Build()
+	File_voter_v1_voter_proto = out.File
+	file_voter_v1_voter_proto_rawDesc = nil
+	file_voter_v1_voter_proto_goTypes = nil
+	file_voter_v1_voter_proto_depIdxs = nil
+}